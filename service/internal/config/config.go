@@ -2,7 +2,13 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
 )
 
 func GetDatabaseURL() string {
@@ -26,3 +32,298 @@ func GetTestDatabaseURL() string {
 		os.Getenv("POSTGRES_DB_TEST"),
 	)
 }
+
+// GetFuzzyMatchThreshold returns the pg_trgm similarity a row needs to match
+// the global filter with match=fuzzy, 0.3 (the pg_trgm default) when
+// FUZZY_MATCH_THRESHOLD is unset or invalid.
+func GetFuzzyMatchThreshold() float64 {
+	threshold, err := strconv.ParseFloat(os.Getenv("FUZZY_MATCH_THRESHOLD"), 64)
+	if err != nil || threshold <= 0 || threshold > 1 {
+		return 0.3
+	}
+	return threshold
+}
+
+// GetTrashRetentionDays returns how many days soft deleted rows stay in the
+// trash before the purge_trash schedule hard deletes them, 30 when
+// TRASH_RETENTION_DAYS is unset or invalid.
+func GetTrashRetentionDays() int {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days < 1 {
+		return 30
+	}
+	return days
+}
+
+// GetTrashPurgeBatchSize returns how many rows the purge deletes per
+// transaction, 500 when TRASH_PURGE_BATCH_SIZE is unset or invalid.
+func GetTrashPurgeBatchSize() int {
+	size, err := strconv.Atoi(os.Getenv("TRASH_PURGE_BATCH_SIZE"))
+	if err != nil || size < 1 {
+		return 500
+	}
+	return size
+}
+
+// GetIdempotencyPurgeBatchSize returns how many expired Idempotency-Keys the
+// purge_idempotency_keys schedule deletes per statement, 500 when
+// IDEMPOTENCY_PURGE_BATCH_SIZE is unset or invalid.
+func GetIdempotencyPurgeBatchSize() int {
+	size, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_PURGE_BATCH_SIZE"))
+	if err != nil || size < 1 {
+		return 500
+	}
+	return size
+}
+
+// GetSchedulerBatchSize returns how many rows the scheduled scans, such as
+// normalize_contacts and report_invalid_identifiers, read or write per batch,
+// 500 when SCHEDULER_BATCH_SIZE is unset or invalid. The trash and
+// Idempotency-Key purges have their own.
+func GetSchedulerBatchSize() int {
+	size, err := strconv.Atoi(os.Getenv("SCHEDULER_BATCH_SIZE"))
+	if err != nil || size < 1 {
+		return 500
+	}
+	return size
+}
+
+// GetBulkMaxItems returns how many items a bulk-* request may carry, 100 when
+// BULK_MAX_ITEMS is unset or invalid.
+func GetBulkMaxItems() int {
+	size, err := strconv.Atoi(os.Getenv("BULK_MAX_ITEMS"))
+	if err != nil || size < 1 {
+		return 100
+	}
+	return size
+}
+
+// GetImportMaxRows returns how many data rows an import file may have, 10000
+// when IMPORT_MAX_ROWS is unset or invalid.
+func GetImportMaxRows() int {
+	rows, err := strconv.Atoi(os.Getenv("IMPORT_MAX_ROWS"))
+	if err != nil || rows < 1 {
+		return 10000
+	}
+	return rows
+}
+
+// GetExportStreamMaxRows returns how many rows an export-* request streams,
+// 10000 when EXPORT_STREAM_MAX_ROWS is unset or invalid. Larger exports are
+// written to a file by the worker.
+func GetExportStreamMaxRows() int {
+	rows, err := strconv.Atoi(os.Getenv("EXPORT_STREAM_MAX_ROWS"))
+	if err != nil || rows < 1 {
+		return 10000
+	}
+	return rows
+}
+
+// GetExportDir returns the directory export files are written to and read
+// from, EXPORT_DIR or storage/exports.
+func GetExportDir() string {
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		return dir
+	}
+	return "storage/exports"
+}
+
+// GetIdempotencyTTL returns how long the response to a request sent with an
+// Idempotency-Key is replayed, 24 hours when IDEMPOTENCY_TTL_HOURS is unset or
+// invalid.
+func GetIdempotencyTTL() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_TTL_HOURS"))
+	if err != nil || hours < 1 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// GetIdempotencyWaitTimeout returns how long a duplicate of a request still
+// running waits for its response, 10 seconds when IDEMPOTENCY_WAIT_SECONDS is
+// unset or invalid.
+func GetIdempotencyWaitTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_WAIT_SECONDS"))
+	if err != nil || seconds < 1 {
+		seconds = 10
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetIdempotencyLockTimeout returns after how long an Idempotency-Key whose
+// request never completed, its server having died, may be taken over, 120
+// seconds when IDEMPOTENCY_LOCK_SECONDS is unset or invalid.
+func GetIdempotencyLockTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_LOCK_SECONDS"))
+	if err != nil || seconds < 1 {
+		seconds = 120
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetDefaultPhoneRegion returns the region, as an ISO 3166-1 alpha-2 code,
+// of the phone contacts written without their country code when their type
+// declares none, DEFAULT_PHONE_REGION or ID.
+func GetDefaultPhoneRegion() string {
+	if region := os.Getenv("DEFAULT_PHONE_REGION"); region != "" {
+		return strings.ToUpper(region)
+	}
+	return "ID"
+}
+
+// GetIdentifierReminderDays returns how many days before their expiry date
+// identifiers are reminded of it, one reminder each, 30, 7 and 1 when
+// IDENTIFIER_REMINDER_DAYS, a comma separated list, is unset or invalid.
+func GetIdentifierReminderDays() []int {
+	var days []int
+	for _, raw := range strings.Split(os.Getenv("IDENTIFIER_REMINDER_DAYS"), ",") {
+		day, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || day < 0 {
+			return []int{30, 7, 1}
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// GetStorageDriver returns where files such as attachments are kept,
+// STORAGE_DRIVER: local (default) for the filesystem or s3 for an
+// S3-compatible object store.
+func GetStorageDriver() string {
+	if driver := os.Getenv("STORAGE_DRIVER"); driver != "" {
+		return strings.ToLower(driver)
+	}
+	return "local"
+}
+
+// GetStorageDir returns the directory the local storage keeps files in,
+// STORAGE_DIR or storage/files.
+func GetStorageDir() string {
+	if dir := os.Getenv("STORAGE_DIR"); dir != "" {
+		return dir
+	}
+	return "storage/files"
+}
+
+// S3Options are the connection of the S3-compatible object store the s3
+// storage keeps files in.
+type S3Options struct {
+	Endpoint  string // host[:port], without scheme
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+// GetS3Options returns the connection of the S3-compatible object store, from
+// the S3_* variables.
+func GetS3Options() S3Options {
+	useSSL, err := strconv.ParseBool(os.Getenv("S3_USE_SSL"))
+	if err != nil {
+		useSSL = true
+	}
+
+	return S3Options{
+		Endpoint:  os.Getenv("S3_ENDPOINT"),
+		Region:    os.Getenv("S3_REGION"),
+		Bucket:    os.Getenv("S3_BUCKET"),
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+		UseSSL:    useSSL,
+	}
+}
+
+// GetAttachmentMaxBytes returns how large an attachment may be, 10 MiB when
+// ATTACHMENT_MAX_MB is unset or invalid.
+func GetAttachmentMaxBytes() int64 {
+	mb, err := strconv.Atoi(os.Getenv("ATTACHMENT_MAX_MB"))
+	if err != nil || mb < 1 {
+		mb = 10
+	}
+	return int64(mb) << 20
+}
+
+// GetAttachmentTypes returns the media types an attachment may have, as
+// sniffed from its content, ATTACHMENT_TYPES (comma separated) or PDF, JPEG,
+// PNG and WebP.
+func GetAttachmentTypes() []string {
+	var types []string
+	for _, raw := range strings.Split(os.Getenv("ATTACHMENT_TYPES"), ",") {
+		if t := strings.ToLower(strings.TrimSpace(raw)); t != "" {
+			types = append(types, t)
+		}
+	}
+	if len(types) == 0 {
+		return []string{"application/pdf", "image/jpeg", "image/png", "image/webp"}
+	}
+	return types
+}
+
+// GetAttachmentURLTTL returns how long a signed download URL of an attachment
+// is valid, 15 minutes when ATTACHMENT_URL_TTL_MINUTES is unset or invalid.
+func GetAttachmentURLTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("ATTACHMENT_URL_TTL_MINUTES"))
+	if err != nil || minutes < 1 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GetAttachmentURLSecret returns the key signing the download URLs of
+// attachments and avatars, ATTACHMENT_URL_SECRET or JWT_SECRET.
+func GetAttachmentURLSecret() string {
+	if secret := os.Getenv("ATTACHMENT_URL_SECRET"); secret != "" {
+		return secret
+	}
+	return os.Getenv("JWT_SECRET")
+}
+
+// GetAvatarMaxBytes returns how large an uploaded avatar may be, 5 MiB when
+// AVATAR_MAX_MB is unset or invalid.
+func GetAvatarMaxBytes() int64 {
+	mb, err := strconv.Atoi(os.Getenv("AVATAR_MAX_MB"))
+	if err != nil || mb < 1 {
+		mb = 5
+	}
+	return int64(mb) << 20
+}
+
+// GetAvatarSize returns the side in pixels of the square an avatar is cropped
+// and scaled down to, 512 when AVATAR_SIZE is unset or invalid.
+func GetAvatarSize() int {
+	size, err := strconv.Atoi(os.Getenv("AVATAR_SIZE"))
+	if err != nil || size < 1 {
+		return 512
+	}
+	return size
+}
+
+// GetAvatarThumbnailSizes returns the sides in pixels of the thumbnails made
+// of an avatar, 256, 128 and 64 when AVATAR_THUMBNAIL_SIZES, a comma separated
+// list, is unset or invalid.
+func GetAvatarThumbnailSizes() []int {
+	var sizes []int
+	for _, raw := range strings.Split(os.Getenv("AVATAR_THUMBNAIL_SIZES"), ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || size < 1 {
+			return []int{256, 128, 64}
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+// GetAsynqRedisOpt returns the Redis connection of the asynq task queue.
+func GetAsynqRedisOpt() asynq.RedisClientOpt {
+	db, err := strconv.Atoi(os.Getenv("REDIS_DB"))
+	if err != nil {
+		log.Fatalf("Invalid REDIS_DB value: %v", err)
+	}
+
+	return asynq.RedisClientOpt{
+		Addr:     fmt.Sprintf("%s:%s", os.Getenv("REDIS_HOST"), os.Getenv("REDIS_PORT")),
+		Password: os.Getenv("REDIS_PASSWORD"),
+		DB:       db,
+	}
+}
@@ -1,14 +1,18 @@
 package rest
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/nibroos/nb-go-api/service/internal/dtos"
 	"github.com/nibroos/nb-go-api/service/internal/middleware"
 	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
 	"github.com/nibroos/nb-go-api/service/internal/service"
 	"github.com/nibroos/nb-go-api/service/internal/utils"
 	"github.com/nibroos/nb-go-api/service/internal/validators/form_requests"
@@ -28,14 +32,101 @@ func (c *AddressController) ListAddresses(ctx *fiber.Ctx) error {
 		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
 	}
 
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.AddressListFields, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	filters["fields"] = strings.Join(fields, ",")
+
+	includes, err := utils.ParseIncludes(filters["include"], repository.ChildIncludeRelations)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
 	addresses, total, err := c.service.ListAddresses(ctx.Context(), filters)
 	if err != nil {
-		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, err.Error(), http.StatusInternalServerError), http.StatusInternalServerError)
+		return utils.SendQueryError(ctx, err)
+	}
+
+	data, err := utils.PickFields(addresses, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	if utils.HasInclude(includes, "user") {
+		userIDs := make([]uint, 0, len(addresses))
+		for _, address := range addresses {
+			userIDs = append(userIDs, address.UserID)
+		}
+		if err := nestUser(ctx, data, userIDs, c.service.GetUsersByIDs); err != nil {
+			return utils.SendQueryError(ctx, err)
+		}
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, data, paginationMeta, "Addresses fetched successfully", http.StatusOK, nil, nil)
+}
+
+// ListTrashedAddresses lists the soft deleted addresses with the filters of index-address.
+func (c *AddressController) ListTrashedAddresses(ctx *fiber.Ctx) error {
+	filters, ok := ctx.Locals("filters").(map[string]string)
+	if !ok {
+		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
+	}
+
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.AddressTrashFields, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	filters["fields"] = strings.Join(fields, ",")
+
+	includes, err := utils.ParseIncludes(filters["include"], repository.ChildIncludeRelations)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	addresses, total, err := c.service.ListTrashedAddresses(ctx.Context(), filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	data, err := utils.PickFields(addresses, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	if utils.HasInclude(includes, "user") {
+		userIDs := make([]uint, 0, len(addresses))
+		for _, address := range addresses {
+			userIDs = append(userIDs, address.UserID)
+		}
+		if err := nestUser(ctx, data, userIDs, c.service.GetUsersByIDs); err != nil {
+			return utils.SendQueryError(ctx, err)
+		}
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, data, paginationMeta, "Trashed addresses fetched successfully", http.StatusOK, nil, nil)
+}
+
+// ListUsersByLocation lists the users with an address within radius of near,
+// or in bbox, with the distance to near.
+func (c *AddressController) ListUsersByLocation(ctx *fiber.Ctx) error {
+	filters, ok := ctx.Locals("filters").(map[string]string)
+	if !ok {
+		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
+	}
+
+	users, total, err := c.service.ListUsersByLocation(ctx.Context(), filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
 	}
 
 	paginationMeta := utils.CreatePaginationMeta(filters, total)
 
-	return utils.GetResponse(ctx, addresses, paginationMeta, "Addresses fetched successfully", http.StatusOK, nil, nil)
+	return utils.GetResponse(ctx, users, paginationMeta, "Users fetched successfully", http.StatusOK, nil, nil)
 }
 func (c *AddressController) CreateAddress(ctx *fiber.Ctx) error {
 	var req dtos.CreateAddressRequest
@@ -45,6 +136,8 @@ func (c *AddressController) CreateAddress(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": err.Error(), "message": "Invalid request", "status": http.StatusBadRequest})
 	}
 
+	normalizeAddressFields(&req.AddressFields, &req.RefNum)
+
 	// Validate the request
 	reqValidator := form_requests.NewAddressStoreRequest().Validate(&req, ctx.Context())
 	if reqValidator != nil {
@@ -61,8 +154,9 @@ func (c *AddressController) CreateAddress(ctx *fiber.Ctx) error {
 		CreatedAt:     &createdAt,
 		OptionsJSON:   nil,
 	}
+	setAddressFields(&address, req.AddressFields)
 
-	createdAddress, err := c.service.CreateAddress(ctx.Context(), &address)
+	createdAddress, err := c.service.CreateAddress(auditContext(ctx), &address)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Failed to create address", http.StatusInternalServerError, err.Error(), nil)
 	}
@@ -76,6 +170,8 @@ func (c *AddressController) CreateAddress(ctx *fiber.Ctx) error {
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	ctx.Set(fiber.HeaderETag, utils.ETag(getAddress.Version))
+
 	return utils.GetResponse(ctx, []interface{}{getAddress}, paginationMeta, "Address created successfully", http.StatusCreated, nil, nil)
 }
 
@@ -91,16 +187,47 @@ func (c *AddressController) GetAddressByID(ctx *fiber.Ctx) error {
 	}
 
 	params := &dtos.GetAddressParams{ID: req.ID}
+	filters := ctx.Locals("filters").(map[string]string)
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.AddressDetailFields, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	params.Fields = withVersion(fields)
+	params.AsOf, err = asOf(filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	includes, err := utils.ParseIncludes(filters["include"], repository.ChildIncludeRelations)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
 	address, err := c.service.GetAddressByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	addressArray := []interface{}{address}
+	data, err := utils.PickFields(address, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	if utils.HasInclude(includes, "user") {
+		if err := nestUser(ctx, data, []uint{address.UserID}, c.service.GetUsersByIDs); err != nil {
+			return utils.SendQueryError(ctx, err)
+		}
+	}
+
+	addressArray := []interface{}{data}
 
-	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	// a past version is not something to write against
+	if params.AsOf == nil {
+		ctx.Set(fiber.HeaderETag, utils.ETag(address.Version))
+	}
+
 	return utils.GetResponse(ctx, addressArray, paginationMeta, "Address fetched successfully", http.StatusOK, nil, nil)
 }
 
@@ -112,8 +239,16 @@ func (c *AddressController) UpdateAddress(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": err.Error(), "message": "Invalid request", "status": http.StatusBadRequest})
 	}
 
+	return c.updateAddress(ctx, &req, "Address updated successfully")
+}
+
+// updateAddress validates req and applies it, the path update-address and
+// revert-address share.
+func (c *AddressController) updateAddress(ctx *fiber.Ctx, req *dtos.UpdateAddressRequest, message string) error {
+	normalizeAddressFields(&req.AddressFields, &req.RefNum)
+
 	// Validate the request
-	reqValidator := form_requests.NewAddressUpdateRequest().Validate(&req, ctx.Context())
+	reqValidator := form_requests.NewAddressUpdateRequest().Validate(req, ctx.Context())
 	if reqValidator != nil {
 		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": reqValidator, "message": "Validation failed", "status": http.StatusBadRequest})
 	}
@@ -125,6 +260,11 @@ func (c *AddressController) UpdateAddress(ctx *fiber.Ctx) error {
 		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusNotFound, err.Error(), nil)
 	}
 
+	version, err := expectedVersion(ctx, req.Version, existingAddress.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingAddress, existingAddress.Version, repository.AddressDetailFields, false)
+	}
+
 	address := models.Address{
 		ID:            req.ID,
 		TypeAddressID: existingAddress.TypeAddressID,
@@ -132,14 +272,19 @@ func (c *AddressController) UpdateAddress(ctx *fiber.Ctx) error {
 		RefNum:        req.RefNum,
 		Status:        req.Status,
 		CreatedAt:     existingAddress.CreatedAt,
+		Version:       version,
 	}
+	setAddressFields(&address, req.AddressFields)
 
 	if req.TypeAddressID != nil {
 		address.TypeAddressID = *req.TypeAddressID
 	}
 
-	updatedAddress, err := c.service.UpdateAddress(ctx.Context(), &address)
+	updatedAddress, err := c.service.UpdateAddress(auditContext(ctx), &address)
 	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendAddressConflict(ctx, req.ID, 0)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to update address", http.StatusInternalServerError, err.Error(), nil)
 	}
 
@@ -152,7 +297,9 @@ func (c *AddressController) UpdateAddress(ctx *fiber.Ctx) error {
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
-	return utils.GetResponse(ctx, []interface{}{getAddress}, paginationMeta, "Address updated successfully", http.StatusOK, nil, nil)
+	ctx.Set(fiber.HeaderETag, utils.ETag(getAddress.Version))
+
+	return utils.GetResponse(ctx, []interface{}{getAddress}, paginationMeta, message, http.StatusOK, nil, nil)
 }
 
 // delete address
@@ -169,13 +316,21 @@ func (c *AddressController) DeleteAddress(ctx *fiber.Ctx) error {
 
 	params := &dtos.GetAddressParams{ID: req.ID}
 	// GET address by ID
-	_, err := c.service.GetAddressByID(ctx.Context(), params)
+	existingAddress, err := c.service.GetAddressByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.DeleteAddress(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingAddress.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingAddress, existingAddress.Version, repository.AddressDetailFields, false)
+	}
+
+	err = c.service.DeleteAddress(auditContext(ctx), req.ID, version)
 	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendAddressConflict(ctx, req.ID, 0)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to delete address", http.StatusInternalServerError, err.Error(), nil)
 	}
 
@@ -197,19 +352,77 @@ func (c *AddressController) RestoreAddress(ctx *fiber.Ctx) error {
 	isDeleted := 1
 	params := &dtos.GetAddressParams{ID: req.ID, IsDeleted: &isDeleted}
 	// GET address by ID
-	_, err := c.service.GetAddressByID(ctx.Context(), params)
+	existingAddress, err := c.service.GetAddressByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.RestoreAddress(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingAddress.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingAddress, existingAddress.Version, repository.AddressDetailFields, false)
+	}
+
+	err = c.service.RestoreAddress(auditContext(ctx), req.ID, version)
 	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendAddressConflict(ctx, req.ID, 0)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to restore address", http.StatusInternalServerError, err.Error(), nil)
 	}
 
 	return utils.GetResponse(ctx, nil, nil, "Address restored successfully", http.StatusOK, nil, nil)
 }
 
+// SetPrimaryAddress makes a address the primary of its user and type, in place of
+// the current one.
+func (c *AddressController) SetPrimaryAddress(ctx *fiber.Ctx) error {
+	return c.setPrimaryAddress(ctx, 0)
+}
+
+// setPrimaryAddress makes the address of the request the primary of its user and
+// type, limited to the addresses of userID unless it is 0.
+func (c *AddressController) setPrimaryAddress(ctx *fiber.Ctx, userID uint) error {
+	var req dtos.DeleteAddressRequest
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	params := &dtos.GetAddressParams{ID: req.ID, UserID: userID}
+	existingAddress, err := c.service.GetAddressByID(ctx.Context(), params)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	version, err := expectedVersion(ctx, req.Version, existingAddress.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingAddress, existingAddress.Version, repository.AddressDetailFields, userID != 0)
+	}
+
+	if err := c.service.SetPrimaryAddress(auditContext(ctx), req.ID, version); err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendAddressConflict(ctx, req.ID, userID)
+		}
+		return utils.GetResponse(ctx, nil, nil, "Failed to set primary address", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	getAddress, err := c.service.GetAddressByID(ctx.Context(), params)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	filters := ctx.Locals("filters").(map[string]string)
+	paginationMeta := utils.CreatePaginationMeta(filters, 1)
+
+	ctx.Set(fiber.HeaderETag, utils.ETag(getAddress.Version))
+
+	return utils.GetResponse(ctx, []interface{}{getAddress}, paginationMeta, "Primary address set successfully", http.StatusOK, nil, nil)
+}
+
 func (c *AddressController) ListAddressesByAuthUser(ctx *fiber.Ctx) error {
 	// Extract user ID from JWT
 	claims, err := middleware.GetAuthUser(ctx)
@@ -225,14 +438,25 @@ func (c *AddressController) ListAddressesByAuthUser(ctx *fiber.Ctx) error {
 		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
 	}
 
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.AddressListFields, true)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	filters["fields"] = strings.Join(fields, ",")
+
 	addresses, total, err := c.service.ListAddresses(ctx.Context(), filters)
 	if err != nil {
-		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, err.Error(), http.StatusInternalServerError), http.StatusInternalServerError)
+		return utils.SendQueryError(ctx, err)
+	}
+
+	data, err := utils.PickFields(addresses, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
 	}
 
 	paginationMeta := utils.CreatePaginationMeta(filters, total)
 
-	return utils.GetResponse(ctx, addresses, paginationMeta, "Addresses fetched successfully", http.StatusOK, nil, nil)
+	return utils.GetResponse(ctx, data, paginationMeta, "Addresses fetched successfully", http.StatusOK, nil, nil)
 }
 
 // make auth create address
@@ -251,6 +475,8 @@ func (c *AddressController) CreateAddressByAuthUser(ctx *fiber.Ctx) error {
 	userID := uint(claims["user_id"].(float64))
 	req.UserID = userID
 
+	normalizeAddressFields(&req.AddressFields, &req.RefNum)
+
 	// Validate the request
 	reqValidator := form_requests.NewAddressStoreRequest().Validate(&req, ctx.Context())
 	if reqValidator != nil {
@@ -267,8 +493,9 @@ func (c *AddressController) CreateAddressByAuthUser(ctx *fiber.Ctx) error {
 		CreatedAt:     &createdAt,
 		OptionsJSON:   nil,
 	}
+	setAddressFields(&address, req.AddressFields)
 
-	createdAddress, err := c.service.CreateAddress(ctx.Context(), &address)
+	createdAddress, err := c.service.CreateAddress(auditContext(ctx), &address)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Failed to create address", http.StatusInternalServerError, err.Error(), nil)
 	}
@@ -282,6 +509,8 @@ func (c *AddressController) CreateAddressByAuthUser(ctx *fiber.Ctx) error {
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	ctx.Set(fiber.HeaderETag, utils.ETag(getAddress.Version))
+
 	return utils.GetResponse(ctx, []interface{}{getAddress}, paginationMeta, "Address created successfully", http.StatusCreated, nil, nil)
 }
 
@@ -306,16 +535,36 @@ func (c *AddressController) GetAddressByIDByAuthUser(ctx *fiber.Ctx) error {
 
 	params.UserID = userID
 
+	filters := ctx.Locals("filters").(map[string]string)
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.AddressDetailFields, true)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	params.Fields = withVersion(fields)
+	params.AsOf, err = asOf(filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
 	address, err := c.service.GetAddressByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	addressArray := []interface{}{address}
+	data, err := utils.PickFields(address, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	addressArray := []interface{}{data}
 
-	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	// a past version is not something to write against
+	if params.AsOf == nil {
+		ctx.Set(fiber.HeaderETag, utils.ETag(address.Version))
+	}
+
 	return utils.GetResponse(ctx, addressArray, paginationMeta, "Address fetched successfully", http.StatusOK, nil, nil)
 }
 
@@ -327,6 +576,8 @@ func (c *AddressController) UpdateAddressByAuthUser(ctx *fiber.Ctx) error {
 		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusBadRequest, err.Error(), nil)
 	}
 
+	normalizeAddressFields(&req.AddressFields, &req.RefNum)
+
 	// Validate the request
 	reqValidator := form_requests.NewAddressUpdateRequest().Validate(&req, ctx.Context())
 	if reqValidator != nil {
@@ -350,6 +601,11 @@ func (c *AddressController) UpdateAddressByAuthUser(ctx *fiber.Ctx) error {
 		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusNotFound, err.Error(), nil)
 	}
 
+	version, err := expectedVersion(ctx, req.Version, existingAddress.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingAddress, existingAddress.Version, repository.AddressDetailFields, true)
+	}
+
 	address := models.Address{
 		ID:            req.ID,
 		TypeAddressID: existingAddress.TypeAddressID,
@@ -357,14 +613,19 @@ func (c *AddressController) UpdateAddressByAuthUser(ctx *fiber.Ctx) error {
 		RefNum:        req.RefNum,
 		Status:        req.Status,
 		CreatedAt:     existingAddress.CreatedAt,
+		Version:       version,
 	}
+	setAddressFields(&address, req.AddressFields)
 
 	if req.TypeAddressID != nil {
 		address.TypeAddressID = *req.TypeAddressID
 	}
 
-	updatedAddress, err := c.service.UpdateAddress(ctx.Context(), &address)
+	updatedAddress, err := c.service.UpdateAddress(auditContext(ctx), &address)
 	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendAddressConflict(ctx, req.ID, userID)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to update address", http.StatusInternalServerError, err.Error(), nil)
 	}
 
@@ -377,6 +638,8 @@ func (c *AddressController) UpdateAddressByAuthUser(ctx *fiber.Ctx) error {
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	ctx.Set(fiber.HeaderETag, utils.ETag(getAddress.Version))
+
 	return utils.GetResponse(ctx, []interface{}{getAddress}, paginationMeta, "Address updated successfully", http.StatusOK, nil, nil)
 }
 
@@ -404,13 +667,21 @@ func (c *AddressController) DeleteAddressByAuthUser(ctx *fiber.Ctx) error {
 	params.UserID = userID
 
 	// GET address by ID
-	_, err = c.service.GetAddressByID(ctx.Context(), params)
+	existingAddress, err := c.service.GetAddressByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.DeleteAddress(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingAddress.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingAddress, existingAddress.Version, repository.AddressDetailFields, true)
+	}
+
+	err = c.service.DeleteAddress(auditContext(ctx), req.ID, version)
 	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendAddressConflict(ctx, req.ID, userID)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to delete address", http.StatusInternalServerError, err.Error(), nil)
 	}
 
@@ -441,15 +712,286 @@ func (c *AddressController) RestoreAddressByAuthUser(ctx *fiber.Ctx) error {
 	params := &dtos.GetAddressParams{ID: req.ID, IsDeleted: &isDeleted, UserID: userID}
 
 	// GET address by ID
-	_, err = c.service.GetAddressByID(ctx.Context(), params)
+	existingAddress, err := c.service.GetAddressByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.RestoreAddress(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingAddress.Version)
 	if err != nil {
+		return sendVersionError(ctx, err, existingAddress, existingAddress.Version, repository.AddressDetailFields, true)
+	}
+
+	err = c.service.RestoreAddress(auditContext(ctx), req.ID, version)
+	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendAddressConflict(ctx, req.ID, userID)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to restore address", http.StatusInternalServerError, err.Error(), nil)
 	}
 
 	return utils.GetResponse(ctx, nil, nil, "Address restored successfully", http.StatusOK, nil, nil)
 }
+
+// SetPrimaryAddressByAuthUser makes a address of the authenticated user the
+// primary of its type.
+func (c *AddressController) SetPrimaryAddressByAuthUser(ctx *fiber.Ctx) error {
+	// Extract user ID from JWT
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	return c.setPrimaryAddress(ctx, uint(claims["user_id"].(float64)))
+}
+
+// ListAddressHistory lists the recorded versions of a address, newest first.
+func (c *AddressController) ListAddressHistory(ctx *fiber.Ctx) error {
+	var req dtos.GetHistoryRequest
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	filters := ctx.Locals("filters").(map[string]string)
+
+	history, total, err := c.service.GetAddressHistory(ctx.Context(), req.ID, filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, history, paginationMeta, "Address history fetched successfully", http.StatusOK, nil, nil)
+}
+
+// RevertAddress writes the state of a past version back as a new version.
+func (c *AddressController) RevertAddress(ctx *fiber.Ctx) error {
+	var req dtos.RevertRequest
+
+	if err := utils.BodyParserWithNull(ctx, &req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": err.Error(), "message": "Invalid request", "status": http.StatusBadRequest})
+	}
+
+	if req.ID == 0 || req.ToVersion == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusBadRequest, "ID and to_version are required", nil)
+	}
+
+	snapshot, err := c.service.GetAddressByID(ctx.Context(), &dtos.GetAddressParams{ID: req.ID, Version: &req.ToVersion})
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Address version not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	update := dtos.UpdateAddressRequest{
+		ID:            snapshot.ID,
+		UserID:        snapshot.UserID,
+		TypeAddressID: &snapshot.TypeAddressID,
+		RefNum:        snapshot.RefNum,
+		Status:        snapshot.Status,
+		Version:       req.Version,
+		AddressFields: dtos.AddressFields{
+			AddressLine1: utils.StringPointerToString(snapshot.AddressLine1),
+			AddressLine2: utils.StringPointerToString(snapshot.AddressLine2),
+			City:         utils.StringPointerToString(snapshot.City),
+			Region:       utils.StringPointerToString(snapshot.Region),
+			PostalCode:   utils.StringPointerToString(snapshot.PostalCode),
+			CountryCode:  utils.StringPointerToString(snapshot.CountryCode),
+			Latitude:     snapshot.Latitude,
+			Longitude:    snapshot.Longitude,
+		},
+	}
+
+	return c.updateAddress(ctx, &update, "Address reverted successfully")
+}
+
+// BulkCreateAddresses creates up to BULK_MAX_ITEMS addresses in one request.
+func (c *AddressController) BulkCreateAddresses(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.CreateAddressRequest](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+	for i := range items {
+		normalizeAddressFields(&items[i].AddressFields, &items[i].RefNum)
+	}
+
+	invalid := form_requests.NewAddressStoreRequest().ValidateBatch(items, ctx.Context())
+
+	return handleBulk(ctx, len(items), atomic, invalid, func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusCreated, func(ctx context.Context, i int) (uint, uint, error) {
+			req := items[i]
+			createdAt := time.Now()
+
+			address := models.Address{
+				TypeAddressID: req.TypeAddressID,
+				UserID:        req.UserID,
+				RefNum:        req.RefNum,
+				Status:        req.Status,
+				CreatedAt:     &createdAt,
+				OptionsJSON:   nil,
+			}
+			setAddressFields(&address, req.AddressFields)
+
+			createdAddress, err := c.service.CreateAddress(ctx, &address)
+			if err != nil {
+				return 0, 0, err
+			}
+			return createdAddress.ID, createdAddress.Version, nil
+		})
+	}, "Addresses created successfully")
+}
+
+// BulkUpdateAddresses updates up to BULK_MAX_ITEMS addresses in one request.
+func (c *AddressController) BulkUpdateAddresses(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.UpdateAddressRequest](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+	for i := range items {
+		normalizeAddressFields(&items[i].AddressFields, &items[i].RefNum)
+	}
+
+	invalid := form_requests.NewAddressUpdateRequest().ValidateBatch(items, ctx.Context())
+
+	return handleBulk(ctx, len(items), atomic, invalid, func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusUpdated, func(ctx context.Context, i int) (uint, uint, error) {
+			req := items[i]
+
+			existingAddress, err := c.service.GetAddressByID(ctx, &dtos.GetAddressParams{ID: req.ID})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			version, err := bulkVersion(req.Version, existingAddress.Version)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			address := models.Address{
+				ID:            req.ID,
+				TypeAddressID: existingAddress.TypeAddressID,
+				UserID:        req.UserID,
+				RefNum:        req.RefNum,
+				Status:        req.Status,
+				CreatedAt:     existingAddress.CreatedAt,
+				Version:       version,
+			}
+			setAddressFields(&address, req.AddressFields)
+
+			if req.TypeAddressID != nil {
+				address.TypeAddressID = *req.TypeAddressID
+			}
+
+			updatedAddress, err := c.service.UpdateAddress(ctx, &address)
+			if err != nil {
+				return 0, 0, err
+			}
+			return updatedAddress.ID, updatedAddress.Version, nil
+		})
+	}, "Addresses updated successfully")
+}
+
+// BulkDeleteAddresses soft deletes up to BULK_MAX_ITEMS addresses in one request.
+func (c *AddressController) BulkDeleteAddresses(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.BulkDeleteItem](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	return handleBulk(ctx, len(items), atomic, validateBulkIDs(items), func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusDeleted, func(ctx context.Context, i int) (uint, uint, error) {
+			item := items[i]
+
+			existingAddress, err := c.service.GetAddressByID(ctx, &dtos.GetAddressParams{ID: item.ID})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			version, err := bulkVersion(item.Version, existingAddress.Version)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			if err := c.service.DeleteAddress(ctx, item.ID, version); err != nil {
+				return 0, 0, err
+			}
+			return item.ID, version + 1, nil
+		})
+	}, "Addresses deleted successfully")
+}
+
+// BulkRestoreAddresses restores up to BULK_MAX_ITEMS soft deleted addresses in one request.
+func (c *AddressController) BulkRestoreAddresses(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.BulkDeleteItem](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	return handleBulk(ctx, len(items), atomic, validateBulkIDs(items), func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusRestored, func(ctx context.Context, i int) (uint, uint, error) {
+			item := items[i]
+
+			isDeleted := 1
+			existingAddress, err := c.service.GetAddressByID(ctx, &dtos.GetAddressParams{ID: item.ID, IsDeleted: &isDeleted})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			version, err := bulkVersion(item.Version, existingAddress.Version)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			if err := c.service.RestoreAddress(ctx, item.ID, version); err != nil {
+				return 0, 0, err
+			}
+			return item.ID, version + 1, nil
+		})
+	}, "Addresses restored successfully")
+}
+
+// normalizeAddressFields canonicalises the structured fields of an address
+// request and, when they hold an address line, formats ref_num from them.
+func normalizeAddressFields(fields *dtos.AddressFields, refNum *string) {
+	address := utils.NormalizeAddress(utils.Address{
+		Line1:       fields.AddressLine1,
+		Line2:       fields.AddressLine2,
+		City:        fields.City,
+		Region:      fields.Region,
+		PostalCode:  fields.PostalCode,
+		CountryCode: fields.CountryCode,
+	})
+
+	fields.AddressLine1 = address.Line1
+	fields.AddressLine2 = address.Line2
+	fields.City = address.City
+	fields.Region = address.Region
+	fields.PostalCode = address.PostalCode
+	fields.CountryCode = address.CountryCode
+
+	if address.Line1 != "" {
+		*refNum = utils.FormatAddress(address)
+	}
+}
+
+// setAddressFields copies the structured fields of a request onto address,
+// the ones not given as NULL.
+func setAddressFields(address *models.Address, fields dtos.AddressFields) {
+	address.AddressLine1 = optionalString(fields.AddressLine1)
+	address.AddressLine2 = optionalString(fields.AddressLine2)
+	address.City = optionalString(fields.City)
+	address.Region = optionalString(fields.Region)
+	address.PostalCode = optionalString(fields.PostalCode)
+	address.CountryCode = optionalString(fields.CountryCode)
+	address.Latitude = fields.Latitude
+	address.Longitude = fields.Longitude
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
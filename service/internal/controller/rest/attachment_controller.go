@@ -0,0 +1,223 @@
+package rest
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nibroos/nb-go-api/service/internal/config"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/storage"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+type AttachmentController struct {
+	service *service.AttachmentService
+}
+
+func NewAttachmentController(service *service.AttachmentService) *AttachmentController {
+	return &AttachmentController{service: service}
+}
+
+// UploadAttachment takes a multipart form with the file as file and the row
+// it is attached to as entity and entity_id.
+func (c *AttachmentController) UploadAttachment(ctx *fiber.Ctx) error {
+	return c.uploadAttachment(ctx, 0)
+}
+
+// UploadAttachmentByAuthUser attaches a file to the authenticated user or to
+// one of their contacts, addresses or identifiers.
+func (c *AttachmentController) UploadAttachmentByAuthUser(ctx *fiber.Ctx) error {
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	return c.uploadAttachment(ctx, uint(claims["user_id"].(float64)))
+}
+
+func (c *AttachmentController) uploadAttachment(ctx *fiber.Ctx, userID uint) error {
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, "file is required", nil)
+	}
+
+	entityID, err := strconv.ParseUint(ctx.FormValue("entity_id"), 10, 0)
+	if err != nil || entityID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, "entity_id is required", nil)
+	}
+
+	if header.Size > config.GetAttachmentMaxBytes() {
+		return sendAttachmentError(ctx, utils.ErrAttachmentTooLarge, "Failed to upload attachment")
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	attachment, created, err := c.service.UploadAttachment(auditContext(ctx), ctx.FormValue("entity"), uint(entityID), userID, header.Filename, data)
+	if err != nil {
+		return sendAttachmentError(ctx, err, "Failed to upload attachment")
+	}
+
+	if !created {
+		return utils.GetResponse(ctx, []interface{}{attachment}, nil, "File already attached", http.StatusOK, nil, nil)
+	}
+	return utils.GetResponse(ctx, []interface{}{attachment}, nil, "Attachment uploaded successfully", http.StatusCreated, nil, nil)
+}
+
+// ListAttachments lists the attachments of the row entity and entity_id
+// name, newest first.
+func (c *AttachmentController) ListAttachments(ctx *fiber.Ctx) error {
+	return c.listAttachments(ctx, 0)
+}
+
+func (c *AttachmentController) ListAttachmentsByAuthUser(ctx *fiber.Ctx) error {
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	return c.listAttachments(ctx, uint(claims["user_id"].(float64)))
+}
+
+func (c *AttachmentController) listAttachments(ctx *fiber.Ctx, userID uint) error {
+	var req dtos.ListAttachmentsRequest
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.EntityID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, "entity_id is required", nil)
+	}
+
+	filters := ctx.Locals("filters").(map[string]string)
+
+	attachments, total, err := c.service.ListAttachments(ctx.Context(), req.Entity, req.EntityID, userID, filters)
+	if err != nil {
+		return sendAttachmentError(ctx, err, "Failed to fetch attachments")
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, attachments, paginationMeta, "Attachments fetched successfully", http.StatusOK, nil, nil)
+}
+
+// GetAttachmentURL returns a signed URL downloading an attachment until it
+// expires.
+func (c *AttachmentController) GetAttachmentURL(ctx *fiber.Ctx) error {
+	return c.getAttachmentURL(ctx, 0)
+}
+
+func (c *AttachmentController) GetAttachmentURLByAuthUser(ctx *fiber.Ctx) error {
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	return c.getAttachmentURL(ctx, uint(claims["user_id"].(float64)))
+}
+
+func (c *AttachmentController) getAttachmentURL(ctx *fiber.Ctx, userID uint) error {
+	var req dtos.GetAttachmentRequest
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Attachment not found", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Attachment not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	url, err := c.service.GetAttachmentURL(ctx.Context(), req.ID, userID)
+	if err != nil {
+		return sendAttachmentError(ctx, err, "Failed to sign attachment URL")
+	}
+
+	return utils.GetResponse(ctx, []interface{}{url}, nil, "Attachment URL signed successfully", http.StatusOK, nil, nil)
+}
+
+// DownloadAttachment sends the file of an attachment through a URL
+// url-attachment signed. It is public: the signature is the authorization.
+func (c *AttachmentController) DownloadAttachment(ctx *fiber.Ctx) error {
+	id, err := strconv.ParseUint(ctx.Query("id"), 10, 0)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Attachment not found", http.StatusBadRequest, "id is required", nil)
+	}
+	expires, err := strconv.ParseInt(ctx.Query("expires"), 10, 64)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Attachment not found", http.StatusBadRequest, "expires is required", nil)
+	}
+
+	attachment, file, err := c.service.OpenAttachment(ctx.Context(), uint(id), expires, ctx.Query("signature"))
+	if err != nil {
+		return sendAttachmentError(ctx, err, "Failed to download attachment")
+	}
+
+	ctx.Set(fiber.HeaderContentType, attachment.ContentType)
+	ctx.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	ctx.Set("X-Content-Type-Options", "nosniff")
+
+	// fasthttp closes file once sent
+	return ctx.SendStream(file, int(attachment.Size))
+}
+
+// DeleteAttachment detaches a file from its row.
+func (c *AttachmentController) DeleteAttachment(ctx *fiber.Ctx) error {
+	return c.deleteAttachment(ctx, 0)
+}
+
+func (c *AttachmentController) DeleteAttachmentByAuthUser(ctx *fiber.Ctx) error {
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	return c.deleteAttachment(ctx, uint(claims["user_id"].(float64)))
+}
+
+func (c *AttachmentController) deleteAttachment(ctx *fiber.Ctx, userID uint) error {
+	var req dtos.GetAttachmentRequest
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Attachment not found", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Attachment not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	if err := c.service.DeleteAttachment(auditContext(ctx), req.ID, userID); err != nil {
+		return sendAttachmentError(ctx, err, "Failed to delete attachment")
+	}
+
+	return utils.GetResponse(ctx, nil, nil, "Attachment deleted successfully", http.StatusOK, nil, nil)
+}
+
+// sendAttachmentError answers with the status an attachment error maps to,
+// 500 with message for the unexpected ones.
+func sendAttachmentError(ctx *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, utils.ErrAttachmentEntity):
+		return utils.GetResponse(ctx, nil, nil, "Entity not found", http.StatusNotFound, err.Error(), nil)
+	case errors.Is(err, utils.ErrAttachmentTooLarge):
+		return utils.GetResponse(ctx, nil, nil, "File too large", http.StatusRequestEntityTooLarge, err.Error(), nil)
+	case errors.Is(err, utils.ErrAttachmentType):
+		return utils.GetResponse(ctx, nil, nil, "Unsupported file type", http.StatusUnsupportedMediaType, err.Error(), nil)
+	case errors.Is(err, utils.ErrAttachmentURL):
+		return utils.GetResponse(ctx, nil, nil, "Invalid download URL", http.StatusForbidden, err.Error(), nil)
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, storage.ErrNotFound):
+		return utils.GetResponse(ctx, nil, nil, "Attachment not found", http.StatusNotFound, err.Error(), nil)
+	}
+	return utils.GetResponse(ctx, nil, nil, message, http.StatusInternalServerError, err.Error(), nil)
+}
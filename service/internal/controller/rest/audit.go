@@ -0,0 +1,28 @@
+package rest
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+// auditContext is the context writes run with: it carries the authenticated
+// user, the client IP and the request ID down to the attribution and audit
+// triggers.
+func auditContext(ctx *fiber.Ctx) context.Context {
+	actor := utils.Actor{
+		IPAddress: ctx.IP(),
+		RequestID: ctx.GetRespHeader(fiber.HeaderXRequestID),
+	}
+
+	if claims, ok := ctx.Locals("user").(jwt.MapClaims); ok {
+		if userID, ok := claims["user_id"].(float64); ok {
+			id := uint(userID)
+			actor.UserID = &id
+		}
+	}
+
+	return utils.WithActor(ctx.Context(), actor)
+}
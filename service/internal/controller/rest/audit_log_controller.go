@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+type AuditLogController struct {
+	service *service.AuditLogService
+}
+
+func NewAuditLogController(service *service.AuditLogService) *AuditLogController {
+	return &AuditLogController{service: service}
+}
+
+func (c *AuditLogController) ListAuditLogs(ctx *fiber.Ctx) error {
+	filters, ok := ctx.Locals("filters").(map[string]string)
+	if !ok {
+		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
+	}
+
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.AuditLogListFields, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	filters["fields"] = strings.Join(fields, ",")
+
+	auditLogs, total, err := c.service.ListAuditLogs(ctx.Context(), filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	data, err := utils.PickFields(auditLogs, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, data, paginationMeta, "Audit logs fetched successfully", http.StatusOK, nil, nil)
+}
@@ -0,0 +1,152 @@
+package rest
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nibroos/nb-go-api/service/internal/config"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/storage"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+type AvatarController struct {
+	service *service.AvatarService
+}
+
+func NewAvatarController(service *service.AvatarService) *AvatarController {
+	return &AvatarController{service: service}
+}
+
+// UploadAvatar takes a multipart form with the image as file and the user it
+// is the avatar of as id.
+func (c *AvatarController) UploadAvatar(ctx *fiber.Ctx) error {
+	id, err := strconv.ParseUint(ctx.FormValue("id"), 10, 0)
+	if err != nil || id == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, "id is required", nil)
+	}
+	return c.uploadAvatar(ctx, uint(id))
+}
+
+// UploadAvatarByAuthUser takes a multipart form with the image of the avatar
+// of the authenticated user as file.
+func (c *AvatarController) UploadAvatarByAuthUser(ctx *fiber.Ctx) error {
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	return c.uploadAvatar(ctx, uint(claims["user_id"].(float64)))
+}
+
+func (c *AvatarController) uploadAvatar(ctx *fiber.Ctx, userID uint) error {
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, "file is required", nil)
+	}
+
+	if header.Size > config.GetAvatarMaxBytes() {
+		return sendAvatarError(ctx, utils.ErrAvatarTooLarge, "Failed to upload avatar")
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	avatar, err := c.service.UploadAvatar(auditContext(ctx), userID, data)
+	if err != nil {
+		return sendAvatarError(ctx, err, "Failed to upload avatar")
+	}
+
+	return utils.GetResponse(ctx, []interface{}{avatar}, nil, "Avatar uploaded successfully", http.StatusCreated, nil, nil)
+}
+
+// DownloadAvatar sends an image of an avatar through the URL of its
+// avatar_url, the smallest thumbnail of at least size pixels when size is
+// given. It is public: the signature is the authorization. The URL changes
+// with the avatar, so the image is cached for good.
+func (c *AvatarController) DownloadAvatar(ctx *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(ctx.Query("user_id"), 10, 0)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Avatar not found", http.StatusBadRequest, "user_id is required", nil)
+	}
+	fileID, err := strconv.ParseUint(ctx.Query("v"), 10, 0)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Avatar not found", http.StatusBadRequest, "v is required", nil)
+	}
+	size := ctx.QueryInt("size", 0)
+	if size < 0 {
+		return utils.GetResponse(ctx, nil, nil, "Avatar not found", http.StatusBadRequest, "size must not be negative", nil)
+	}
+
+	image, file, err := c.service.OpenAvatar(ctx.Context(), uint(userID), uint(fileID), ctx.Query("signature"), size)
+	if err != nil {
+		return sendAvatarError(ctx, err, "Failed to download avatar")
+	}
+
+	ctx.Set(fiber.HeaderContentType, image.ContentType)
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	ctx.Set("X-Content-Type-Options", "nosniff")
+
+	// fasthttp closes file once sent
+	return ctx.SendStream(file, int(image.Bytes))
+}
+
+// DeleteAvatar removes the avatar of the user id.
+func (c *AvatarController) DeleteAvatar(ctx *fiber.Ctx) error {
+	var req dtos.GetUserByIDRequest
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Avatar not found", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Avatar not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	return c.deleteAvatar(ctx, req.ID)
+}
+
+func (c *AvatarController) DeleteAvatarByAuthUser(ctx *fiber.Ctx) error {
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	return c.deleteAvatar(ctx, uint(claims["user_id"].(float64)))
+}
+
+func (c *AvatarController) deleteAvatar(ctx *fiber.Ctx, userID uint) error {
+	if err := c.service.DeleteAvatar(auditContext(ctx), userID); err != nil {
+		return sendAvatarError(ctx, err, "Failed to delete avatar")
+	}
+
+	return utils.GetResponse(ctx, nil, nil, "Avatar deleted successfully", http.StatusOK, nil, nil)
+}
+
+// sendAvatarError answers with the status an avatar error maps to, 500 with
+// message for the unexpected ones.
+func sendAvatarError(ctx *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, utils.ErrAvatarTooLarge):
+		return utils.GetResponse(ctx, nil, nil, "File too large", http.StatusRequestEntityTooLarge, err.Error(), nil)
+	case errors.Is(err, utils.ErrAvatarImage):
+		return utils.GetResponse(ctx, nil, nil, "Unsupported image", http.StatusUnsupportedMediaType, err.Error(), nil)
+	case errors.Is(err, utils.ErrAvatarURL):
+		return utils.GetResponse(ctx, nil, nil, "Invalid avatar URL", http.StatusForbidden, err.Error(), nil)
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, storage.ErrNotFound):
+		return utils.GetResponse(ctx, nil, nil, "Avatar not found", http.StatusNotFound, err.Error(), nil)
+	}
+	return utils.GetResponse(ctx, nil, nil, message, http.StatusInternalServerError, err.Error(), nil)
+}
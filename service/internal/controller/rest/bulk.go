@@ -0,0 +1,126 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nibroos/nb-go-api/service/internal/config"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+// errBulkTooLarge is returned by parseBulk when a request carries more items
+// than BULK_MAX_ITEMS.
+var errBulkTooLarge = errors.New("too many items")
+
+// parseBulk reads the body of a bulk-* request. Each item is parsed like the
+// body of its single item endpoint, empty strings as null. atomic reports
+// the mode, atomic unless partial is asked for.
+func parseBulk[T any](ctx *fiber.Ctx) (items []T, atomic bool, err error) {
+	var body dtos.BulkRequest[json.RawMessage]
+	if err := json.Unmarshal(ctx.Body(), &body); err != nil {
+		return nil, false, err
+	}
+
+	switch body.Mode {
+	case "", utils.BulkModeAtomic:
+		atomic = true
+	case utils.BulkModePartial:
+	default:
+		return nil, false, fmt.Errorf("mode must be %s or %s", utils.BulkModeAtomic, utils.BulkModePartial)
+	}
+
+	if len(body.Items) == 0 {
+		return nil, false, errors.New("items is required")
+	}
+	if max := config.GetBulkMaxItems(); len(body.Items) > max {
+		return nil, false, fmt.Errorf("%w: at most %d items are accepted", errBulkTooLarge, max)
+	}
+
+	items = make([]T, len(body.Items))
+	for i, raw := range body.Items {
+		if err := utils.UnmarshalWithNull(raw, &items[i]); err != nil {
+			return nil, false, fmt.Errorf("items.%d: %w", i, err)
+		}
+	}
+
+	return items, atomic, nil
+}
+
+// sendBulkParseError responds to a parseBulk error.
+func sendBulkParseError(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, errBulkTooLarge) {
+		return utils.GetResponse(ctx, nil, nil, "Too many items", http.StatusRequestEntityTooLarge, err.Error(), nil)
+	}
+	return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+}
+
+// validateBulkIDs checks the items of bulk-delete-* and bulk-restore-*.
+func validateBulkIDs(items []dtos.BulkDeleteItem) map[int]map[string]string {
+	invalid := make(map[int]map[string]string)
+	for i, item := range items {
+		if item.ID == 0 {
+			invalid[i] = map[string]string{"id": "The id field is required"}
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	return invalid
+}
+
+// bulkVersion is expectedVersion for an item of a bulk request, which has no
+// If-Match of its own.
+func bulkVersion(bodyVersion *uint, current uint) (uint, error) {
+	if bodyVersion == nil {
+		return current, nil
+	}
+	if *bodyVersion != current {
+		return 0, utils.ErrVersionConflict
+	}
+	return *bodyVersion, nil
+}
+
+// handleBulk writes the n items of a bulk request with run, unless one failed
+// validation (invalid, by index) in an atomic request, and responds with the
+// result of every item: 200 when all were written, 207 when some failed in a
+// partial request and 400 when an atomic request wrote nothing.
+func handleBulk(ctx *fiber.Ctx, n int, atomic bool, invalid map[int]map[string]string, run func(results []dtos.BulkItemResult) error, message string) error {
+	results := make([]dtos.BulkItemResult, n)
+	for i := range results {
+		results[i].Index = i
+		if errs, ok := invalid[i]; ok {
+			results[i].Status = utils.BulkStatusFailed
+			results[i].Errors = errs
+		}
+	}
+
+	var err error
+	if atomic && len(invalid) > 0 {
+		for i := range results {
+			if results[i].Status == "" {
+				results[i].Status = utils.BulkStatusSkipped
+			}
+		}
+		err = utils.ErrBulkFailed
+	} else {
+		err = run(results)
+	}
+
+	if errors.Is(err, utils.ErrBulkFailed) {
+		return utils.GetResponse(ctx, results, nil, "Bulk request failed, nothing was written", http.StatusBadRequest, nil, nil)
+	}
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Bulk request failed", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	for _, result := range results {
+		if result.Status == utils.BulkStatusFailed {
+			return utils.GetResponse(ctx, results, nil, "Bulk request partially applied", http.StatusMultiStatus, nil, nil)
+		}
+	}
+	return utils.GetResponse(ctx, results, nil, message, http.StatusOK, nil, nil)
+}
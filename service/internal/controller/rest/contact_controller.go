@@ -1,14 +1,18 @@
 package rest
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/nibroos/nb-go-api/service/internal/dtos"
 	"github.com/nibroos/nb-go-api/service/internal/middleware"
 	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
 	"github.com/nibroos/nb-go-api/service/internal/service"
 	"github.com/nibroos/nb-go-api/service/internal/utils"
 	"github.com/nibroos/nb-go-api/service/internal/validators/form_requests"
@@ -28,14 +32,83 @@ func (c *ContactController) ListContacts(ctx *fiber.Ctx) error {
 		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
 	}
 
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.ContactListFields, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	filters["fields"] = strings.Join(fields, ",")
+
+	includes, err := utils.ParseIncludes(filters["include"], repository.ChildIncludeRelations)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
 	contacts, total, err := c.service.ListContacts(ctx.Context(), filters)
 	if err != nil {
-		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, err.Error(), http.StatusInternalServerError), http.StatusInternalServerError)
+		return utils.SendQueryError(ctx, err)
+	}
+
+	data, err := utils.PickFields(contacts, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	if utils.HasInclude(includes, "user") {
+		userIDs := make([]uint, 0, len(contacts))
+		for _, contact := range contacts {
+			userIDs = append(userIDs, contact.UserID)
+		}
+		if err := nestUser(ctx, data, userIDs, c.service.GetUsersByIDs); err != nil {
+			return utils.SendQueryError(ctx, err)
+		}
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, data, paginationMeta, "Contacts fetched successfully", http.StatusOK, nil, nil)
+}
+
+// ListTrashedContacts lists the soft deleted contacts with the filters of index-contact.
+func (c *ContactController) ListTrashedContacts(ctx *fiber.Ctx) error {
+	filters, ok := ctx.Locals("filters").(map[string]string)
+	if !ok {
+		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
+	}
+
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.ContactTrashFields, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	filters["fields"] = strings.Join(fields, ",")
+
+	includes, err := utils.ParseIncludes(filters["include"], repository.ChildIncludeRelations)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	contacts, total, err := c.service.ListTrashedContacts(ctx.Context(), filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	data, err := utils.PickFields(contacts, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	if utils.HasInclude(includes, "user") {
+		userIDs := make([]uint, 0, len(contacts))
+		for _, contact := range contacts {
+			userIDs = append(userIDs, contact.UserID)
+		}
+		if err := nestUser(ctx, data, userIDs, c.service.GetUsersByIDs); err != nil {
+			return utils.SendQueryError(ctx, err)
+		}
 	}
 
 	paginationMeta := utils.CreatePaginationMeta(filters, total)
 
-	return utils.GetResponse(ctx, contacts, paginationMeta, "Contacts fetched successfully", http.StatusOK, nil, nil)
+	return utils.GetResponse(ctx, data, paginationMeta, "Trashed contacts fetched successfully", http.StatusOK, nil, nil)
 }
 func (c *ContactController) CreateContact(ctx *fiber.Ctx) error {
 	var req dtos.CreateContactRequest
@@ -62,7 +135,15 @@ func (c *ContactController) CreateContact(ctx *fiber.Ctx) error {
 		OptionsJSON:   nil,
 	}
 
-	createdContact, err := c.service.CreateContact(ctx.Context(), &contact)
+	refNumErrors, err := c.checkRefNum(ctx.Context(), &contact)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Failed to create contact", http.StatusInternalServerError, err.Error(), nil)
+	}
+	if refNumErrors != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": refNumErrors, "message": "Validation failed", "status": http.StatusBadRequest})
+	}
+
+	createdContact, err := c.service.CreateContact(auditContext(ctx), &contact)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Failed to create contact", http.StatusInternalServerError, err.Error(), nil)
 	}
@@ -76,6 +157,8 @@ func (c *ContactController) CreateContact(ctx *fiber.Ctx) error {
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	ctx.Set(fiber.HeaderETag, utils.ETag(getContact.Version))
+
 	return utils.GetResponse(ctx, []interface{}{getContact}, paginationMeta, "Contact created successfully", http.StatusCreated, nil, nil)
 }
 
@@ -91,16 +174,47 @@ func (c *ContactController) GetContactByID(ctx *fiber.Ctx) error {
 	}
 
 	params := &dtos.GetContactParams{ID: req.ID}
+	filters := ctx.Locals("filters").(map[string]string)
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.ContactDetailFields, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	params.Fields = withVersion(fields)
+	params.AsOf, err = asOf(filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	includes, err := utils.ParseIncludes(filters["include"], repository.ChildIncludeRelations)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
 	contact, err := c.service.GetContactByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	contactArray := []interface{}{contact}
+	data, err := utils.PickFields(contact, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	if utils.HasInclude(includes, "user") {
+		if err := nestUser(ctx, data, []uint{contact.UserID}, c.service.GetUsersByIDs); err != nil {
+			return utils.SendQueryError(ctx, err)
+		}
+	}
+
+	contactArray := []interface{}{data}
 
-	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	// a past version is not something to write against
+	if params.AsOf == nil {
+		ctx.Set(fiber.HeaderETag, utils.ETag(contact.Version))
+	}
+
 	return utils.GetResponse(ctx, contactArray, paginationMeta, "Contact fetched successfully", http.StatusOK, nil, nil)
 }
 
@@ -112,8 +226,14 @@ func (c *ContactController) UpdateContact(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": err.Error(), "message": "Invalid request", "status": http.StatusBadRequest})
 	}
 
+	return c.updateContact(ctx, &req, "Contact updated successfully")
+}
+
+// updateContact validates req and applies it, the path update-contact and
+// revert-contact share.
+func (c *ContactController) updateContact(ctx *fiber.Ctx, req *dtos.UpdateContactRequest, message string) error {
 	// Validate the request
-	reqValidator := form_requests.NewContactUpdateRequest().Validate(&req, ctx.Context())
+	reqValidator := form_requests.NewContactUpdateRequest().Validate(req, ctx.Context())
 	if reqValidator != nil {
 		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": reqValidator, "message": "Validation failed", "status": http.StatusBadRequest})
 	}
@@ -125,6 +245,11 @@ func (c *ContactController) UpdateContact(ctx *fiber.Ctx) error {
 		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusNotFound, err.Error(), nil)
 	}
 
+	version, err := expectedVersion(ctx, req.Version, existingContact.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingContact, existingContact.Version, repository.ContactDetailFields, false)
+	}
+
 	contact := models.Contact{
 		ID:            req.ID,
 		TypeContactID: existingContact.TypeContactID,
@@ -132,16 +257,28 @@ func (c *ContactController) UpdateContact(ctx *fiber.Ctx) error {
 		RefNum:        req.RefNum,
 		Status:        req.Status,
 		CreatedAt:     existingContact.CreatedAt,
+		Version:       version,
 	}
 
 	if req.TypeContactID != nil {
 		contact.TypeContactID = *req.TypeContactID
 	}
 
-	updatedContact, err := c.service.UpdateContact(ctx.Context(), &contact)
+	refNumErrors, err := c.checkRefNum(ctx.Context(), &contact)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Failed to update contact", http.StatusInternalServerError, err.Error(), nil)
 	}
+	if refNumErrors != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": refNumErrors, "message": "Validation failed", "status": http.StatusBadRequest})
+	}
+
+	updatedContact, err := c.service.UpdateContact(auditContext(ctx), &contact)
+	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendContactConflict(ctx, req.ID, 0)
+		}
+		return utils.GetResponse(ctx, nil, nil, "Failed to update contact", http.StatusInternalServerError, err.Error(), nil)
+	}
 
 	params = &dtos.GetContactParams{ID: updatedContact.ID}
 	getContact, err := c.service.GetContactByID(ctx.Context(), params)
@@ -152,7 +289,9 @@ func (c *ContactController) UpdateContact(ctx *fiber.Ctx) error {
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
-	return utils.GetResponse(ctx, []interface{}{getContact}, paginationMeta, "Contact updated successfully", http.StatusOK, nil, nil)
+	ctx.Set(fiber.HeaderETag, utils.ETag(getContact.Version))
+
+	return utils.GetResponse(ctx, []interface{}{getContact}, paginationMeta, message, http.StatusOK, nil, nil)
 }
 
 // delete contact
@@ -169,13 +308,21 @@ func (c *ContactController) DeleteContact(ctx *fiber.Ctx) error {
 
 	params := &dtos.GetContactParams{ID: req.ID}
 	// GET contact by ID
-	_, err := c.service.GetContactByID(ctx.Context(), params)
+	existingContact, err := c.service.GetContactByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.DeleteContact(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingContact.Version)
 	if err != nil {
+		return sendVersionError(ctx, err, existingContact, existingContact.Version, repository.ContactDetailFields, false)
+	}
+
+	err = c.service.DeleteContact(auditContext(ctx), req.ID, version)
+	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendContactConflict(ctx, req.ID, 0)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to delete contact", http.StatusInternalServerError, err.Error(), nil)
 	}
 
@@ -197,19 +344,77 @@ func (c *ContactController) RestoreContact(ctx *fiber.Ctx) error {
 	isDeleted := 1
 	params := &dtos.GetContactParams{ID: req.ID, IsDeleted: &isDeleted}
 	// GET contact by ID
-	_, err := c.service.GetContactByID(ctx.Context(), params)
+	existingContact, err := c.service.GetContactByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.RestoreContact(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingContact.Version)
 	if err != nil {
+		return sendVersionError(ctx, err, existingContact, existingContact.Version, repository.ContactDetailFields, false)
+	}
+
+	err = c.service.RestoreContact(auditContext(ctx), req.ID, version)
+	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendContactConflict(ctx, req.ID, 0)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to restore contact", http.StatusInternalServerError, err.Error(), nil)
 	}
 
 	return utils.GetResponse(ctx, nil, nil, "Contact restored successfully", http.StatusOK, nil, nil)
 }
 
+// SetPrimaryContact makes a contact the primary of its user and type, in place of
+// the current one.
+func (c *ContactController) SetPrimaryContact(ctx *fiber.Ctx) error {
+	return c.setPrimaryContact(ctx, 0)
+}
+
+// setPrimaryContact makes the contact of the request the primary of its user and
+// type, limited to the contacts of userID unless it is 0.
+func (c *ContactController) setPrimaryContact(ctx *fiber.Ctx, userID uint) error {
+	var req dtos.DeleteContactRequest
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	params := &dtos.GetContactParams{ID: req.ID, UserID: userID}
+	existingContact, err := c.service.GetContactByID(ctx.Context(), params)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	version, err := expectedVersion(ctx, req.Version, existingContact.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingContact, existingContact.Version, repository.ContactDetailFields, userID != 0)
+	}
+
+	if err := c.service.SetPrimaryContact(auditContext(ctx), req.ID, version); err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendContactConflict(ctx, req.ID, userID)
+		}
+		return utils.GetResponse(ctx, nil, nil, "Failed to set primary contact", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	getContact, err := c.service.GetContactByID(ctx.Context(), params)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	filters := ctx.Locals("filters").(map[string]string)
+	paginationMeta := utils.CreatePaginationMeta(filters, 1)
+
+	ctx.Set(fiber.HeaderETag, utils.ETag(getContact.Version))
+
+	return utils.GetResponse(ctx, []interface{}{getContact}, paginationMeta, "Primary contact set successfully", http.StatusOK, nil, nil)
+}
+
 func (c *ContactController) ListContactsByAuthUser(ctx *fiber.Ctx) error {
 	// Extract user ID from JWT
 	claims, err := middleware.GetAuthUser(ctx)
@@ -225,14 +430,25 @@ func (c *ContactController) ListContactsByAuthUser(ctx *fiber.Ctx) error {
 		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
 	}
 
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.ContactListFields, true)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	filters["fields"] = strings.Join(fields, ",")
+
 	contacts, total, err := c.service.ListContacts(ctx.Context(), filters)
 	if err != nil {
-		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, err.Error(), http.StatusInternalServerError), http.StatusInternalServerError)
+		return utils.SendQueryError(ctx, err)
+	}
+
+	data, err := utils.PickFields(contacts, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
 	}
 
 	paginationMeta := utils.CreatePaginationMeta(filters, total)
 
-	return utils.GetResponse(ctx, contacts, paginationMeta, "Contacts fetched successfully", http.StatusOK, nil, nil)
+	return utils.GetResponse(ctx, data, paginationMeta, "Contacts fetched successfully", http.StatusOK, nil, nil)
 }
 
 // make auth create contact
@@ -268,7 +484,15 @@ func (c *ContactController) CreateContactByAuthUser(ctx *fiber.Ctx) error {
 		OptionsJSON:   nil,
 	}
 
-	createdContact, err := c.service.CreateContact(ctx.Context(), &contact)
+	refNumErrors, err := c.checkRefNum(ctx.Context(), &contact)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Failed to create contact", http.StatusInternalServerError, err.Error(), nil)
+	}
+	if refNumErrors != nil {
+		return utils.GetResponse(ctx, nil, nil, "Validation failed", http.StatusBadRequest, refNumErrors, nil)
+	}
+
+	createdContact, err := c.service.CreateContact(auditContext(ctx), &contact)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Failed to create contact", http.StatusInternalServerError, err.Error(), nil)
 	}
@@ -282,6 +506,8 @@ func (c *ContactController) CreateContactByAuthUser(ctx *fiber.Ctx) error {
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	ctx.Set(fiber.HeaderETag, utils.ETag(getContact.Version))
+
 	return utils.GetResponse(ctx, []interface{}{getContact}, paginationMeta, "Contact created successfully", http.StatusCreated, nil, nil)
 }
 
@@ -306,16 +532,36 @@ func (c *ContactController) GetContactByIDByAuthUser(ctx *fiber.Ctx) error {
 
 	params.UserID = userID
 
+	filters := ctx.Locals("filters").(map[string]string)
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.ContactDetailFields, true)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	params.Fields = withVersion(fields)
+	params.AsOf, err = asOf(filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
 	contact, err := c.service.GetContactByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	contactArray := []interface{}{contact}
+	data, err := utils.PickFields(contact, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	contactArray := []interface{}{data}
 
-	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	// a past version is not something to write against
+	if params.AsOf == nil {
+		ctx.Set(fiber.HeaderETag, utils.ETag(contact.Version))
+	}
+
 	return utils.GetResponse(ctx, contactArray, paginationMeta, "Contact fetched successfully", http.StatusOK, nil, nil)
 }
 
@@ -350,6 +596,11 @@ func (c *ContactController) UpdateContactByAuthUser(ctx *fiber.Ctx) error {
 		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusNotFound, err.Error(), nil)
 	}
 
+	version, err := expectedVersion(ctx, req.Version, existingContact.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingContact, existingContact.Version, repository.ContactDetailFields, true)
+	}
+
 	contact := models.Contact{
 		ID:            req.ID,
 		TypeContactID: existingContact.TypeContactID,
@@ -357,16 +608,28 @@ func (c *ContactController) UpdateContactByAuthUser(ctx *fiber.Ctx) error {
 		RefNum:        req.RefNum,
 		Status:        req.Status,
 		CreatedAt:     existingContact.CreatedAt,
+		Version:       version,
 	}
 
 	if req.TypeContactID != nil {
 		contact.TypeContactID = *req.TypeContactID
 	}
 
-	updatedContact, err := c.service.UpdateContact(ctx.Context(), &contact)
+	refNumErrors, err := c.checkRefNum(ctx.Context(), &contact)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Failed to update contact", http.StatusInternalServerError, err.Error(), nil)
 	}
+	if refNumErrors != nil {
+		return utils.GetResponse(ctx, nil, nil, "Validation failed", http.StatusBadRequest, refNumErrors, nil)
+	}
+
+	updatedContact, err := c.service.UpdateContact(auditContext(ctx), &contact)
+	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendContactConflict(ctx, req.ID, userID)
+		}
+		return utils.GetResponse(ctx, nil, nil, "Failed to update contact", http.StatusInternalServerError, err.Error(), nil)
+	}
 
 	params = &dtos.GetContactParams{ID: updatedContact.ID}
 	getContact, err := c.service.GetContactByID(ctx.Context(), params)
@@ -377,6 +640,8 @@ func (c *ContactController) UpdateContactByAuthUser(ctx *fiber.Ctx) error {
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	ctx.Set(fiber.HeaderETag, utils.ETag(getContact.Version))
+
 	return utils.GetResponse(ctx, []interface{}{getContact}, paginationMeta, "Contact updated successfully", http.StatusOK, nil, nil)
 }
 
@@ -404,13 +669,21 @@ func (c *ContactController) DeleteContactByAuthUser(ctx *fiber.Ctx) error {
 	params.UserID = userID
 
 	// GET contact by ID
-	_, err = c.service.GetContactByID(ctx.Context(), params)
+	existingContact, err := c.service.GetContactByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.DeleteContact(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingContact.Version)
 	if err != nil {
+		return sendVersionError(ctx, err, existingContact, existingContact.Version, repository.ContactDetailFields, true)
+	}
+
+	err = c.service.DeleteContact(auditContext(ctx), req.ID, version)
+	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendContactConflict(ctx, req.ID, userID)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to delete contact", http.StatusInternalServerError, err.Error(), nil)
 	}
 
@@ -441,15 +714,299 @@ func (c *ContactController) RestoreContactByAuthUser(ctx *fiber.Ctx) error {
 	params := &dtos.GetContactParams{ID: req.ID, IsDeleted: &isDeleted, UserID: userID}
 
 	// GET contact by ID
-	_, err = c.service.GetContactByID(ctx.Context(), params)
+	existingContact, err := c.service.GetContactByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.RestoreContact(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingContact.Version)
 	if err != nil {
+		return sendVersionError(ctx, err, existingContact, existingContact.Version, repository.ContactDetailFields, true)
+	}
+
+	err = c.service.RestoreContact(auditContext(ctx), req.ID, version)
+	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendContactConflict(ctx, req.ID, userID)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to restore contact", http.StatusInternalServerError, err.Error(), nil)
 	}
 
 	return utils.GetResponse(ctx, nil, nil, "Contact restored successfully", http.StatusOK, nil, nil)
 }
+
+// SetPrimaryContactByAuthUser makes a contact of the authenticated user the
+// primary of its type.
+func (c *ContactController) SetPrimaryContactByAuthUser(ctx *fiber.Ctx) error {
+	// Extract user ID from JWT
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	return c.setPrimaryContact(ctx, uint(claims["user_id"].(float64)))
+}
+
+// ListContactHistory lists the recorded versions of a contact, newest first.
+func (c *ContactController) ListContactHistory(ctx *fiber.Ctx) error {
+	var req dtos.GetHistoryRequest
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	filters := ctx.Locals("filters").(map[string]string)
+
+	history, total, err := c.service.GetContactHistory(ctx.Context(), req.ID, filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, history, paginationMeta, "Contact history fetched successfully", http.StatusOK, nil, nil)
+}
+
+// RevertContact writes the state of a past version back as a new version.
+func (c *ContactController) RevertContact(ctx *fiber.Ctx) error {
+	var req dtos.RevertRequest
+
+	if err := utils.BodyParserWithNull(ctx, &req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": err.Error(), "message": "Invalid request", "status": http.StatusBadRequest})
+	}
+
+	if req.ID == 0 || req.ToVersion == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusBadRequest, "ID and to_version are required", nil)
+	}
+
+	snapshot, err := c.service.GetContactByID(ctx.Context(), &dtos.GetContactParams{ID: req.ID, Version: &req.ToVersion})
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Contact version not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	update := dtos.UpdateContactRequest{
+		ID:            snapshot.ID,
+		UserID:        snapshot.UserID,
+		TypeContactID: &snapshot.TypeContactID,
+		RefNum:        snapshot.RefNum,
+		Status:        snapshot.Status,
+		Version:       req.Version,
+	}
+
+	return c.updateContact(ctx, &update, "Contact reverted successfully")
+}
+
+// BulkCreateContacts creates up to BULK_MAX_ITEMS contacts in one request.
+func (c *ContactController) BulkCreateContacts(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.CreateContactRequest](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	invalid := form_requests.NewContactStoreRequest().ValidateBatch(items, ctx.Context())
+
+	checks := make([]dtos.ContactRefNumCheck, len(items))
+	for i, item := range items {
+		checks[i] = dtos.ContactRefNumCheck{UserID: item.UserID, TypeContactID: item.TypeContactID, RefNum: item.RefNum}
+	}
+	normalized, invalid, err := c.checkBulkRefNums(ctx.Context(), checks, invalid)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Bulk request failed", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return handleBulk(ctx, len(items), atomic, invalid, func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusCreated, func(ctx context.Context, i int) (uint, uint, error) {
+			req := items[i]
+			createdAt := time.Now()
+
+			contact := models.Contact{
+				TypeContactID: req.TypeContactID,
+				UserID:        req.UserID,
+				RefNum:        req.RefNum,
+				Status:        req.Status,
+				CreatedAt:     &createdAt,
+				OptionsJSON:   nil,
+			}
+			contact.NormalizedRefNum = &normalized[i]
+
+			createdContact, err := c.service.CreateContact(ctx, &contact)
+			if err != nil {
+				return 0, 0, err
+			}
+			return createdContact.ID, createdContact.Version, nil
+		})
+	}, "Contacts created successfully")
+}
+
+// BulkUpdateContacts updates up to BULK_MAX_ITEMS contacts in one request.
+func (c *ContactController) BulkUpdateContacts(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.UpdateContactRequest](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	invalid := form_requests.NewContactUpdateRequest().ValidateBatch(items, ctx.Context())
+
+	checks := make([]dtos.ContactRefNumCheck, len(items))
+	for i, item := range items {
+		checks[i] = dtos.ContactRefNumCheck{ID: item.ID, UserID: item.UserID, RefNum: item.RefNum}
+		if item.TypeContactID != nil {
+			checks[i].TypeContactID = *item.TypeContactID
+		}
+	}
+	normalized, invalid, err := c.checkBulkRefNums(ctx.Context(), checks, invalid)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Bulk request failed", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return handleBulk(ctx, len(items), atomic, invalid, func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusUpdated, func(ctx context.Context, i int) (uint, uint, error) {
+			req := items[i]
+
+			existingContact, err := c.service.GetContactByID(ctx, &dtos.GetContactParams{ID: req.ID})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			version, err := bulkVersion(req.Version, existingContact.Version)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			contact := models.Contact{
+				ID:            req.ID,
+				TypeContactID: existingContact.TypeContactID,
+				UserID:        req.UserID,
+				RefNum:        req.RefNum,
+				Status:        req.Status,
+				CreatedAt:     existingContact.CreatedAt,
+				Version:       version,
+			}
+
+			if req.TypeContactID != nil {
+				contact.TypeContactID = *req.TypeContactID
+			}
+			contact.NormalizedRefNum = &normalized[i]
+
+			updatedContact, err := c.service.UpdateContact(ctx, &contact)
+			if err != nil {
+				return 0, 0, err
+			}
+			return updatedContact.ID, updatedContact.Version, nil
+		})
+	}, "Contacts updated successfully")
+}
+
+// BulkDeleteContacts soft deletes up to BULK_MAX_ITEMS contacts in one request.
+func (c *ContactController) BulkDeleteContacts(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.BulkDeleteItem](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	return handleBulk(ctx, len(items), atomic, validateBulkIDs(items), func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusDeleted, func(ctx context.Context, i int) (uint, uint, error) {
+			item := items[i]
+
+			existingContact, err := c.service.GetContactByID(ctx, &dtos.GetContactParams{ID: item.ID})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			version, err := bulkVersion(item.Version, existingContact.Version)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			if err := c.service.DeleteContact(ctx, item.ID, version); err != nil {
+				return 0, 0, err
+			}
+			return item.ID, version + 1, nil
+		})
+	}, "Contacts deleted successfully")
+}
+
+// BulkRestoreContacts restores up to BULK_MAX_ITEMS soft deleted contacts in one request.
+func (c *ContactController) BulkRestoreContacts(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.BulkDeleteItem](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	return handleBulk(ctx, len(items), atomic, validateBulkIDs(items), func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusRestored, func(ctx context.Context, i int) (uint, uint, error) {
+			item := items[i]
+
+			isDeleted := 1
+			existingContact, err := c.service.GetContactByID(ctx, &dtos.GetContactParams{ID: item.ID, IsDeleted: &isDeleted})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			version, err := bulkVersion(item.Version, existingContact.Version)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			if err := c.service.RestoreContact(ctx, item.ID, version); err != nil {
+				return 0, 0, err
+			}
+			return item.ID, version + 1, nil
+		})
+	}, "Contacts restored successfully")
+}
+
+// checkRefNum checks the ref_num written to contact against the format of its
+// type and the other contacts of its user, and sets how it is stored. It
+// returns the validation errors.
+func (c *ContactController) checkRefNum(ctx context.Context, contact *models.Contact) (map[string]string, error) {
+	normalized, invalid, err := c.service.CheckRefNums(ctx, []dtos.ContactRefNumCheck{{
+		ID:            contact.ID,
+		UserID:        contact.UserID,
+		TypeContactID: contact.TypeContactID,
+		RefNum:        contact.RefNum,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if message, ok := invalid[0]; ok {
+		return map[string]string{"ref_num": message}, nil
+	}
+
+	contact.NormalizedRefNum = &normalized[0]
+	return nil, nil
+}
+
+// checkBulkRefNums checks the ref_num of the items of a bulk request that
+// passed validation, see checkRefNum, adding its errors to invalid. It returns
+// how the ref_num are stored, by index.
+func (c *ContactController) checkBulkRefNums(ctx context.Context, checks []dtos.ContactRefNumCheck, invalid map[int]map[string]string) ([]string, map[int]map[string]string, error) {
+	var valid []dtos.ContactRefNumCheck
+	var indexes []int
+	for i, check := range checks {
+		if _, ok := invalid[i]; !ok {
+			valid = append(valid, check)
+			indexes = append(indexes, i)
+		}
+	}
+
+	checked, refNumErrors, err := c.service.CheckRefNums(ctx, valid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	normalized := make([]string, len(checks))
+	for j, i := range indexes {
+		normalized[i] = checked[j]
+		if message, ok := refNumErrors[j]; ok {
+			if invalid == nil {
+				invalid = make(map[int]map[string]string)
+			}
+			invalid[i] = map[string]string{"ref_num": message}
+		}
+	}
+	return normalized, invalid, nil
+}
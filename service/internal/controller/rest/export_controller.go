@@ -0,0 +1,154 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nibroos/nb-go-api/service/internal/config"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+type ExportController struct {
+	service *service.ExportService
+}
+
+func NewExportController(service *service.ExportService) *ExportController {
+	return &ExportController{service: service}
+}
+
+func (c *ExportController) ExportUsers(ctx *fiber.Ctx) error {
+	return c.export(ctx, utils.ExportEntityUsers, repository.UserListFields)
+}
+
+func (c *ExportController) ExportContacts(ctx *fiber.Ctx) error {
+	return c.export(ctx, utils.ExportEntityContacts, repository.ContactListFields)
+}
+
+func (c *ExportController) ExportAddresses(ctx *fiber.Ctx) error {
+	return c.export(ctx, utils.ExportEntityAddresses, repository.AddressListFields)
+}
+
+func (c *ExportController) ExportIdentifiers(ctx *fiber.Ctx) error {
+	return c.export(ctx, utils.ExportEntityIdentifiers, repository.IdentifierListFields)
+}
+
+// export answers an export-* request: every row its index-* endpoint would
+// list with the same filters, unpaged, as CSV or NDJSON (`format`). Up to
+// EXPORT_STREAM_MAX_ROWS rows are streamed in the response, more are queued
+// as an export job whose file download-export returns once completed.
+func (c *ExportController) export(ctx *fiber.Ctx, entity string, allowed map[string]utils.Field) error {
+	filters, ok := ctx.Locals("filters").(map[string]string)
+	if !ok {
+		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
+	}
+
+	format, err := utils.ParseExportFormat(filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	fields, err := utils.SelectFields(ctx, filters["fields"], allowed, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	filters["fields"] = strings.Join(fields, ",")
+
+	list, total, err := c.service.PrepareExport(ctx.Context(), entity, filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	if total > config.GetExportStreamMaxRows() {
+		claims, err := middleware.GetAuthUser(ctx)
+		if err != nil {
+			return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+		}
+		userID := uint(claims["user_id"].(float64))
+
+		job, err := c.service.QueueExport(auditContext(ctx), userID, entity, format, filters, total)
+		if err != nil {
+			return utils.GetResponse(ctx, nil, nil, "Failed to queue export", http.StatusInternalServerError, err.Error(), nil)
+		}
+		return utils.GetResponse(ctx, []interface{}{job}, nil, "Export queued, download it with download-export once completed", http.StatusAccepted, nil, nil)
+	}
+
+	fileName := fmt.Sprintf("%s-%s.%s", entity, time.Now().Format("20060102150405"), format)
+	ctx.Set(fiber.HeaderContentType, utils.ExportContentType(format))
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, fileName))
+
+	// the body is written after the handler returns, the request context is
+	// gone by then
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if _, err := c.service.StreamExport(context.Background(), list, utils.NewExportWriter(format, w)); err != nil {
+			log.Printf("Export of %s failed: %v", entity, err)
+		}
+	})
+	return nil
+}
+
+func (c *ExportController) GetExportJob(ctx *fiber.Ctx) error {
+	var req dtos.GetExportJobRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Export not found", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Export not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	userID := uint(claims["user_id"].(float64))
+
+	job, err := c.service.GetExportJob(ctx.Context(), req.ID, userID)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Export not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	return utils.GetResponse(ctx, []interface{}{job}, nil, "Export fetched successfully", http.StatusOK, nil, nil)
+}
+
+// DownloadExport sends the file of a completed export job.
+func (c *ExportController) DownloadExport(ctx *fiber.Ctx) error {
+	var req dtos.GetExportJobRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Export not found", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Export not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	userID := uint(claims["user_id"].(float64))
+
+	path, err := c.service.GetExportFile(ctx.Context(), req.ID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrExportStatus):
+			return utils.GetResponse(ctx, nil, nil, "Export not ready", http.StatusConflict, "only a completed export can be downloaded", nil)
+		case errors.Is(err, sql.ErrNoRows):
+			return utils.GetResponse(ctx, nil, nil, "Export not found", http.StatusNotFound, err.Error(), nil)
+		}
+		return utils.GetResponse(ctx, nil, nil, "Failed to download export", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return ctx.Download(path)
+}
@@ -0,0 +1,17 @@
+package rest
+
+import (
+	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+// asOf reads the as_of filter of the show-* endpoints. Only the record itself
+// is read from its history; roles and embedded relations stay current.
+func asOf(filters map[string]string) (*time.Time, error) {
+	at, ok, err := utils.ParseTimeFilter(filters, "as_of")
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &at, nil
+}
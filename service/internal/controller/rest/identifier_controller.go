@@ -1,14 +1,18 @@
 package rest
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/nibroos/nb-go-api/service/internal/dtos"
 	"github.com/nibroos/nb-go-api/service/internal/middleware"
 	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
 	"github.com/nibroos/nb-go-api/service/internal/service"
 	"github.com/nibroos/nb-go-api/service/internal/utils"
 	"github.com/nibroos/nb-go-api/service/internal/validators/form_requests"
@@ -28,14 +32,83 @@ func (c *IdentifierController) ListIdentifiers(ctx *fiber.Ctx) error {
 		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
 	}
 
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.IdentifierListFields, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	filters["fields"] = strings.Join(fields, ",")
+
+	includes, err := utils.ParseIncludes(filters["include"], repository.ChildIncludeRelations)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
 	identifiers, total, err := c.service.ListIdentifiers(ctx.Context(), filters)
 	if err != nil {
-		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, err.Error(), http.StatusInternalServerError), http.StatusInternalServerError)
+		return utils.SendQueryError(ctx, err)
+	}
+
+	data, err := utils.PickFields(identifiers, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	if utils.HasInclude(includes, "user") {
+		userIDs := make([]uint, 0, len(identifiers))
+		for _, identifier := range identifiers {
+			userIDs = append(userIDs, identifier.UserID)
+		}
+		if err := nestUser(ctx, data, userIDs, c.service.GetUsersByIDs); err != nil {
+			return utils.SendQueryError(ctx, err)
+		}
 	}
 
 	paginationMeta := utils.CreatePaginationMeta(filters, total)
 
-	return utils.GetResponse(ctx, identifiers, paginationMeta, "Identifiers fetched successfully", http.StatusOK, nil, nil)
+	return utils.GetResponse(ctx, data, paginationMeta, "Identifiers fetched successfully", http.StatusOK, nil, nil)
+}
+
+// ListTrashedIdentifiers lists the soft deleted identifiers with the filters of index-identifier.
+func (c *IdentifierController) ListTrashedIdentifiers(ctx *fiber.Ctx) error {
+	filters, ok := ctx.Locals("filters").(map[string]string)
+	if !ok {
+		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
+	}
+
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.IdentifierTrashFields, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	filters["fields"] = strings.Join(fields, ",")
+
+	includes, err := utils.ParseIncludes(filters["include"], repository.ChildIncludeRelations)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	identifiers, total, err := c.service.ListTrashedIdentifiers(ctx.Context(), filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	data, err := utils.PickFields(identifiers, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	if utils.HasInclude(includes, "user") {
+		userIDs := make([]uint, 0, len(identifiers))
+		for _, identifier := range identifiers {
+			userIDs = append(userIDs, identifier.UserID)
+		}
+		if err := nestUser(ctx, data, userIDs, c.service.GetUsersByIDs); err != nil {
+			return utils.SendQueryError(ctx, err)
+		}
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, data, paginationMeta, "Trashed identifiers fetched successfully", http.StatusOK, nil, nil)
 }
 func (c *IdentifierController) CreateIdentifier(ctx *fiber.Ctx) error {
 	var req dtos.CreateIdentifierRequest
@@ -57,12 +130,21 @@ func (c *IdentifierController) CreateIdentifier(ctx *fiber.Ctx) error {
 		TypeIdentifierID: req.TypeIdentifierID,
 		UserID:           req.UserID,
 		RefNum:           req.RefNum,
-		Status:           req.Status,
+		IssuedAt:         req.IssuedAt,
+		ExpiresAt:        req.ExpiresAt,
 		CreatedAt:        &createdAt,
 		OptionsJSON:      nil,
 	}
 
-	createdIdentifier, err := c.service.CreateIdentifier(ctx.Context(), &identifier)
+	refNumErrors, err := c.checkRefNum(ctx.Context(), &identifier)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Failed to create identifier", http.StatusInternalServerError, err.Error(), nil)
+	}
+	if refNumErrors != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": refNumErrors, "message": "Validation failed", "status": http.StatusBadRequest})
+	}
+
+	createdIdentifier, err := c.service.CreateIdentifier(auditContext(ctx), &identifier)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Failed to create identifier", http.StatusInternalServerError, err.Error(), nil)
 	}
@@ -76,6 +158,8 @@ func (c *IdentifierController) CreateIdentifier(ctx *fiber.Ctx) error {
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	ctx.Set(fiber.HeaderETag, utils.ETag(getIdentifier.Version))
+
 	return utils.GetResponse(ctx, []interface{}{getIdentifier}, paginationMeta, "Identifier created successfully", http.StatusCreated, nil, nil)
 }
 
@@ -91,16 +175,47 @@ func (c *IdentifierController) GetIdentifierByID(ctx *fiber.Ctx) error {
 	}
 
 	params := &dtos.GetIdentifierParams{ID: req.ID}
+	filters := ctx.Locals("filters").(map[string]string)
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.IdentifierDetailFields, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	params.Fields = withVersion(fields)
+	params.AsOf, err = asOf(filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	includes, err := utils.ParseIncludes(filters["include"], repository.ChildIncludeRelations)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
 	identifier, err := c.service.GetIdentifierByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	identifierArray := []interface{}{identifier}
+	data, err := utils.PickFields(identifier, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	if utils.HasInclude(includes, "user") {
+		if err := nestUser(ctx, data, []uint{identifier.UserID}, c.service.GetUsersByIDs); err != nil {
+			return utils.SendQueryError(ctx, err)
+		}
+	}
+
+	identifierArray := []interface{}{data}
 
-	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	// a past version is not something to write against
+	if params.AsOf == nil {
+		ctx.Set(fiber.HeaderETag, utils.ETag(identifier.Version))
+	}
+
 	return utils.GetResponse(ctx, identifierArray, paginationMeta, "Identifier fetched successfully", http.StatusOK, nil, nil)
 }
 
@@ -112,8 +227,14 @@ func (c *IdentifierController) UpdateIdentifier(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": err.Error(), "message": "Invalid request", "status": http.StatusBadRequest})
 	}
 
+	return c.updateIdentifier(ctx, &req, "Identifier updated successfully")
+}
+
+// updateIdentifier validates req and applies it, the path update-identifier and
+// revert-identifier share.
+func (c *IdentifierController) updateIdentifier(ctx *fiber.Ctx, req *dtos.UpdateIdentifierRequest, message string) error {
 	// Validate the request
-	reqValidator := form_requests.NewIdentifierUpdateRequest().Validate(&req, ctx.Context())
+	reqValidator := form_requests.NewIdentifierUpdateRequest().Validate(req, ctx.Context())
 	if reqValidator != nil {
 		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": reqValidator, "message": "Validation failed", "status": http.StatusBadRequest})
 	}
@@ -125,21 +246,42 @@ func (c *IdentifierController) UpdateIdentifier(ctx *fiber.Ctx) error {
 		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusNotFound, err.Error(), nil)
 	}
 
+	version, err := expectedVersion(ctx, req.Version, existingIdentifier.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingIdentifier, existingIdentifier.Version, repository.IdentifierDetailFields, false)
+	}
+
 	identifier := models.Identifier{
 		ID:               req.ID,
 		TypeIdentifierID: existingIdentifier.TypeIdentifierID,
 		UserID:           req.UserID,
 		RefNum:           req.RefNum,
-		Status:           req.Status,
+		IssuedAt:         req.IssuedAt,
+		ExpiresAt:        req.ExpiresAt,
 		CreatedAt:        existingIdentifier.CreatedAt,
+		Version:          version,
 	}
 
 	if req.TypeIdentifierID != nil {
 		identifier.TypeIdentifierID = *req.TypeIdentifierID
 	}
 
-	updatedIdentifier, err := c.service.UpdateIdentifier(ctx.Context(), &identifier)
+	refNumErrors, err := c.checkRefNum(ctx.Context(), &identifier)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Failed to update identifier", http.StatusInternalServerError, err.Error(), nil)
+	}
+	if refNumErrors != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": refNumErrors, "message": "Validation failed", "status": http.StatusBadRequest})
+	}
+
+	updatedIdentifier, err := c.service.UpdateIdentifier(auditContext(ctx), &identifier)
 	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendIdentifierConflict(ctx, req.ID, 0)
+		}
+		if errors.Is(err, utils.ErrIdentifierLocked) {
+			return utils.GetResponse(ctx, nil, nil, "Identifier cannot be updated", http.StatusConflict, err.Error(), nil)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to update identifier", http.StatusInternalServerError, err.Error(), nil)
 	}
 
@@ -152,7 +294,9 @@ func (c *IdentifierController) UpdateIdentifier(ctx *fiber.Ctx) error {
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
-	return utils.GetResponse(ctx, []interface{}{getIdentifier}, paginationMeta, "Identifier updated successfully", http.StatusOK, nil, nil)
+	ctx.Set(fiber.HeaderETag, utils.ETag(getIdentifier.Version))
+
+	return utils.GetResponse(ctx, []interface{}{getIdentifier}, paginationMeta, message, http.StatusOK, nil, nil)
 }
 
 // delete identifier
@@ -169,13 +313,21 @@ func (c *IdentifierController) DeleteIdentifier(ctx *fiber.Ctx) error {
 
 	params := &dtos.GetIdentifierParams{ID: req.ID}
 	// GET identifier by ID
-	_, err := c.service.GetIdentifierByID(ctx.Context(), params)
+	existingIdentifier, err := c.service.GetIdentifierByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.DeleteIdentifier(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingIdentifier.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingIdentifier, existingIdentifier.Version, repository.IdentifierDetailFields, false)
+	}
+
+	err = c.service.DeleteIdentifier(auditContext(ctx), req.ID, version)
 	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendIdentifierConflict(ctx, req.ID, 0)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to delete identifier", http.StatusInternalServerError, err.Error(), nil)
 	}
 
@@ -197,13 +349,21 @@ func (c *IdentifierController) RestoreIdentifier(ctx *fiber.Ctx) error {
 	isDeleted := 1
 	params := &dtos.GetIdentifierParams{ID: req.ID, IsDeleted: &isDeleted}
 	// GET identifier by ID
-	_, err := c.service.GetIdentifierByID(ctx.Context(), params)
+	existingIdentifier, err := c.service.GetIdentifierByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.RestoreIdentifier(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingIdentifier.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingIdentifier, existingIdentifier.Version, repository.IdentifierDetailFields, false)
+	}
+
+	err = c.service.RestoreIdentifier(auditContext(ctx), req.ID, version)
 	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendIdentifierConflict(ctx, req.ID, 0)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to restore identifier", http.StatusInternalServerError, err.Error(), nil)
 	}
 
@@ -225,14 +385,25 @@ func (c *IdentifierController) ListIdentifiersByAuthUser(ctx *fiber.Ctx) error {
 		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
 	}
 
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.IdentifierListFields, true)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	filters["fields"] = strings.Join(fields, ",")
+
 	identifiers, total, err := c.service.ListIdentifiersByAuthUser(ctx.Context(), filters)
 	if err != nil {
-		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, err.Error(), http.StatusInternalServerError), http.StatusInternalServerError)
+		return utils.SendQueryError(ctx, err)
+	}
+
+	data, err := utils.PickFields(identifiers, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
 	}
 
 	paginationMeta := utils.CreatePaginationMeta(filters, total)
 
-	return utils.GetResponse(ctx, identifiers, paginationMeta, "Identifiers fetched successfully", http.StatusOK, nil, nil)
+	return utils.GetResponse(ctx, data, paginationMeta, "Identifiers fetched successfully", http.StatusOK, nil, nil)
 }
 
 func (c *IdentifierController) GetIdentifierByAuthUser(ctx *fiber.Ctx) error {
@@ -254,16 +425,35 @@ func (c *IdentifierController) GetIdentifierByAuthUser(ctx *fiber.Ctx) error {
 	}
 
 	params := &dtos.GetIdentifierParams{ID: req.ID, UserID: userID}
+	filters := ctx.Locals("filters").(map[string]string)
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.IdentifierDetailFields, true)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	params.Fields = withVersion(fields)
+	params.AsOf, err = asOf(filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
 	identifier, err := c.service.GetIdentifierByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	identifierArray := []interface{}{identifier}
+	data, err := utils.PickFields(identifier, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	identifierArray := []interface{}{data}
 
-	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	// a past version is not something to write against
+	if params.AsOf == nil {
+		ctx.Set(fiber.HeaderETag, utils.ETag(identifier.Version))
+	}
 	return utils.GetResponse(ctx, identifierArray, paginationMeta, "Identifier fetched successfully", http.StatusOK, nil, nil)
 }
 
@@ -296,12 +486,21 @@ func (c *IdentifierController) CreateIdentifierByAuthUser(ctx *fiber.Ctx) error
 		TypeIdentifierID: req.TypeIdentifierID,
 		UserID:           userID,
 		RefNum:           req.RefNum,
-		Status:           req.Status,
+		IssuedAt:         req.IssuedAt,
+		ExpiresAt:        req.ExpiresAt,
 		CreatedAt:        &createdAt,
 		OptionsJSON:      nil,
 	}
 
-	createdIdentifier, err := c.service.CreateIdentifier(ctx.Context(), &identifier)
+	refNumErrors, err := c.checkRefNum(ctx.Context(), &identifier)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Failed to create identifier", http.StatusInternalServerError, err.Error(), nil)
+	}
+	if refNumErrors != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": refNumErrors, "message": "Validation failed", "status": http.StatusBadRequest})
+	}
+
+	createdIdentifier, err := c.service.CreateIdentifier(auditContext(ctx), &identifier)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Failed to create identifier", http.StatusInternalServerError, err.Error(), nil)
 	}
@@ -315,6 +514,8 @@ func (c *IdentifierController) CreateIdentifierByAuthUser(ctx *fiber.Ctx) error
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	ctx.Set(fiber.HeaderETag, utils.ETag(getIdentifier.Version))
+
 	return utils.GetResponse(ctx, []interface{}{getIdentifier}, paginationMeta, "Identifier created successfully", http.StatusCreated, nil, nil)
 }
 
@@ -345,21 +546,42 @@ func (c *IdentifierController) UpdateIdentifierByAuthUser(ctx *fiber.Ctx) error
 		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusNotFound, err.Error(), nil)
 	}
 
+	version, err := expectedVersion(ctx, req.Version, existingIdentifier.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingIdentifier, existingIdentifier.Version, repository.IdentifierDetailFields, true)
+	}
+
 	identifier := models.Identifier{
 		ID:               req.ID,
 		TypeIdentifierID: existingIdentifier.TypeIdentifierID,
 		UserID:           userID,
 		RefNum:           req.RefNum,
-		Status:           req.Status,
+		IssuedAt:         req.IssuedAt,
+		ExpiresAt:        req.ExpiresAt,
 		CreatedAt:        existingIdentifier.CreatedAt,
+		Version:          version,
 	}
 
 	if req.TypeIdentifierID != nil {
 		identifier.TypeIdentifierID = *req.TypeIdentifierID
 	}
 
-	updatedIdentifier, err := c.service.UpdateIdentifier(ctx.Context(), &identifier)
+	refNumErrors, err := c.checkRefNum(ctx.Context(), &identifier)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Failed to update identifier", http.StatusInternalServerError, err.Error(), nil)
+	}
+	if refNumErrors != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": refNumErrors, "message": "Validation failed", "status": http.StatusBadRequest})
+	}
+
+	updatedIdentifier, err := c.service.UpdateIdentifier(auditContext(ctx), &identifier)
 	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendIdentifierConflict(ctx, req.ID, userID)
+		}
+		if errors.Is(err, utils.ErrIdentifierLocked) {
+			return utils.GetResponse(ctx, nil, nil, "Identifier cannot be updated", http.StatusConflict, err.Error(), nil)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to update identifier", http.StatusInternalServerError, err.Error(), nil)
 	}
 
@@ -372,6 +594,8 @@ func (c *IdentifierController) UpdateIdentifierByAuthUser(ctx *fiber.Ctx) error
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	ctx.Set(fiber.HeaderETag, utils.ETag(getIdentifier.Version))
+
 	return utils.GetResponse(ctx, []interface{}{getIdentifier}, paginationMeta, "Identifier updated successfully", http.StatusOK, nil, nil)
 }
 
@@ -395,13 +619,21 @@ func (c *IdentifierController) DeleteIdentifierByAuthUser(ctx *fiber.Ctx) error
 
 	params := &dtos.GetIdentifierParams{ID: req.ID, UserID: userID}
 	// GET identifier by ID
-	_, err = c.service.GetIdentifierByID(ctx.Context(), params)
+	existingIdentifier, err := c.service.GetIdentifierByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.DeleteIdentifier(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingIdentifier.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingIdentifier, existingIdentifier.Version, repository.IdentifierDetailFields, true)
+	}
+
+	err = c.service.DeleteIdentifier(auditContext(ctx), req.ID, version)
 	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendIdentifierConflict(ctx, req.ID, userID)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to delete identifier", http.StatusInternalServerError, err.Error(), nil)
 	}
 
@@ -429,15 +661,493 @@ func (c *IdentifierController) RestoreIdentifierByAuthUser(ctx *fiber.Ctx) error
 	isDeleted := 1
 	params := &dtos.GetIdentifierParams{ID: req.ID, UserID: userID, IsDeleted: &isDeleted}
 	// GET identifier by ID
-	_, err = c.service.GetIdentifierByID(ctx.Context(), params)
+	existingIdentifier, err := c.service.GetIdentifierByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.RestoreIdentifier(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingIdentifier.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingIdentifier, existingIdentifier.Version, repository.IdentifierDetailFields, true)
+	}
+
+	err = c.service.RestoreIdentifier(auditContext(ctx), req.ID, version)
 	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendIdentifierConflict(ctx, req.ID, userID)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to restore identifier", http.StatusInternalServerError, err.Error(), nil)
 	}
 
 	return utils.GetResponse(ctx, nil, nil, "Identifier restored successfully", http.StatusOK, nil, nil)
 }
+
+// SubmitIdentifier submits an identifier for review.
+func (c *IdentifierController) SubmitIdentifier(ctx *fiber.Ctx) error {
+	return c.transitionIdentifier(ctx, utils.IdentifierStatusSubmitted, 0, "Identifier submitted successfully")
+}
+
+// SubmitIdentifierByAuthUser submits an identifier of the authenticated user
+// for review.
+func (c *IdentifierController) SubmitIdentifierByAuthUser(ctx *fiber.Ctx) error {
+	// Extract user ID from JWT
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	return c.transitionIdentifier(ctx, utils.IdentifierStatusSubmitted, uint(claims["user_id"].(float64)), "Identifier submitted successfully")
+}
+
+// ApproveIdentifier verifies a submitted identifier, a reviewer's decision.
+func (c *IdentifierController) ApproveIdentifier(ctx *fiber.Ctx) error {
+	return c.transitionIdentifier(ctx, utils.IdentifierStatusVerified, 0, "Identifier verified successfully")
+}
+
+// RejectIdentifier rejects a submitted identifier with a reason, a reviewer's
+// decision.
+func (c *IdentifierController) RejectIdentifier(ctx *fiber.Ctx) error {
+	return c.transitionIdentifier(ctx, utils.IdentifierStatusRejected, 0, "Identifier rejected successfully")
+}
+
+// transitionIdentifier moves the identifier of the request to status to,
+// limited to the identifiers of userID unless it is 0.
+func (c *IdentifierController) transitionIdentifier(ctx *fiber.Ctx, to string, userID uint, message string) error {
+	var req dtos.IdentifierTransitionRequest
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	if to == utils.IdentifierStatusRejected && (req.Reason == nil || strings.TrimSpace(*req.Reason) == "") {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": map[string]string{"reason": "The reason field is required to reject an identifier"}, "message": "Validation failed", "status": http.StatusBadRequest})
+	}
+
+	params := &dtos.GetIdentifierParams{ID: req.ID, UserID: userID}
+	existingIdentifier, err := c.service.GetIdentifierByID(ctx.Context(), params)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	version, err := expectedVersion(ctx, req.Version, existingIdentifier.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingIdentifier, existingIdentifier.Version, repository.IdentifierDetailFields, userID != 0)
+	}
+
+	if err := c.service.TransitionIdentifier(auditContext(ctx), req.ID, version, to, req.Reason); err != nil {
+		switch {
+		case errors.Is(err, utils.ErrVersionConflict):
+			return c.sendIdentifierConflict(ctx, req.ID, userID)
+		case errors.Is(err, utils.ErrIdentifierTransition):
+			return utils.GetResponse(ctx, nil, nil, "Identifier status cannot change", http.StatusConflict, fmt.Sprintf("a %s identifier cannot become %s", existingIdentifier.Status, to), nil)
+		}
+		return utils.GetResponse(ctx, nil, nil, "Failed to change identifier status", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	getIdentifier, err := c.service.GetIdentifierByID(ctx.Context(), params)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	filters := ctx.Locals("filters").(map[string]string)
+	paginationMeta := utils.CreatePaginationMeta(filters, 1)
+
+	ctx.Set(fiber.HeaderETag, utils.ETag(getIdentifier.Version))
+
+	return utils.GetResponse(ctx, []interface{}{getIdentifier}, paginationMeta, message, http.StatusOK, nil, nil)
+}
+
+// ReverifyIdentifier changes an identifier and submits it for review again,
+// the way a verified identifier is changed.
+func (c *IdentifierController) ReverifyIdentifier(ctx *fiber.Ctx) error {
+	return c.reverifyIdentifier(ctx, 0)
+}
+
+// ReverifyIdentifierByAuthUser changes an identifier of the authenticated user
+// and submits it for review again.
+func (c *IdentifierController) ReverifyIdentifierByAuthUser(ctx *fiber.Ctx) error {
+	// Extract user ID from JWT
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	return c.reverifyIdentifier(ctx, uint(claims["user_id"].(float64)))
+}
+
+// reverifyIdentifier applies the update of the request as a re-verification,
+// limited to the identifiers of userID unless it is 0.
+func (c *IdentifierController) reverifyIdentifier(ctx *fiber.Ctx, userID uint) error {
+	var req dtos.UpdateIdentifierRequest
+
+	if err := utils.BodyParserWithNull(ctx, &req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": err.Error(), "message": "Invalid request", "status": http.StatusBadRequest})
+	}
+
+	if userID != 0 {
+		req.UserID = userID
+	}
+
+	// Validate the request
+	reqValidator := form_requests.NewIdentifierUpdateRequest().Validate(&req, ctx.Context())
+	if reqValidator != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": reqValidator, "message": "Validation failed", "status": http.StatusBadRequest})
+	}
+
+	params := &dtos.GetIdentifierParams{ID: req.ID, UserID: userID}
+	existingIdentifier, err := c.service.GetIdentifierByID(ctx.Context(), params)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	version, err := expectedVersion(ctx, req.Version, existingIdentifier.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingIdentifier, existingIdentifier.Version, repository.IdentifierDetailFields, userID != 0)
+	}
+
+	identifier := models.Identifier{
+		ID:               req.ID,
+		TypeIdentifierID: existingIdentifier.TypeIdentifierID,
+		UserID:           req.UserID,
+		RefNum:           req.RefNum,
+		IssuedAt:         req.IssuedAt,
+		ExpiresAt:        req.ExpiresAt,
+		CreatedAt:        existingIdentifier.CreatedAt,
+		Version:          version,
+	}
+
+	if req.TypeIdentifierID != nil {
+		identifier.TypeIdentifierID = *req.TypeIdentifierID
+	}
+
+	refNumErrors, err := c.checkRefNum(ctx.Context(), &identifier)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Failed to update identifier", http.StatusInternalServerError, err.Error(), nil)
+	}
+	if refNumErrors != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": refNumErrors, "message": "Validation failed", "status": http.StatusBadRequest})
+	}
+
+	if _, err := c.service.ReverifyIdentifier(auditContext(ctx), &identifier); err != nil {
+		switch {
+		case errors.Is(err, utils.ErrVersionConflict):
+			return c.sendIdentifierConflict(ctx, req.ID, userID)
+		case errors.Is(err, utils.ErrIdentifierTransition):
+			return utils.GetResponse(ctx, nil, nil, "Identifier status cannot change", http.StatusConflict, fmt.Sprintf("a %s identifier cannot become %s", existingIdentifier.Status, utils.IdentifierStatusSubmitted), nil)
+		}
+		return utils.GetResponse(ctx, nil, nil, "Failed to update identifier", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	getIdentifier, err := c.service.GetIdentifierByID(ctx.Context(), params)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	filters := ctx.Locals("filters").(map[string]string)
+	paginationMeta := utils.CreatePaginationMeta(filters, 1)
+
+	ctx.Set(fiber.HeaderETag, utils.ETag(getIdentifier.Version))
+
+	return utils.GetResponse(ctx, []interface{}{getIdentifier}, paginationMeta, "Identifier submitted for re-verification", http.StatusOK, nil, nil)
+}
+
+// ListIdentifierTransitions lists the status transitions of an identifier,
+// newest first.
+func (c *IdentifierController) ListIdentifierTransitions(ctx *fiber.Ctx) error {
+	return c.listIdentifierTransitions(ctx, 0)
+}
+
+// ListIdentifierTransitionsByAuthUser lists the status transitions of an
+// identifier of the authenticated user, newest first.
+func (c *IdentifierController) ListIdentifierTransitionsByAuthUser(ctx *fiber.Ctx) error {
+	// Extract user ID from JWT
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	return c.listIdentifierTransitions(ctx, uint(claims["user_id"].(float64)))
+}
+
+func (c *IdentifierController) listIdentifierTransitions(ctx *fiber.Ctx, userID uint) error {
+	var req dtos.GetHistoryRequest
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	filters := ctx.Locals("filters").(map[string]string)
+
+	transitions, total, err := c.service.ListIdentifierTransitions(ctx.Context(), req.ID, userID, filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, transitions, paginationMeta, "Identifier transitions fetched successfully", http.StatusOK, nil, nil)
+}
+
+// ListIdentifierHistory lists the recorded versions of a identifier, newest first.
+func (c *IdentifierController) ListIdentifierHistory(ctx *fiber.Ctx) error {
+	var req dtos.GetHistoryRequest
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	filters := ctx.Locals("filters").(map[string]string)
+
+	history, total, err := c.service.GetIdentifierHistory(ctx.Context(), req.ID, filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, history, paginationMeta, "Identifier history fetched successfully", http.StatusOK, nil, nil)
+}
+
+// RevertIdentifier writes the state of a past version back as a new version.
+func (c *IdentifierController) RevertIdentifier(ctx *fiber.Ctx) error {
+	var req dtos.RevertRequest
+
+	if err := utils.BodyParserWithNull(ctx, &req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": err.Error(), "message": "Invalid request", "status": http.StatusBadRequest})
+	}
+
+	if req.ID == 0 || req.ToVersion == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusBadRequest, "ID and to_version are required", nil)
+	}
+
+	snapshot, err := c.service.GetIdentifierByID(ctx.Context(), &dtos.GetIdentifierParams{ID: req.ID, Version: &req.ToVersion})
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Identifier version not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	update := dtos.UpdateIdentifierRequest{
+		ID:               snapshot.ID,
+		UserID:           snapshot.UserID,
+		TypeIdentifierID: &snapshot.TypeIdentifierID,
+		RefNum:           snapshot.RefNum,
+		Version:          req.Version,
+		IdentifierValidity: dtos.IdentifierValidity{
+			IssuedAt:  snapshot.IssuedAt,
+			ExpiresAt: snapshot.ExpiresAt,
+		},
+	}
+
+	return c.updateIdentifier(ctx, &update, "Identifier reverted successfully")
+}
+
+// BulkCreateIdentifiers creates up to BULK_MAX_ITEMS identifiers in one request.
+func (c *IdentifierController) BulkCreateIdentifiers(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.CreateIdentifierRequest](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	invalid := form_requests.NewIdentifierStoreRequest().ValidateBatch(items, ctx.Context())
+
+	checks := make([]dtos.IdentifierRefNumCheck, len(items))
+	for i, item := range items {
+		checks[i] = dtos.IdentifierRefNumCheck{TypeIdentifierID: item.TypeIdentifierID, RefNum: item.RefNum}
+	}
+	invalid, err = c.checkBulkRefNums(ctx.Context(), checks, invalid)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Bulk request failed", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return handleBulk(ctx, len(items), atomic, invalid, func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusCreated, func(ctx context.Context, i int) (uint, uint, error) {
+			req := items[i]
+			createdAt := time.Now()
+
+			identifier := models.Identifier{
+				TypeIdentifierID: req.TypeIdentifierID,
+				UserID:           req.UserID,
+				RefNum:           req.RefNum,
+				IssuedAt:         req.IssuedAt,
+				ExpiresAt:        req.ExpiresAt,
+				CreatedAt:        &createdAt,
+				OptionsJSON:      nil,
+			}
+
+			createdIdentifier, err := c.service.CreateIdentifier(ctx, &identifier)
+			if err != nil {
+				return 0, 0, err
+			}
+			return createdIdentifier.ID, createdIdentifier.Version, nil
+		})
+	}, "Identifiers created successfully")
+}
+
+// BulkUpdateIdentifiers updates up to BULK_MAX_ITEMS identifiers in one request.
+func (c *IdentifierController) BulkUpdateIdentifiers(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.UpdateIdentifierRequest](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	invalid := form_requests.NewIdentifierUpdateRequest().ValidateBatch(items, ctx.Context())
+
+	checks := make([]dtos.IdentifierRefNumCheck, len(items))
+	for i, item := range items {
+		checks[i] = dtos.IdentifierRefNumCheck{ID: item.ID, RefNum: item.RefNum}
+		if item.TypeIdentifierID != nil {
+			checks[i].TypeIdentifierID = *item.TypeIdentifierID
+		}
+	}
+	invalid, err = c.checkBulkRefNums(ctx.Context(), checks, invalid)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Bulk request failed", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return handleBulk(ctx, len(items), atomic, invalid, func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusUpdated, func(ctx context.Context, i int) (uint, uint, error) {
+			req := items[i]
+
+			existingIdentifier, err := c.service.GetIdentifierByID(ctx, &dtos.GetIdentifierParams{ID: req.ID})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			version, err := bulkVersion(req.Version, existingIdentifier.Version)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			identifier := models.Identifier{
+				ID:               req.ID,
+				TypeIdentifierID: existingIdentifier.TypeIdentifierID,
+				UserID:           req.UserID,
+				RefNum:           req.RefNum,
+				IssuedAt:         req.IssuedAt,
+				ExpiresAt:        req.ExpiresAt,
+				CreatedAt:        existingIdentifier.CreatedAt,
+				Version:          version,
+			}
+
+			if req.TypeIdentifierID != nil {
+				identifier.TypeIdentifierID = *req.TypeIdentifierID
+			}
+
+			updatedIdentifier, err := c.service.UpdateIdentifier(ctx, &identifier)
+			if err != nil {
+				return 0, 0, err
+			}
+			return updatedIdentifier.ID, updatedIdentifier.Version, nil
+		})
+	}, "Identifiers updated successfully")
+}
+
+// BulkDeleteIdentifiers soft deletes up to BULK_MAX_ITEMS identifiers in one request.
+func (c *IdentifierController) BulkDeleteIdentifiers(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.BulkDeleteItem](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	return handleBulk(ctx, len(items), atomic, validateBulkIDs(items), func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusDeleted, func(ctx context.Context, i int) (uint, uint, error) {
+			item := items[i]
+
+			existingIdentifier, err := c.service.GetIdentifierByID(ctx, &dtos.GetIdentifierParams{ID: item.ID})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			version, err := bulkVersion(item.Version, existingIdentifier.Version)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			if err := c.service.DeleteIdentifier(ctx, item.ID, version); err != nil {
+				return 0, 0, err
+			}
+			return item.ID, version + 1, nil
+		})
+	}, "Identifiers deleted successfully")
+}
+
+// BulkRestoreIdentifiers restores up to BULK_MAX_ITEMS soft deleted identifiers in one request.
+func (c *IdentifierController) BulkRestoreIdentifiers(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.BulkDeleteItem](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	return handleBulk(ctx, len(items), atomic, validateBulkIDs(items), func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusRestored, func(ctx context.Context, i int) (uint, uint, error) {
+			item := items[i]
+
+			isDeleted := 1
+			existingIdentifier, err := c.service.GetIdentifierByID(ctx, &dtos.GetIdentifierParams{ID: item.ID, IsDeleted: &isDeleted})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			version, err := bulkVersion(item.Version, existingIdentifier.Version)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			if err := c.service.RestoreIdentifier(ctx, item.ID, version); err != nil {
+				return 0, 0, err
+			}
+			return item.ID, version + 1, nil
+		})
+	}, "Identifiers restored successfully")
+}
+
+// checkRefNum checks the ref_num written to identifier against the validators
+// of its type. It returns the validation errors.
+func (c *IdentifierController) checkRefNum(ctx context.Context, identifier *models.Identifier) (map[string]string, error) {
+	invalid, err := c.service.CheckRefNums(ctx, []dtos.IdentifierRefNumCheck{{
+		ID:               identifier.ID,
+		TypeIdentifierID: identifier.TypeIdentifierID,
+		RefNum:           identifier.RefNum,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if message, ok := invalid[0]; ok {
+		return map[string]string{"ref_num": message}, nil
+	}
+	return nil, nil
+}
+
+// checkBulkRefNums checks the ref_num of the items of a bulk request that
+// passed validation, see checkRefNum, adding its errors to invalid.
+func (c *IdentifierController) checkBulkRefNums(ctx context.Context, checks []dtos.IdentifierRefNumCheck, invalid map[int]map[string]string) (map[int]map[string]string, error) {
+	var valid []dtos.IdentifierRefNumCheck
+	var indexes []int
+	for i, check := range checks {
+		if _, ok := invalid[i]; !ok {
+			valid = append(valid, check)
+			indexes = append(indexes, i)
+		}
+	}
+
+	refNumErrors, err := c.service.CheckRefNums(ctx, valid)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, message := range refNumErrors {
+		if invalid == nil {
+			invalid = make(map[int]map[string]string)
+		}
+		invalid[indexes[j]] = map[string]string{"ref_num": message}
+	}
+	return invalid, nil
+}
@@ -0,0 +1,141 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+type ImportController struct {
+	service *service.ImportService
+}
+
+func NewImportController(service *service.ImportService) *ImportController {
+	return &ImportController{service: service}
+}
+
+// UploadImport takes a multipart form with the spreadsheet as file, the
+// entity it holds and optionally mapping, a JSON object of header to field.
+// Nothing is written but the import job: its report lists the rows that
+// would fail and commit-import writes the others.
+func (c *ImportController) UploadImport(ctx *fiber.Ctx) error {
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, "file is required", nil)
+	}
+
+	var mapping map[string]string
+	if raw := ctx.FormValue("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, fmt.Sprintf("mapping: %v", err), nil)
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	job, err := c.service.UploadImport(auditContext(ctx), ctx.FormValue("entity"), header.Filename, data, mapping)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrImportTooLarge):
+			return utils.GetResponse(ctx, nil, nil, "Too many rows", http.StatusRequestEntityTooLarge, err.Error(), nil)
+		case errors.Is(err, service.ErrInvalidImport):
+			return utils.GetResponse(ctx, nil, nil, "Invalid import", http.StatusBadRequest, err.Error(), nil)
+		}
+		return utils.GetResponse(ctx, nil, nil, "Failed to upload import", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return utils.GetResponse(ctx, []interface{}{job}, nil, "Import validated successfully", http.StatusCreated, nil, nil)
+}
+
+func (c *ImportController) GetImportJob(ctx *fiber.Ctx) error {
+	var req dtos.GetImportJobRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Import not found", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Import not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	job, err := c.service.GetImportJob(ctx.Context(), req.ID)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Import not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	return utils.GetResponse(ctx, []interface{}{job}, nil, "Import fetched successfully", http.StatusOK, nil, nil)
+}
+
+// DownloadImportReport sends the report of an import job as a CSV file of
+// row, field and error.
+func (c *ImportController) DownloadImportReport(ctx *fiber.Ctx) error {
+	var req dtos.GetImportJobRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Import not found", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Import not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	report, err := c.service.GetImportReport(ctx.Context(), req.ID)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Import not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"row", "field", "error"})
+	for _, line := range report {
+		w.Write([]string{strconv.Itoa(line.Row), line.Field, line.Error})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Failed to write report", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	ctx.Set(fiber.HeaderContentType, "text/csv")
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="import-%d-report.csv"`, req.ID))
+	return ctx.Status(http.StatusOK).Send(buf.Bytes())
+}
+
+// CommitImport queues a validated import job; show-import reports its
+// progress.
+func (c *ImportController) CommitImport(ctx *fiber.Ctx) error {
+	var req dtos.GetImportJobRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Import not found", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Import not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	job, err := c.service.CommitImport(auditContext(ctx), req.ID)
+	if err != nil {
+		if errors.Is(err, utils.ErrImportStatus) {
+			return utils.GetResponse(ctx, nil, nil, "Import cannot be committed", http.StatusConflict, "only a validated import can be committed", nil)
+		}
+		return utils.GetResponse(ctx, nil, nil, "Failed to commit import", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return utils.GetResponse(ctx, []interface{}{job}, nil, "Import queued successfully", http.StatusAccepted, nil, nil)
+}
@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+// usersLoader loads users by ID, it is implemented by the child services.
+type usersLoader func(ctx context.Context, ids []uint) (map[uint]dtos.UserListDTO, error)
+
+// nestUser embeds the owning user (include=user) into the picked child rows,
+// userIDs[i] being the user ID of the i-th row.
+func nestUser(ctx *fiber.Ctx, data interface{}, userIDs []uint, load usersLoader) error {
+	users, err := load(ctx.Context(), userIDs)
+	if err != nil {
+		return err
+	}
+
+	fields, err := utils.SelectFields(ctx, "", repository.UserListFields, false)
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(userIDs))
+	for i, userID := range userIDs {
+		user, ok := users[userID]
+		if !ok {
+			continue
+		}
+		if values[i], err = utils.PickFields(user, fields); err != nil {
+			return err
+		}
+	}
+
+	utils.NestInto(data, "user", values)
+	return nil
+}
+
+// nestUserIncludes embeds the relations requested with `include` into the
+// picked users, userIDs[i] being the ID of the i-th user. Nested rows are
+// narrowed to the fields the caller may read by default.
+func (c *UserController) nestUserIncludes(ctx *fiber.Ctx, data interface{}, userIDs []uint, includes []string) error {
+	related, err := c.service.GetUserIncludes(ctx.Context(), userIDs, includes)
+	if err != nil {
+		return err
+	}
+
+	for _, include := range includes {
+		var fields []string
+		switch include {
+		case "contacts":
+			fields, err = utils.SelectFields(ctx, "", repository.ContactListFields, false)
+		case "addresses":
+			fields, err = utils.SelectFields(ctx, "", repository.AddressListFields, false)
+		case "identifiers":
+			fields, err = utils.SelectFields(ctx, "", repository.IdentifierListFields, false)
+		}
+		if err != nil {
+			return err
+		}
+
+		values := make([]interface{}, len(userIDs))
+		for i, userID := range userIDs {
+			var rows interface{}
+			switch include {
+			case "contacts":
+				rows = append([]dtos.ContactListDTO{}, related.Contacts[userID]...)
+			case "addresses":
+				rows = append([]dtos.AddressListDTO{}, related.Addresses[userID]...)
+			case "identifiers":
+				rows = append([]dtos.IdentifierListDTO{}, related.Identifiers[userID]...)
+			case "roles":
+				values[i] = append([]string{}, related.Roles[userID]...)
+				continue
+			}
+
+			if values[i], err = utils.PickFields(rows, fields); err != nil {
+				return err
+			}
+		}
+
+		utils.NestInto(data, include, values)
+	}
+
+	return nil
+}
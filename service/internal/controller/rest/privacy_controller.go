@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+type PrivacyController struct {
+	service *service.PrivacyService
+}
+
+func NewPrivacyController(service *service.PrivacyService) *PrivacyController {
+	return &PrivacyController{service: service}
+}
+
+// ExportPersonalData sends everything held about a user as a JSON document,
+// or a ZIP archive of one document per kind of data with format=zip. Users
+// may export their own data, anyone else's takes manage_personal_data.
+func (c *PrivacyController) ExportPersonalData(ctx *fiber.Ctx) error {
+	var req dtos.ExportPersonalDataRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	if uint(claims["user_id"].(float64)) != req.ID && !utils.HasPermission(ctx, utils.PermissionManagePersonalData) {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Forbidden"})
+	}
+
+	format, err := utils.ParsePersonalDataFormat(req.Format)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	bundle, err := c.service.GetPersonalData(ctx.Context(), req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusNotFound, err.Error(), nil)
+		}
+		return utils.GetResponse(ctx, nil, nil, "Failed to export personal data", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	fileName := fmt.Sprintf("user-%d-personal-data-%s.%s", req.ID, bundle.GeneratedAt.Format("20060102150405"), format)
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, fileName))
+
+	if format == utils.PersonalDataFormatJSON {
+		return ctx.Status(http.StatusOK).JSON(bundle)
+	}
+
+	var buf bytes.Buffer
+	if err := utils.WriteJSONZip(&buf, c.service.PersonalDataFiles(bundle), bundle.GeneratedAt); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Failed to export personal data", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	ctx.Set(fiber.HeaderContentType, "application/zip")
+	return ctx.Status(http.StatusOK).Send(buf.Bytes())
+}
+
+// EraseUser anonymises the personal data of a user, live or deleted, in
+// place of deleting it. It cannot be undone, so the request has to confirm
+// it.
+func (c *PrivacyController) EraseUser(ctx *fiber.Ctx) error {
+	var req dtos.EraseUserRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	if !req.Confirm {
+		return utils.GetResponse(ctx, nil, nil, "Erasure not confirmed", http.StatusBadRequest, "confirm must be true, an erasure cannot be undone", nil)
+	}
+
+	version, err := utils.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	if version == nil {
+		version = req.Version
+	}
+
+	erasure, err := c.service.EraseUser(auditContext(ctx), req.ID, version, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusNotFound, err.Error(), nil)
+		case errors.Is(err, utils.ErrUserErased):
+			return utils.GetResponse(ctx, nil, nil, "User already erased", http.StatusConflict, err.Error(), nil)
+		case errors.Is(err, utils.ErrVersionConflict):
+			return utils.GetResponse(ctx, nil, nil, "Version conflict", http.StatusConflict, err.Error(), nil)
+		}
+		return utils.GetResponse(ctx, nil, nil, "Failed to erase user", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return utils.GetResponse(ctx, []interface{}{erasure}, nil, "User erased successfully", http.StatusOK, nil, nil)
+}
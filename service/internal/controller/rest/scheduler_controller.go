@@ -2,25 +2,34 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/hibiken/asynq"
 	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/config"
 	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
 	"github.com/nibroos/nb-go-api/service/internal/scheduler"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/storage"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
 	"github.com/robfig/cron/v3"
 	"gorm.io/gorm"
 )
 
 type SchedulerController struct {
-	Cron  *cron.Cron
-	DB    *gorm.DB
-	SqlDB *sqlx.DB
+	Cron      *cron.Cron
+	DB        *gorm.DB
+	SqlDB     *sqlx.DB
+	processes map[string]func()
 }
 
 type ScheduleRequest struct {
+	Version     *uint      `json:"version"` // of the scheduler to stop, If-Match otherwise
 	StartAt     time.Time  `json:"start_at"`
 	EndAt       *time.Time `json:"end_at,omitempty"`
 	Cron        string     `json:"cron"`
@@ -39,7 +48,33 @@ var availableProcesses = map[string]func(){
 }
 
 func NewSchedulerController(cron *cron.Cron, db *gorm.DB, sqlDB *sqlx.DB) *SchedulerController {
-	return &SchedulerController{Cron: cron, DB: db, SqlDB: sqlDB}
+	trashService := service.NewTrashService(repository.NewTrashRepository(db, sqlDB), repository.NewAuditLogRepository(db, sqlDB))
+	idempotencyRepo := repository.NewIdempotencyRepository(db, sqlDB)
+	contactService := service.NewContactService(repository.NewContactRepository(db, sqlDB))
+	identifierService := service.NewIdentifierService(repository.NewIdentifierRepository(db, sqlDB))
+	// identifier expiry reminders are sent by the worker
+	queue := asynq.NewClient(config.GetAsynqRedisOpt())
+
+	// processes needing the database on top of availableProcesses
+	processes := map[string]func(){
+		"purge_trash":                 func() { scheduler.PurgeTrash(trashService) },
+		"purge_idempotency_keys":      func() { scheduler.PurgeIdempotencyKeys(idempotencyRepo) },
+		"normalize_contacts":          func() { scheduler.NormalizeContacts(contactService) },
+		"report_invalid_identifiers":  func() { scheduler.ReportInvalidIdentifiers(identifierService) },
+		"remind_expiring_identifiers": func() { scheduler.RemindExpiringIdentifiers(identifierService, queue) },
+		"expire_identifiers":          func() { scheduler.ExpireIdentifiers(identifierService) },
+	}
+	if store, err := storage.New(); err != nil {
+		log.Printf("Failed to set up the storage, purge_orphan_files is unavailable: %v", err)
+	} else {
+		fileService := service.NewFileService(repository.NewFileRepository(db, sqlDB), store)
+		processes["purge_orphan_files"] = func() { scheduler.PurgeOrphanFiles(fileService) }
+	}
+	for name, process := range availableProcesses {
+		processes[name] = process
+	}
+
+	return &SchedulerController{Cron: cron, DB: db, SqlDB: sqlDB, processes: processes}
 }
 
 func (sc *SchedulerController) Schedule(c *fiber.Ctx) error {
@@ -60,7 +95,7 @@ func (sc *SchedulerController) Schedule(c *fiber.Ctx) error {
 
 func (sc *SchedulerController) startTask(c *fiber.Ctx, req ScheduleRequest) error {
 	// Validate process name
-	task, exists := availableProcesses[req.Name]
+	task, exists := sc.processes[req.Name]
 	if !exists {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid process name", "message": "Process not found"})
 	}
@@ -116,20 +151,38 @@ func (sc *SchedulerController) startTask(c *fiber.Ctx, req ScheduleRequest) erro
 }
 
 func (sc *SchedulerController) StopCron(name string) error {
+	scheduler, err := sc.runningScheduler(name)
+	if err != nil {
+		return err
+	}
+	return sc.stopScheduler(scheduler)
+}
+
+// runningScheduler returns the running scheduler of process name.
+func (sc *SchedulerController) runningScheduler(name string) (*models.Scheduler, error) {
 	var scheduler models.Scheduler
 	if err := sc.DB.Where("name = ? AND status = ?", name, "running").First(&scheduler).Error; err != nil {
 		log.Printf("Failed to find scheduler: %v", err)
-		return err
+		return nil, err
 	}
+	return &scheduler, nil
+}
 
-	// Use the stored EntryID to remove the cron job
-	sc.Cron.Remove(cron.EntryID(scheduler.EntryID))
-	scheduler.Status = "stopped"
-	scheduler.UpdatedAt = time.Now()
-	if err := sc.DB.Save(&scheduler).Error; err != nil {
-		return err
+// stopScheduler stops scheduler if it is still at the version it was read
+// at, utils.ErrVersionConflict otherwise: it was stopped or reloaded since.
+func (sc *SchedulerController) stopScheduler(scheduler *models.Scheduler) error {
+	result := sc.DB.Model(&models.Scheduler{}).
+		Where("id = ? AND version = ?", scheduler.ID, scheduler.Version).
+		Updates(map[string]interface{}{"status": "stopped", "updated_at": time.Now(), "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrVersionConflict
 	}
 
+	// Use the stored EntryID to remove the cron job
+	sc.Cron.Remove(cron.EntryID(scheduler.EntryID))
 	return nil
 }
 func (sc *SchedulerController) ReloadSchedules() error {
@@ -184,7 +237,7 @@ func (sc *SchedulerController) ReloadSchedules() error {
 	// Save the new schedulers
 	if len(newSchedulers) > 0 {
 		for _, scheduler := range newSchedulers {
-			task, exists := availableProcesses[scheduler.Name]
+			task, exists := sc.processes[scheduler.Name]
 			if !exists {
 				continue
 			}
@@ -212,11 +265,28 @@ func (sc *SchedulerController) ReloadSchedules() error {
 }
 
 func (sc *SchedulerController) stopTask(c *fiber.Ctx, req ScheduleRequest) error {
-	if err := sc.StopCron(req.Name); err != nil {
-		log.Printf("Failed to stop task: %v", err)
+	scheduler, err := sc.runningScheduler(req.Name)
+	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error(), "message": "Failed to stop task"})
 	}
 
+	_, err = expectedVersion(c, req.Version, scheduler.Version)
+	if err == nil {
+		err = sc.stopScheduler(scheduler)
+	}
+	if errors.Is(err, utils.ErrVersionConflict) {
+		var current models.Scheduler
+		if sc.DB.First(&current, scheduler.ID).Error == nil {
+			scheduler = &current
+		}
+		c.Set(fiber.HeaderETag, utils.ETag(scheduler.Version))
+		return utils.GetResponse(c, []interface{}{scheduler}, nil, "Version conflict", http.StatusConflict, err.Error(), nil)
+	}
+	if err != nil {
+		log.Printf("Failed to stop task: %v", err)
+		return utils.SendQueryError(c, err)
+	}
+
 	return c.JSON(fiber.Map{"message": "Task stopped successfully"})
 }
 
@@ -0,0 +1,64 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+type SearchController struct {
+	service *service.SearchService
+}
+
+func NewSearchController(service *service.SearchService) *SearchController {
+	return &SearchController{service: service}
+}
+
+// Search looks up users, contacts, addresses and identifiers matching `q`.
+// Hits are limited to records the caller could open through the resource
+// endpoints: identifiers of other users require the read_identifiers
+// permission.
+func (c *SearchController) Search(ctx *fiber.Ctx) error {
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	userID := uint(claims["user_id"].(float64))
+
+	filters, ok := ctx.Locals("filters").(map[string]string)
+	if !ok {
+		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
+	}
+
+	query := utils.SearchQuery(filters["q"])
+	if query == "" {
+		return utils.GetResponse(ctx, nil, nil, "Invalid search", http.StatusBadRequest, "q is required", nil)
+	}
+
+	types, err := utils.ParseSearchTypes(filters["types"])
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	params := &dtos.SearchParams{
+		Query:           query,
+		Types:           types,
+		UserID:          userID,
+		ReadIdentifiers: utils.HasPermission(ctx, utils.PermissionReadIdentifiers),
+		Page:            utils.GetIntOrDefault(filters["page"], 1),
+		PerPage:         utils.GetIntOrDefault(filters["per_page"], 10),
+	}
+
+	hits, facets, total, err := c.service.Search(ctx.Context(), params)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, hits, paginationMeta, "Search results fetched successfully", http.StatusOK, nil, fiber.Map{"facets": facets})
+}
@@ -31,6 +31,15 @@ func (c *SeederController) RunSeeders(ctx *fiber.Ctx) error {
 		"20241105045641_create_mix_values_identifier_seeder.sql",
 		"20241105045650_create_mix_values_contact_seeder.sql",
 		"20241105045700_create_mix_values_address_seeder.sql",
+		"20261019103000_create_read_identifiers_permission_seeder.sql",
+		"20261019130000_create_read_audit_logs_permission_seeder.sql",
+		"20261019140000_create_import_data_permission_seeder.sql",
+		"20261019153000_create_manage_personal_data_permission_seeder.sql",
+		"20261019160000_create_merge_users_permission_seeder.sql",
+		"20261019173000_create_contact_formats_seeder.sql",
+		"20261019180000_create_identifier_validators_seeder.sql",
+		"20261019190000_create_verify_identifiers_permission_seeder.sql",
+		"20261019200000_create_manage_attachments_permission_seeder.sql",
 	}
 
 	// Get the seed files directory from the environment variable
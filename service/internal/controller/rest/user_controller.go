@@ -1,12 +1,18 @@
 package rest
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/nibroos/nb-go-api/service/internal/dtos"
 	"github.com/nibroos/nb-go-api/service/internal/middleware"
 	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
 	"github.com/nibroos/nb-go-api/service/internal/service"
 	"github.com/nibroos/nb-go-api/service/internal/utils"
 	"github.com/nibroos/nb-go-api/service/internal/validators/form_requests"
@@ -26,14 +32,91 @@ func (c *UserController) GetUsers(ctx *fiber.Ctx) error {
 		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
 	}
 
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.UserListFields, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	// fuzzy matches always report their similarity
+	if filters["match"] == "fuzzy" && filters["global"] != "" && !utils.HasField(fields, "score") {
+		fields = append(fields, "score")
+	}
+	filters["fields"] = strings.Join(fields, ",")
+
+	includes, err := utils.ParseIncludes(filters["include"], repository.UserIncludeRelations)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
 	users, total, err := c.service.GetUsers(ctx.Context(), filters)
 	if err != nil {
-		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, err.Error(), http.StatusInternalServerError), http.StatusInternalServerError)
+		return utils.SendQueryError(ctx, err)
+	}
+
+	data, err := utils.PickFields(users, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	if len(includes) > 0 {
+		userIDs := make([]uint, 0, len(users))
+		for _, user := range users {
+			userIDs = append(userIDs, uint(user.ID))
+		}
+		if err := c.nestUserIncludes(ctx, data, userIDs, includes); err != nil {
+			return utils.SendQueryError(ctx, err)
+		}
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, data, paginationMeta, "Users fetched successfully", http.StatusOK, nil, nil)
+}
+
+// GetTrashedUsers lists the soft deleted users with the filters of index-user.
+func (c *UserController) GetTrashedUsers(ctx *fiber.Ctx) error {
+	filters, ok := ctx.Locals("filters").(map[string]string)
+	if !ok {
+		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
+	}
+
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.UserTrashFields, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	// fuzzy matches always report their similarity
+	if filters["match"] == "fuzzy" && filters["global"] != "" && !utils.HasField(fields, "score") {
+		fields = append(fields, "score")
+	}
+	filters["fields"] = strings.Join(fields, ",")
+
+	includes, err := utils.ParseIncludes(filters["include"], repository.UserIncludeRelations)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	users, total, err := c.service.GetTrashedUsers(ctx.Context(), filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	data, err := utils.PickFields(users, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	if len(includes) > 0 {
+		userIDs := make([]uint, 0, len(users))
+		for _, user := range users {
+			userIDs = append(userIDs, uint(user.ID))
+		}
+		if err := c.nestUserIncludes(ctx, data, userIDs, includes); err != nil {
+			return utils.SendQueryError(ctx, err)
+		}
 	}
 
 	paginationMeta := utils.CreatePaginationMeta(filters, total)
 
-	return utils.GetResponse(ctx, users, paginationMeta, "Users fetched successfully", http.StatusOK, nil, nil)
+	return utils.GetResponse(ctx, data, paginationMeta, "Trashed users fetched successfully", http.StatusOK, nil, nil)
 }
 func (c *UserController) CreateUser(ctx *fiber.Ctx) error {
 	var req dtos.CreateUserRequest
@@ -59,7 +142,7 @@ func (c *UserController) CreateUser(ctx *fiber.Ctx) error {
 		Address:  req.Address,
 	}
 
-	createdUser, err := c.service.CreateUser(ctx.Context(), &user, req.RoleIDs)
+	createdUser, err := c.service.CreateUser(auditContext(ctx), &user, req.RoleIDs)
 	if err != nil {
 		if err.Error() == "username already exists" {
 			return ctx.Status(http.StatusConflict).JSON(fiber.Map{"errors": err.Error(), "message": "Username already exists", "status": http.StatusConflict})
@@ -76,6 +159,8 @@ func (c *UserController) CreateUser(ctx *fiber.Ctx) error {
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
+	ctx.Set(fiber.HeaderETag, utils.ETag(getUser.Version))
+
 	return utils.GetResponse(ctx, []interface{}{getUser}, paginationMeta, "User created successfully", http.StatusCreated, nil, nil)
 }
 func (c *UserController) GetUserByID(ctx *fiber.Ctx) error {
@@ -89,18 +174,75 @@ func (c *UserController) GetUserByID(ctx *fiber.Ctx) error {
 		return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusBadRequest, "ID is required", nil)
 	}
 
-	params := &dtos.GetUserByIDParams{ID: req.ID}
+	return c.getUserByID(ctx, req.ID)
+}
+
+// GetMe shows the authenticated user as show-user does.
+func (c *UserController) GetMe(ctx *fiber.Ctx) error {
+	claims, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Unauthorized", http.StatusUnauthorized, err.Error(), nil)
+	}
+	return c.getUserByID(ctx, uint(claims["user_id"].(float64)))
+}
+
+func (c *UserController) getUserByID(ctx *fiber.Ctx, id uint) error {
+	params := &dtos.GetUserByIDParams{ID: id}
+	filters := ctx.Locals("filters").(map[string]string)
+	fields, err := utils.SelectFields(ctx, filters["fields"], repository.UserDetailFields, false)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+	params.Fields = withVersion(fields)
+	params.AsOf, err = asOf(filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	includes, err := utils.ParseIncludes(filters["include"], repository.UserIncludeRelations)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	message := "User fetched successfully"
 	user, err := c.service.GetUserByID(ctx.Context(), params)
+	if errors.Is(err, sql.ErrNoRows) && params.AsOf == nil {
+		// a user merged into another one resolves to the survivor
+		targetID, merged, mergeErr := c.service.GetMergedUserID(ctx.Context(), id)
+		if mergeErr != nil {
+			return utils.SendQueryError(ctx, mergeErr)
+		}
+		if merged {
+			params.ID = targetID
+			user, err = c.service.GetUserByID(ctx.Context(), params)
+			message = fmt.Sprintf("User %d was merged into user %d", id, targetID)
+		}
+	}
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	userArray := []interface{}{user}
+	data, err := utils.PickFields(user, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	if len(includes) > 0 {
+		if err := c.nestUserIncludes(ctx, data, []uint{user.ID}, includes); err != nil {
+			return utils.SendQueryError(ctx, err)
+		}
+	}
+
+	userArray := []interface{}{data}
 
-	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
-	return utils.GetResponse(ctx, userArray, paginationMeta, "User fetched successfully", http.StatusOK, nil, nil)
+	// a past version is not something to write against
+	if params.AsOf == nil {
+		ctx.Set(fiber.HeaderETag, utils.ETag(user.Version))
+	}
+
+	return utils.GetResponse(ctx, userArray, paginationMeta, message, http.StatusOK, nil, nil)
 }
 
 // update user
@@ -111,8 +253,14 @@ func (c *UserController) UpdateUser(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": err.Error(), "message": "Invalid request", "status": http.StatusBadRequest})
 	}
 
+	return c.updateUser(ctx, &req, "User updated successfully")
+}
+
+// updateUser validates req and applies it, the path update-user and
+// revert-user share.
+func (c *UserController) updateUser(ctx *fiber.Ctx, req *dtos.UpdateUserRequest, message string) error {
 	// Validate the request
-	reqValidator := form_requests.NewUserdUpdateRequest().Validate(&req, ctx.Context())
+	reqValidator := form_requests.NewUserdUpdateRequest().Validate(req, ctx.Context())
 	if reqValidator != nil {
 		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": reqValidator, "message": "Validation failed", "status": http.StatusBadRequest})
 	}
@@ -123,6 +271,11 @@ func (c *UserController) UpdateUser(ctx *fiber.Ctx) error {
 		return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusNotFound, err.Error(), nil)
 	}
 
+	version, err := expectedVersion(ctx, req.Version, existingUser.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingUser, existingUser.Version, repository.UserDetailFields, false)
+	}
+
 	user := models.User{
 		ID:       req.ID,
 		Name:     req.Name,
@@ -130,6 +283,7 @@ func (c *UserController) UpdateUser(ctx *fiber.Ctx) error {
 		Email:    req.Email,
 		Address:  req.Address,
 		Password: *existingUser.Password,
+		Version:  version,
 	}
 
 	// Update password only if a new one is provided
@@ -141,8 +295,11 @@ func (c *UserController) UpdateUser(ctx *fiber.Ctx) error {
 		user.Password = hashedPassword
 	}
 
-	updatedUser, err := c.service.UpdateUser(ctx.Context(), &user, req.RoleIDs)
+	updatedUser, err := c.service.UpdateUser(auditContext(ctx), &user, req.RoleIDs)
 	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendUserConflict(ctx, req.ID)
+		}
 		if err.Error() == "username already exists" {
 			return ctx.Status(http.StatusConflict).JSON(fiber.Map{"errors": err.Error(), "message": "Username already exists", "status": http.StatusConflict})
 		}
@@ -158,7 +315,9 @@ func (c *UserController) UpdateUser(ctx *fiber.Ctx) error {
 	filters := ctx.Locals("filters").(map[string]string)
 	paginationMeta := utils.CreatePaginationMeta(filters, 1)
 
-	return utils.GetResponse(ctx, getUser, paginationMeta, "User updated successfully", http.StatusOK, nil, nil)
+	ctx.Set(fiber.HeaderETag, utils.ETag(getUser.Version))
+
+	return utils.GetResponse(ctx, getUser, paginationMeta, message, http.StatusOK, nil, nil)
 }
 
 func (c *UserController) Login(ctx *fiber.Ctx) error {
@@ -203,7 +362,7 @@ func (c *UserController) Register(ctx *fiber.Ctx) error {
 
 	roleIDS := []uint32{utils.RoleStudent}
 
-	createdUser, err := c.service.CreateUser(ctx.Context(), &user, roleIDS)
+	createdUser, err := c.service.CreateUser(auditContext(ctx), &user, roleIDS)
 	if err != nil {
 		if err.Error() == "username already exists" {
 			return ctx.Status(http.StatusConflict).JSON(fiber.Map{"errors": err.Error(), "message": "Username already exists", "status": http.StatusConflict})
@@ -244,13 +403,21 @@ func (c *UserController) DeleteUser(ctx *fiber.Ctx) error {
 
 	params := &dtos.GetUserByIDParams{ID: req.ID}
 	// GET user by ID
-	_, err := c.service.GetUserByID(ctx.Context(), params)
+	existingUser, err := c.service.GetUserByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.DeleteUser(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingUser.Version)
 	if err != nil {
+		return sendVersionError(ctx, err, existingUser, existingUser.Version, repository.UserDetailFields, false)
+	}
+
+	err = c.service.DeleteUser(auditContext(ctx), req.ID, version)
+	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendUserConflict(ctx, req.ID)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to delete user", http.StatusInternalServerError, err.Error(), nil)
 	}
 
@@ -272,15 +439,230 @@ func (c *UserController) RestoreUser(ctx *fiber.Ctx) error {
 	isDeleted := 1
 	params := &dtos.GetUserByIDParams{ID: req.ID, IsDeleted: &isDeleted}
 	// GET user by ID
-	_, err := c.service.GetUserByID(ctx.Context(), params)
+	existingUser, err := c.service.GetUserByID(ctx.Context(), params)
 	if err != nil {
 		return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusNotFound, err.Error(), nil)
 	}
 
-	err = c.service.RestoreUser(ctx.Context(), req.ID)
+	version, err := expectedVersion(ctx, req.Version, existingUser.Version)
+	if err != nil {
+		return sendVersionError(ctx, err, existingUser, existingUser.Version, repository.UserDetailFields, false)
+	}
+
+	err = c.service.RestoreUser(auditContext(ctx), req.ID, version)
 	if err != nil {
+		if errors.Is(err, utils.ErrVersionConflict) {
+			return c.sendUserConflict(ctx, req.ID)
+		}
 		return utils.GetResponse(ctx, nil, nil, "Failed to restore user", http.StatusInternalServerError, err.Error(), nil)
 	}
 
 	return utils.GetResponse(ctx, nil, nil, "User restored successfully", http.StatusOK, nil, nil)
 }
+
+// GetUserHistory lists the recorded versions of a user, newest first.
+func (c *UserController) GetUserHistory(ctx *fiber.Ctx) error {
+	var req dtos.GetHistoryRequest
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.ID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusBadRequest, "ID is required", nil)
+	}
+
+	filters := ctx.Locals("filters").(map[string]string)
+
+	history, total, err := c.service.GetUserHistory(ctx.Context(), req.ID, filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, history, paginationMeta, "User history fetched successfully", http.StatusOK, nil, nil)
+}
+
+// RevertUser writes the state of a past version back as a new version. The
+// password is never reverted, and roles are not versioned: they are kept
+// unless role_ids is given.
+func (c *UserController) RevertUser(ctx *fiber.Ctx) error {
+	var req dtos.RevertUserRequest
+
+	if err := utils.BodyParserWithNull(ctx, &req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"errors": err.Error(), "message": "Invalid request", "status": http.StatusBadRequest})
+	}
+
+	if req.ID == 0 || req.ToVersion == 0 {
+		return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusBadRequest, "ID and to_version are required", nil)
+	}
+
+	snapshot, err := c.service.GetUserByID(ctx.Context(), &dtos.GetUserByIDParams{ID: req.ID, Version: &req.ToVersion})
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "User version not found", http.StatusNotFound, err.Error(), nil)
+	}
+
+	roleIDs := req.RoleIDs
+	if roleIDs == nil {
+		roleIDs, err = c.service.GetRoleIDsByUserID(ctx.Context(), req.ID)
+		if err != nil {
+			return utils.SendQueryError(ctx, err)
+		}
+	}
+
+	update := dtos.UpdateUserRequest{
+		ID:       snapshot.ID,
+		Username: snapshot.Username,
+		Name:     snapshot.Name,
+		Email:    snapshot.Email,
+		Address:  snapshot.Address,
+		RoleIDs:  roleIDs,
+		Version:  req.Version,
+	}
+
+	return c.updateUser(ctx, &update, "User reverted successfully")
+}
+
+// BulkCreateUsers creates up to BULK_MAX_ITEMS users in one request.
+func (c *UserController) BulkCreateUsers(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.CreateUserRequest](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	invalid := form_requests.NewUserStoreRequest().ValidateBatch(items, ctx.Context())
+
+	return handleBulk(ctx, len(items), atomic, invalid, func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusCreated, func(ctx context.Context, i int) (uint, uint, error) {
+			req := items[i]
+
+			// CreateUser hashes the password
+			user := models.User{
+				Name:     req.Name,
+				Username: req.Username,
+				Email:    req.Email,
+				Password: req.Password,
+				Address:  req.Address,
+			}
+
+			createdUser, err := c.service.CreateUser(ctx, &user, req.RoleIDs)
+			if err != nil {
+				return 0, 0, err
+			}
+			return createdUser.ID, createdUser.Version, nil
+		})
+	}, "Users created successfully")
+}
+
+// BulkUpdateUsers updates up to BULK_MAX_ITEMS users in one request.
+func (c *UserController) BulkUpdateUsers(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.UpdateUserRequest](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	invalid := form_requests.NewUserdUpdateRequest().ValidateBatch(items, ctx.Context())
+
+	return handleBulk(ctx, len(items), atomic, invalid, func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusUpdated, func(ctx context.Context, i int) (uint, uint, error) {
+			req := items[i]
+
+			existingUser, err := c.service.GetUserByID(ctx, &dtos.GetUserByIDParams{ID: req.ID})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			version, err := bulkVersion(req.Version, existingUser.Version)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			user := models.User{
+				ID:       req.ID,
+				Name:     req.Name,
+				Username: req.Username,
+				Email:    req.Email,
+				Address:  req.Address,
+				Password: *existingUser.Password,
+				Version:  version,
+			}
+
+			// Update password only if a new one is provided
+			if req.Password != nil && *req.Password != "" {
+				hashedPassword, err := utils.HashPassword(*req.Password)
+				if err != nil {
+					return 0, 0, err
+				}
+				user.Password = hashedPassword
+			}
+
+			updatedUser, err := c.service.UpdateUser(ctx, &user, req.RoleIDs)
+			if err != nil {
+				return 0, 0, err
+			}
+			return updatedUser.ID, updatedUser.Version, nil
+		})
+	}, "Users updated successfully")
+}
+
+// BulkDeleteUsers soft deletes up to BULK_MAX_ITEMS users, with their
+// children, in one request.
+func (c *UserController) BulkDeleteUsers(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.BulkDeleteItem](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	return handleBulk(ctx, len(items), atomic, validateBulkIDs(items), func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusDeleted, func(ctx context.Context, i int) (uint, uint, error) {
+			item := items[i]
+
+			existingUser, err := c.service.GetUserByID(ctx, &dtos.GetUserByIDParams{ID: item.ID})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			version, err := bulkVersion(item.Version, existingUser.Version)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			if err := c.service.DeleteUser(ctx, item.ID, version); err != nil {
+				return 0, 0, err
+			}
+			return item.ID, version + 1, nil
+		})
+	}, "Users deleted successfully")
+}
+
+// BulkRestoreUsers restores up to BULK_MAX_ITEMS soft deleted users, with the
+// children deleted along with them, in one request.
+func (c *UserController) BulkRestoreUsers(ctx *fiber.Ctx) error {
+	items, atomic, err := parseBulk[dtos.BulkDeleteItem](ctx)
+	if err != nil {
+		return sendBulkParseError(ctx, err)
+	}
+
+	return handleBulk(ctx, len(items), atomic, validateBulkIDs(items), func(results []dtos.BulkItemResult) error {
+		return c.service.RunBulk(auditContext(ctx), results, atomic, utils.BulkStatusRestored, func(ctx context.Context, i int) (uint, uint, error) {
+			item := items[i]
+
+			isDeleted := 1
+			existingUser, err := c.service.GetUserByID(ctx, &dtos.GetUserByIDParams{ID: item.ID, IsDeleted: &isDeleted})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			version, err := bulkVersion(item.Version, existingUser.Version)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			if err := c.service.RestoreUser(ctx, item.ID, version); err != nil {
+				return 0, 0, err
+			}
+			return item.ID, version + 1, nil
+		})
+	}, "Users restored successfully")
+}
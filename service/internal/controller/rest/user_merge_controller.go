@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+type UserMergeController struct {
+	service *service.UserMergeService
+}
+
+func NewUserMergeController(service *service.UserMergeService) *UserMergeController {
+	return &UserMergeController{service: service}
+}
+
+// FindDuplicateUsers lists the pairs of users that may be the same person,
+// with the signals they were scored by.
+func (c *UserMergeController) FindDuplicateUsers(ctx *fiber.Ctx) error {
+	filters, ok := ctx.Locals("filters").(map[string]string)
+	if !ok {
+		return utils.SendResponse(ctx, utils.WrapResponse(nil, nil, "Invalid filters", http.StatusBadRequest), http.StatusBadRequest)
+	}
+
+	duplicates, total, err := c.service.FindDuplicateUsers(ctx.Context(), filters)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	paginationMeta := utils.CreatePaginationMeta(filters, total)
+
+	return utils.GetResponse(ctx, duplicates, paginationMeta, "Duplicate users fetched successfully", http.StatusOK, nil, nil)
+}
+
+// MergeUsers merges the source user into the target one, show-user of the
+// source resolving to the target afterwards.
+func (c *UserMergeController) MergeUsers(ctx *fiber.Ctx) error {
+	var req dtos.MergeUsersRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if req.SourceID == 0 || req.TargetID == 0 {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, "source_id and target_id are required", nil)
+	}
+
+	if req.SourceID == req.TargetID {
+		return utils.GetResponse(ctx, nil, nil, "Invalid request", http.StatusBadRequest, "a user cannot be merged into itself", nil)
+	}
+
+	merge, err := c.service.MergeUsers(auditContext(ctx), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrMergeUsers):
+			return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusNotFound, err.Error(), nil)
+		case errors.Is(err, utils.ErrVersionConflict):
+			return utils.GetResponse(ctx, nil, nil, "Version conflict", http.StatusConflict, err.Error(), nil)
+		}
+		return utils.GetResponse(ctx, nil, nil, "Failed to merge users", http.StatusInternalServerError, err.Error(), nil)
+	}
+
+	return utils.GetResponse(ctx, []interface{}{merge}, nil, "Users merged successfully", http.StatusOK, nil, nil)
+}
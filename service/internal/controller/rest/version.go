@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+// expectedVersion resolves the version a write expects: If-Match when sent,
+// else the `version` field of the body, else the current one. A version other
+// than current is reported as utils.ErrVersionConflict.
+func expectedVersion(ctx *fiber.Ctx, bodyVersion *uint, current uint) (uint, error) {
+	version, err := utils.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return 0, err
+	}
+	if version == nil {
+		version = bodyVersion
+	}
+	if version == nil {
+		return current, nil
+	}
+	if *version != current {
+		return 0, utils.ErrVersionConflict
+	}
+	return *version, nil
+}
+
+// sendVersionError responds to an expectedVersion error: 409 with the current
+// state of the record on a conflict, 400 for a malformed If-Match.
+func sendVersionError(ctx *fiber.Ctx, err error, current interface{}, version uint, allowed map[string]utils.Field, owner bool) error {
+	if !errors.Is(err, utils.ErrVersionConflict) {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	fields, err := utils.SelectFields(ctx, "", allowed, owner)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	data, err := utils.PickFields(current, fields)
+	if err != nil {
+		return utils.SendQueryError(ctx, err)
+	}
+
+	ctx.Set(fiber.HeaderETag, utils.ETag(version))
+	return utils.GetResponse(ctx, []interface{}{data}, nil, "Version conflict", http.StatusConflict, utils.ErrVersionConflict.Error(), nil)
+}
+
+// The send*Conflict helpers answer a write that lost the race against another
+// one after the version check: they reload the record, deleted or not.
+
+func (c *UserController) sendUserConflict(ctx *fiber.Ctx, id uint) error {
+	user, err := c.service.GetUserByID(ctx.Context(), &dtos.GetUserByIDParams{ID: id})
+	if err != nil {
+		isDeleted := 1
+		user, err = c.service.GetUserByID(ctx.Context(), &dtos.GetUserByIDParams{ID: id, IsDeleted: &isDeleted})
+	}
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "User not found", http.StatusNotFound, err.Error(), nil)
+	}
+	return sendVersionError(ctx, utils.ErrVersionConflict, user, user.Version, repository.UserDetailFields, false)
+}
+
+func (c *ContactController) sendContactConflict(ctx *fiber.Ctx, id uint, userID uint) error {
+	contact, err := c.service.GetContactByID(ctx.Context(), &dtos.GetContactParams{ID: id, UserID: userID})
+	if err != nil {
+		isDeleted := 1
+		contact, err = c.service.GetContactByID(ctx.Context(), &dtos.GetContactParams{ID: id, UserID: userID, IsDeleted: &isDeleted})
+	}
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Contact not found", http.StatusNotFound, err.Error(), nil)
+	}
+	return sendVersionError(ctx, utils.ErrVersionConflict, contact, contact.Version, repository.ContactDetailFields, userID != 0)
+}
+
+func (c *AddressController) sendAddressConflict(ctx *fiber.Ctx, id uint, userID uint) error {
+	address, err := c.service.GetAddressByID(ctx.Context(), &dtos.GetAddressParams{ID: id, UserID: userID})
+	if err != nil {
+		isDeleted := 1
+		address, err = c.service.GetAddressByID(ctx.Context(), &dtos.GetAddressParams{ID: id, UserID: userID, IsDeleted: &isDeleted})
+	}
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Address not found", http.StatusNotFound, err.Error(), nil)
+	}
+	return sendVersionError(ctx, utils.ErrVersionConflict, address, address.Version, repository.AddressDetailFields, userID != 0)
+}
+
+func (c *IdentifierController) sendIdentifierConflict(ctx *fiber.Ctx, id uint, userID uint) error {
+	identifier, err := c.service.GetIdentifierByID(ctx.Context(), &dtos.GetIdentifierParams{ID: id, UserID: userID})
+	if err != nil {
+		isDeleted := 1
+		identifier, err = c.service.GetIdentifierByID(ctx.Context(), &dtos.GetIdentifierParams{ID: id, UserID: userID, IsDeleted: &isDeleted})
+	}
+	if err != nil {
+		return utils.GetResponse(ctx, nil, nil, "Identifier not found", http.StatusNotFound, err.Error(), nil)
+	}
+	return sendVersionError(ctx, utils.ErrVersionConflict, identifier, identifier.Version, repository.IdentifierDetailFields, userID != 0)
+}
+
+// withVersion adds the version to a field selection: the ETag needs it even
+// when the response leaves it out.
+func withVersion(fields []string) []string {
+	if utils.HasField(fields, "version") {
+		return fields
+	}
+	return append(append([]string{}, fields...), "version")
+}
@@ -0,0 +1,21 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS users_search_vector_trigger ON users;
+DROP TRIGGER IF EXISTS contacts_search_vector_trigger ON contacts;
+DROP TRIGGER IF EXISTS addresses_search_vector_trigger ON addresses;
+DROP TRIGGER IF EXISTS identifiers_search_vector_trigger ON identifiers;
+
+DROP FUNCTION IF EXISTS users_search_vector_update();
+DROP FUNCTION IF EXISTS ref_num_search_vector_update();
+
+DROP INDEX IF EXISTS idx_users_search_vector;
+DROP INDEX IF EXISTS idx_contacts_search_vector;
+DROP INDEX IF EXISTS idx_addresses_search_vector;
+DROP INDEX IF EXISTS idx_identifiers_search_vector;
+
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE contacts DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE addresses DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE identifiers DROP COLUMN IF EXISTS search_vector;
+
+COMMIT;
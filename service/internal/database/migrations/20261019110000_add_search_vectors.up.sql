@@ -0,0 +1,66 @@
+BEGIN;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS search_vector tsvector;
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS search_vector tsvector;
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS search_vector tsvector;
+ALTER TABLE identifiers ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION users_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('simple', coalesce(NEW.name, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(NEW.username, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(NEW.email, '')), 'B');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+-- contacts, addresses and identifiers share the same shape: the reference
+-- number is weighted above the name of its type (TG_ARGV[0] is the type column)
+CREATE OR REPLACE FUNCTION ref_num_search_vector_update() RETURNS trigger AS $$
+DECLARE
+  type_name TEXT;
+BEGIN
+  EXECUTE format('SELECT name FROM mix_values WHERE id = ($1).%I', TG_ARGV[0])
+    INTO type_name
+    USING NEW;
+
+  NEW.search_vector :=
+    setweight(to_tsvector('simple', coalesce(NEW.ref_num, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(type_name, '')), 'C');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_search_vector_trigger ON users;
+CREATE TRIGGER users_search_vector_trigger
+  BEFORE INSERT OR UPDATE OF name, username, email ON users
+  FOR EACH ROW EXECUTE FUNCTION users_search_vector_update();
+
+DROP TRIGGER IF EXISTS contacts_search_vector_trigger ON contacts;
+CREATE TRIGGER contacts_search_vector_trigger
+  BEFORE INSERT OR UPDATE OF ref_num, type_contact_id ON contacts
+  FOR EACH ROW EXECUTE FUNCTION ref_num_search_vector_update('type_contact_id');
+
+DROP TRIGGER IF EXISTS addresses_search_vector_trigger ON addresses;
+CREATE TRIGGER addresses_search_vector_trigger
+  BEFORE INSERT OR UPDATE OF ref_num, type_address_id ON addresses
+  FOR EACH ROW EXECUTE FUNCTION ref_num_search_vector_update('type_address_id');
+
+DROP TRIGGER IF EXISTS identifiers_search_vector_trigger ON identifiers;
+CREATE TRIGGER identifiers_search_vector_trigger
+  BEFORE INSERT OR UPDATE OF ref_num, type_identifier_id ON identifiers
+  FOR EACH ROW EXECUTE FUNCTION ref_num_search_vector_update('type_identifier_id');
+
+-- backfill existing rows through the triggers
+UPDATE users SET name = name;
+UPDATE contacts SET ref_num = ref_num;
+UPDATE addresses SET ref_num = ref_num;
+UPDATE identifiers SET ref_num = ref_num;
+
+CREATE INDEX IF NOT EXISTS idx_users_search_vector ON users USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_contacts_search_vector ON contacts USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_addresses_search_vector ON addresses USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_identifiers_search_vector ON identifiers USING GIN (search_vector);
+
+COMMIT;
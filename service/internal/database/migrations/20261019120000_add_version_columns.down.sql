@@ -0,0 +1,12 @@
+BEGIN;
+
+ALTER TABLE users DROP COLUMN IF EXISTS version;
+ALTER TABLE contacts DROP COLUMN IF EXISTS version;
+ALTER TABLE addresses DROP COLUMN IF EXISTS version;
+ALTER TABLE identifiers DROP COLUMN IF EXISTS version;
+ALTER TABLE groups DROP COLUMN IF EXISTS version;
+ALTER TABLE pools DROP COLUMN IF EXISTS version;
+ALTER TABLE mix_values DROP COLUMN IF EXISTS version;
+ALTER TABLE schedulers DROP COLUMN IF EXISTS version;
+
+COMMIT;
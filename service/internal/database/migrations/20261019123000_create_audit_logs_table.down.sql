@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP INDEX IF EXISTS identifiers_deleted_at_idx;
+DROP INDEX IF EXISTS addresses_deleted_at_idx;
+DROP INDEX IF EXISTS contacts_deleted_at_idx;
+DROP INDEX IF EXISTS users_deleted_at_idx;
+
+DROP TABLE IF EXISTS audit_logs;
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+-- actor_id and entity_id are plain columns: audit records outlive the rows
+-- they describe, including purged users.
+CREATE TABLE IF NOT EXISTS audit_logs (
+  id BIGSERIAL PRIMARY KEY,
+  actor_id INT,
+  entity VARCHAR(50) NOT NULL,
+  entity_id INT,
+  action VARCHAR(50) NOT NULL,
+  metadata JSONB,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS audit_logs_entity_idx ON audit_logs (entity, entity_id);
+CREATE INDEX IF NOT EXISTS audit_logs_created_at_idx ON audit_logs (created_at);
+
+-- index-trash-* and the purge look soft deleted rows up by deleted_at
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS contacts_deleted_at_idx ON contacts (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS addresses_deleted_at_idx ON addresses (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS identifiers_deleted_at_idx ON identifiers (deleted_at) WHERE deleted_at IS NOT NULL;
+
+COMMIT;
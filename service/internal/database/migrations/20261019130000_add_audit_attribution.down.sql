@@ -0,0 +1,38 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS audit_logs_no_truncate ON audit_logs;
+DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs;
+DROP FUNCTION IF EXISTS audit_logs_append_only();
+
+DROP TRIGGER IF EXISTS pools_audit_log ON pools;
+DROP TRIGGER IF EXISTS groups_audit_log ON groups;
+DROP TRIGGER IF EXISTS identifiers_audit_log ON identifiers;
+DROP TRIGGER IF EXISTS addresses_audit_log ON addresses;
+DROP TRIGGER IF EXISTS contacts_audit_log ON contacts;
+DROP TRIGGER IF EXISTS users_audit_log ON users;
+DROP FUNCTION IF EXISTS audit_log_write();
+
+DROP TRIGGER IF EXISTS pools_created_updated_by ON pools;
+DROP TRIGGER IF EXISTS groups_created_updated_by ON groups;
+DROP TRIGGER IF EXISTS identifiers_created_updated_by ON identifiers;
+DROP TRIGGER IF EXISTS addresses_created_updated_by ON addresses;
+DROP TRIGGER IF EXISTS contacts_created_updated_by ON contacts;
+DROP TRIGGER IF EXISTS users_created_updated_by ON users;
+DROP FUNCTION IF EXISTS set_created_updated_by();
+DROP FUNCTION IF EXISTS current_actor_id();
+
+DROP INDEX IF EXISTS audit_logs_request_id_idx;
+DROP INDEX IF EXISTS audit_logs_actor_id_idx;
+
+ALTER TABLE audit_logs
+  DROP COLUMN IF EXISTS request_id,
+  DROP COLUMN IF EXISTS ip_address,
+  DROP COLUMN IF EXISTS after,
+  DROP COLUMN IF EXISTS before;
+
+ALTER TABLE identifiers DROP COLUMN IF EXISTS updated_by_id, DROP COLUMN IF EXISTS created_by_id;
+ALTER TABLE addresses DROP COLUMN IF EXISTS updated_by_id, DROP COLUMN IF EXISTS created_by_id;
+ALTER TABLE contacts DROP COLUMN IF EXISTS updated_by_id, DROP COLUMN IF EXISTS created_by_id;
+ALTER TABLE users DROP COLUMN IF EXISTS updated_by_id, DROP COLUMN IF EXISTS created_by_id;
+
+COMMIT;
@@ -0,0 +1,158 @@
+BEGIN;
+
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS created_by_id INT REFERENCES users(id),
+  ADD COLUMN IF NOT EXISTS updated_by_id INT REFERENCES users(id);
+ALTER TABLE contacts
+  ADD COLUMN IF NOT EXISTS created_by_id INT REFERENCES users(id),
+  ADD COLUMN IF NOT EXISTS updated_by_id INT REFERENCES users(id);
+ALTER TABLE addresses
+  ADD COLUMN IF NOT EXISTS created_by_id INT REFERENCES users(id),
+  ADD COLUMN IF NOT EXISTS updated_by_id INT REFERENCES users(id);
+ALTER TABLE identifiers
+  ADD COLUMN IF NOT EXISTS created_by_id INT REFERENCES users(id),
+  ADD COLUMN IF NOT EXISTS updated_by_id INT REFERENCES users(id);
+
+ALTER TABLE audit_logs
+  ADD COLUMN IF NOT EXISTS before JSONB,
+  ADD COLUMN IF NOT EXISTS after JSONB,
+  ADD COLUMN IF NOT EXISTS ip_address VARCHAR(45),
+  ADD COLUMN IF NOT EXISTS request_id VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS audit_logs_actor_id_idx ON audit_logs (actor_id);
+CREATE INDEX IF NOT EXISTS audit_logs_request_id_idx ON audit_logs (request_id);
+
+-- The unit of work sets app.actor_id, app.ip_address and app.request_id for
+-- the transactions of API requests, see repository.setActor. Migrations that
+-- backfill rows have no actor: they set app.request_id to
+-- migration:<version>, which tells their audit records apart.
+CREATE OR REPLACE FUNCTION current_actor_id() RETURNS INT AS $$
+  SELECT NULLIF(current_setting('app.actor_id', true), '')::INT;
+$$ LANGUAGE sql STABLE;
+
+-- Writes without an actor (seeders, scheduled jobs) keep the columns as given.
+CREATE OR REPLACE FUNCTION set_created_updated_by() RETURNS trigger AS $$
+DECLARE
+  actor INT := current_actor_id();
+BEGIN
+  IF actor IS NULL THEN
+    RETURN NEW;
+  END IF;
+
+  IF TG_OP = 'INSERT' THEN
+    NEW.created_by_id := COALESCE(NEW.created_by_id, actor);
+  END IF;
+  NEW.updated_by_id := actor;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_created_updated_by BEFORE INSERT OR UPDATE ON users
+FOR EACH ROW EXECUTE FUNCTION set_created_updated_by();
+CREATE TRIGGER contacts_created_updated_by BEFORE INSERT OR UPDATE ON contacts
+FOR EACH ROW EXECUTE FUNCTION set_created_updated_by();
+CREATE TRIGGER addresses_created_updated_by BEFORE INSERT OR UPDATE ON addresses
+FOR EACH ROW EXECUTE FUNCTION set_created_updated_by();
+CREATE TRIGGER identifiers_created_updated_by BEFORE INSERT OR UPDATE ON identifiers
+FOR EACH ROW EXECUTE FUNCTION set_created_updated_by();
+CREATE TRIGGER groups_created_updated_by BEFORE INSERT OR UPDATE ON groups
+FOR EACH ROW EXECUTE FUNCTION set_created_updated_by();
+CREATE TRIGGER pools_created_updated_by BEFORE INSERT OR UPDATE ON pools
+FOR EACH ROW EXECUTE FUNCTION set_created_updated_by();
+
+-- audit_log_write records inserts as create, updates as update, delete or
+-- restore depending on deleted_at, and hard deletes as purge with the whole
+-- deleted row as before. The before / after of an update only hold the
+-- changed columns; bookkeeping columns are left out and a new password is
+-- only flagged. Updates changing nothing else are not recorded.
+CREATE OR REPLACE FUNCTION audit_log_write() RETURNS trigger AS $$
+DECLARE
+  ignored TEXT[] := ARRAY['search_vector', 'password', 'version', 'updated_at', 'created_by_id', 'updated_by_id'];
+  new_row JSONB;
+  old_row JSONB;
+  audit_action TEXT;
+  audit_entity_id INT;
+  old_values JSONB;
+  new_values JSONB;
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    audit_action := 'create';
+    audit_entity_id := NEW.id;
+    new_values := to_jsonb(NEW) - ignored;
+  ELSIF TG_OP = 'DELETE' THEN
+    audit_action := 'purge';
+    audit_entity_id := OLD.id;
+    old_values := to_jsonb(OLD) - ignored;
+  ELSE
+    audit_entity_id := NEW.id;
+    new_row := to_jsonb(NEW) - ignored;
+    old_row := to_jsonb(OLD) - ignored;
+
+    SELECT jsonb_object_agg(n.key, n.value) INTO new_values
+    FROM jsonb_each(new_row) n
+    WHERE old_row -> n.key IS DISTINCT FROM n.value;
+
+    SELECT jsonb_object_agg(o.key, o.value) INTO old_values
+    FROM jsonb_each(old_row) o
+    WHERE new_row -> o.key IS DISTINCT FROM o.value;
+
+    IF to_jsonb(OLD) ->> 'password' IS DISTINCT FROM to_jsonb(NEW) ->> 'password' THEN
+      new_values := COALESCE(new_values, '{}'::JSONB) || '{"password": "[changed]"}'::JSONB;
+    END IF;
+
+    IF new_values IS NULL THEN
+      RETURN NULL;
+    END IF;
+
+    -- read from the rows, not every audited table has deleted_at
+    IF old_row ->> 'deleted_at' IS NULL AND new_row ->> 'deleted_at' IS NOT NULL THEN
+      audit_action := 'delete';
+    ELSIF old_row ->> 'deleted_at' IS NOT NULL AND new_row ->> 'deleted_at' IS NULL THEN
+      audit_action := 'restore';
+    ELSE
+      audit_action := 'update';
+    END IF;
+  END IF;
+
+  INSERT INTO audit_logs (actor_id, entity, entity_id, action, before, after, ip_address, request_id)
+  VALUES (
+    current_actor_id(),
+    TG_TABLE_NAME,
+    audit_entity_id,
+    audit_action,
+    old_values,
+    new_values,
+    NULLIF(current_setting('app.ip_address', true), ''),
+    NULLIF(current_setting('app.request_id', true), '')
+  );
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_audit_log AFTER INSERT OR UPDATE OR DELETE ON users
+FOR EACH ROW EXECUTE FUNCTION audit_log_write();
+CREATE TRIGGER contacts_audit_log AFTER INSERT OR UPDATE OR DELETE ON contacts
+FOR EACH ROW EXECUTE FUNCTION audit_log_write();
+CREATE TRIGGER addresses_audit_log AFTER INSERT OR UPDATE OR DELETE ON addresses
+FOR EACH ROW EXECUTE FUNCTION audit_log_write();
+CREATE TRIGGER identifiers_audit_log AFTER INSERT OR UPDATE OR DELETE ON identifiers
+FOR EACH ROW EXECUTE FUNCTION audit_log_write();
+CREATE TRIGGER groups_audit_log AFTER INSERT OR UPDATE OR DELETE ON groups
+FOR EACH ROW EXECUTE FUNCTION audit_log_write();
+CREATE TRIGGER pools_audit_log AFTER INSERT OR UPDATE OR DELETE ON pools
+FOR EACH ROW EXECUTE FUNCTION audit_log_write();
+
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs
+FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
+CREATE TRIGGER audit_logs_no_truncate BEFORE TRUNCATE ON audit_logs
+FOR EACH STATEMENT EXECUTE FUNCTION audit_logs_append_only();
+
+COMMIT;
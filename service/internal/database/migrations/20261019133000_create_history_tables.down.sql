@@ -0,0 +1,14 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS identifiers_history ON identifiers;
+DROP TRIGGER IF EXISTS addresses_history ON addresses;
+DROP TRIGGER IF EXISTS contacts_history ON contacts;
+DROP TRIGGER IF EXISTS users_history ON users;
+DROP FUNCTION IF EXISTS record_history();
+
+DROP TABLE IF EXISTS identifiers_history;
+DROP TABLE IF EXISTS addresses_history;
+DROP TABLE IF EXISTS contacts_history;
+DROP TABLE IF EXISTS users_history;
+
+COMMIT;
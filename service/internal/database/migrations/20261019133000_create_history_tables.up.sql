@@ -0,0 +1,132 @@
+BEGIN;
+
+-- One row per version of a record: data is the row as it was (without
+-- search_vector and password) from valid_from until valid_to, NULL for the
+-- current version. Reading it back with jsonb_populate_record keeps working
+-- when the table gains columns later.
+CREATE TABLE IF NOT EXISTS users_history (
+  history_id BIGSERIAL PRIMARY KEY,
+  id INT NOT NULL,
+  version INT NOT NULL,
+  operation VARCHAR(10) NOT NULL,
+  data JSONB NOT NULL,
+  changed_by_id INT,
+  valid_from timestamp with time zone NOT NULL,
+  valid_to timestamp with time zone,
+  UNIQUE (id, version)
+);
+
+CREATE INDEX IF NOT EXISTS users_history_valid_from_idx ON users_history (id, valid_from);
+
+CREATE TABLE IF NOT EXISTS contacts_history (
+  history_id BIGSERIAL PRIMARY KEY,
+  id INT NOT NULL,
+  version INT NOT NULL,
+  operation VARCHAR(10) NOT NULL,
+  data JSONB NOT NULL,
+  changed_by_id INT,
+  valid_from timestamp with time zone NOT NULL,
+  valid_to timestamp with time zone,
+  UNIQUE (id, version)
+);
+
+CREATE INDEX IF NOT EXISTS contacts_history_valid_from_idx ON contacts_history (id, valid_from);
+
+CREATE TABLE IF NOT EXISTS addresses_history (
+  history_id BIGSERIAL PRIMARY KEY,
+  id INT NOT NULL,
+  version INT NOT NULL,
+  operation VARCHAR(10) NOT NULL,
+  data JSONB NOT NULL,
+  changed_by_id INT,
+  valid_from timestamp with time zone NOT NULL,
+  valid_to timestamp with time zone,
+  UNIQUE (id, version)
+);
+
+CREATE INDEX IF NOT EXISTS addresses_history_valid_from_idx ON addresses_history (id, valid_from);
+
+CREATE TABLE IF NOT EXISTS identifiers_history (
+  history_id BIGSERIAL PRIMARY KEY,
+  id INT NOT NULL,
+  version INT NOT NULL,
+  operation VARCHAR(10) NOT NULL,
+  data JSONB NOT NULL,
+  changed_by_id INT,
+  valid_from timestamp with time zone NOT NULL,
+  valid_to timestamp with time zone,
+  UNIQUE (id, version)
+);
+
+CREATE INDEX IF NOT EXISTS identifiers_history_valid_from_idx ON identifiers_history (id, valid_from);
+
+-- record_history closes the current version of the row and adds the new one
+-- whenever the version changes, so writes that do not bump it (bookkeeping
+-- such as the trash purge clearing created_by_id) are not versions.
+CREATE OR REPLACE FUNCTION record_history() RETURNS trigger AS $$
+DECLARE
+  history_table TEXT := TG_TABLE_NAME || '_history';
+  history_operation TEXT := 'create';
+BEGIN
+  IF TG_OP = 'UPDATE' THEN
+    IF NEW.version IS NOT DISTINCT FROM OLD.version THEN
+      RETURN NULL;
+    END IF;
+
+    IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+      history_operation := 'delete';
+    ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+      history_operation := 'restore';
+    ELSE
+      history_operation := 'update';
+    END IF;
+
+    EXECUTE format('UPDATE %I SET valid_to = NOW() WHERE id = $1 AND valid_to IS NULL', history_table)
+    USING NEW.id;
+  END IF;
+
+  EXECUTE format(
+    'INSERT INTO %I (id, version, operation, data, changed_by_id, valid_from) VALUES ($1, $2, $3, $4, $5, NOW())',
+    history_table
+  )
+  USING NEW.id, NEW.version, history_operation, to_jsonb(NEW) - ARRAY['search_vector', 'password'], current_actor_id();
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_history AFTER INSERT OR UPDATE ON users
+FOR EACH ROW EXECUTE FUNCTION record_history();
+CREATE TRIGGER contacts_history AFTER INSERT OR UPDATE ON contacts
+FOR EACH ROW EXECUTE FUNCTION record_history();
+CREATE TRIGGER addresses_history AFTER INSERT OR UPDATE ON addresses
+FOR EACH ROW EXECUTE FUNCTION record_history();
+CREATE TRIGGER identifiers_history AFTER INSERT OR UPDATE ON identifiers
+FOR EACH ROW EXECUTE FUNCTION record_history();
+
+-- the current state of existing rows is their first known version
+INSERT INTO users_history (id, version, operation, data, valid_from)
+SELECT t.id, t.version, 'backfill', to_jsonb(t) - ARRAY['search_vector', 'password'],
+  COALESCE(t.deleted_at, t.updated_at, t.created_at, NOW())
+FROM users t
+ON CONFLICT (id, version) DO NOTHING;
+
+INSERT INTO contacts_history (id, version, operation, data, valid_from)
+SELECT t.id, t.version, 'backfill', to_jsonb(t) - ARRAY['search_vector', 'password'],
+  COALESCE(t.deleted_at, t.updated_at, t.created_at, NOW())
+FROM contacts t
+ON CONFLICT (id, version) DO NOTHING;
+
+INSERT INTO addresses_history (id, version, operation, data, valid_from)
+SELECT t.id, t.version, 'backfill', to_jsonb(t) - ARRAY['search_vector', 'password'],
+  COALESCE(t.deleted_at, t.updated_at, t.created_at, NOW())
+FROM addresses t
+ON CONFLICT (id, version) DO NOTHING;
+
+INSERT INTO identifiers_history (id, version, operation, data, valid_from)
+SELECT t.id, t.version, 'backfill', to_jsonb(t) - ARRAY['search_vector', 'password'],
+  COALESCE(t.deleted_at, t.updated_at, t.created_at, NOW())
+FROM identifiers t
+ON CONFLICT (id, version) DO NOTHING;
+
+COMMIT;
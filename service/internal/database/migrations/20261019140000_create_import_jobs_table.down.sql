@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS import_jobs_created_updated_by ON import_jobs;
+DROP TABLE IF EXISTS import_jobs;
+
+COMMIT;
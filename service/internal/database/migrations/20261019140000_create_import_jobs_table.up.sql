@@ -0,0 +1,32 @@
+BEGIN;
+
+-- An import is validated (dry run) on upload and written later by the worker,
+-- the file is kept with the job so that both read the same rows.
+CREATE TABLE IF NOT EXISTS import_jobs (
+  id SERIAL PRIMARY KEY,
+  entity VARCHAR(50) NOT NULL,
+  file_name VARCHAR(255) NOT NULL,
+  file BYTEA NOT NULL,
+  mapping JSONB NOT NULL DEFAULT '{}',
+  status VARCHAR(20) NOT NULL,
+  total_rows INT NOT NULL DEFAULT 0,
+  invalid_rows INT NOT NULL DEFAULT 0,
+  processed_rows INT NOT NULL DEFAULT 0,
+  imported_rows INT NOT NULL DEFAULT 0,
+  failed_rows INT NOT NULL DEFAULT 0,
+  report JSONB NOT NULL DEFAULT '[]',
+  error TEXT,
+  created_by_id INT REFERENCES users(id) ON DELETE SET NULL,
+  updated_by_id INT REFERENCES users(id) ON DELETE SET NULL,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  started_at timestamp with time zone,
+  finished_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS import_jobs_created_by_id_idx ON import_jobs (created_by_id);
+
+CREATE TRIGGER import_jobs_created_updated_by BEFORE INSERT OR UPDATE ON import_jobs
+FOR EACH ROW EXECUTE FUNCTION set_created_updated_by();
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS export_jobs_created_updated_by ON export_jobs;
+DROP TABLE IF EXISTS export_jobs;
+
+COMMIT;
@@ -0,0 +1,29 @@
+BEGIN;
+
+-- An export too large to stream is written to a file by the worker, the job
+-- keeps the filters it was asked with and where the file went.
+CREATE TABLE IF NOT EXISTS export_jobs (
+  id SERIAL PRIMARY KEY,
+  entity VARCHAR(50) NOT NULL,
+  format VARCHAR(20) NOT NULL,
+  filters JSONB NOT NULL DEFAULT '{}',
+  status VARCHAR(20) NOT NULL,
+  total_rows INT NOT NULL DEFAULT 0,
+  processed_rows INT NOT NULL DEFAULT 0,
+  file_name VARCHAR(255),
+  file_size BIGINT,
+  error TEXT,
+  created_by_id INT REFERENCES users(id) ON DELETE SET NULL,
+  updated_by_id INT REFERENCES users(id) ON DELETE SET NULL,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  started_at timestamp with time zone,
+  finished_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS export_jobs_created_by_id_idx ON export_jobs (created_by_id);
+
+CREATE TRIGGER export_jobs_created_updated_by BEFORE INSERT OR UPDATE ON export_jobs
+FOR EACH ROW EXECUTE FUNCTION set_created_updated_by();
+
+COMMIT;
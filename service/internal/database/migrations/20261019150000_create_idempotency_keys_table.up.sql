@@ -0,0 +1,26 @@
+BEGIN;
+
+-- The response to a request sent with an Idempotency-Key, replayed when the
+-- same request is retried with the key. A key is scoped to its user and route;
+-- user_id is 0 for anonymous requests. A processing row is the claim of the
+-- request running with the key, duplicates wait for it to complete.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  id BIGSERIAL PRIMARY KEY,
+  user_id INT NOT NULL DEFAULT 0,
+  path VARCHAR(255) NOT NULL,
+  key VARCHAR(255) NOT NULL,
+  request_hash CHAR(64) NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  response_status INT,
+  response_headers JSONB,
+  response_body BYTEA,
+  locked_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  completed_at timestamp with time zone,
+  expires_at timestamp with time zone NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idempotency_keys_user_id_path_key_idx ON idempotency_keys (user_id, path, key);
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+COMMIT;
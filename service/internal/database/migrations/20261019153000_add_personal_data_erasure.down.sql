@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS redact_jsonb(JSONB, TEXT[]);
+
+DROP TRIGGER IF EXISTS users_erased_immutable ON users;
+DROP FUNCTION IF EXISTS users_erased_immutable();
+
+DROP INDEX IF EXISTS users_erased_at_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS erased_at;
+
+COMMIT;
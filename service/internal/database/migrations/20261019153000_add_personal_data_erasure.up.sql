@@ -0,0 +1,61 @@
+BEGIN;
+
+-- Set when the personal data of a user was erased, see
+-- repository.PrivacyRepository.EraseUser. The row itself stays, with its
+-- children, so references and counts keep working.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS erased_at timestamp with time zone;
+
+CREATE INDEX IF NOT EXISTS users_erased_at_idx ON users (erased_at) WHERE erased_at IS NOT NULL;
+
+-- An erasure cannot be undone: the anonymised columns of an erased user, and
+-- erased_at itself, never change again (revert-user included).
+CREATE OR REPLACE FUNCTION users_erased_immutable() RETURNS trigger AS $$
+BEGIN
+  IF NEW.erased_at IS DISTINCT FROM OLD.erased_at
+    OR NEW.name IS DISTINCT FROM OLD.name
+    OR NEW.username IS DISTINCT FROM OLD.username
+    OR NEW.email IS DISTINCT FROM OLD.email
+    OR NEW.address IS DISTINCT FROM OLD.address
+    OR NEW.password IS DISTINCT FROM OLD.password THEN
+    RAISE EXCEPTION 'user % was erased', OLD.id;
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_erased_immutable BEFORE UPDATE ON users
+FOR EACH ROW WHEN (OLD.erased_at IS NOT NULL) EXECUTE FUNCTION users_erased_immutable();
+
+-- redact_jsonb replaces the non null values of keys in an object by
+-- "[erased]", leaving which columns were set (and when) readable.
+CREATE OR REPLACE FUNCTION redact_jsonb(data JSONB, keys TEXT[]) RETURNS JSONB AS $$
+  SELECT CASE
+    WHEN data IS NULL OR jsonb_typeof(data) <> 'object' THEN data
+    ELSE (
+      SELECT COALESCE(jsonb_object_agg(
+        d.key,
+        CASE WHEN d.key = ANY(keys) AND d.value <> 'null'::JSONB THEN '"[erased]"'::JSONB ELSE d.value END
+      ), '{}'::JSONB)
+      FROM jsonb_each(data) d
+    )
+  END;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- audit_logs stays append-only, except that the transaction erasing a user
+-- (app.erasure = on) may redact the values and IP addresses of its records.
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+DECLARE
+  redactable TEXT[] := ARRAY['before', 'after', 'metadata', 'ip_address'];
+BEGIN
+  IF TG_OP = 'UPDATE'
+    AND current_setting('app.erasure', true) = 'on'
+    AND to_jsonb(NEW) - redactable = to_jsonb(OLD) - redactable THEN
+    RETURN NEW;
+  END IF;
+
+  RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS identifiers_type_ref_num_idx;
+DROP INDEX IF EXISTS contacts_type_ref_num_idx;
+
+DROP TABLE IF EXISTS user_merges;
+
+COMMIT;
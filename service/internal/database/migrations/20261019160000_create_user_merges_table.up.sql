@@ -0,0 +1,18 @@
+BEGIN;
+
+-- One row per user merged into another. source_id and target_id are plain
+-- columns: the redirect outlives the purge of the merged user.
+CREATE TABLE IF NOT EXISTS user_merges (
+  source_id INT PRIMARY KEY,
+  target_id INT NOT NULL,
+  merged_by_id INT,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS user_merges_target_id_idx ON user_merges (target_id);
+
+-- the duplicate finder pairs users sharing a contact or an identifier
+CREATE INDEX IF NOT EXISTS contacts_type_ref_num_idx ON contacts (type_contact_id, ref_num) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS identifiers_type_ref_num_idx ON identifiers (type_identifier_id, ref_num) WHERE deleted_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+DROP FUNCTION IF EXISTS parse_address(TEXT);
+
+DROP INDEX IF EXISTS addresses_country_code_city_idx;
+
+ALTER TABLE addresses DROP CONSTRAINT IF EXISTS addresses_coordinates_check;
+ALTER TABLE addresses DROP CONSTRAINT IF EXISTS addresses_country_code_check;
+
+ALTER TABLE addresses
+  DROP COLUMN IF EXISTS longitude,
+  DROP COLUMN IF EXISTS latitude,
+  DROP COLUMN IF EXISTS country_code,
+  DROP COLUMN IF EXISTS postal_code,
+  DROP COLUMN IF EXISTS region,
+  DROP COLUMN IF EXISTS city,
+  DROP COLUMN IF EXISTS address_line2,
+  DROP COLUMN IF EXISTS address_line1;
+
+COMMIT;
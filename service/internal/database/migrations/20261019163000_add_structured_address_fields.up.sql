@@ -0,0 +1,119 @@
+BEGIN;
+
+-- the backfill is audited as written by this migration
+SELECT set_config('app.request_id', 'migration:20261019163000', true);
+
+-- ref_num stays the address on one line, formatted from these fields when
+-- they are given.
+ALTER TABLE addresses
+  ADD COLUMN IF NOT EXISTS address_line1 VARCHAR(255),
+  ADD COLUMN IF NOT EXISTS address_line2 VARCHAR(255),
+  ADD COLUMN IF NOT EXISTS city VARCHAR(100),
+  ADD COLUMN IF NOT EXISTS region VARCHAR(100),
+  ADD COLUMN IF NOT EXISTS postal_code VARCHAR(20),
+  ADD COLUMN IF NOT EXISTS country_code CHAR(2),
+  ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+  ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+
+ALTER TABLE addresses ADD CONSTRAINT addresses_country_code_check
+  CHECK (country_code ~ '^[A-Z]{2}$');
+
+ALTER TABLE addresses ADD CONSTRAINT addresses_coordinates_check
+  CHECK ((latitude IS NULL) = (longitude IS NULL) AND latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180);
+
+CREATE INDEX IF NOT EXISTS addresses_country_code_city_idx ON addresses (country_code, city) WHERE deleted_at IS NULL;
+
+-- parse_address splits an address written on one line the way the API
+-- formats ref_num, "line 1[, line 2], city[, region] [postal code][, country
+-- code]", into its parts, with the casing the API gives them. It returns no
+-- row when the text does not look like one: fewer than two parts, or neither
+-- a postal code nor a country to tell the city from the lines.
+CREATE OR REPLACE FUNCTION parse_address(address TEXT)
+RETURNS TABLE (address_line1 TEXT, address_line2 TEXT, city TEXT, region TEXT, postal_code TEXT, country_code TEXT) AS $$
+DECLARE
+  parts TEXT[];
+  n INT;
+  lines INT;
+  postal TEXT[];
+BEGIN
+  parts := ARRAY(
+    SELECT btrim(regexp_replace(p, '\s+', ' ', 'g'))
+    FROM unnest(string_to_array(address, ',')) AS p
+    WHERE btrim(p) <> ''
+  );
+  n := cardinality(parts);
+
+  -- a trailing part of two letters is the country
+  IF n > 0 AND parts[n] ~ '^[A-Za-z]{2}$' THEN
+    country_code := upper(parts[n]);
+    n := n - 1;
+  END IF;
+  IF n < 2 THEN
+    RETURN;
+  END IF;
+
+  -- the postal code is the end of the last part, with a digit in it ("62704",
+  -- "SW1A 1AA"); before it is the region when abbreviated and preceded by the
+  -- city, the city otherwise
+  postal := regexp_match(parts[n], '^(.*?) ?([A-Za-z0-9]*[0-9][A-Za-z0-9-]*( [0-9][A-Za-z]{2})?)$');
+  IF postal IS NOT NULL AND length(postal[2]) <= 10 THEN
+    postal_code := upper(postal[2]);
+    IF postal[1] = '' AND n >= 3 THEN
+      city := parts[n - 1];
+      lines := n - 2;
+    ELSIF postal[1] ~ '^[A-Za-z]{2,3}$' AND n >= 3 THEN
+      region := upper(postal[1]);
+      city := parts[n - 1];
+      lines := n - 2;
+    ELSIF postal[1] <> '' THEN
+      city := postal[1];
+      lines := n - 1;
+    ELSE
+      RETURN;
+    END IF;
+  ELSIF country_code IS NOT NULL THEN
+    city := parts[n];
+    lines := n - 1;
+  ELSE
+    RETURN;
+  END IF;
+
+  IF city = upper(city) OR city = lower(city) THEN
+    city := initcap(city);
+  END IF;
+  address_line1 := parts[1];
+  address_line2 := NULLIF(array_to_string(parts[2:lines], ', '), '');
+
+  -- parts too long for their column are not what they were taken for
+  IF length(address_line1) > 255 OR length(address_line2) > 255 OR length(city) > 100 OR length(region) > 100 THEN
+    RETURN;
+  END IF;
+  RETURN NEXT;
+END
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- Backfill the addresses whose ref_num parses. This is not a new version of
+-- them, so the current version in the history gets the fields in place.
+UPDATE addresses a SET
+  address_line1 = p.address_line1,
+  address_line2 = p.address_line2,
+  city = p.city,
+  region = p.region,
+  postal_code = p.postal_code,
+  country_code = p.country_code
+FROM addresses s
+CROSS JOIN LATERAL parse_address(s.ref_num) p
+WHERE a.id = s.id AND a.address_line1 IS NULL;
+
+UPDATE addresses_history h SET data = h.data || jsonb_build_object(
+  'address_line1', a.address_line1,
+  'address_line2', a.address_line2,
+  'city', a.city,
+  'region', a.region,
+  'postal_code', a.postal_code,
+  'country_code', a.country_code
+)
+FROM addresses a
+WHERE h.id = a.id AND h.version = a.version AND a.address_line1 IS NOT NULL;
+
+COMMIT;
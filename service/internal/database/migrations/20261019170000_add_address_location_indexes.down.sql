@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS addresses_coordinates_idx;
+DROP INDEX IF EXISTS addresses_earth_idx;
+
+COMMIT;
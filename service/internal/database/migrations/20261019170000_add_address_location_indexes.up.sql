@@ -0,0 +1,14 @@
+BEGIN;
+
+-- earthdistance (on cube) measures the radius searches
+CREATE EXTENSION IF NOT EXISTS cube;
+CREATE EXTENSION IF NOT EXISTS earthdistance;
+
+-- near and radius match earth_box against the position of the addresses
+CREATE INDEX IF NOT EXISTS addresses_earth_idx ON addresses
+  USING GIST (ll_to_earth(latitude, longitude)) WHERE latitude IS NOT NULL;
+
+-- bbox compares the coordinates themselves
+CREATE INDEX IF NOT EXISTS addresses_coordinates_idx ON addresses (latitude, longitude) WHERE latitude IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,31 @@
+BEGIN;
+
+CREATE OR REPLACE FUNCTION ref_num_search_vector_update() RETURNS trigger AS $$
+DECLARE
+  type_name TEXT;
+BEGIN
+  EXECUTE format('SELECT name FROM mix_values WHERE id = ($1).%I', TG_ARGV[0])
+    INTO type_name
+    USING NEW;
+
+  NEW.search_vector :=
+    setweight(to_tsvector('simple', coalesce(NEW.ref_num, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(type_name, '')), 'C');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS contacts_search_vector_trigger ON contacts;
+CREATE TRIGGER contacts_search_vector_trigger
+  BEFORE INSERT OR UPDATE OF ref_num, type_contact_id ON contacts
+  FOR EACH ROW EXECUTE FUNCTION ref_num_search_vector_update('type_contact_id');
+
+DROP INDEX IF EXISTS contacts_type_normalized_ref_num_idx;
+DROP INDEX IF EXISTS contacts_user_normalized_ref_num_idx;
+CREATE INDEX IF NOT EXISTS contacts_type_ref_num_idx ON contacts (type_contact_id, ref_num) WHERE deleted_at IS NULL;
+
+ALTER TABLE contacts DROP COLUMN IF EXISTS normalized_ref_num;
+
+UPDATE contacts SET ref_num = ref_num;
+
+COMMIT;
@@ -0,0 +1,49 @@
+BEGIN;
+
+-- the backfill is audited as written by this migration
+SELECT set_config('app.request_id', 'migration:20261019173000', true);
+
+-- the ref_num of a contact as the format of its type stores it: +6281234567890
+-- for 0812-3456-7890. Uniqueness, search and the duplicate finder use it.
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS normalized_ref_num VARCHAR(255);
+
+-- Backfill with the whitespace collapsed, as for a type without a format; the
+-- normalize_contacts job applies the formats of the types. This is not a new
+-- version of them, so the current version in the history gets it in place.
+UPDATE contacts SET normalized_ref_num = regexp_replace(btrim(ref_num), '\s+', ' ', 'g')
+WHERE normalized_ref_num IS NULL;
+
+UPDATE contacts_history h SET data = h.data || jsonb_build_object('normalized_ref_num', c.normalized_ref_num)
+FROM contacts c
+WHERE h.id = c.id AND h.version = c.version;
+
+CREATE INDEX IF NOT EXISTS contacts_user_normalized_ref_num_idx ON contacts (user_id, normalized_ref_num) WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS contacts_type_ref_num_idx;
+CREATE INDEX IF NOT EXISTS contacts_type_normalized_ref_num_idx ON contacts (type_contact_id, normalized_ref_num) WHERE deleted_at IS NULL;
+
+-- the normalized ref_num is searched too; the tables without one read NULL
+CREATE OR REPLACE FUNCTION ref_num_search_vector_update() RETURNS trigger AS $$
+DECLARE
+  type_name TEXT;
+BEGIN
+  EXECUTE format('SELECT name FROM mix_values WHERE id = ($1).%I', TG_ARGV[0])
+    INTO type_name
+    USING NEW;
+
+  NEW.search_vector :=
+    setweight(to_tsvector('simple', coalesce(NEW.ref_num, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(to_jsonb(NEW) ->> 'normalized_ref_num', '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(type_name, '')), 'C');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS contacts_search_vector_trigger ON contacts;
+CREATE TRIGGER contacts_search_vector_trigger
+  BEFORE INSERT OR UPDATE OF ref_num, normalized_ref_num, type_contact_id ON contacts
+  FOR EACH ROW EXECUTE FUNCTION ref_num_search_vector_update('type_contact_id');
+
+UPDATE contacts SET ref_num = ref_num;
+
+COMMIT;
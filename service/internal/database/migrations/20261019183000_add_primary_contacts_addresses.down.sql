@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP INDEX IF EXISTS addresses_primary_idx;
+DROP INDEX IF EXISTS contacts_primary_idx;
+
+ALTER TABLE addresses DROP COLUMN IF EXISTS is_primary;
+ALTER TABLE contacts DROP COLUMN IF EXISTS is_primary;
+
+COMMIT;
@@ -0,0 +1,42 @@
+BEGIN;
+
+-- the backfill is audited as written by this migration
+SELECT set_config('app.request_id', 'migration:20261019183000', true);
+
+-- A user has at most one primary contact per contact type and one primary
+-- address per address type, among the ones not in the trash.
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS is_primary BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS is_primary BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- the oldest of each user and type becomes its primary. This is not a new
+-- version of them, so the current version in the history gets it in place.
+UPDATE contacts c SET is_primary = TRUE
+FROM (
+  SELECT DISTINCT ON (user_id, type_contact_id) id
+  FROM contacts
+  WHERE deleted_at IS NULL
+  ORDER BY user_id, type_contact_id, id
+) f
+WHERE c.id = f.id;
+
+UPDATE addresses a SET is_primary = TRUE
+FROM (
+  SELECT DISTINCT ON (user_id, type_address_id) id
+  FROM addresses
+  WHERE deleted_at IS NULL
+  ORDER BY user_id, type_address_id, id
+) f
+WHERE a.id = f.id;
+
+UPDATE contacts_history h SET data = h.data || jsonb_build_object('is_primary', c.is_primary)
+FROM contacts c
+WHERE h.id = c.id AND h.version = c.version;
+
+UPDATE addresses_history h SET data = h.data || jsonb_build_object('is_primary', a.is_primary)
+FROM addresses a
+WHERE h.id = a.id AND h.version = a.version;
+
+CREATE UNIQUE INDEX IF NOT EXISTS contacts_primary_idx ON contacts (user_id, type_contact_id) WHERE is_primary AND deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS addresses_primary_idx ON addresses (user_id, type_address_id) WHERE is_primary AND deleted_at IS NULL;
+
+COMMIT;
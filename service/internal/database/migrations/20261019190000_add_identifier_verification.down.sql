@@ -0,0 +1,20 @@
+BEGIN;
+
+DROP TABLE IF EXISTS identifier_status_transitions;
+
+DROP INDEX IF EXISTS identifiers_status_idx;
+
+ALTER TABLE identifiers DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE identifiers DROP CONSTRAINT IF EXISTS identifiers_status_check;
+ALTER TABLE identifiers ALTER COLUMN status DROP NOT NULL;
+ALTER TABLE identifiers ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE identifiers ALTER COLUMN status TYPE INT USING CASE WHEN status = 'verified' THEN 1 ELSE 0 END;
+
+-- only the versions written since have a status of a step
+UPDATE identifiers_history SET data = (data - 'status_reason') || jsonb_build_object('status', CASE WHEN data ->> 'status' = 'verified' THEN 1 ELSE 0 END)
+WHERE jsonb_typeof(data -> 'status') = 'string';
+
+DROP FUNCTION IF EXISTS identifier_history_data(JSONB);
+DROP FUNCTION IF EXISTS legacy_identifier_status(INT);
+
+COMMIT;
@@ -0,0 +1,46 @@
+BEGIN;
+
+-- The status of an identifier is a step of its verification: a draft is
+-- submitted, then verified or rejected by a reviewer; a verified one expires.
+-- legacy_identifier_status maps the former integers, 1 for an active
+-- identifier, to it: an active one stays verified, the others are drafts.
+CREATE OR REPLACE FUNCTION legacy_identifier_status(status INT) RETURNS TEXT AS $$
+  SELECT CASE status WHEN 1 THEN 'verified' ELSE 'draft' END
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- identifier_history_data reads a version of an identifier from its history,
+-- which is left as it was written: the status of the versions written before
+-- is translated as the rows were.
+CREATE OR REPLACE FUNCTION identifier_history_data(data JSONB) RETURNS JSONB AS $$
+  SELECT CASE
+    WHEN jsonb_typeof(data -> 'status') = 'string' THEN data
+    ELSE data || jsonb_build_object('status', legacy_identifier_status((data ->> 'status')::INT))
+  END
+$$ LANGUAGE SQL IMMUTABLE;
+
+ALTER TABLE identifiers ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE identifiers ALTER COLUMN status TYPE TEXT USING legacy_identifier_status(status);
+ALTER TABLE identifiers ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE identifiers ALTER COLUMN status SET NOT NULL;
+ALTER TABLE identifiers ADD CONSTRAINT identifiers_status_check
+  CHECK (status IN ('draft', 'submitted', 'verified', 'rejected', 'expired'));
+
+-- the reason given with the last transition, as why it was rejected
+ALTER TABLE identifiers ADD COLUMN IF NOT EXISTS status_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS identifiers_status_idx ON identifiers (status) WHERE deleted_at IS NULL;
+
+-- One row per status transition of an identifier.
+CREATE TABLE IF NOT EXISTS identifier_status_transitions (
+  id SERIAL PRIMARY KEY,
+  identifier_id INT NOT NULL REFERENCES identifiers(id) ON DELETE CASCADE,
+  from_status TEXT NOT NULL,
+  to_status TEXT NOT NULL,
+  reason TEXT,
+  actor_id INT,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS identifier_status_transitions_identifier_id_idx ON identifier_status_transitions (identifier_id);
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+DROP TABLE IF EXISTS identifier_expiry_reminders;
+
+DROP INDEX IF EXISTS identifiers_expires_at_idx;
+
+ALTER TABLE identifiers DROP CONSTRAINT IF EXISTS identifiers_validity_check;
+ALTER TABLE identifiers DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE identifiers DROP COLUMN IF EXISTS issued_at;
+
+UPDATE identifiers_history SET data = data - 'issued_at' - 'expires_at';
+
+COMMIT;
@@ -0,0 +1,23 @@
+BEGIN;
+
+-- The dates an identifier was issued and stops being valid. A verified one
+-- whose expiry date has passed is expired by the expire_identifiers schedule.
+ALTER TABLE identifiers ADD COLUMN IF NOT EXISTS issued_at DATE;
+ALTER TABLE identifiers ADD COLUMN IF NOT EXISTS expires_at DATE;
+ALTER TABLE identifiers ADD CONSTRAINT identifiers_validity_check
+  CHECK (issued_at IS NULL OR expires_at IS NULL OR expires_at > issued_at);
+
+CREATE INDEX IF NOT EXISTS identifiers_expires_at_idx ON identifiers (expires_at) WHERE deleted_at IS NULL AND expires_at IS NOT NULL;
+
+-- One row per expiry reminder enqueued, so the remind_expiring_identifiers
+-- schedule sends each one once. A renewed identifier has a new expiry date and
+-- is reminded again.
+CREATE TABLE IF NOT EXISTS identifier_expiry_reminders (
+  identifier_id INT NOT NULL REFERENCES identifiers(id) ON DELETE CASCADE,
+  expires_at DATE NOT NULL,
+  days_before INT NOT NULL,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (identifier_id, expires_at, days_before)
+);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS attachments;
+DROP TABLE IF EXISTS files;
+
+COMMIT;
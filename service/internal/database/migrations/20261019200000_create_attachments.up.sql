@@ -0,0 +1,35 @@
+BEGIN;
+
+-- One row per distinct file content, stored once however many times it is
+-- attached. A file no attachment references anymore is removed from the
+-- storage by the purge_orphan_files schedule.
+CREATE TABLE IF NOT EXISTS files (
+  id SERIAL PRIMARY KEY,
+  sha256 CHAR(64) NOT NULL UNIQUE,
+  size BIGINT NOT NULL,
+  content_type TEXT NOT NULL,
+  storage_key TEXT NOT NULL,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A file attached to a row of an entity, as the scan of an identifier. The
+-- attachments of a row go with it when it is purged or its user erased.
+CREATE TABLE IF NOT EXISTS attachments (
+  id SERIAL PRIMARY KEY,
+  entity TEXT NOT NULL CHECK (entity IN ('users', 'contacts', 'addresses', 'identifiers')),
+  entity_id INT NOT NULL,
+  file_id INT NOT NULL REFERENCES files(id),
+  file_name TEXT NOT NULL,
+  created_by_id INT,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (entity, entity_id, file_id)
+);
+
+CREATE INDEX IF NOT EXISTS attachments_file_id_idx ON attachments (file_id);
+
+-- Files are only ever added and purged. The attachments are not audited, their
+-- file names may be personal data.
+CREATE TRIGGER files_audit_log AFTER INSERT OR DELETE ON files
+FOR EACH ROW EXECUTE FUNCTION audit_log_write();
+
+COMMIT;
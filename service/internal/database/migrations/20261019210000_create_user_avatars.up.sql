@@ -0,0 +1,16 @@
+BEGIN;
+
+-- The avatar of a user, as files: size 0 is the square image uploaded, the
+-- others its thumbnails of that many pixels a side, made by the worker. A new
+-- avatar replaces every row of the user.
+CREATE TABLE IF NOT EXISTS user_avatars (
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  size INT NOT NULL CHECK (size >= 0),
+  file_id INT NOT NULL REFERENCES files(id),
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id, size)
+);
+
+CREATE INDEX IF NOT EXISTS user_avatars_file_id_idx ON user_avatars (file_id);
+
+COMMIT;
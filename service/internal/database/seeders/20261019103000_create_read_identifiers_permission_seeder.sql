@@ -0,0 +1,82 @@
+BEGIN;
+
+INSERT INTO
+  mix_values (
+    group_id,
+    name,
+    description,
+    status,
+    options_json,
+    created_at,
+    updated_at
+  )
+VALUES
+  (
+    (
+      SELECT
+        id
+      FROM
+        groups
+      WHERE
+        name = 'permissions'
+    ),
+    'read_identifiers',
+    'Permission to read identifier numbers',
+    1,
+    '{}',
+    CURRENT_TIMESTAMP,
+    CURRENT_TIMESTAMP
+  );
+
+INSERT INTO
+  pools (
+    group1_id,
+    group2_id,
+    mv1_id,
+    mv2_id,
+    created_by_id,
+    updated_by_id,
+    created_at,
+    updated_at
+  )
+VALUES
+  (
+    (
+      SELECT
+        id
+      FROM
+        groups
+      WHERE
+        name = 'roles'
+    ),
+    (
+      SELECT
+        id
+      FROM
+        groups
+      WHERE
+        name = 'permissions'
+    ),
+    (
+      SELECT
+        id
+      FROM
+        mix_values
+      WHERE
+        name = 'superadmin'
+    ),
+    (
+      SELECT
+        id
+      FROM
+        mix_values
+      WHERE
+        name = 'read_identifiers'
+    ),
+    1,
+    1,
+    CURRENT_TIMESTAMP,
+    CURRENT_TIMESTAMP
+  );
+
+COMMIT;
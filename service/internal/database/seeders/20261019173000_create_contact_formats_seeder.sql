@@ -0,0 +1,80 @@
+BEGIN;
+
+-- the existing contact types are phone numbers, Indonesian ones when written
+-- without their country code
+UPDATE
+  mix_values
+SET
+  options_json = '{"format": "phone", "default_region": "ID"}'
+WHERE
+  group_id = (
+    SELECT
+      id
+    FROM
+      groups
+    WHERE
+      name = 'contacts'
+  )
+  AND name IN ('Pribadi (Nomor HP/WhatsApp)', 'Rumah', 'Kantor', 'Saudara');
+
+INSERT INTO
+  mix_values (
+    group_id,
+    name,
+    description,
+    status,
+    options_json,
+    created_at,
+    updated_at
+  )
+VALUES
+  (
+    (
+      SELECT
+        id
+      FROM
+        groups
+      WHERE
+        name = 'contacts'
+    ),
+    'Email',
+    'Kontak - Email',
+    1,
+    '{"format": "email"}',
+    CURRENT_TIMESTAMP,
+    CURRENT_TIMESTAMP
+  ),
+  (
+    (
+      SELECT
+        id
+      FROM
+        groups
+      WHERE
+        name = 'contacts'
+    ),
+    'Situs Web',
+    'Kontak - Situs Web',
+    1,
+    '{"format": "url"}',
+    CURRENT_TIMESTAMP,
+    CURRENT_TIMESTAMP
+  ),
+  (
+    (
+      SELECT
+        id
+      FROM
+        groups
+      WHERE
+        name = 'contacts'
+    ),
+    'Media Sosial',
+    'Kontak - Media Sosial',
+    1,
+    '{"format": "handle"}',
+    CURRENT_TIMESTAMP,
+    CURRENT_TIMESTAMP
+  );
+
+COMMIT;
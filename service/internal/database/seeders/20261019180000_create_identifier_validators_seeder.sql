@@ -0,0 +1,26 @@
+BEGIN;
+
+-- the format and check digit rules of the Indonesian identifiers; Lainnya
+-- takes anything
+UPDATE
+  mix_values
+SET
+  options_json = CASE
+    name
+    WHEN 'KTP' THEN '{"validators": [{"type": "custom", "name": "ktp"}]}'
+    WHEN 'NPWP' THEN '{"validators": [{"type": "custom", "name": "npwp"}]}'
+    WHEN 'SIM' THEN '{"validators": [{"type": "regex", "pattern": "^[0-9]{12,14}$", "message": "the ref_num must be 12 to 14 digits"}]}'
+    WHEN 'Passport' THEN '{"validators": [{"type": "regex", "pattern": "^[A-Z][0-9]{7}$", "message": "the ref_num must be a letter followed by 7 digits"}]}'
+  END::JSONB
+WHERE
+  group_id = (
+    SELECT
+      id
+    FROM
+      groups
+    WHERE
+      name = 'identifiers'
+  )
+  AND name IN ('KTP', 'NPWP', 'SIM', 'Passport');
+
+COMMIT;
@@ -1,6 +1,7 @@
 package dtos
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -13,6 +14,10 @@ type GetUsersRequest struct {
 	Page           *string `json:"page" default:"1"`              // Default page to 1
 	OrderColumn    string  `json:"order_column" default:"id"`     // Default order column to "id"
 	OrderDirection string  `json:"order_direction" default:"asc"` // Default order direction to "asc"
+	Sort           string  `json:"sort"`                          // e.g. "-created_at,name", overrides order_column
+	Nulls          string  `json:"nulls"`                         // "first" or "last"
+	Match          string  `json:"match"`                         // "contains" (default) or "fuzzy"
+	Threshold      *string `json:"threshold"`                     // fuzzy similarity, defaults to FUZZY_MATCH_THRESHOLD
 }
 
 type CreateUserRequest struct {
@@ -32,11 +37,15 @@ type UpdateUserRequest struct {
 	Address  *string  `json:"address"`
 	Password *string  `json:"password"`
 	RoleIDs  []uint32 `json:"role_ids"`
+	Version  *uint    `json:"version"` // expected version, If-Match takes precedence
 }
 
 type GetUserByIDParams struct {
 	ID        uint `json:"id"`
 	IsDeleted *int
+	Fields    []string   // nil selects every column
+	AsOf      *time.Time // read the version current at that time from the history
+	Version   *uint      // read that version from the history
 }
 
 type GetUserByIDRequest struct {
@@ -44,14 +53,19 @@ type GetUserByIDRequest struct {
 }
 
 type DeleteUserRequest struct {
-	ID uint `json:"id"`
+	ID      uint  `json:"id"`
+	Version *uint `json:"version"` // expected version, If-Match takes precedence
 }
 
 type UserListDTO struct {
-	ID       int     `json:"id"`
-	Username *string `json:"username"`
-	Name     string  `json:"name"`
-	Email    string  `json:"email"`
+	ID        int      `json:"id"`
+	Username  *string  `json:"username"`
+	Name      string   `json:"name"`
+	Email     string   `json:"email"`
+	Version   uint     `json:"version"`
+	Score     *float64 `json:"score,omitempty"`
+	AvatarURL *string  `json:"avatar_url" db:"-"`
+	DeletedAt *string  `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 type UserDetailDTO struct {
@@ -63,8 +77,26 @@ type UserDetailDTO struct {
 	Password    *string  `json:"password"`
 	Roles       []string `json:"roles"`
 	Permissions []string `json:"permissions"`
-	CreatedAt   *string  `json:"created_at"`
+	// the user's primary contacts and address, always the current ones
+	PrimaryEmail   *string `json:"primary_email"`
+	PrimaryPhone   *string `json:"primary_phone"`
+	PrimaryAddress *string `json:"primary_address"`
+	// the signed URL of the user's avatar, always the current one
+	AvatarURL   *string `json:"avatar_url" db:"-"`
+	CreatedAt   *string `json:"created_at"`
+	Version     uint    `json:"version"`
+	CreatedByID *uint   `json:"created_by_id" db:"created_by_id"`
+	UpdatedByID *uint   `json:"updated_by_id" db:"updated_by_id"`
+}
+
+// UserIncludes holds the relations loaded for `include`, keyed by user ID.
+type UserIncludes struct {
+	Contacts    map[uint][]ContactListDTO
+	Addresses   map[uint][]AddressListDTO
+	Identifiers map[uint][]IdentifierListDTO
+	Roles       map[uint][]string
 }
+
 type GetUsersResult struct {
 	Users []UserListDTO
 	Total int
@@ -83,11 +115,18 @@ type RegisterRequest struct {
 	Password string  `json:"password"`
 }
 
+// IdentifierValidity are the dates an identifier is valid between, as
+// YYYY-MM-DD.
+type IdentifierValidity struct {
+	IssuedAt  *string `json:"issued_at"`
+	ExpiresAt *string `json:"expires_at"`
+}
+
 type CreateIdentifierRequest struct {
 	UserID           uint   `json:"user_id"`
 	TypeIdentifierID uint   `json:"type_identifier_id"`
 	RefNum           string `json:"ref_num"`
-	Status           uint   `json:"status"`
+	IdentifierValidity
 }
 
 type UpdateIdentifierRequest struct {
@@ -95,7 +134,26 @@ type UpdateIdentifierRequest struct {
 	UserID           uint   `json:"user_id"`
 	TypeIdentifierID *uint  `json:"type_identifier_id"`
 	RefNum           string `json:"ref_num"`
-	Status           uint   `json:"status"`
+	Version          *uint  `json:"version"` // expected version, If-Match takes precedence
+	IdentifierValidity
+}
+
+// IdentifierRefNumCheck is a ref_num written to an identifier, checked against
+// the validators of its type.
+type IdentifierRefNumCheck struct {
+	ID               uint // the identifier written, 0 for a new one
+	TypeIdentifierID uint // 0 for the type of identifier ID
+	RefNum           string
+}
+
+// InvalidIdentifierDTO is an identifier whose ref_num fails the validators of
+// its type.
+type InvalidIdentifierDTO struct {
+	ID                 uint   `json:"id"`
+	UserID             uint   `json:"user_id"`
+	TypeIdentifierID   uint   `json:"type_identifier_id"`
+	TypeIdentifierName string `json:"type_identifier_name"`
+	Error              string `json:"error"`
 }
 
 type GetIdentifierByIDRequest struct {
@@ -106,6 +164,9 @@ type GetIdentifierParams struct {
 	ID        uint
 	UserID    uint
 	IsDeleted *int
+	Fields    []string   // nil selects every column
+	AsOf      *time.Time // read the version current at that time from the history
+	Version   *uint      // read that version from the history
 }
 
 func NewGetIdentifierParams(id uint) *GetIdentifierParams {
@@ -117,7 +178,27 @@ func NewGetIdentifierParams(id uint) *GetIdentifierParams {
 }
 
 type DeleteIdentifierRequest struct {
-	ID uint `json:"id"`
+	ID      uint  `json:"id"`
+	Version *uint `json:"version"` // expected version, If-Match takes precedence
+}
+
+// IdentifierTransitionRequest moves an identifier to another status, see
+// utils.CanTransitionIdentifier.
+type IdentifierTransitionRequest struct {
+	ID      uint    `json:"id"`
+	Version *uint   `json:"version"` // expected version, If-Match takes precedence
+	Reason  *string `json:"reason"`  // required to reject
+}
+
+// IdentifierTransitionDTO is a status transition of an identifier.
+type IdentifierTransitionDTO struct {
+	ID         uint      `json:"id" db:"id"`
+	FromStatus string    `json:"from_status" db:"from_status"`
+	ToStatus   string    `json:"to_status" db:"to_status"`
+	Reason     *string   `json:"reason" db:"reason"`
+	ActorID    *uint     `json:"actor_id" db:"actor_id"`
+	ActorName  *string   `json:"actor_name" db:"actor_name"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 }
 
 type IdentifierListDTO struct {
@@ -127,9 +208,14 @@ type IdentifierListDTO struct {
 	TypeIdentifierID   uint    `json:"type_identifier_id" db:"type_identifier_id"`
 	TypeIdentifierName string  `json:"type_identifier_name" db:"type_identifier_name"`
 	RefNum             string  `json:"ref_num" db:"ref_num"`
-	Status             uint    `json:"status" db:"status"`
+	Status             string  `json:"status" db:"status"`
+	StatusReason       *string `json:"status_reason" db:"status_reason"`
+	IssuedAt           *string `json:"issued_at" db:"issued_at"`
+	ExpiresAt          *string `json:"expires_at" db:"expires_at"`
 	CreatedAt          *string `json:"created_at" db:"created_at"`
 	UpdatedAt          *string `json:"updated_at" db:"updated_at"`
+	Version            uint    `json:"version" db:"version"`
+	DeletedAt          *string `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 type IdentifierDetailDTO struct {
@@ -139,9 +225,15 @@ type IdentifierDetailDTO struct {
 	TypeIdentifierID   uint       `json:"type_identifier_id" db:"type_identifier_id"`
 	TypeIdentifierName string     `json:"type_identifier_name" db:"type_identifier_name"`
 	RefNum             string     `json:"ref_num" db:"ref_num"`
-	Status             uint       `json:"status" db:"status"`
+	Status             string     `json:"status" db:"status"`
+	StatusReason       *string    `json:"status_reason" db:"status_reason"`
+	IssuedAt           *string    `json:"issued_at" db:"issued_at"`
+	ExpiresAt          *string    `json:"expires_at" db:"expires_at"`
 	CreatedAt          *time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt          *time.Time `json:"updated_at" db:"updated_at"`
+	Version            uint       `json:"version" db:"version"`
+	CreatedByID        *uint      `json:"created_by_id" db:"created_by_id"`
+	UpdatedByID        *uint      `json:"updated_by_id" db:"updated_by_id"`
 	DeletedAt          *time.Time `json:"deleted_at" db:"deleted_at"`
 }
 type ListIdentifiersResult struct {
@@ -163,6 +255,16 @@ type UpdateContactRequest struct {
 	TypeContactID *uint  `json:"type_contact_id"`
 	RefNum        string `json:"ref_num"`
 	Status        uint   `json:"status"`
+	Version       *uint  `json:"version"` // expected version, If-Match takes precedence
+}
+
+// ContactRefNumCheck is a ref_num written to a contact, checked against the
+// format of its type and the other contacts of its user.
+type ContactRefNumCheck struct {
+	ID            uint // the contact written, 0 for a new one
+	UserID        uint
+	TypeContactID uint // 0 for the type of contact ID
+	RefNum        string
 }
 
 type GetContactByIDRequest struct {
@@ -173,6 +275,9 @@ type GetContactParams struct {
 	ID        uint
 	UserID    uint
 	IsDeleted *int
+	Fields    []string   // nil selects every column
+	AsOf      *time.Time // read the version current at that time from the history
+	Version   *uint      // read that version from the history
 }
 
 func NewGetContactParams(id uint) *GetContactParams {
@@ -184,32 +289,42 @@ func NewGetContactParams(id uint) *GetContactParams {
 }
 
 type DeleteContactRequest struct {
-	ID uint `json:"id"`
+	ID      uint  `json:"id"`
+	Version *uint `json:"version"` // expected version, If-Match takes precedence
 }
 
 type ContactListDTO struct {
-	ID              int     `json:"id" db:"id"`
-	UserID          uint    `json:"user_id" db:"user_id"`
-	UserName        string  `json:"user_name" db:"user_name"`
-	TypeContactID   uint    `json:"type_contact_id" db:"type_contact_id"`
-	TypeContactName string  `json:"type_contact_name" db:"type_contact_name"`
-	RefNum          string  `json:"ref_num" db:"ref_num"`
-	Status          uint    `json:"status" db:"status"`
-	CreatedAt       *string `json:"created_at" db:"created_at"`
-	UpdatedAt       *string `json:"updated_at" db:"updated_at"`
+	ID               int     `json:"id" db:"id"`
+	UserID           uint    `json:"user_id" db:"user_id"`
+	UserName         string  `json:"user_name" db:"user_name"`
+	TypeContactID    uint    `json:"type_contact_id" db:"type_contact_id"`
+	TypeContactName  string  `json:"type_contact_name" db:"type_contact_name"`
+	RefNum           string  `json:"ref_num" db:"ref_num"`
+	NormalizedRefNum *string `json:"normalized_ref_num" db:"normalized_ref_num"`
+	IsPrimary        bool    `json:"is_primary" db:"is_primary"`
+	Status           uint    `json:"status" db:"status"`
+	CreatedAt        *string `json:"created_at" db:"created_at"`
+	UpdatedAt        *string `json:"updated_at" db:"updated_at"`
+	Version          uint    `json:"version" db:"version"`
+	DeletedAt        *string `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 type ContactDetailDTO struct {
-	ID              uint       `json:"id" db:"id"`
-	UserID          uint       `json:"user_id" db:"user_id"`
-	UserName        string     `json:"user_name" db:"user_name"`
-	TypeContactID   uint       `json:"type_contact_id" db:"type_contact_id"`
-	TypeContactName string     `json:"type_contact_name" db:"type_contact_name"`
-	RefNum          string     `json:"ref_num" db:"ref_num"`
-	Status          uint       `json:"status" db:"status"`
-	CreatedAt       *time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt       *time.Time `json:"updated_at" db:"updated_at"`
-	DeletedAt       *time.Time `json:"deleted_at" db:"deleted_at"`
+	ID               uint       `json:"id" db:"id"`
+	UserID           uint       `json:"user_id" db:"user_id"`
+	UserName         string     `json:"user_name" db:"user_name"`
+	TypeContactID    uint       `json:"type_contact_id" db:"type_contact_id"`
+	TypeContactName  string     `json:"type_contact_name" db:"type_contact_name"`
+	RefNum           string     `json:"ref_num" db:"ref_num"`
+	NormalizedRefNum *string    `json:"normalized_ref_num" db:"normalized_ref_num"`
+	IsPrimary        bool       `json:"is_primary" db:"is_primary"`
+	Status           uint       `json:"status" db:"status"`
+	CreatedAt        *time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        *time.Time `json:"updated_at" db:"updated_at"`
+	Version          uint       `json:"version" db:"version"`
+	CreatedByID      *uint      `json:"created_by_id" db:"created_by_id"`
+	UpdatedByID      *uint      `json:"updated_by_id" db:"updated_by_id"`
+	DeletedAt        *time.Time `json:"deleted_at" db:"deleted_at"`
 }
 type ListContactsResult struct {
 	Contacts []ContactListDTO
@@ -217,11 +332,25 @@ type ListContactsResult struct {
 	Err      error
 }
 
+// AddressFields are the structured fields of an address. When address_line1
+// is given, ref_num is formatted from them.
+type AddressFields struct {
+	AddressLine1 string   `json:"address_line1"`
+	AddressLine2 string   `json:"address_line2"`
+	City         string   `json:"city"`
+	Region       string   `json:"region"`
+	PostalCode   string   `json:"postal_code"`
+	CountryCode  string   `json:"country_code"` // ISO 3166-1 alpha-2
+	Latitude     *float64 `json:"latitude"`
+	Longitude    *float64 `json:"longitude"`
+}
+
 type CreateAddressRequest struct {
 	TypeAddressID uint   `json:"type_address_id"`
 	UserID        uint   `json:"user_id"`
 	RefNum        string `json:"ref_num"`
 	Status        uint   `json:"status"`
+	AddressFields
 }
 
 type UpdateAddressRequest struct {
@@ -230,6 +359,8 @@ type UpdateAddressRequest struct {
 	TypeAddressID *uint  `json:"type_address_id"`
 	RefNum        string `json:"ref_num"`
 	Status        uint   `json:"status"`
+	Version       *uint  `json:"version"` // expected version, If-Match takes precedence
+	AddressFields
 }
 
 type GetAddressByIDRequest struct {
@@ -240,6 +371,9 @@ type GetAddressParams struct {
 	ID        uint
 	UserID    uint
 	IsDeleted *int
+	Fields    []string   // nil selects every column
+	AsOf      *time.Time // read the version current at that time from the history
+	Version   *uint      // read that version from the history
 }
 
 func NewGetAddressParams(id uint) *GetAddressParams {
@@ -251,19 +385,32 @@ func NewGetAddressParams(id uint) *GetAddressParams {
 }
 
 type DeleteAddressRequest struct {
-	ID uint `json:"id"`
+	ID      uint  `json:"id"`
+	Version *uint `json:"version"` // expected version, If-Match takes precedence
 }
 
 type AddressListDTO struct {
-	ID              int     `json:"id" db:"id"`
-	UserID          uint    `json:"user_id" db:"user_id"`
-	UserName        string  `json:"user_name" db:"user_name"`
-	TypeAddressID   uint    `json:"type_address_id" db:"type_address_id"`
-	TypeAddressName string  `json:"type_address_name" db:"type_address_name"`
-	RefNum          string  `json:"ref_num" db:"ref_num"`
-	Status          uint    `json:"status" db:"status"`
-	CreatedAt       *string `json:"created_at" db:"created_at"`
-	UpdatedAt       *string `json:"updated_at" db:"updated_at"`
+	ID              int      `json:"id" db:"id"`
+	UserID          uint     `json:"user_id" db:"user_id"`
+	UserName        string   `json:"user_name" db:"user_name"`
+	TypeAddressID   uint     `json:"type_address_id" db:"type_address_id"`
+	TypeAddressName string   `json:"type_address_name" db:"type_address_name"`
+	RefNum          string   `json:"ref_num" db:"ref_num"`
+	AddressLine1    *string  `json:"address_line1" db:"address_line1"`
+	AddressLine2    *string  `json:"address_line2" db:"address_line2"`
+	City            *string  `json:"city" db:"city"`
+	Region          *string  `json:"region" db:"region"`
+	PostalCode      *string  `json:"postal_code" db:"postal_code"`
+	CountryCode     *string  `json:"country_code" db:"country_code"`
+	Latitude        *float64 `json:"latitude" db:"latitude"`
+	Longitude       *float64 `json:"longitude" db:"longitude"`
+	DistanceKm      *float64 `json:"distance_km" db:"distance_km"` // from near, when given
+	IsPrimary       bool     `json:"is_primary" db:"is_primary"`
+	Status          uint     `json:"status" db:"status"`
+	CreatedAt       *string  `json:"created_at" db:"created_at"`
+	UpdatedAt       *string  `json:"updated_at" db:"updated_at"`
+	Version         uint     `json:"version" db:"version"`
+	DeletedAt       *string  `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 type AddressDetailDTO struct {
@@ -273,9 +420,21 @@ type AddressDetailDTO struct {
 	TypeAddressID   uint       `json:"type_address_id" db:"type_address_id"`
 	TypeAddressName string     `json:"type_address_name" db:"type_address_name"`
 	RefNum          string     `json:"ref_num" db:"ref_num"`
+	AddressLine1    *string    `json:"address_line1" db:"address_line1"`
+	AddressLine2    *string    `json:"address_line2" db:"address_line2"`
+	City            *string    `json:"city" db:"city"`
+	Region          *string    `json:"region" db:"region"`
+	PostalCode      *string    `json:"postal_code" db:"postal_code"`
+	CountryCode     *string    `json:"country_code" db:"country_code"`
+	Latitude        *float64   `json:"latitude" db:"latitude"`
+	Longitude       *float64   `json:"longitude" db:"longitude"`
+	IsPrimary       bool       `json:"is_primary" db:"is_primary"`
 	Status          uint       `json:"status" db:"status"`
 	CreatedAt       *time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt       *time.Time `json:"updated_at" db:"updated_at"`
+	Version         uint       `json:"version" db:"version"`
+	CreatedByID     *uint      `json:"created_by_id" db:"created_by_id"`
+	UpdatedByID     *uint      `json:"updated_by_id" db:"updated_by_id"`
 	DeletedAt       *time.Time `json:"deleted_at" db:"deleted_at"`
 }
 type ListAddressesResult struct {
@@ -305,3 +464,305 @@ type SchedulerListDTO struct {
 	StartAt     *string `json:"start_at" db:"start_at"`
 	EndAt       *string `json:"end_at" db:"end_at"`
 }
+
+type SearchParams struct {
+	Query           string   // tsquery built by utils.SearchQuery
+	Types           []string // entity types to return, every type when empty
+	UserID          uint     // authenticated user, owner of the records they can always find
+	ReadIdentifiers bool     // whether identifiers of other users are searchable
+	Page            int
+	PerPage         int
+}
+
+type SearchHitDTO struct {
+	Type    string  `json:"type" db:"type"`
+	ID      uint    `json:"id" db:"id"`
+	UserID  uint    `json:"user_id" db:"user_id"`
+	Title   string  `json:"title" db:"title"`
+	Snippet string  `json:"snippet" db:"snippet"`
+	Rank    float64 `json:"rank" db:"rank"`
+}
+
+type SearchResult struct {
+	Hits   []SearchHitDTO
+	Facets map[string]int
+	Err    error
+}
+
+type AuditLogListDTO struct {
+	ID        uint             `json:"id" db:"id"`
+	ActorID   *uint            `json:"actor_id" db:"actor_id"`
+	ActorName *string          `json:"actor_name" db:"actor_name"`
+	Entity    string           `json:"entity" db:"entity"`
+	EntityID  *uint            `json:"entity_id" db:"entity_id"`
+	Action    string           `json:"action" db:"action"`
+	Before    *json.RawMessage `json:"before" db:"before"`
+	After     *json.RawMessage `json:"after" db:"after"`
+	Metadata  *json.RawMessage `json:"metadata" db:"metadata"`
+	IPAddress *string          `json:"ip_address" db:"ip_address"`
+	RequestID *string          `json:"request_id" db:"request_id"`
+	CreatedAt *string          `json:"created_at" db:"created_at"`
+}
+
+type ListAuditLogsResult struct {
+	AuditLogs []AuditLogListDTO
+	Total     int
+	Err       error
+}
+
+type HistoryDTO struct {
+	Version       uint            `json:"version" db:"version"`
+	Operation     string          `json:"operation" db:"operation"`
+	Data          json.RawMessage `json:"data" db:"data"`
+	ChangedByID   *uint           `json:"changed_by_id" db:"changed_by_id"`
+	ChangedByName *string         `json:"changed_by_name" db:"changed_by_name"`
+	ValidFrom     string          `json:"valid_from" db:"valid_from"`
+	ValidTo       *string         `json:"valid_to" db:"valid_to"`
+}
+
+type ListHistoryResult struct {
+	History []HistoryDTO
+	Total   int
+	Err     error
+}
+
+type GetHistoryRequest struct {
+	ID uint `json:"id"`
+}
+
+type RevertRequest struct {
+	ID        uint  `json:"id"`
+	ToVersion uint  `json:"to_version"`
+	Version   *uint `json:"version"` // expected current version, If-Match takes precedence
+}
+
+type RevertUserRequest struct {
+	RevertRequest
+	RoleIDs []uint32 `json:"role_ids"` // the current roles when empty, roles have no history
+}
+
+// BulkRequest is the body of the bulk-* endpoints, items being the bodies of
+// the matching single item endpoint.
+type BulkRequest[T any] struct {
+	Mode  string `json:"mode"` // atomic (default) or partial
+	Items []T    `json:"items"`
+}
+
+// BulkDeleteItem is an item of bulk-delete-* and bulk-restore-*.
+type BulkDeleteItem struct {
+	ID      uint  `json:"id"`
+	Version *uint `json:"version"` // expected version, any when empty
+}
+
+// BulkItemResult is the outcome of the item at Index of a bulk request.
+type BulkItemResult struct {
+	Index   int               `json:"index"`
+	Status  string            `json:"status"`
+	ID      *uint             `json:"id,omitempty"`
+	Version *uint             `json:"version,omitempty"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+// ImportJobDTO is an import job without its file and report.
+type ImportJobDTO struct {
+	ID            uint       `json:"id" db:"id"`
+	Entity        string     `json:"entity" db:"entity"`
+	FileName      string     `json:"file_name" db:"file_name"`
+	Status        string     `json:"status" db:"status"`
+	TotalRows     int        `json:"total_rows" db:"total_rows"`
+	InvalidRows   int        `json:"invalid_rows" db:"invalid_rows"`
+	ProcessedRows int        `json:"processed_rows" db:"processed_rows"`
+	ImportedRows  int        `json:"imported_rows" db:"imported_rows"`
+	FailedRows    int        `json:"failed_rows" db:"failed_rows"`
+	Progress      float64    `json:"progress" db:"-"` // percentage of the rows processed by the commit
+	Error         *string    `json:"error" db:"error"`
+	CreatedByID   *uint      `json:"created_by_id" db:"created_by_id"`
+	CreatedAt     *time.Time `json:"created_at" db:"created_at"`
+	StartedAt     *time.Time `json:"started_at" db:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at" db:"finished_at"`
+}
+
+// ImportRowError is a line of an import report. Row is the line of the
+// spreadsheet, the header being line 1.
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+type GetImportJobRequest struct {
+	ID uint `json:"id"`
+}
+
+// ExportJobDTO is an export job, its file downloaded with download-export.
+type ExportJobDTO struct {
+	ID            uint       `json:"id" db:"id"`
+	Entity        string     `json:"entity" db:"entity"`
+	Format        string     `json:"format" db:"format"`
+	Status        string     `json:"status" db:"status"`
+	TotalRows     int        `json:"total_rows" db:"total_rows"`
+	ProcessedRows int        `json:"processed_rows" db:"processed_rows"`
+	Progress      float64    `json:"progress" db:"-"` // percentage of the rows written
+	FileSize      *int64     `json:"file_size" db:"file_size"`
+	Error         *string    `json:"error" db:"error"`
+	CreatedByID   *uint      `json:"created_by_id" db:"created_by_id"`
+	CreatedAt     *time.Time `json:"created_at" db:"created_at"`
+	StartedAt     *time.Time `json:"started_at" db:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at" db:"finished_at"`
+}
+
+type GetExportJobRequest struct {
+	ID uint `json:"id"`
+}
+
+// AttachmentDTO is a file attached to a row of an entity, downloaded through
+// the signed URL of url-attachment.
+type AttachmentDTO struct {
+	ID          uint      `json:"id" db:"id"`
+	Entity      string    `json:"entity" db:"entity"`
+	EntityID    uint      `json:"entity_id" db:"entity_id"`
+	FileName    string    `json:"file_name" db:"file_name"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	Size        int64     `json:"size" db:"size"`
+	SHA256      string    `json:"sha256" db:"sha256"`
+	StorageKey  string    `json:"-" db:"storage_key"`
+	OwnerID     uint      `json:"-" db:"owner_id"` // the user of the row attached to
+	CreatedByID *uint     `json:"created_by_id" db:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type GetAttachmentRequest struct {
+	ID uint `json:"id"`
+}
+
+// ListAttachmentsRequest names the row whose attachments are listed.
+type ListAttachmentsRequest struct {
+	Entity   string `json:"entity"`
+	EntityID uint   `json:"entity_id"`
+}
+
+// AttachmentURLDTO is a URL downloading an attachment until ExpiresAt.
+type AttachmentURLDTO struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AvatarDTO is the avatar of a user. AvatarURL gets a thumbnail with a size
+// parameter once the worker generated it, the uploaded image until then.
+type AvatarDTO struct {
+	UserID    uint   `json:"user_id"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// IdempotencyKey is an Idempotency-Key header, scoped to the user and route
+// it was sent to. UserID is 0 for anonymous requests.
+type IdempotencyKey struct {
+	UserID uint
+	Path   string
+	Key    string
+}
+
+// IdempotencyRecord is the request holding an IdempotencyKey and, once it
+// completed, the response replayed to its retries.
+type IdempotencyRecord struct {
+	RequestHash     string
+	Status          string
+	ResponseStatus  int
+	ResponseHeaders map[string]string
+	ResponseBody    []byte
+}
+
+type ExportPersonalDataRequest struct {
+	ID     uint   `json:"id"`
+	Format string `json:"format"` // json (default) or zip
+}
+
+// PersonalDataBundle is everything held about a user, as returned by
+// export-personal-data-user.
+type PersonalDataBundle struct {
+	GeneratedAt           time.Time       `json:"generated_at" db:"-"`
+	User                  json.RawMessage `json:"user" db:"user"`
+	Roles                 json.RawMessage `json:"roles" db:"roles"`
+	Contacts              json.RawMessage `json:"contacts" db:"contacts"`
+	Addresses             json.RawMessage `json:"addresses" db:"addresses"`
+	Identifiers           json.RawMessage `json:"identifiers" db:"identifiers"`
+	IdentifierTransitions json.RawMessage `json:"identifier_transitions" db:"identifier_transitions"`
+	AuditLogs             json.RawMessage `json:"audit_logs" db:"audit_logs"`
+}
+
+type EraseUserRequest struct {
+	ID      uint   `json:"id"`
+	Version *uint  `json:"version"` // expected version, If-Match takes precedence
+	Reason  string `json:"reason"`
+	Confirm bool   `json:"confirm"` // must be true, an erasure cannot be undone
+}
+
+// UserErasureDTO reports what erasing a user anonymised.
+type UserErasureDTO struct {
+	UserID      uint      `json:"user_id"`
+	ErasedAt    time.Time `json:"erased_at"`
+	Contacts    int       `json:"contacts"`
+	Addresses   int       `json:"addresses"`
+	Identifiers int       `json:"identifiers"`
+	Attachments int       `json:"attachments"` // detached, their files purged once orphaned
+	AuditLogs   int       `json:"audit_logs"`  // audit records about them, redacted
+}
+
+// DuplicateUserDTO is a pair of users that may be the same person, found by
+// index-duplicate-user.
+type DuplicateUserDTO struct {
+	UserID            uint    `json:"user_id" db:"user_id"`
+	UserName          *string `json:"user_name" db:"user_name"`
+	UserEmail         string  `json:"user_email" db:"user_email"`
+	DuplicateID       uint    `json:"duplicate_id" db:"duplicate_id"`
+	DuplicateName     *string `json:"duplicate_name" db:"duplicate_name"`
+	DuplicateEmail    string  `json:"duplicate_email" db:"duplicate_email"`
+	NameSimilarity    float64 `json:"name_similarity" db:"name_similarity"`
+	SharedContacts    int     `json:"shared_contacts" db:"shared_contacts"`
+	SharedIdentifiers int     `json:"shared_identifiers" db:"shared_identifiers"`
+	Score             float64 `json:"score" db:"score"`
+}
+
+type ListDuplicateUsersResult struct {
+	Duplicates []DuplicateUserDTO
+	Total      int
+	Err        error
+}
+
+type MergeUsersRequest struct {
+	SourceID      uint  `json:"source_id"`      // merged and soft deleted
+	TargetID      uint  `json:"target_id"`      // the survivor
+	SourceVersion *uint `json:"source_version"` // expected versions, optional
+	TargetVersion *uint `json:"target_version"`
+}
+
+// UserMergeDTO reports what merging a user into another moved.
+type UserMergeDTO struct {
+	SourceID    uint `json:"source_id"`
+	TargetID    uint `json:"target_id"`
+	Contacts    int  `json:"contacts"`
+	Addresses   int  `json:"addresses"`
+	Identifiers int  `json:"identifiers"`
+	Roles       int  `json:"roles"` // roles the target did not have yet
+}
+
+// UserLocationDTO is a user found by index-location-user, with the address
+// that put it there: its closest one with near, its first one otherwise.
+type UserLocationDTO struct {
+	ID            uint     `json:"id" db:"id"`
+	Name          *string  `json:"name" db:"name"`
+	Email         string   `json:"email" db:"email"`
+	AddressID     uint     `json:"address_id" db:"address_id"`
+	AddressRefNum *string  `json:"address_ref_num" db:"address_ref_num"`
+	City          *string  `json:"city" db:"city"`
+	CountryCode   *string  `json:"country_code" db:"country_code"`
+	Latitude      float64  `json:"latitude" db:"latitude"`
+	Longitude     float64  `json:"longitude" db:"longitude"`
+	DistanceKm    *float64 `json:"distance_km" db:"distance_km"` // from near, when given
+}
+
+type ListUserLocationsResult struct {
+	Users []UserLocationDTO
+	Total int
+	Err   error
+}
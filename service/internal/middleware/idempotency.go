@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/nibroos/nb-go-api/service/internal/config"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+// IdempotencyStore keeps the Idempotency-Keys and the responses to replay
+// for them, as repository.IdempotencyRepository does.
+type IdempotencyStore interface {
+	ClaimIdempotencyKey(ctx context.Context, key dtos.IdempotencyKey, requestHash string, ttl time.Duration, lockTimeout time.Duration) (bool, error)
+	GetIdempotencyKey(ctx context.Context, key dtos.IdempotencyKey) (*dtos.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, key dtos.IdempotencyKey, status int, headers map[string]string, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, key dtos.IdempotencyKey) error
+}
+
+// idempotencyPollInterval is how often a duplicate request checks whether the
+// request holding its key completed.
+const idempotencyPollInterval = 100 * time.Millisecond
+
+// maxIdempotencyKeyLength is the longest Idempotency-Key accepted.
+const maxIdempotencyKeyLength = 255
+
+// idempotencyReplayHeaders are the response headers replayed with the body.
+var idempotencyReplayHeaders = []string{fiber.HeaderContentType, fiber.HeaderETag}
+
+// Idempotency makes a route safe to retry with an Idempotency-Key header.
+// The first request with a key runs and its response is kept for
+// IDEMPOTENCY_TTL_HOURS; a retry with the same key and body gets that
+// response again without running, and one with another body is rejected with
+// 422. A duplicate arriving while the first request still runs waits for its
+// response. Server errors are not kept, the request runs again when retried.
+// Requests without the header run as usual.
+func Idempotency(store IdempotencyStore) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		raw := ctx.Get(utils.HeaderIdempotencyKey)
+		if raw == "" {
+			return ctx.Next()
+		}
+		if len(raw) > maxIdempotencyKeyLength {
+			return utils.GetResponse(ctx, nil, nil, "Invalid Idempotency-Key", http.StatusBadRequest, "the Idempotency-Key must be at most 255 characters", nil)
+		}
+
+		key := dtos.IdempotencyKey{UserID: authUserID(ctx), Path: ctx.Path(), Key: raw}
+		hash := requestHash(ctx)
+
+		deadline := time.Now().Add(config.GetIdempotencyWaitTimeout())
+		for {
+			claimed, err := store.ClaimIdempotencyKey(ctx.Context(), key, hash, config.GetIdempotencyTTL(), config.GetIdempotencyLockTimeout())
+			if err != nil {
+				return utils.GetResponse(ctx, nil, nil, "Failed to check the Idempotency-Key", http.StatusInternalServerError, err.Error(), nil)
+			}
+			if claimed {
+				return runIdempotent(ctx, store, key)
+			}
+
+			record, err := store.GetIdempotencyKey(ctx.Context(), key)
+			if errors.Is(err, sql.ErrNoRows) {
+				// released by a failed request in between, claim it again
+				continue
+			}
+			if err != nil {
+				return utils.GetResponse(ctx, nil, nil, "Failed to check the Idempotency-Key", http.StatusInternalServerError, err.Error(), nil)
+			}
+
+			if record.RequestHash != hash {
+				return utils.GetResponse(ctx, nil, nil, "Idempotency-Key reused", http.StatusUnprocessableEntity, "the Idempotency-Key was already used with a different request", nil)
+			}
+			if record.Status == utils.IdempotencyStatusCompleted {
+				return replayIdempotent(ctx, record)
+			}
+
+			if time.Now().After(deadline) {
+				return utils.GetResponse(ctx, nil, nil, "Request in progress", http.StatusConflict, "a request with this Idempotency-Key is still being processed, retry later", nil)
+			}
+			time.Sleep(idempotencyPollInterval)
+		}
+	}
+}
+
+// runIdempotent runs the request holding key and keeps its response.
+func runIdempotent(ctx *fiber.Ctx, store IdempotencyStore, key dtos.IdempotencyKey) error {
+	if err := ctx.Next(); err != nil {
+		releaseIdempotencyKey(ctx, store, key)
+		return err
+	}
+
+	status := ctx.Response().StatusCode()
+	if status >= http.StatusInternalServerError {
+		releaseIdempotencyKey(ctx, store, key)
+		return nil
+	}
+
+	headers := make(map[string]string)
+	for _, header := range idempotencyReplayHeaders {
+		if value := ctx.GetRespHeader(header); value != "" {
+			headers[header] = value
+		}
+	}
+
+	if err := store.CompleteIdempotencyKey(ctx.Context(), key, status, headers, ctx.Response().Body()); err != nil {
+		// the response stands, a retry runs again
+		log.Printf("Failed to store the response for Idempotency-Key %q: %v", key.Key, err)
+		releaseIdempotencyKey(ctx, store, key)
+	}
+	return nil
+}
+
+func releaseIdempotencyKey(ctx *fiber.Ctx, store IdempotencyStore, key dtos.IdempotencyKey) {
+	if err := store.ReleaseIdempotencyKey(ctx.Context(), key); err != nil {
+		log.Printf("Failed to release Idempotency-Key %q: %v", key.Key, err)
+	}
+}
+
+// replayIdempotent responds with the response kept for a key.
+func replayIdempotent(ctx *fiber.Ctx, record *dtos.IdempotencyRecord) error {
+	for header, value := range record.ResponseHeaders {
+		ctx.Set(header, value)
+	}
+	ctx.Set(utils.HeaderIdempotentReplayed, "true")
+	return ctx.Status(record.ResponseStatus).Send(record.ResponseBody)
+}
+
+// requestHash identifies a request by its method, route and body.
+func requestHash(ctx *fiber.Ctx) string {
+	h := sha256.New()
+	h.Write([]byte(ctx.Method()))
+	h.Write([]byte{0})
+	h.Write([]byte(ctx.Path()))
+	h.Write([]byte{0})
+	h.Write(ctx.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// authUserID returns the ID of the user of the JWT, 0 without one.
+func authUserID(ctx *fiber.Ctx) uint {
+	if claims, ok := ctx.Locals("user").(jwt.MapClaims); ok {
+		if userID, ok := claims["user_id"].(float64); ok {
+			return uint(userID)
+		}
+	}
+	return 0
+}
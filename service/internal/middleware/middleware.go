@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -97,6 +98,11 @@ func ConvertRequestToFilters() fiber.Handler {
 
 func ConvertEmptyStringsToNull() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
+		// File uploads are not JSON, nor are bodyless downloads
+		if strings.HasPrefix(ctx.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) || len(ctx.Body()) == 0 {
+			return ctx.Next()
+		}
+
 		// Parse the request body into a map
 		var body map[string]interface{}
 		if err := json.Unmarshal(ctx.Body(), &body); err != nil {
@@ -143,6 +149,11 @@ func ConvertToClientTimezone() fiber.Handler {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid timezone"})
 		}
 
+		// File uploads are not JSON
+		if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+			return c.Next()
+		}
+
 		// Convert start_at and end_at if they exist in the request body
 		var body map[string]interface{}
 		if err := c.BodyParser(&body); err != nil {
@@ -5,6 +5,7 @@ import (
 
 	"github.com/nibroos/nb-go-api/service/internal/dtos"
 	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
 	"github.com/stretchr/testify/mock"
 	"gorm.io/gorm"
 )
@@ -29,6 +30,11 @@ func (m *MockUserRepository) GetUsers(ctx context.Context, filters map[string]st
 	return args.Get(0).([]dtos.UserListDTO), args.Int(1), args.Error(2)
 }
 
+func (m *MockUserRepository) GetTrashedUsers(ctx context.Context, filters map[string]string) ([]dtos.UserListDTO, int, error) {
+	args := m.Called(ctx, filters)
+	return args.Get(0).([]dtos.UserListDTO), args.Int(1), args.Error(2)
+}
+
 func (m *MockUserRepository) GetUserByID(ctx context.Context, params *dtos.GetUserByIDParams) (*dtos.UserDetailDTO, error) {
 	args := m.Called(ctx, params)
 	return args.Get(0).(*dtos.UserDetailDTO), args.Error(1)
@@ -39,42 +45,78 @@ func (m *MockUserRepository) GetUserByEmail(ctx context.Context, email string) (
 	return args.Get(0).(*dtos.UserDetailDTO), args.Error(1)
 }
 
-func (m *MockUserRepository) BeginTransaction() *gorm.DB {
-	args := m.Called()
-	return args.Get(0).(*gorm.DB)
+func (m *MockUserRepository) GetUserIncludes(ctx context.Context, userIDs []uint, includes []string) (*dtos.UserIncludes, error) {
+	args := m.Called(ctx, userIDs, includes)
+	return args.Get(0).(*dtos.UserIncludes), args.Error(1)
+}
+
+// WithTransaction runs fn with ctx, its own return value standing in for the
+// commit error.
+func (m *MockUserRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	args := m.Called(ctx)
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) AttachRoles(ctx context.Context, user *models.User, roleIDs []uint32) error {
+	args := m.Called(ctx, user, roleIDs)
+	return args.Error(0)
 }
 
-func (m *MockUserRepository) AttachRoles(tx *gorm.DB, user *models.User, roleIDs []uint32) error {
-	args := m.Called(tx, user, roleIDs)
+func (m *MockUserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	args := m.Called(ctx, user)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) CreateUser(tx *gorm.DB, user *models.User) error {
-	args := m.Called(tx, user)
+func (m *MockUserRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	args := m.Called(ctx, user)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) UpdateUser(tx *gorm.DB, user *models.User) error {
-	args := m.Called(tx, user)
+func (m *MockUserRepository) DeleteUser(ctx context.Context, id uint, version uint) error {
+	args := m.Called(ctx, id, version)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) DeleteUser(tx *gorm.DB, id uint) error {
-	args := m.Called(tx, id)
+func (m *MockUserRepository) DeleteRolesByUserID(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) DeleteRolesByUserID(tx *gorm.DB, userID uint) error {
-	args := m.Called(tx, userID)
+func (m *MockUserRepository) RestoreUser(ctx context.Context, id uint, version uint) error {
+	args := m.Called(ctx, id, version)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) RestoreUser(tx *gorm.DB, id uint) error {
-	args := m.Called(tx, id)
+func (m *MockUserRepository) DeleteChildrenByUserID(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) Commit(tx *gorm.DB) error {
-	args := m.Called(tx)
+func (m *MockUserRepository) RestoreChildrenByUserID(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
 	return args.Error(0)
 }
+
+func (m *MockUserRepository) GetUserHistory(ctx context.Context, id uint, filters map[string]string) ([]dtos.HistoryDTO, int, error) {
+	args := m.Called(ctx, id, filters)
+	return args.Get(0).([]dtos.HistoryDTO), args.Int(1), args.Error(2)
+}
+
+func (m *MockUserRepository) GetRoleIDsByUserID(ctx context.Context, userID uint) ([]uint32, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]uint32), args.Error(1)
+}
+
+func (m *MockUserRepository) ExportUsers(filters map[string]string) (*repository.ListQuery, error) {
+	args := m.Called(filters)
+	list, _ := args.Get(0).(*repository.ListQuery)
+	return list, args.Error(1)
+}
+
+func (m *MockUserRepository) GetMergedUserID(ctx context.Context, id uint) (uint, bool, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(uint), args.Bool(1), args.Error(2)
+}
@@ -12,8 +12,18 @@ type Address struct {
 	TypeAddressID uint       `json:"type_address_id" gorm:"column:type_address_id"`
 	UserID        uint       `json:"user_id" gorm:"column:user_id"`
 	RefNum        string     `json:"ref_num" gorm:"column:ref_num"`
+	AddressLine1  *string    `json:"address_line1" gorm:"column:address_line1"`
+	AddressLine2  *string    `json:"address_line2" gorm:"column:address_line2"`
+	City          *string    `json:"city" gorm:"column:city"`
+	Region        *string    `json:"region" gorm:"column:region"`
+	PostalCode    *string    `json:"postal_code" gorm:"column:postal_code"`
+	CountryCode   *string    `json:"country_code" gorm:"column:country_code"`
+	Latitude      *float64   `json:"latitude" gorm:"column:latitude"`
+	Longitude     *float64   `json:"longitude" gorm:"column:longitude"`
+	IsPrimary     bool       `json:"is_primary" gorm:"column:is_primary"`
 	Status        uint       `json:"status" gorm:"column:status"`
 	OptionsJSON   *string    `json:"options_json" gorm:"column:options_json"`
 	CreatedAt     *time.Time `json:"created_at" gorm:"column:created_at"`
 	DeletedAt     *time.Time `json:"deleted_at" gorm:"column:deleted_at"`
+	Version       uint       `json:"version" gorm:"column:version;default:1"`
 }
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// File is a distinct file content kept in the storage, shared by the
+// attachments of it.
+type File struct {
+	ID          uint       `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	SHA256      string     `json:"sha256" gorm:"column:sha256"`
+	Size        int64      `json:"size" gorm:"column:size"`
+	ContentType string     `json:"content_type" gorm:"column:content_type"`
+	StorageKey  string     `json:"storage_key" gorm:"column:storage_key"`
+	CreatedAt   *time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+type Attachment struct {
+	ID          uint       `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	Entity      string     `json:"entity" gorm:"column:entity"`
+	EntityID    uint       `json:"entity_id" gorm:"column:entity_id"`
+	FileID      uint       `json:"file_id" gorm:"column:file_id"`
+	FileName    string     `json:"file_name" gorm:"column:file_name"`
+	CreatedByID *uint      `json:"created_by_id" gorm:"column:created_by_id"`
+	CreatedAt   *time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// UserAvatar is an image of the avatar of a user: the square image uploaded
+// when Size is 0, a thumbnail of Size pixels a side otherwise.
+type UserAvatar struct {
+	UserID    uint       `json:"user_id" gorm:"column:user_id;primaryKey"`
+	Size      int        `json:"size" gorm:"column:size;primaryKey"`
+	FileID    uint       `json:"file_id" gorm:"column:file_id"`
+	CreatedAt *time.Time `json:"created_at" gorm:"column:created_at"`
+}
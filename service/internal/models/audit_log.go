@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+type AuditLog struct {
+	ID        uint       `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ActorID   *uint      `json:"actor_id" gorm:"column:actor_id"`
+	Entity    string     `json:"entity" gorm:"column:entity"`
+	EntityID  *uint      `json:"entity_id" gorm:"column:entity_id"`
+	Action    string     `json:"action" gorm:"column:action"`
+	Before    *string    `json:"before" gorm:"column:before;type:jsonb"`
+	After     *string    `json:"after" gorm:"column:after;type:jsonb"`
+	Metadata  *string    `json:"metadata" gorm:"column:metadata;type:jsonb"`
+	IPAddress *string    `json:"ip_address" gorm:"column:ip_address"`
+	RequestID *string    `json:"request_id" gorm:"column:request_id"`
+	CreatedAt *time.Time `json:"created_at" gorm:"column:created_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+type ExportJob struct {
+	ID            uint       `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	Entity        string     `json:"entity" gorm:"column:entity"`
+	Format        string     `json:"format" gorm:"column:format"`
+	Filters       string     `json:"filters" gorm:"column:filters;type:jsonb"`
+	Status        string     `json:"status" gorm:"column:status"`
+	TotalRows     int        `json:"total_rows" gorm:"column:total_rows"`
+	ProcessedRows int        `json:"processed_rows" gorm:"column:processed_rows"`
+	FileName      *string    `json:"file_name" gorm:"column:file_name"`
+	FileSize      *int64     `json:"file_size" gorm:"column:file_size"`
+	Error         *string    `json:"error" gorm:"column:error"`
+	CreatedByID   *uint      `json:"created_by_id" gorm:"column:created_by_id"`
+	CreatedAt     *time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt     *time.Time `json:"updated_at" gorm:"column:updated_at"`
+	StartedAt     *time.Time `json:"started_at" gorm:"column:started_at"`
+	FinishedAt    *time.Time `json:"finished_at" gorm:"column:finished_at"`
+}
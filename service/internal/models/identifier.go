@@ -12,8 +12,12 @@ type Identifier struct {
 	TypeIdentifierID uint       `json:"type_identifier_id" gorm:"column:type_identifier_id"`
 	UserID           uint       `json:"user_id" gorm:"column:user_id"`
 	RefNum           string     `json:"ref_num" gorm:"column:ref_num"`
-	Status           uint       `json:"status" gorm:"column:status"`
+	Status           string     `json:"status" gorm:"column:status"`
+	StatusReason     *string    `json:"status_reason" gorm:"column:status_reason"`
+	IssuedAt         *string    `json:"issued_at" gorm:"column:issued_at"`
+	ExpiresAt        *string    `json:"expires_at" gorm:"column:expires_at"`
 	OptionsJSON      *string    `json:"options_json" gorm:"column:options_json"`
 	CreatedAt        *time.Time `json:"created_at" gorm:"column:created_at"`
 	DeletedAt        *time.Time `json:"deleted_at" gorm:"column:deleted_at"`
+	Version          uint       `json:"version" gorm:"column:version;default:1"`
 }
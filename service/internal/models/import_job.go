@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+type ImportJob struct {
+	ID            uint       `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	Entity        string     `json:"entity" gorm:"column:entity"`
+	FileName      string     `json:"file_name" gorm:"column:file_name"`
+	File          []byte     `json:"-" gorm:"column:file"`
+	Mapping       string     `json:"mapping" gorm:"column:mapping;type:jsonb"`
+	Status        string     `json:"status" gorm:"column:status"`
+	TotalRows     int        `json:"total_rows" gorm:"column:total_rows"`
+	InvalidRows   int        `json:"invalid_rows" gorm:"column:invalid_rows"`
+	ProcessedRows int        `json:"processed_rows" gorm:"column:processed_rows"`
+	ImportedRows  int        `json:"imported_rows" gorm:"column:imported_rows"`
+	FailedRows    int        `json:"failed_rows" gorm:"column:failed_rows"`
+	Report        string     `json:"report" gorm:"column:report;type:jsonb"`
+	Error         *string    `json:"error" gorm:"column:error"`
+	CreatedByID   *uint      `json:"created_by_id" gorm:"column:created_by_id"`
+	CreatedAt     *time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt     *time.Time `json:"updated_at" gorm:"column:updated_at"`
+	StartedAt     *time.Time `json:"started_at" gorm:"column:started_at"`
+	FinishedAt    *time.Time `json:"finished_at" gorm:"column:finished_at"`
+}
@@ -12,6 +12,7 @@ type Scheduler struct {
 	Payload     string     `json:"payload" gorm:"column:payload"`
 	Status      string     `json:"status" gorm:"column:status"`
 	EntryID     int        `json:"entry_id" gorm:"column:entry_id"`
+	Version     uint       `json:"version" gorm:"column:version;default:1"`
 	StartAt     time.Time  `json:"start_at" gorm:"column:start_at"`
 	EndAt       *time.Time `json:"end_at" gorm:"column:end_at"`
 	CreatedAt   time.Time  `json:"created_at" gorm:"column:created_at"`
@@ -12,5 +12,6 @@ type User struct {
 	Email    string  `json:"email" gorm:"column:email;unique"`
 	Password string  `json:"-" gorm:"column:password"`
 	Address  *string `json:"address" gorm:"column:address"`
+	Version  uint    `json:"version" gorm:"column:version;default:1"`
 	Roles    []Role  `json:"roles,omitempty" gorm:"many2many:user_roles"`
 }
@@ -11,41 +11,207 @@ import (
 	"gorm.io/gorm"
 )
 
+// addressSortColumns maps the sort keys accepted by ListAddresses to their columns.
+var addressSortColumns = map[string]string{
+	"id":                "id",
+	"ref_num":           "ref_num",
+	"city":              "city",
+	"region":            "region",
+	"postal_code":       "postal_code",
+	"country_code":      "country_code",
+	"distance_km":       "distance_km",
+	"user_name":         "user_name",
+	"type_address_name": "type_address_name",
+	"is_primary":        "is_primary",
+	"status":            "status",
+	"created_at":        "created_at",
+	"updated_at":        "updated_at",
+}
+
+// AddressListFields are the fields index-address can select with `fields`.
+var AddressListFields = map[string]utils.Field{
+	"id":                {Column: "id"},
+	"user_id":           {Column: "user_id"},
+	"user_name":         {Column: "user_name"},
+	"type_address_id":   {Column: "type_address_id"},
+	"type_address_name": {Column: "type_address_name"},
+	"ref_num":           {Column: "ref_num"},
+	"address_line1":     {Column: "address_line1"},
+	"address_line2":     {Column: "address_line2"},
+	"city":              {Column: "city"},
+	"region":            {Column: "region"},
+	"postal_code":       {Column: "postal_code"},
+	"country_code":      {Column: "country_code"},
+	"latitude":          {Column: "latitude"},
+	"longitude":         {Column: "longitude"},
+	"distance_km":       {Column: "distance_km"},
+	"is_primary":        {Column: "is_primary"},
+	"status":            {Column: "status"},
+	"created_at":        {Column: "created_at"},
+	"updated_at":        {Column: "updated_at"},
+	"version":           {Column: "version"},
+}
+
+// AddressTrashFields are the fields index-trash-address can select with `fields`.
+var AddressTrashFields = trashFields(AddressListFields)
+
+// AddressDetailFields are the fields show-address can select with `fields`.
+var AddressDetailFields = map[string]utils.Field{
+	"id":                {Column: "c.id"},
+	"user_id":           {Column: "c.user_id"},
+	"user_name":         {Column: "u.name AS user_name"},
+	"type_address_id":   {Column: "c.type_address_id"},
+	"type_address_name": {Column: "ti.name AS type_address_name"},
+	"ref_num":           {Column: "c.ref_num"},
+	"address_line1":     {Column: "c.address_line1"},
+	"address_line2":     {Column: "c.address_line2"},
+	"city":              {Column: "c.city"},
+	"region":            {Column: "c.region"},
+	"postal_code":       {Column: "c.postal_code"},
+	"country_code":      {Column: "c.country_code"},
+	"latitude":          {Column: "c.latitude"},
+	"longitude":         {Column: "c.longitude"},
+	"is_primary":        {Column: "c.is_primary"},
+	"status":            {Column: "c.status"},
+	"created_at":        {Column: "c.created_at"},
+	"updated_at":        {Column: "c.updated_at"},
+	"version":           {Column: "c.version"},
+	"created_by_id":     {Column: "c.created_by_id"},
+	"updated_by_id":     {Column: "c.updated_by_id"},
+	"deleted_at":        {Column: "c.deleted_at"},
+}
+
+// userLocationSortColumns maps the sort keys accepted by ListUsersByLocation
+// to their columns.
+var userLocationSortColumns = map[string]string{
+	"id":           "id",
+	"name":         "name",
+	"email":        "email",
+	"city":         "city",
+	"country_code": "country_code",
+	"distance_km":  "distance_km",
+}
+
 type AddressRepository struct {
+	*Transactor
 	db    *gorm.DB
 	sqlDB *sqlx.DB
 }
 
 func NewAddressRepository(db *gorm.DB, sqlDB *sqlx.DB) *AddressRepository {
 	return &AddressRepository{
-		db:    db,
-		sqlDB: sqlDB,
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
 	}
 }
 
 func (r *AddressRepository) ListAddresses(ctx context.Context, filters map[string]string) ([]dtos.AddressListDTO, int, error) {
+	return r.listAddresses(ctx, filters, false)
+}
+
+// ListTrashedAddresses lists the soft deleted addresses, most recently deleted first.
+func (r *AddressRepository) ListTrashedAddresses(ctx context.Context, filters map[string]string) ([]dtos.AddressListDTO, int, error) {
+	return r.listAddresses(ctx, filters, true)
+}
+
+func (r *AddressRepository) listAddresses(ctx context.Context, filters map[string]string, trashed bool) ([]dtos.AddressListDTO, int, error) {
 	addresses := []dtos.AddressListDTO{}
 	var total int
 
+	list, err := addressListQuery(filters, trashed)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	perPage := utils.GetIntOrDefault(filters["per_page"], 10)
+	currentPage := utils.GetIntOrDefault(filters["page"], 1)
+
+	query := list.query + fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(list.args)+1, len(list.args)+2)
+	args := append(append([]interface{}{}, list.args...), perPage, (currentPage-1)*perPage)
+
+	// Channels for concurrent execution
+	countChan := make(chan error)
+	selectChan := make(chan error)
+
+	// Goroutine for count query
+	go func() {
+		err := getContext(ctx, r.sqlDB, &total, list.countQuery, list.args...)
+		countChan <- err
+	}()
+
+	// Goroutine for select query
+	go func() {
+		err := selectContext(ctx, r.sqlDB, &addresses, query, args...)
+		selectChan <- err
+	}()
+
+	// Wait for both goroutines to finish
+	countErr := <-countChan
+	selectErr := <-selectChan
+
+	if countErr != nil {
+		return nil, 0, countErr
+	}
+
+	if selectErr != nil {
+		return nil, 0, selectErr
+	}
+
+	return addresses, total, nil
+}
+
+// ExportAddresses returns the query of index-address for filters, unpaged, for
+// StreamExport.
+func (r *AddressRepository) ExportAddresses(filters map[string]string) (*ListQuery, error) {
+	return addressListQuery(filters, false)
+}
+
+// addressListQuery builds the query of index-address (index-trash-address when
+// trashed) for filters, without paging.
+func addressListQuery(filters map[string]string, trashed bool) (*ListQuery, error) {
+	allowedFields, sortColumns, scope := AddressListFields, addressSortColumns, "IS NULL"
+	if trashed {
+		allowedFields, sortColumns, scope = AddressTrashFields, trashSortColumns(addressSortColumns), "IS NOT NULL"
+		if filters["sort"] == "" && filters["order_column"] == "" {
+			filters["sort"] = "-deleted_at"
+		}
+	}
+
+	geo, i, err := parseGeoFilter(filters, 1)
+	if err != nil {
+		return nil, err
+	}
+	if geo.near && filters["sort"] == "" && filters["order_column"] == "" {
+		filters["sort"] = "distance_km"
+	}
+
 	from := `FROM (
-        SELECT c.id, c.user_id, c.type_address_id, c.ref_num, c.status, c.created_at, c.updated_at,
+        SELECT c.id, c.user_id, c.type_address_id, c.ref_num,
+        c.address_line1, c.address_line2, c.city, c.region, c.postal_code, c.country_code, c.latitude, c.longitude,
+        c.is_primary, c.status, c.created_at, c.updated_at, c.version, c.deleted_at,
+        ` + geo.distance + ` AS distance_km,
         u.name as user_name,
         ti.name as type_address_name
 
         FROM addresses c
         JOIN users u ON c.user_id = u.id
         JOIN mix_values ti ON c.type_address_id = ti.id
-        WHERE c.deleted_at IS NULL
+        WHERE c.deleted_at ` + scope + geo.where + `
     ) AS alias WHERE 1=1`
 
-	query := `SELECT * ` + from
+	fields, err := utils.ParseFields(filters["fields"], allowedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + utils.SelectColumns(fields, allowedFields) + ` ` + from
 	countQuery := `SELECT COUNT(*) ` + from
 
-	var args []interface{}
-	i := 1
+	args := geo.args
 	for key, value := range filters {
 		switch key {
-		case "ref_num":
+		case "ref_num", "city", "postal_code":
 			if value != "" {
 				query += fmt.Sprintf(" AND %s ILIKE $%d", key, i)
 				countQuery += fmt.Sprintf(" AND %s ILIKE $%d", key, i)
@@ -55,6 +221,13 @@ func (r *AddressRepository) ListAddresses(ctx context.Context, filters map[strin
 		}
 	}
 
+	if value, ok := filters["country_code"]; ok && value != "" {
+		query += fmt.Sprintf(" AND country_code = $%d", i)
+		countQuery += fmt.Sprintf(" AND country_code = $%d", i)
+		args = append(args, utils.NormalizeCountryCode(value))
+		i++
+	}
+
 	if value, ok := filters["user_id"]; ok && value != "" {
 		query += fmt.Sprintf(" AND user_id = $%d", i)
 		countQuery += fmt.Sprintf(" AND user_id = $%d", i)
@@ -62,6 +235,13 @@ func (r *AddressRepository) ListAddresses(ctx context.Context, filters map[strin
 		i++
 	}
 
+	if value, ok := filters["is_primary"]; ok && value != "" {
+		query += fmt.Sprintf(" AND is_primary = $%d", i)
+		countQuery += fmt.Sprintf(" AND is_primary = $%d", i)
+		args = append(args, value == "true" || value == "1")
+		i++
+	}
+
 	if value, ok := filters["global"]; ok && value != "" {
 		query += fmt.Sprintf(" AND (ref_num ILIKE $%d OR user_name ILIKE $%d OR type_address_name ILIKE $%d)", i, i+1, i+2)
 		countQuery += fmt.Sprintf(" AND (ref_num ILIKE $%d OR user_name ILIKE $%d OR type_address_name ILIKE $%d)", i, i+1, i+2)
@@ -69,59 +249,77 @@ func (r *AddressRepository) ListAddresses(ctx context.Context, filters map[strin
 		i += 3
 	}
 
-	countArgs := append([]interface{}{}, args...)
+	sort, err := utils.ParseSort(filters, sortColumns)
+	if err != nil {
+		return nil, err
+	}
+	query += sort.OrderBy("id")
 
-	allowedOrderColumns := []string{"id", "ref_num", "user_name", "type_address_name"}
-	orderColumn := utils.GetStringOrDefaultFromArray(filters["order_column"], allowedOrderColumns, "id")
-	orderDirection := utils.GetStringOrDefault(filters["order_direction"], "asc")
-	query += fmt.Sprintf(" ORDER BY %s %s", orderColumn, orderDirection)
+	return &ListQuery{query: query, countQuery: countQuery, args: args}, nil
+}
 
-	perPage := utils.GetIntOrDefault(filters["per_page"], 10)
-	currentPage := utils.GetIntOrDefault(filters["page"], 1)
+// ListUsersByLocation lists the live users with a live address matching the
+// near, radius and bbox filters, one of which is required, each with its
+// closest matching address. Sorted by distance with near, by ID otherwise.
+func (r *AddressRepository) ListUsersByLocation(ctx context.Context, filters map[string]string) ([]dtos.UserLocationDTO, int, error) {
+	users := []dtos.UserLocationDTO{}
+	var total int
 
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", i, i+1)
-	args = append(args, perPage, (currentPage-1)*perPage)
+	geo, i, err := parseGeoFilter(filters, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !geo.located() {
+		return nil, 0, fmt.Errorf("%w: near or bbox is required", utils.ErrInvalidFilter)
+	}
 
-	// Channels for concurrent execution
-	countChan := make(chan error)
-	selectChan := make(chan error)
+	from := `FROM (
+		SELECT DISTINCT ON (u.id) u.id, u.name, u.email,
+		c.id AS address_id, c.ref_num AS address_ref_num, c.city, c.country_code, c.latitude, c.longitude,
+		` + geo.distance + ` AS distance_km
+		FROM addresses c
+		JOIN users u ON u.id = c.user_id AND u.deleted_at IS NULL
+		WHERE c.deleted_at IS NULL AND c.latitude IS NOT NULL` + geo.where + `
+		ORDER BY u.id, distance_km, c.id
+	) AS alias`
+	args := geo.args
 
-	// Goroutine for count query
-	go func() {
-		err := r.sqlDB.GetContext(ctx, &total, countQuery, countArgs...)
-		countChan <- err
-	}()
+	query := `SELECT * ` + from
+	countQuery := `SELECT COUNT(*) ` + from
 
-	// Goroutine for select query
-	go func() {
-		err := r.sqlDB.SelectContext(ctx, &addresses, query, args...)
-		selectChan <- err
-	}()
+	if filters["sort"] == "" && filters["order_column"] == "" && geo.near {
+		filters["sort"] = "distance_km"
+	}
+	sort, err := utils.ParseSort(filters, userLocationSortColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+	query += sort.OrderBy("id")
 
-	// Wait for both goroutines to finish
-	countErr := <-countChan
-	selectErr := <-selectChan
+	perPage := utils.GetIntOrDefault(filters["per_page"], 10)
+	currentPage := utils.GetIntOrDefault(filters["page"], 1)
 
-	if countErr != nil {
-		return nil, 0, countErr
-	}
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", i, i+1)
+	selectArgs := append(append([]interface{}{}, args...), perPage, (currentPage-1)*perPage)
 
-	if selectErr != nil {
-		return nil, 0, selectErr
+	if err := getContext(ctx, r.sqlDB, &total, countQuery, args...); err != nil {
+		return nil, 0, err
+	}
+	if err := selectContext(ctx, r.sqlDB, &users, query, selectArgs...); err != nil {
+		return nil, 0, err
 	}
 
-	return addresses, total, nil
+	return users, total, nil
 }
 
 func (r *AddressRepository) GetAddressByID(ctx context.Context, params *dtos.GetAddressParams) (*dtos.AddressDetailDTO, error) {
 	var address dtos.AddressDetailDTO
 	// deletedAt := params.IsDeleted
 
-	query := `SELECT c.id, c.user_id, c.type_address_id, c.ref_num, c.status, c.created_at, c.updated_at, c.deleted_at,
-	u.name as user_name,
-	ti.name as type_address_name
+	source, historyArgs := detailSource("addresses", params.AsOf, params.Version)
 
-	FROM addresses c
+	query := `SELECT ` + utils.SelectColumns(params.Fields, AddressDetailFields) + `
+	FROM ` + source + ` c
 	JOIN users u ON c.user_id = u.id
 	JOIN mix_values ti ON c.type_address_id = ti.id
 	WHERE 1=1`
@@ -131,7 +329,8 @@ func (r *AddressRepository) GetAddressByID(ctx context.Context, params *dtos.Get
 	i := 1
 	query += " AND c.id = $1"
 	args = append(args, params.ID)
-	i++
+	args = append(args, historyArgs...)
+	i += 1 + len(historyArgs)
 
 	isDeletedQuery := ` AND c.deleted_at IS NULL`
 	if params.IsDeleted != nil && *params.IsDeleted == 1 {
@@ -146,50 +345,77 @@ func (r *AddressRepository) GetAddressByID(ctx context.Context, params *dtos.Get
 
 	query += isDeletedQuery
 
-	if err := r.sqlDB.Get(&address, query, args...); err != nil {
+	if err := getContext(ctx, r.sqlDB, &address, query, args...); err != nil {
 		return nil, err
 	}
 
 	return &address, nil
 }
 
-// BeginTransaction starts a new transaction
-func (r *AddressRepository) BeginTransaction() *gorm.DB {
-	return r.db.Begin()
+// GetAddressHistory lists the versions of a address.
+func (r *AddressRepository) GetAddressHistory(ctx context.Context, id uint, filters map[string]string) ([]dtos.HistoryDTO, int, error) {
+	return listHistory(ctx, r.sqlDB, "addresses", id, filters)
+}
+
+// GetUsersByIDs loads the users embedded by include=user, keyed by ID.
+func (r *AddressRepository) GetUsersByIDs(ctx context.Context, ids []uint) (map[uint]dtos.UserListDTO, error) {
+	return usersByIDs(ctx, r.sqlDB, ids)
 }
 
-func (r *AddressRepository) CreateAddress(tx *gorm.DB, address *models.Address) error {
-	if err := tx.Create(address).Error; err != nil {
+// CreateAddress creates address, the primary of its user and type when they
+// have none.
+func (r *AddressRepository) CreateAddress(ctx context.Context, address *models.Address) error {
+	isPrimary, _, err := writePrimary(ctx, r.db, "addresses", 0, primaryGroup{UserID: address.UserID, TypeID: address.TypeAddressID})
+	if err != nil {
+		return err
+	}
+	address.IsPrimary = isPrimary
+
+	if err := gormFrom(ctx, r.db).Create(address).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *AddressRepository) UpdateAddress(tx *gorm.DB, address *models.Address) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Save(address).Error; err != nil {
-			return err
-		}
-		return nil
-	})
+// UpdateAddress saves address if its Version is still the stored one, and bumps it.
+// Moved to another user or type, it stops being the primary of the former,
+// which gets another, and becomes the primary of the new one when it has none.
+func (r *AddressRepository) UpdateAddress(ctx context.Context, address *models.Address) error {
+	isPrimary, former, err := writePrimary(ctx, r.db, "addresses", address.ID, primaryGroup{UserID: address.UserID, TypeID: address.TypeAddressID})
+	if err != nil {
+		return err
+	}
+	address.IsPrimary = isPrimary
+
+	expected := address.Version
+	address.Version = expected + 1
 
+	result := gormFrom(ctx, r.db).Select("*").Where("version = ?", expected).Updates(address)
+	if err := checkVersion(result); err != nil {
+		address.Version = expected
+		return err
+	}
+
+	if former != nil {
+		return promotePrimary(ctx, r.db, "addresses", *former)
+	}
+	return nil
 }
 
-func (r *AddressRepository) DeleteAddress(tx *gorm.DB, id uint) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		// if err := tx.Unscoped().Delete(&models.Address{}, id).Error; err != nil {
-		if err := tx.Delete(&models.Address{}, id).Error; err != nil {
-			return err
-		}
-		return nil
-	})
+// DeleteAddress moves an address to the trash. When it was the primary of its
+// user and type, the oldest other one left becomes theirs.
+func (r *AddressRepository) DeleteAddress(ctx context.Context, id uint, version uint) error {
+	return deletePrimary(ctx, r.db, "addresses", id, version)
 }
 
-func (r *AddressRepository) RestoreAddress(tx *gorm.DB, id uint) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Exec("UPDATE addresses SET deleted_at = NULL WHERE id = ?", id).Error; err != nil {
-			return err
-		}
-		return nil
-	})
+// RestoreAddress takes an address out of the trash, as the primary of its
+// user and type when they have none.
+func (r *AddressRepository) RestoreAddress(ctx context.Context, id uint, version uint) error {
+	return restorePrimary(ctx, r.db, "addresses", id, version)
+}
+
+// SetPrimaryAddress makes an address, expected at version, the primary of its
+// user and type in place of the current one, whose version is bumped too.
+func (r *AddressRepository) SetPrimaryAddress(ctx context.Context, id uint, version uint) error {
+	return setPrimary(ctx, r.db, "addresses", id, version)
 }
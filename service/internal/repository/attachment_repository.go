@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+type AttachmentRepository struct {
+	*Transactor
+	db    *gorm.DB
+	sqlDB *sqlx.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB, sqlDB *sqlx.DB) *AttachmentRepository {
+	return &AttachmentRepository{
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
+	}
+}
+
+// attachmentOwner is the user of the row attachment a is attached to.
+func attachmentOwner() string {
+	owner := `CASE a.entity WHEN 'users' THEN a.entity_id`
+	for _, table := range userChildTables {
+		owner += ` WHEN '` + table + `' THEN (SELECT user_id FROM ` + table + ` WHERE id = a.entity_id)`
+	}
+	return owner + ` END`
+}
+
+// attachmentColumns are the columns of an attachments a joined to its file f
+// read into dtos.AttachmentDTO.
+var attachmentColumns = `a.id, a.entity, a.entity_id, a.file_name, f.content_type, f.size, f.sha256, f.storage_key,
+	` + attachmentOwner() + ` AS owner_id, a.created_by_id, a.created_at`
+
+// GetEntityOwner returns the user a live row id of entity, one of
+// utils.AttachmentEntities, belongs to: the user itself for users.
+func (r *AttachmentRepository) GetEntityOwner(ctx context.Context, entity string, id uint) (uint, error) {
+	if !utils.IsAttachmentEntity(entity) {
+		return 0, utils.ErrAttachmentEntity
+	}
+
+	column := "user_id"
+	if entity == "users" {
+		column = "id"
+	}
+
+	var owner uint
+	err := getContext(ctx, r.sqlDB, &owner, `SELECT `+column+` FROM `+entity+` WHERE id = $1 AND deleted_at IS NULL`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, utils.ErrAttachmentEntity
+	}
+	return owner, err
+}
+
+// CreateAttachment attaches the file of attachment to its row, attributed to
+// the actor of ctx. It returns false when the file was attached to the row
+// already, attachment then getting the ID of that attachment.
+func (r *AttachmentRepository) CreateAttachment(ctx context.Context, attachment *models.Attachment) (bool, error) {
+	var ids []uint
+	if err := gormFrom(ctx, r.db).Raw(`
+		INSERT INTO attachments (entity, entity_id, file_id, file_name, created_by_id)
+		VALUES (?, ?, ?, ?, current_actor_id())
+		ON CONFLICT (entity, entity_id, file_id) DO NOTHING
+		RETURNING id
+	`, attachment.Entity, attachment.EntityID, attachment.FileID, attachment.FileName).Scan(&ids).Error; err != nil {
+		return false, err
+	}
+	if len(ids) > 0 {
+		attachment.ID = ids[0]
+		return true, nil
+	}
+
+	err := getContext(ctx, r.sqlDB, &attachment.ID, `
+		SELECT id FROM attachments WHERE entity = $1 AND entity_id = $2 AND file_id = $3
+	`, attachment.Entity, attachment.EntityID, attachment.FileID)
+	return false, err
+}
+
+func (r *AttachmentRepository) GetAttachmentByID(ctx context.Context, id uint) (*dtos.AttachmentDTO, error) {
+	var attachment dtos.AttachmentDTO
+	query := `SELECT ` + attachmentColumns + `
+	FROM attachments a
+	JOIN files f ON f.id = a.file_id
+	WHERE a.id = $1`
+	if err := getContext(ctx, r.sqlDB, &attachment, query, id); err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// ListAttachments lists the attachments of row entityID of entity, newest
+// first.
+func (r *AttachmentRepository) ListAttachments(ctx context.Context, entity string, entityID uint, filters map[string]string) ([]dtos.AttachmentDTO, int, error) {
+	attachments := []dtos.AttachmentDTO{}
+	var total int
+
+	from := `FROM attachments a
+	JOIN files f ON f.id = a.file_id
+	WHERE a.entity = $1 AND a.entity_id = $2`
+
+	query := `SELECT ` + attachmentColumns + ` ` + from + ` ORDER BY a.id DESC LIMIT $3 OFFSET $4`
+	countQuery := `SELECT COUNT(*) ` + from
+
+	perPage := utils.GetIntOrDefault(filters["per_page"], 10)
+	currentPage := utils.GetIntOrDefault(filters["page"], 1)
+
+	// Channels for concurrent execution
+	countChan := make(chan error)
+	selectChan := make(chan error)
+
+	go func() {
+		countChan <- getContext(ctx, r.sqlDB, &total, countQuery, entity, entityID)
+	}()
+
+	go func() {
+		selectChan <- selectContext(ctx, r.sqlDB, &attachments, query, entity, entityID, perPage, (currentPage-1)*perPage)
+	}()
+
+	countErr := <-countChan
+	selectErr := <-selectChan
+
+	if countErr != nil {
+		return nil, 0, countErr
+	}
+
+	if selectErr != nil {
+		return nil, 0, selectErr
+	}
+
+	return attachments, total, nil
+}
+
+// DeleteAttachment detaches an attachment from its row. Its file stays
+// stored until purge_orphan_files finds nothing referencing it.
+func (r *AttachmentRepository) DeleteAttachment(ctx context.Context, id uint) error {
+	result := gormFrom(ctx, r.db).Exec(`DELETE FROM attachments WHERE id = ?`, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// deleteAttachments detaches every attachment of the rows ids of table, as
+// they are purged.
+func deleteAttachments(db *gorm.DB, table string, ids []uint) error {
+	return db.Exec(`DELETE FROM attachments WHERE entity = ? AND entity_id IN ?`, table, ids).Error
+}
+
+// deleteUserAttachments detaches every attachment of the users userIDs and of
+// their contacts, addresses and identifiers, and returns how many there were.
+func deleteUserAttachments(db *gorm.DB, userIDs []uint) (int, error) {
+	condition, args := `(entity = 'users' AND entity_id IN ?)`, []interface{}{userIDs}
+	for _, table := range userChildTables {
+		condition += ` OR (entity = '` + table + `' AND entity_id IN (SELECT id FROM ` + table + ` WHERE user_id IN ?))`
+		args = append(args, userIDs)
+	}
+
+	result := db.Exec(`DELETE FROM attachments WHERE `+condition, args...)
+	return int(result.RowsAffected), result.Error
+}
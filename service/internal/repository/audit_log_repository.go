@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+// auditLogSortColumns maps the sort keys accepted by ListAuditLogs to their columns.
+var auditLogSortColumns = map[string]string{
+	"id":         "id",
+	"actor_id":   "actor_id",
+	"actor_name": "actor_name",
+	"entity":     "entity",
+	"action":     "action",
+	"created_at": "created_at",
+}
+
+// AuditLogListFields are the fields index-audit-log can select with `fields`.
+var AuditLogListFields = map[string]utils.Field{
+	"id":         {Column: "id"},
+	"actor_id":   {Column: "actor_id"},
+	"actor_name": {Column: "actor_name"},
+	"entity":     {Column: "entity"},
+	"entity_id":  {Column: "entity_id"},
+	"action":     {Column: "action"},
+	"before":     {Column: "before"},
+	"after":      {Column: "after"},
+	"metadata":   {Column: "metadata"},
+	"ip_address": {Column: "ip_address"},
+	"request_id": {Column: "request_id"},
+	"created_at": {Column: "created_at"},
+}
+
+type AuditLogRepository struct {
+	*Transactor
+	db    *gorm.DB
+	sqlDB *sqlx.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB, sqlDB *sqlx.DB) *AuditLogRepository {
+	return &AuditLogRepository{
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
+	}
+}
+
+// CreateAuditLog appends a record to the audit log, in the unit of work of
+// ctx if any so it is only kept when the audited change is.
+func (r *AuditLogRepository) CreateAuditLog(ctx context.Context, log *models.AuditLog) error {
+	return gormFrom(ctx, r.db).Create(log).Error
+}
+
+// ListAuditLogs lists the audit log, newest records first unless sorted
+// otherwise. created_from is inclusive, created_to exclusive.
+func (r *AuditLogRepository) ListAuditLogs(ctx context.Context, filters map[string]string) ([]dtos.AuditLogListDTO, int, error) {
+	auditLogs := []dtos.AuditLogListDTO{}
+	var total int
+
+	from := `FROM (
+        SELECT a.id, a.actor_id, a.entity, a.entity_id, a.action, a.before, a.after, a.metadata,
+        a.ip_address, a.request_id, a.created_at,
+        u.name as actor_name
+
+        FROM audit_logs a
+        LEFT JOIN users u ON a.actor_id = u.id
+    ) AS alias WHERE 1=1`
+
+	fields, err := utils.ParseFields(filters["fields"], AuditLogListFields)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + utils.SelectColumns(fields, AuditLogListFields) + ` ` + from
+	countQuery := `SELECT COUNT(*) ` + from
+
+	var args []interface{}
+	i := 1
+	for _, key := range []string{"entity", "action", "request_id"} {
+		if value := filters[key]; value != "" {
+			query += fmt.Sprintf(" AND %s = $%d", key, i)
+			countQuery += fmt.Sprintf(" AND %s = $%d", key, i)
+			args = append(args, value)
+			i++
+		}
+	}
+
+	for _, key := range []string{"actor_id", "entity_id"} {
+		id, ok, err := utils.ParseIDFilter(filters, key)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			query += fmt.Sprintf(" AND %s = $%d", key, i)
+			countQuery += fmt.Sprintf(" AND %s = $%d", key, i)
+			args = append(args, id)
+			i++
+		}
+	}
+
+	for _, bound := range []struct{ key, operator string }{{"created_from", ">="}, {"created_to", "<"}} {
+		at, ok, err := utils.ParseTimeFilter(filters, bound.key)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			query += fmt.Sprintf(" AND created_at %s $%d", bound.operator, i)
+			countQuery += fmt.Sprintf(" AND created_at %s $%d", bound.operator, i)
+			args = append(args, at)
+			i++
+		}
+	}
+
+	countArgs := append([]interface{}{}, args...)
+
+	if filters["sort"] == "" && filters["order_column"] == "" {
+		filters["sort"] = "-id"
+	}
+	sort, err := utils.ParseSort(filters, auditLogSortColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+	query += sort.OrderBy("id")
+
+	perPage := utils.GetIntOrDefault(filters["per_page"], 10)
+	currentPage := utils.GetIntOrDefault(filters["page"], 1)
+
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", i, i+1)
+	args = append(args, perPage, (currentPage-1)*perPage)
+
+	// Channels for concurrent execution
+	countChan := make(chan error)
+	selectChan := make(chan error)
+
+	// Goroutine for count query
+	go func() {
+		countChan <- getContext(ctx, r.sqlDB, &total, countQuery, countArgs...)
+	}()
+
+	// Goroutine for select query
+	go func() {
+		selectChan <- selectContext(ctx, r.sqlDB, &auditLogs, query, args...)
+	}()
+
+	// Wait for both goroutines to finish
+	countErr := <-countChan
+	selectErr := <-selectChan
+
+	if countErr != nil {
+		return nil, 0, countErr
+	}
+
+	if selectErr != nil {
+		return nil, 0, selectErr
+	}
+
+	return auditLogs, total, nil
+}
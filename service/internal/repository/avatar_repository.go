@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/config"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+// AvatarDownloadPath is the endpoint the URL of an avatar points at.
+const AvatarDownloadPath = "/api/v1/users/download-avatar-user"
+
+// AvatarImage is an image of an avatar, see models.UserAvatar, with the file
+// it is stored as.
+type AvatarImage struct {
+	Size        int    `db:"size"`
+	FileID      uint   `db:"file_id"`
+	ContentType string `db:"content_type"`
+	Bytes       int64  `db:"bytes"`
+	StorageKey  string `db:"storage_key"`
+}
+
+type AvatarRepository struct {
+	*Transactor
+	db    *gorm.DB
+	sqlDB *sqlx.DB
+}
+
+func NewAvatarRepository(db *gorm.DB, sqlDB *sqlx.DB) *AvatarRepository {
+	return &AvatarRepository{
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
+	}
+}
+
+// LockAvatarUser locks live, not erased user userID until the end of the unit
+// of work of ctx, so the avatars written for it do not interleave. It returns
+// sql.ErrNoRows when there is no such user.
+func (r *AvatarRepository) LockAvatarUser(ctx context.Context, userID uint) error {
+	var locked uint
+	return getContext(ctx, r.sqlDB, &locked, `
+		SELECT id FROM users WHERE id = $1 AND deleted_at IS NULL AND erased_at IS NULL FOR NO KEY UPDATE
+	`, userID)
+}
+
+// ReplaceAvatar makes file fileID the avatar of user userID, dropping the
+// images of the previous one.
+func (r *AvatarRepository) ReplaceAvatar(ctx context.Context, userID uint, fileID uint) error {
+	db := gormFrom(ctx, r.db)
+	if err := db.Exec(`DELETE FROM user_avatars WHERE user_id = ?`, userID).Error; err != nil {
+		return err
+	}
+	return db.Exec(`INSERT INTO user_avatars (user_id, size, file_id) VALUES (?, 0, ?)`, userID, fileID).Error
+}
+
+// DeleteAvatar drops the avatar of user userID, sql.ErrNoRows when it has
+// none. Its files stay stored until purge_orphan_files finds nothing
+// referencing them.
+func (r *AvatarRepository) DeleteAvatar(ctx context.Context, userID uint) error {
+	result := gormFrom(ctx, r.db).Exec(`DELETE FROM user_avatars WHERE user_id = ?`, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetAvatarImages lists the images of the avatar of user userID, the uploaded
+// one first, then the thumbnails from the smallest.
+func (r *AvatarRepository) GetAvatarImages(ctx context.Context, userID uint) ([]AvatarImage, error) {
+	images := []AvatarImage{}
+	if err := selectContext(ctx, r.sqlDB, &images, `
+		SELECT ua.size, ua.file_id, f.content_type, f.size AS bytes, f.storage_key
+		FROM user_avatars ua
+		JOIN files f ON f.id = ua.file_id
+		WHERE ua.user_id = $1
+		ORDER BY ua.size
+	`, userID); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// AddAvatarThumbnail records file fileID as the thumbnail of size pixels of
+// the avatar of user userID, unless that avatar is no longer file
+// avatarFileID. It returns whether it did.
+func (r *AvatarRepository) AddAvatarThumbnail(ctx context.Context, userID uint, avatarFileID uint, size int, fileID uint) (bool, error) {
+	result := gormFrom(ctx, r.db).Exec(`
+		INSERT INTO user_avatars (user_id, size, file_id)
+		SELECT ?, ?, ?
+		WHERE EXISTS (SELECT 1 FROM user_avatars WHERE user_id = ? AND size = 0 AND file_id = ?)
+		ON CONFLICT (user_id, size) DO UPDATE SET file_id = EXCLUDED.file_id, created_at = NOW()
+	`, userID, size, fileID, userID, avatarFileID)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// avatarURLs returns the URLs of the avatars of the users ids that have one,
+// keyed by user.
+func avatarURLs(ctx context.Context, sqlDB *sqlx.DB, ids []uint) (map[uint]string, error) {
+	result := make(map[uint]string)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var avatars []struct {
+		UserID uint `db:"user_id"`
+		FileID uint `db:"file_id"`
+	}
+	query := `SELECT user_id, file_id FROM user_avatars WHERE size = 0 AND user_id IN (?)`
+	if err := selectIn(ctx, sqlDB, &avatars, query, ids); err != nil {
+		return nil, err
+	}
+
+	secret := config.GetAttachmentURLSecret()
+	for _, avatar := range avatars {
+		result[avatar.UserID] = utils.AvatarURL(AvatarDownloadPath, secret, avatar.UserID, avatar.FileID)
+	}
+	return result, nil
+}
+
+// withAvatarURLs sets the avatar_url of users, the ones without an avatar
+// left nil.
+func withAvatarURLs(ctx context.Context, sqlDB *sqlx.DB, users []dtos.UserListDTO) error {
+	ids := make([]uint, len(users))
+	for i, user := range users {
+		ids[i] = uint(user.ID)
+	}
+
+	urls, err := avatarURLs(ctx, sqlDB, ids)
+	if err != nil {
+		return err
+	}
+	for i, user := range users {
+		if url, ok := urls[uint(user.ID)]; ok {
+			users[i].AvatarURL = &url
+		}
+	}
+	return nil
+}
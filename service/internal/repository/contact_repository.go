@@ -5,40 +5,166 @@ import (
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/nibroos/nb-go-api/service/internal/dtos"
 	"github.com/nibroos/nb-go-api/service/internal/models"
 	"github.com/nibroos/nb-go-api/service/internal/utils"
 	"gorm.io/gorm"
 )
 
+// contactSortColumns maps the sort keys accepted by ListContacts to their columns.
+var contactSortColumns = map[string]string{
+	"id":                "id",
+	"ref_num":           "ref_num",
+	"user_name":         "user_name",
+	"type_contact_name": "type_contact_name",
+	"is_primary":        "is_primary",
+	"status":            "status",
+	"created_at":        "created_at",
+	"updated_at":        "updated_at",
+}
+
+// ContactListFields are the fields index-contact can select with `fields`.
+var ContactListFields = map[string]utils.Field{
+	"id":                 {Column: "id"},
+	"user_id":            {Column: "user_id"},
+	"user_name":          {Column: "user_name"},
+	"type_contact_id":    {Column: "type_contact_id"},
+	"type_contact_name":  {Column: "type_contact_name"},
+	"ref_num":            {Column: "ref_num"},
+	"normalized_ref_num": {Column: "normalized_ref_num"},
+	"is_primary":         {Column: "is_primary"},
+	"status":             {Column: "status"},
+	"created_at":         {Column: "created_at"},
+	"updated_at":         {Column: "updated_at"},
+	"version":            {Column: "version"},
+}
+
+// ContactTrashFields are the fields index-trash-contact can select with `fields`.
+var ContactTrashFields = trashFields(ContactListFields)
+
+// ContactDetailFields are the fields show-contact can select with `fields`.
+var ContactDetailFields = map[string]utils.Field{
+	"id":                 {Column: "c.id"},
+	"user_id":            {Column: "c.user_id"},
+	"user_name":          {Column: "u.name AS user_name"},
+	"type_contact_id":    {Column: "c.type_contact_id"},
+	"type_contact_name":  {Column: "ti.name AS type_contact_name"},
+	"ref_num":            {Column: "c.ref_num"},
+	"normalized_ref_num": {Column: "c.normalized_ref_num"},
+	"is_primary":         {Column: "c.is_primary"},
+	"status":             {Column: "c.status"},
+	"created_at":         {Column: "c.created_at"},
+	"updated_at":         {Column: "c.updated_at"},
+	"version":            {Column: "c.version"},
+	"created_by_id":      {Column: "c.created_by_id"},
+	"updated_by_id":      {Column: "c.updated_by_id"},
+	"deleted_at":         {Column: "c.deleted_at"},
+}
+
 type ContactRepository struct {
+	*Transactor
 	db    *gorm.DB
 	sqlDB *sqlx.DB
 }
 
 func NewContactRepository(db *gorm.DB, sqlDB *sqlx.DB) *ContactRepository {
 	return &ContactRepository{
-		db:    db,
-		sqlDB: sqlDB,
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
 	}
 }
 
 func (r *ContactRepository) ListContacts(ctx context.Context, filters map[string]string) ([]dtos.ContactListDTO, int, error) {
+	return r.listContacts(ctx, filters, false)
+}
+
+// ListTrashedContacts lists the soft deleted contacts, most recently deleted first.
+func (r *ContactRepository) ListTrashedContacts(ctx context.Context, filters map[string]string) ([]dtos.ContactListDTO, int, error) {
+	return r.listContacts(ctx, filters, true)
+}
+
+func (r *ContactRepository) listContacts(ctx context.Context, filters map[string]string, trashed bool) ([]dtos.ContactListDTO, int, error) {
 	contacts := []dtos.ContactListDTO{}
 	var total int
 
+	list, err := contactListQuery(filters, trashed)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	perPage := utils.GetIntOrDefault(filters["per_page"], 10)
+	currentPage := utils.GetIntOrDefault(filters["page"], 1)
+
+	query := list.query + fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(list.args)+1, len(list.args)+2)
+	args := append(append([]interface{}{}, list.args...), perPage, (currentPage-1)*perPage)
+
+	// Channels for concurrent execution
+	countChan := make(chan error)
+	selectChan := make(chan error)
+
+	// Goroutine for count query
+	go func() {
+		err := getContext(ctx, r.sqlDB, &total, list.countQuery, list.args...)
+		countChan <- err
+	}()
+
+	// Goroutine for select query
+	go func() {
+		err := selectContext(ctx, r.sqlDB, &contacts, query, args...)
+		selectChan <- err
+	}()
+
+	// Wait for both goroutines to finish
+	countErr := <-countChan
+	selectErr := <-selectChan
+
+	if countErr != nil {
+		return nil, 0, countErr
+	}
+
+	if selectErr != nil {
+		return nil, 0, selectErr
+	}
+
+	return contacts, total, nil
+}
+
+// ExportContacts returns the query of index-contact for filters, unpaged, for
+// StreamExport.
+func (r *ContactRepository) ExportContacts(filters map[string]string) (*ListQuery, error) {
+	return contactListQuery(filters, false)
+}
+
+// contactListQuery builds the query of index-contact (index-trash-contact when
+// trashed) for filters, without paging.
+func contactListQuery(filters map[string]string, trashed bool) (*ListQuery, error) {
+	allowedFields, sortColumns, scope := ContactListFields, contactSortColumns, "IS NULL"
+	if trashed {
+		allowedFields, sortColumns, scope = ContactTrashFields, trashSortColumns(contactSortColumns), "IS NOT NULL"
+		if filters["sort"] == "" && filters["order_column"] == "" {
+			filters["sort"] = "-deleted_at"
+		}
+	}
+
 	from := `FROM (
-        SELECT c.id, c.user_id, c.type_contact_id, c.ref_num, c.status, c.created_at, c.updated_at,
+        SELECT c.id, c.user_id, c.type_contact_id, c.ref_num, c.normalized_ref_num, c.is_primary, c.status, c.created_at, c.updated_at, c.version, c.deleted_at,
         u.name as user_name,
         ti.name as type_contact_name
 
         FROM contacts c
         JOIN users u ON c.user_id = u.id
         JOIN mix_values ti ON c.type_contact_id = ti.id
-        WHERE c.deleted_at IS NULL
+        WHERE c.deleted_at ` + scope + `
     ) AS alias WHERE 1=1`
 
-	query := `SELECT * ` + from
+	fields, err := utils.ParseFields(filters["fields"], allowedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + utils.SelectColumns(fields, allowedFields) + ` ` + from
 	countQuery := `SELECT COUNT(*) ` + from
 
 	var args []interface{}
@@ -47,8 +173,9 @@ func (r *ContactRepository) ListContacts(ctx context.Context, filters map[string
 		switch key {
 		case "ref_num":
 			if value != "" {
-				query += fmt.Sprintf(" AND %s ILIKE $%d", key, i)
-				countQuery += fmt.Sprintf(" AND %s ILIKE $%d", key, i)
+				// matches how it was written and how it is stored, as +62812... for 0812...
+				query += fmt.Sprintf(" AND (ref_num ILIKE $%d OR normalized_ref_num ILIKE $%d)", i, i)
+				countQuery += fmt.Sprintf(" AND (ref_num ILIKE $%d OR normalized_ref_num ILIKE $%d)", i, i)
 				args = append(args, "%"+value+"%")
 				i++
 			}
@@ -62,66 +189,37 @@ func (r *ContactRepository) ListContacts(ctx context.Context, filters map[string
 		i++
 	}
 
+	if value, ok := filters["is_primary"]; ok && value != "" {
+		query += fmt.Sprintf(" AND is_primary = $%d", i)
+		countQuery += fmt.Sprintf(" AND is_primary = $%d", i)
+		args = append(args, value == "true" || value == "1")
+		i++
+	}
+
 	if value, ok := filters["global"]; ok && value != "" {
-		query += fmt.Sprintf(" AND (ref_num ILIKE $%d OR user_name ILIKE $%d OR type_contact_name ILIKE $%d)", i, i+1, i+2)
-		countQuery += fmt.Sprintf(" AND (ref_num ILIKE $%d OR user_name ILIKE $%d OR type_contact_name ILIKE $%d)", i, i+1, i+2)
+		query += fmt.Sprintf(" AND (ref_num ILIKE $%d OR normalized_ref_num ILIKE $%d OR user_name ILIKE $%d OR type_contact_name ILIKE $%d)", i, i, i+1, i+2)
+		countQuery += fmt.Sprintf(" AND (ref_num ILIKE $%d OR normalized_ref_num ILIKE $%d OR user_name ILIKE $%d OR type_contact_name ILIKE $%d)", i, i, i+1, i+2)
 		args = append(args, "%"+value+"%", "%"+value+"%", "%"+value+"%")
 		i += 3
 	}
 
-	countArgs := append([]interface{}{}, args...)
-
-	allowedOrderColumns := []string{"id", "ref_num", "user_name", "type_contact_name"}
-	orderColumn := utils.GetStringOrDefaultFromArray(filters["order_column"], allowedOrderColumns, "id")
-	orderDirection := utils.GetStringOrDefault(filters["order_direction"], "asc")
-	query += fmt.Sprintf(" ORDER BY %s %s", orderColumn, orderDirection)
-
-	perPage := utils.GetIntOrDefault(filters["per_page"], 10)
-	currentPage := utils.GetIntOrDefault(filters["page"], 1)
-
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", i, i+1)
-	args = append(args, perPage, (currentPage-1)*perPage)
-
-	// Channels for concurrent execution
-	countChan := make(chan error)
-	selectChan := make(chan error)
-
-	// Goroutine for count query
-	go func() {
-		err := r.sqlDB.GetContext(ctx, &total, countQuery, countArgs...)
-		countChan <- err
-	}()
-
-	// Goroutine for select query
-	go func() {
-		err := r.sqlDB.SelectContext(ctx, &contacts, query, args...)
-		selectChan <- err
-	}()
-
-	// Wait for both goroutines to finish
-	countErr := <-countChan
-	selectErr := <-selectChan
-
-	if countErr != nil {
-		return nil, 0, countErr
-	}
-
-	if selectErr != nil {
-		return nil, 0, selectErr
+	sort, err := utils.ParseSort(filters, sortColumns)
+	if err != nil {
+		return nil, err
 	}
+	query += sort.OrderBy("id")
 
-	return contacts, total, nil
+	return &ListQuery{query: query, countQuery: countQuery, args: args}, nil
 }
 
 func (r *ContactRepository) GetContactByID(ctx context.Context, params *dtos.GetContactParams) (*dtos.ContactDetailDTO, error) {
 	var contact dtos.ContactDetailDTO
 	// deletedAt := params.IsDeleted
 
-	query := `SELECT c.id, c.user_id, c.type_contact_id, c.ref_num, c.status, c.created_at, c.updated_at, c.deleted_at,
-	u.name as user_name,
-	ti.name as type_contact_name
+	source, historyArgs := detailSource("contacts", params.AsOf, params.Version)
 
-	FROM contacts c
+	query := `SELECT ` + utils.SelectColumns(params.Fields, ContactDetailFields) + `
+	FROM ` + source + ` c
 	JOIN users u ON c.user_id = u.id
 	JOIN mix_values ti ON c.type_contact_id = ti.id
 	WHERE 1=1`
@@ -131,7 +229,8 @@ func (r *ContactRepository) GetContactByID(ctx context.Context, params *dtos.Get
 	i := 1
 	query += " AND c.id = $1"
 	args = append(args, params.ID)
-	i++
+	args = append(args, historyArgs...)
+	i += 1 + len(historyArgs)
 
 	isDeletedQuery := ` AND c.deleted_at IS NULL`
 	if params.IsDeleted != nil && *params.IsDeleted == 1 {
@@ -146,50 +245,186 @@ func (r *ContactRepository) GetContactByID(ctx context.Context, params *dtos.Get
 
 	query += isDeletedQuery
 
-	if err := r.sqlDB.Get(&contact, query, args...); err != nil {
+	if err := getContext(ctx, r.sqlDB, &contact, query, args...); err != nil {
 		return nil, err
 	}
 
 	return &contact, nil
 }
 
-// BeginTransaction starts a new transaction
-func (r *ContactRepository) BeginTransaction() *gorm.DB {
-	return r.db.Begin()
+// GetContactHistory lists the versions of a contact.
+func (r *ContactRepository) GetContactHistory(ctx context.Context, id uint, filters map[string]string) ([]dtos.HistoryDTO, int, error) {
+	return listHistory(ctx, r.sqlDB, "contacts", id, filters)
 }
 
-func (r *ContactRepository) CreateContact(tx *gorm.DB, contact *models.Contact) error {
-	if err := tx.Create(contact).Error; err != nil {
-		return err
+// GetUsersByIDs loads the users embedded by include=user, keyed by ID.
+func (r *ContactRepository) GetUsersByIDs(ctx context.Context, ids []uint) (map[uint]dtos.UserListDTO, error) {
+	return usersByIDs(ctx, r.sqlDB, ids)
+}
+
+// GetContactTypeFormats returns the options_json of the contact types ids,
+// keyed by ID, for the formats they declare. Types without options are left
+// out.
+func (r *ContactRepository) GetContactTypeFormats(ctx context.Context, ids []uint) (map[uint]string, error) {
+	return mixValueOptions(ctx, r.sqlDB, ids)
+}
+
+// GetContactTypeIDs returns the type of the contacts ids, keyed by ID.
+func (r *ContactRepository) GetContactTypeIDs(ctx context.Context, ids []uint) (map[uint]uint, error) {
+	result := make(map[uint]uint)
+	if len(ids) == 0 {
+		return result, nil
 	}
-	return nil
+
+	var contacts []ContactRefNum
+	query := `SELECT id, type_contact_id FROM contacts WHERE id IN (?)`
+	if err := selectIn(ctx, r.sqlDB, &contacts, query, ids); err != nil {
+		return nil, err
+	}
+
+	for _, contact := range contacts {
+		result[contact.ID] = contact.TypeContactID
+	}
+	return result, nil
 }
 
-func (r *ContactRepository) UpdateContact(tx *gorm.DB, contact *models.Contact) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Save(contact).Error; err != nil {
-			return err
-		}
-		return nil
-	})
+// TakenContactRefNums returns the indexes i for which the user userIDs[i] has
+// a contact other than ids[i], not in the trash, stored as normalized[i].
+func (r *ContactRepository) TakenContactRefNums(ctx context.Context, userIDs []uint, normalized []string, ids []uint) ([]int, error) {
+	taken := []int{}
+	if len(normalized) == 0 {
+		return taken, nil
+	}
 
+	users := make([]int64, len(userIDs))
+	contacts := make([]int64, len(ids))
+	for i := range userIDs {
+		users[i], contacts[i] = int64(userIDs[i]), int64(ids[i])
+	}
+	query := `
+		SELECT v.i - 1
+		FROM UNNEST($1::BIGINT[], $2::TEXT[], $3::BIGINT[]) WITH ORDINALITY AS v(user_id, value, id, i)
+		WHERE EXISTS (
+			SELECT 1 FROM contacts c
+			WHERE c.user_id = v.user_id AND c.normalized_ref_num = v.value AND c.id <> v.id AND c.deleted_at IS NULL
+		)`
+	if err := selectContext(ctx, r.sqlDB, &taken, query, pq.Array(users), pq.Array(normalized), pq.Array(contacts)); err != nil {
+		return nil, err
+	}
+	return taken, nil
 }
 
-func (r *ContactRepository) DeleteContact(tx *gorm.DB, id uint) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		// if err := tx.Unscoped().Delete(&models.Contact{}, id).Error; err != nil {
-		if err := tx.Delete(&models.Contact{}, id).Error; err != nil {
-			return err
-		}
-		return nil
-	})
+// ContactRefNum is the ref_num of a contact and how it is stored normalized.
+type ContactRefNum struct {
+	ID               uint    `db:"id"`
+	TypeContactID    uint    `db:"type_contact_id"`
+	RefNum           string  `db:"ref_num"`
+	NormalizedRefNum *string `db:"normalized_ref_num"`
+}
+
+// ListContactRefNums lists up to limit contacts, trashed ones included, with
+// an ID above afterID, in ID order, for renormalizing their ref_num. The ones
+// of erased users are left out, they have no ref_num left.
+func (r *ContactRepository) ListContactRefNums(ctx context.Context, afterID uint, limit int) ([]ContactRefNum, error) {
+	contacts := []ContactRefNum{}
+	query := `
+		SELECT c.id, c.type_contact_id, c.ref_num, c.normalized_ref_num
+		FROM contacts c
+		WHERE c.id > $1 AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = c.user_id AND u.erased_at IS NOT NULL)
+		ORDER BY c.id
+		LIMIT $2`
+	if err := selectContext(ctx, r.sqlDB, &contacts, query, afterID, limit); err != nil {
+		return nil, err
+	}
+	return contacts, nil
 }
 
-func (r *ContactRepository) RestoreContact(tx *gorm.DB, id uint) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Exec("UPDATE contacts SET deleted_at = NULL WHERE id = ?", id).Error; err != nil {
+// SetNormalizedRefNums stores the normalized ref_num of contacts, keyed by ID.
+// It is bookkeeping: the version stays.
+func (r *ContactRepository) SetNormalizedRefNums(ctx context.Context, normalized map[uint]string) error {
+	if len(normalized) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(normalized))
+	values := make([]string, 0, len(normalized))
+	for id, value := range normalized {
+		ids = append(ids, int64(id))
+		values = append(values, value)
+	}
+	return r.WithTransaction(ctx, func(ctx context.Context) error {
+		err := execContext(ctx, r.sqlDB, `
+			UPDATE contacts c SET normalized_ref_num = v.value
+			FROM UNNEST($1::BIGINT[], $2::TEXT[]) AS v(id, value)
+			WHERE c.id = v.id
+		`, pq.Array(ids), pq.Array(values))
+		if err != nil {
 			return err
 		}
-		return nil
+
+		// not a new version: the current one in the history gets it in place
+		return execContext(ctx, r.sqlDB, `
+			UPDATE contacts_history h SET data = h.data || jsonb_build_object('normalized_ref_num', c.normalized_ref_num)
+			FROM contacts c
+			WHERE h.id = c.id AND h.version = c.version AND c.id = ANY($1::BIGINT[])
+		`, pq.Array(ids))
 	})
 }
+
+// CreateContact creates contact, the primary of its user and type when they
+// have none.
+func (r *ContactRepository) CreateContact(ctx context.Context, contact *models.Contact) error {
+	isPrimary, _, err := writePrimary(ctx, r.db, "contacts", 0, primaryGroup{UserID: contact.UserID, TypeID: contact.TypeContactID})
+	if err != nil {
+		return err
+	}
+	contact.IsPrimary = isPrimary
+
+	if err := gormFrom(ctx, r.db).Create(contact).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateContact saves contact if its Version is still the stored one, and bumps it.
+// Moved to another user or type, it stops being the primary of the former,
+// which gets another, and becomes the primary of the new one when it has none.
+func (r *ContactRepository) UpdateContact(ctx context.Context, contact *models.Contact) error {
+	isPrimary, former, err := writePrimary(ctx, r.db, "contacts", contact.ID, primaryGroup{UserID: contact.UserID, TypeID: contact.TypeContactID})
+	if err != nil {
+		return err
+	}
+	contact.IsPrimary = isPrimary
+
+	expected := contact.Version
+	contact.Version = expected + 1
+
+	result := gormFrom(ctx, r.db).Select("*").Where("version = ?", expected).Updates(contact)
+	if err := checkVersion(result); err != nil {
+		contact.Version = expected
+		return err
+	}
+
+	if former != nil {
+		return promotePrimary(ctx, r.db, "contacts", *former)
+	}
+	return nil
+}
+
+// DeleteContact moves a contact to the trash. When it was the primary of its user
+// and type, the oldest other one left becomes theirs.
+func (r *ContactRepository) DeleteContact(ctx context.Context, id uint, version uint) error {
+	return deletePrimary(ctx, r.db, "contacts", id, version)
+}
+
+// RestoreContact takes a contact out of the trash, as the primary of its user and
+// type when they have none.
+func (r *ContactRepository) RestoreContact(ctx context.Context, id uint, version uint) error {
+	return restorePrimary(ctx, r.db, "contacts", id, version)
+}
+
+// SetPrimaryContact makes a contact, expected at version, the primary of its user and
+// type in place of the current one, whose version is bumped too.
+func (r *ContactRepository) SetPrimaryContact(ctx context.Context, id uint, version uint) error {
+	return setPrimary(ctx, r.db, "contacts", id, version)
+}
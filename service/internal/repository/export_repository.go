@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is how many rows each FETCH of an export cursor reads.
+const exportBatchSize = 1000
+
+// ListQuery is the query of an index-* endpoint before paging, built from its
+// filters.
+type ListQuery struct {
+	query      string
+	countQuery string
+	args       []interface{}
+	threshold  float64 // pg_trgm similarity threshold of a fuzzy match, 0 for none
+}
+
+type ExportRepository struct {
+	*Transactor
+	db    *gorm.DB
+	sqlDB *sqlx.DB
+}
+
+func NewExportRepository(db *gorm.DB, sqlDB *sqlx.DB) *ExportRepository {
+	return &ExportRepository{
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
+	}
+}
+
+// CountExport returns how many rows list selects.
+func (r *ExportRepository) CountExport(ctx context.Context, list *ListQuery) (int, error) {
+	var total int
+	count := func(ctx context.Context) error {
+		return getContext(ctx, r.sqlDB, &total, list.countQuery, list.args...)
+	}
+
+	var err error
+	if list.threshold > 0 {
+		err = withSimilarityThreshold(ctx, r.sqlDB, list.threshold, count)
+	} else {
+		err = count(ctx)
+	}
+	return total, err
+}
+
+// StreamExport writes the rows list selects to w. They are read through a
+// server-side cursor, exportBatchSize rows at a time, so memory does not grow
+// with the export. It returns how many rows were written.
+func (r *ExportRepository) StreamExport(ctx context.Context, list *ListQuery, w utils.ExportWriter) (int, error) {
+	tx, err := r.sqlDB.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if list.threshold > 0 {
+		if _, err := tx.ExecContext(ctx, `SELECT set_config('pg_trgm.similarity_threshold', $1, true)`, fmt.Sprint(list.threshold)); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DECLARE export_cursor NO SCROLL CURSOR FOR `+list.query, list.args...); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for header := true; ; header = false {
+		n, err := fetchExportBatch(ctx, tx, w, header)
+		if err != nil {
+			return written, err
+		}
+		written += n
+		if n < exportBatchSize {
+			break
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return written, err
+	}
+	return written, tx.Commit()
+}
+
+// fetchExportBatch writes the next batch of the export cursor, preceded by
+// the header when asked to.
+func fetchExportBatch(ctx context.Context, tx *sqlx.Tx, w utils.ExportWriter, header bool) (int, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`FETCH %d FROM export_cursor`, exportBatchSize))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if header {
+		if err := w.WriteHeader(columns); err != nil {
+			return 0, err
+		}
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return n, err
+		}
+		if err := w.WriteRow(values); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
+
+func (r *ExportRepository) CreateExportJob(ctx context.Context, job *models.ExportJob) error {
+	return gormFrom(ctx, r.db).Create(job).Error
+}
+
+// GetExportJob returns an export job of the user who asked for it.
+func (r *ExportRepository) GetExportJob(ctx context.Context, id uint, userID uint) (*dtos.ExportJobDTO, error) {
+	var job dtos.ExportJobDTO
+	query := `SELECT id, entity, format, status, total_rows, processed_rows, file_size, error,
+	created_by_id, created_at, started_at, finished_at
+	FROM export_jobs WHERE id = $1 AND created_by_id = $2`
+	if err := getContext(ctx, r.sqlDB, &job, query, id, userID); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetExportFile returns the file name of a completed export job of the user
+// who asked for it, ErrExportStatus while it has none.
+func (r *ExportRepository) GetExportFile(ctx context.Context, id uint, userID uint) (string, error) {
+	var job struct {
+		Status   string  `db:"status"`
+		FileName *string `db:"file_name"`
+	}
+	query := `SELECT status, file_name FROM export_jobs WHERE id = $1 AND created_by_id = $2`
+	if err := getContext(ctx, r.sqlDB, &job, query, id, userID); err != nil {
+		return "", err
+	}
+	if job.Status != utils.ExportStatusCompleted || job.FileName == nil {
+		return "", utils.ErrExportStatus
+	}
+	return *job.FileName, nil
+}
+
+// StartExportJob marks a queued export job running and returns it,
+// ErrExportStatus when it is not queued.
+func (r *ExportRepository) StartExportJob(ctx context.Context, id uint) (*models.ExportJob, error) {
+	var jobs []models.ExportJob
+	result := gormFrom(ctx, r.db).Raw(`
+		UPDATE export_jobs SET status = ?, started_at = NOW(), processed_rows = 0, updated_at = NOW()
+		WHERE id = ? AND status = ?
+		RETURNING *
+	`, utils.ExportStatusRunning, id, utils.ExportStatusQueued).Scan(&jobs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(jobs) == 0 {
+		return nil, utils.ErrExportStatus
+	}
+	return &jobs[0], nil
+}
+
+// UpdateExportProgress records how many rows of an export job were written.
+func (r *ExportRepository) UpdateExportProgress(ctx context.Context, id uint, processed int) error {
+	return gormFrom(ctx, r.db).Exec(`
+		UPDATE export_jobs SET processed_rows = ?, updated_at = NOW() WHERE id = ?
+	`, processed, id).Error
+}
+
+// CompleteExportJob records the file an export job was written to.
+func (r *ExportRepository) CompleteExportJob(ctx context.Context, id uint, rows int, fileName string, fileSize int64) error {
+	return gormFrom(ctx, r.db).Exec(`
+		UPDATE export_jobs SET status = ?, total_rows = ?, processed_rows = ?, file_name = ?, file_size = ?,
+		finished_at = NOW(), updated_at = NOW()
+		WHERE id = ?
+	`, utils.ExportStatusCompleted, rows, rows, fileName, fileSize, id).Error
+}
+
+// FailExportJob records why an export job failed.
+func (r *ExportRepository) FailExportJob(ctx context.Context, id uint, failure string) error {
+	return gormFrom(ctx, r.db).Exec(`
+		UPDATE export_jobs SET status = ?, error = ?, finished_at = NOW(), updated_at = NOW()
+		WHERE id = ?
+	`, utils.ExportStatusFailed, failure, id).Error
+}
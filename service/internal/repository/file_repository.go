@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/nibroos/nb-go-api/service/internal/models"
+	"gorm.io/gorm"
+)
+
+// orphanFileAge is how long a file nothing references is kept, so the upload
+// that stored it can reference it first.
+const orphanFileAge = "1 hour"
+
+// fileReferences is the condition of a file f being referenced, by an
+// attachment or as an avatar.
+const fileReferences = `(EXISTS (SELECT 1 FROM attachments a WHERE a.file_id = f.id)
+	OR EXISTS (SELECT 1 FROM user_avatars ua WHERE ua.file_id = f.id))`
+
+type FileRepository struct {
+	*Transactor
+	db    *gorm.DB
+	sqlDB *sqlx.DB
+}
+
+func NewFileRepository(db *gorm.DB, sqlDB *sqlx.DB) *FileRepository {
+	return &FileRepository{
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
+	}
+}
+
+// GetFileBySHA256 returns the file of content sum, nil when none is stored.
+func (r *FileRepository) GetFileBySHA256(ctx context.Context, sum string) (*models.File, error) {
+	var files []models.File
+	if err := gormFrom(ctx, r.db).Raw(`SELECT * FROM files WHERE sha256 = ?`, sum).Scan(&files).Error; err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	return &files[0], nil
+}
+
+// LockFile locks file id until the end of the unit of work of ctx, so it is
+// not purged before it is referenced. It returns sql.ErrNoRows when the file
+// is gone.
+func (r *FileRepository) LockFile(ctx context.Context, id uint) error {
+	var locked uint
+	return getContext(ctx, r.sqlDB, &locked, `SELECT id FROM files WHERE id = $1 FOR SHARE`, id)
+}
+
+// CreateFile records file, stored under its StorageKey. It returns false when
+// a file of the same content was recorded first, file left untouched.
+func (r *FileRepository) CreateFile(ctx context.Context, file *models.File) (bool, error) {
+	var ids []uint
+	if err := gormFrom(ctx, r.db).Raw(`
+		INSERT INTO files (sha256, size, content_type, storage_key) VALUES (?, ?, ?, ?)
+		ON CONFLICT (sha256) DO NOTHING
+		RETURNING id
+	`, file.SHA256, file.Size, file.ContentType, file.StorageKey).Scan(&ids).Error; err != nil {
+		return false, err
+	}
+	if len(ids) == 0 {
+		return false, nil
+	}
+	file.ID = ids[0]
+	return true, nil
+}
+
+// GetFileByID returns file id, sql.ErrNoRows when it is gone.
+func (r *FileRepository) GetFileByID(ctx context.Context, id uint) (*models.File, error) {
+	var files []models.File
+	if err := gormFrom(ctx, r.db).Raw(`SELECT * FROM files WHERE id = ?`, id).Scan(&files).Error; err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &files[0], nil
+}
+
+// ListOrphanFiles lists up to limit files nothing references, recorded longer
+// than orphanFileAge ago, with an ID above afterID, in ID order.
+func (r *FileRepository) ListOrphanFiles(ctx context.Context, afterID uint, limit int) ([]models.File, error) {
+	var files []models.File
+	if err := gormFrom(ctx, r.db).Raw(`
+		SELECT * FROM files f
+		WHERE f.id > ? AND f.created_at < NOW() - INTERVAL '`+orphanFileAge+`'
+		AND NOT `+fileReferences+`
+		ORDER BY f.id
+		LIMIT ?
+	`, afterID, limit).Scan(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// DeleteOrphanFile deletes the record of file id unless something references
+// it, and returns whether it did. Its content is left to remove from the
+// storage.
+func (r *FileRepository) DeleteOrphanFile(ctx context.Context, id uint) (bool, error) {
+	result := gormFrom(ctx, r.db).Exec(`
+		DELETE FROM files f WHERE f.id = ? AND NOT `+fileReferences+`
+	`, id)
+	if isForeignKeyViolation(result.Error) {
+		// referenced by an upload meanwhile
+		return false, nil
+	}
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// isForeignKeyViolation reports whether err is a foreign key violation.
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23503"
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23503"
+	}
+
+	return false
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+// geoFilter is the near, radius and bbox filters of a list as SQL over the
+// latitude and longitude of the addresses aliased c. The conditions go in the
+// query reading addresses, where the indexes on their coordinates apply.
+type geoFilter struct {
+	near     bool
+	distance string // distance_km, from near; NULL without it
+	where    string // conditions, each starting with " AND"
+	args     []interface{}
+}
+
+// parseGeoFilter reads the geo filters of filters, their placeholders
+// numbered from i. It returns the number of the next placeholder.
+func parseGeoFilter(filters map[string]string, i int) (*geoFilter, int, error) {
+	geo := &geoFilter{distance: "NULL::FLOAT8"}
+
+	near, ok, err := utils.ParseNearFilter(filters)
+	if err != nil {
+		return nil, i, err
+	}
+	if ok {
+		origin := fmt.Sprintf("ll_to_earth($%d::FLOAT8, $%d::FLOAT8)", i, i+1)
+		position := "ll_to_earth(c.latitude, c.longitude)"
+		geo.near = true
+		geo.distance = fmt.Sprintf("earth_distance(%s, %s) / 1000", origin, position)
+		geo.where += " AND c.latitude IS NOT NULL"
+		geo.args = append(geo.args, near.Point.Latitude, near.Point.Longitude)
+		i += 2
+
+		if near.RadiusKm != nil {
+			// the box finds the candidates with the index, the distance drops its corners
+			geo.where += fmt.Sprintf(" AND earth_box(%s, $%d::FLOAT8 * 1000) @> %s AND %s <= $%d::FLOAT8", origin, i, position, geo.distance, i)
+			geo.args = append(geo.args, *near.RadiusKm)
+			i++
+		}
+	}
+
+	box, ok, err := utils.ParseBoundingBoxFilter(filters)
+	if err != nil {
+		return nil, i, err
+	}
+	if ok {
+		geo.where += fmt.Sprintf(" AND c.latitude BETWEEN $%d AND $%d", i, i+1)
+		if box.MinLongitude <= box.MaxLongitude {
+			geo.where += fmt.Sprintf(" AND c.longitude BETWEEN $%d AND $%d", i+2, i+3)
+		} else {
+			// across the antimeridian
+			geo.where += fmt.Sprintf(" AND (c.longitude >= $%d OR c.longitude <= $%d)", i+2, i+3)
+		}
+		geo.args = append(geo.args, box.MinLatitude, box.MaxLatitude, box.MinLongitude, box.MaxLongitude)
+		i += 4
+	}
+
+	return geo, i, nil
+}
+
+// located reports whether the filters restrict the rows to a place.
+func (g *geoFilter) located() bool {
+	return g.where != ""
+}
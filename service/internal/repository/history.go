@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+// historySortColumns maps the sort keys accepted by listHistory to their columns.
+var historySortColumns = map[string]string{
+	"version":    "version",
+	"valid_from": "valid_from",
+}
+
+// historyData returns what reads the data of a version of table from its
+// history h. The versions of identifiers written before statuses were steps
+// of their verification keep their former status, translated as they are read.
+func historyData(table string) string {
+	if table == "identifiers" {
+		return `identifier_history_data(h.data)`
+	}
+	return `h.data`
+}
+
+// detailSource returns what the detail query of table reads from: the table
+// itself, or the version of the row in its history current at asOf or
+// numbered version. The row ID is bound to $1 and the history bound, if any,
+// to $2, which args holds.
+func detailSource(table string, asOf *time.Time, version *uint) (source string, args []interface{}) {
+	var condition string
+	switch {
+	case asOf != nil:
+		condition = `h.valid_from <= $2 AND (h.valid_to IS NULL OR h.valid_to > $2)`
+		args = append(args, *asOf)
+	case version != nil:
+		condition = `h.version = $2`
+		args = append(args, *version)
+	default:
+		return table, nil
+	}
+
+	return `(
+		SELECT r.* FROM ` + table + `_history h
+		CROSS JOIN LATERAL jsonb_populate_record(NULL::` + table + `, ` + historyData(table) + `) r
+		WHERE h.id = $1 AND ` + condition + `
+	)`, args
+}
+
+// listHistory lists the versions of the row id of table, newest first unless
+// sorted otherwise. table must be one of userChildTables or users.
+func listHistory(ctx context.Context, sqlDB *sqlx.DB, table string, id uint, filters map[string]string) ([]dtos.HistoryDTO, int, error) {
+	history := []dtos.HistoryDTO{}
+	var total int
+
+	from := `FROM (
+        SELECT h.id, h.version, h.operation, ` + historyData(table) + ` AS data, h.changed_by_id, h.valid_from, h.valid_to,
+        u.name as changed_by_name
+
+        FROM ` + table + `_history h
+        LEFT JOIN users u ON h.changed_by_id = u.id
+    ) AS alias WHERE id = $1`
+
+	query := `SELECT version, operation, data, changed_by_id, changed_by_name, valid_from, valid_to ` + from
+	countQuery := `SELECT COUNT(*) ` + from
+
+	if filters["sort"] == "" && filters["order_column"] == "" {
+		filters["sort"] = "-version"
+	}
+	sort, err := utils.ParseSort(filters, historySortColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+	query += sort.OrderBy("version")
+
+	perPage := utils.GetIntOrDefault(filters["per_page"], 10)
+	currentPage := utils.GetIntOrDefault(filters["page"], 1)
+
+	query += ` LIMIT $2 OFFSET $3`
+
+	// Channels for concurrent execution
+	countChan := make(chan error)
+	selectChan := make(chan error)
+
+	// Goroutine for count query
+	go func() {
+		countChan <- getContext(ctx, sqlDB, &total, countQuery, id)
+	}()
+
+	// Goroutine for select query
+	go func() {
+		selectChan <- selectContext(ctx, sqlDB, &history, query, id, perPage, (currentPage-1)*perPage)
+	}()
+
+	// Wait for both goroutines to finish
+	countErr := <-countChan
+	selectErr := <-selectChan
+
+	if countErr != nil {
+		return nil, 0, countErr
+	}
+
+	if selectErr != nil {
+		return nil, 0, selectErr
+	}
+
+	return history, total, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+type IdempotencyRepository struct {
+	*Transactor
+	db    *gorm.DB
+	sqlDB *sqlx.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB, sqlDB *sqlx.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
+	}
+}
+
+// ClaimIdempotencyKey records that a request hashing to requestHash runs with
+// key, unless another request holds it. An expired key, or one left
+// processing for longer than lockTimeout by a request that never completed,
+// is taken over. It reports whether the key was claimed.
+func (r *IdempotencyRepository) ClaimIdempotencyKey(ctx context.Context, key dtos.IdempotencyKey, requestHash string, ttl time.Duration, lockTimeout time.Duration) (bool, error) {
+	now := time.Now()
+	var ids []int64
+	err := selectContext(ctx, r.sqlDB, &ids, `
+		INSERT INTO idempotency_keys (user_id, path, key, request_hash, status, locked_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, path, key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash, status = EXCLUDED.status,
+			response_status = NULL, response_headers = NULL, response_body = NULL,
+			locked_at = EXCLUDED.locked_at, created_at = EXCLUDED.locked_at,
+			completed_at = NULL, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < $6
+		OR (idempotency_keys.status = $5 AND idempotency_keys.locked_at < $8)
+		RETURNING id
+	`, key.UserID, key.Path, key.Key, requestHash, utils.IdempotencyStatusProcessing, now, now.Add(ttl), now.Add(-lockTimeout))
+	if err != nil {
+		return false, err
+	}
+	return len(ids) > 0, nil
+}
+
+// GetIdempotencyKey returns the request holding key, sql.ErrNoRows when none
+// does.
+func (r *IdempotencyRepository) GetIdempotencyKey(ctx context.Context, key dtos.IdempotencyKey) (*dtos.IdempotencyRecord, error) {
+	var row struct {
+		RequestHash     string `db:"request_hash"`
+		Status          string `db:"status"`
+		ResponseStatus  *int   `db:"response_status"`
+		ResponseHeaders []byte `db:"response_headers"`
+		ResponseBody    []byte `db:"response_body"`
+	}
+	query := `SELECT request_hash, status, response_status, response_headers, response_body
+	FROM idempotency_keys WHERE user_id = $1 AND path = $2 AND key = $3`
+	if err := getContext(ctx, r.sqlDB, &row, query, key.UserID, key.Path, key.Key); err != nil {
+		return nil, err
+	}
+
+	record := &dtos.IdempotencyRecord{
+		RequestHash:  row.RequestHash,
+		Status:       row.Status,
+		ResponseBody: row.ResponseBody,
+	}
+	if row.ResponseStatus != nil {
+		record.ResponseStatus = *row.ResponseStatus
+	}
+	if row.ResponseHeaders != nil {
+		if err := json.Unmarshal(row.ResponseHeaders, &record.ResponseHeaders); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// CompleteIdempotencyKey stores the response of the request holding key.
+func (r *IdempotencyRepository) CompleteIdempotencyKey(ctx context.Context, key dtos.IdempotencyKey, status int, headers map[string]string, body []byte) error {
+	rawHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	return gormFrom(ctx, r.db).Exec(`
+		UPDATE idempotency_keys SET status = ?, response_status = ?, response_headers = ?, response_body = ?, completed_at = NOW()
+		WHERE user_id = ? AND path = ? AND key = ? AND status = ?
+	`, utils.IdempotencyStatusCompleted, status, string(rawHeaders), body, key.UserID, key.Path, key.Key, utils.IdempotencyStatusProcessing).Error
+}
+
+// ReleaseIdempotencyKey frees key after its request failed without a
+// response worth replaying, so that a retry runs again.
+func (r *IdempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, key dtos.IdempotencyKey) error {
+	return gormFrom(ctx, r.db).Exec(`
+		DELETE FROM idempotency_keys WHERE user_id = ? AND path = ? AND key = ? AND status = ?
+	`, key.UserID, key.Path, key.Key, utils.IdempotencyStatusProcessing).Error
+}
+
+// PurgeExpiredIdempotencyKeys deletes up to limit expired keys and returns
+// how many were deleted.
+func (r *IdempotencyRepository) PurgeExpiredIdempotencyKeys(ctx context.Context, limit int) (int64, error) {
+	result := gormFrom(ctx, r.db).Exec(`
+		DELETE FROM idempotency_keys WHERE id IN (
+			SELECT id FROM idempotency_keys WHERE expires_at < NOW() ORDER BY id LIMIT ?
+		)
+	`, limit)
+	return result.RowsAffected, result.Error
+}
@@ -5,40 +5,169 @@ import (
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/nibroos/nb-go-api/service/internal/dtos"
 	"github.com/nibroos/nb-go-api/service/internal/models"
 	"github.com/nibroos/nb-go-api/service/internal/utils"
 	"gorm.io/gorm"
 )
 
+// identifierSortColumns maps the sort keys accepted by ListIdentifiers to their columns.
+var identifierSortColumns = map[string]string{
+	"id":                   "id",
+	"ref_num":              "ref_num",
+	"user_name":            "user_name",
+	"type_identifier_name": "type_identifier_name",
+	"status":               "status",
+	"issued_at":            "issued_at",
+	"expires_at":           "expires_at",
+	"created_at":           "created_at",
+	"updated_at":           "updated_at",
+}
+
+// IdentifierListFields are the fields index-identifier can select with `fields`.
+var IdentifierListFields = map[string]utils.Field{
+	"id":                   {Column: "id"},
+	"user_id":              {Column: "user_id"},
+	"user_name":            {Column: "user_name"},
+	"type_identifier_id":   {Column: "type_identifier_id"},
+	"type_identifier_name": {Column: "type_identifier_name"},
+	"ref_num":              {Column: "ref_num", Permission: utils.PermissionReadIdentifiers},
+	"status":               {Column: "status"},
+	"status_reason":        {Column: "status_reason"},
+	"issued_at":            {Column: "to_char(issued_at, 'YYYY-MM-DD') AS issued_at"},
+	"expires_at":           {Column: "to_char(expires_at, 'YYYY-MM-DD') AS expires_at"},
+	"created_at":           {Column: "created_at"},
+	"updated_at":           {Column: "updated_at"},
+	"version":              {Column: "version"},
+}
+
+// IdentifierTrashFields are the fields index-trash-identifier can select with `fields`.
+var IdentifierTrashFields = trashFields(IdentifierListFields)
+
+// IdentifierDetailFields are the fields show-identifier can select with `fields`.
+var IdentifierDetailFields = map[string]utils.Field{
+	"id":                   {Column: "i.id"},
+	"user_id":              {Column: "i.user_id"},
+	"user_name":            {Column: "u.name AS user_name"},
+	"type_identifier_id":   {Column: "i.type_identifier_id"},
+	"type_identifier_name": {Column: "ti.name AS type_identifier_name"},
+	"ref_num":              {Column: "i.ref_num", Permission: utils.PermissionReadIdentifiers},
+	"status":               {Column: "i.status"},
+	"status_reason":        {Column: "i.status_reason"},
+	"issued_at":            {Column: "to_char(i.issued_at, 'YYYY-MM-DD') AS issued_at"},
+	"expires_at":           {Column: "to_char(i.expires_at, 'YYYY-MM-DD') AS expires_at"},
+	"created_at":           {Column: "i.created_at"},
+	"updated_at":           {Column: "i.updated_at"},
+	"version":              {Column: "i.version"},
+	"created_by_id":        {Column: "i.created_by_id"},
+	"updated_by_id":        {Column: "i.updated_by_id"},
+	"deleted_at":           {Column: "i.deleted_at"},
+}
+
 type IdentifierRepository struct {
+	*Transactor
 	db    *gorm.DB
 	sqlDB *sqlx.DB
 }
 
 func NewIdentifierRepository(db *gorm.DB, sqlDB *sqlx.DB) *IdentifierRepository {
 	return &IdentifierRepository{
-		db:    db,
-		sqlDB: sqlDB,
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
 	}
 }
 
 func (r *IdentifierRepository) ListIdentifiers(ctx context.Context, filters map[string]string) ([]dtos.IdentifierListDTO, int, error) {
+	return r.listIdentifiers(ctx, filters, false)
+}
+
+// ListTrashedIdentifiers lists the soft deleted identifiers, most recently deleted first.
+func (r *IdentifierRepository) ListTrashedIdentifiers(ctx context.Context, filters map[string]string) ([]dtos.IdentifierListDTO, int, error) {
+	return r.listIdentifiers(ctx, filters, true)
+}
+
+func (r *IdentifierRepository) listIdentifiers(ctx context.Context, filters map[string]string, trashed bool) ([]dtos.IdentifierListDTO, int, error) {
 	identifiers := []dtos.IdentifierListDTO{}
 	var total int
 
+	list, err := identifierListQuery(filters, trashed)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	perPage := utils.GetIntOrDefault(filters["per_page"], 10)
+	currentPage := utils.GetIntOrDefault(filters["page"], 1)
+
+	query := list.query + fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(list.args)+1, len(list.args)+2)
+	args := append(append([]interface{}{}, list.args...), perPage, (currentPage-1)*perPage)
+
+	// Channels for concurrent execution
+	countChan := make(chan error)
+	selectChan := make(chan error)
+
+	// Goroutine for count query
+	go func() {
+		err := getContext(ctx, r.sqlDB, &total, list.countQuery, list.args...)
+		countChan <- err
+	}()
+
+	// Goroutine for select query
+	go func() {
+		err := selectContext(ctx, r.sqlDB, &identifiers, query, args...)
+		selectChan <- err
+	}()
+
+	// Wait for both goroutines to finish
+	countErr := <-countChan
+	selectErr := <-selectChan
+
+	if countErr != nil {
+		return nil, 0, countErr
+	}
+
+	if selectErr != nil {
+		return nil, 0, selectErr
+	}
+
+	return identifiers, total, nil
+}
+
+// ExportIdentifiers returns the query of index-identifier for filters, unpaged, for
+// StreamExport.
+func (r *IdentifierRepository) ExportIdentifiers(filters map[string]string) (*ListQuery, error) {
+	return identifierListQuery(filters, false)
+}
+
+// identifierListQuery builds the query of index-identifier (index-trash-identifier when
+// trashed) for filters, without paging.
+func identifierListQuery(filters map[string]string, trashed bool) (*ListQuery, error) {
+	allowedFields, sortColumns, scope := IdentifierListFields, identifierSortColumns, "IS NULL"
+	if trashed {
+		allowedFields, sortColumns, scope = IdentifierTrashFields, trashSortColumns(identifierSortColumns), "IS NOT NULL"
+		if filters["sort"] == "" && filters["order_column"] == "" {
+			filters["sort"] = "-deleted_at"
+		}
+	}
+
 	from := `FROM (
-        SELECT i.id, i.user_id, i.type_identifier_id, i.ref_num, i.status, i.created_at, i.updated_at,
+        SELECT i.id, i.user_id, i.type_identifier_id, i.ref_num, i.status, i.status_reason, i.issued_at, i.expires_at, i.created_at, i.updated_at, i.version, i.deleted_at,
         u.name as user_name,
         ti.name as type_identifier_name
 
         FROM identifiers i
         JOIN users u ON i.user_id = u.id
         JOIN mix_values ti ON i.type_identifier_id = ti.id
-        WHERE i.deleted_at IS NULL
+        WHERE i.deleted_at ` + scope + `
     ) AS alias WHERE 1=1`
 
-	query := `SELECT * ` + from
+	fields, err := utils.ParseFields(filters["fields"], allowedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + utils.SelectColumns(fields, allowedFields) + ` ` + from
 	countQuery := `SELECT COUNT(*) ` + from
 
 	var args []interface{}
@@ -62,6 +191,25 @@ func (r *IdentifierRepository) ListIdentifiers(ctx context.Context, filters map[
 		i++
 	}
 
+	if value, ok := filters["status"]; ok && value != "" {
+		query += fmt.Sprintf(" AND status = $%d", i)
+		countQuery += fmt.Sprintf(" AND status = $%d", i)
+		args = append(args, value)
+		i++
+	}
+
+	// expiring within that many days from today, the ones expired left out
+	days, ok, err := utils.ParseDaysFilter(filters, "expiring_within")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		query += fmt.Sprintf(" AND expires_at BETWEEN CURRENT_DATE AND CURRENT_DATE + $%d::INT", i)
+		countQuery += fmt.Sprintf(" AND expires_at BETWEEN CURRENT_DATE AND CURRENT_DATE + $%d::INT", i)
+		args = append(args, days)
+		i++
+	}
+
 	if value, ok := filters["global"]; ok && value != "" {
 		query += fmt.Sprintf(" AND (ref_num ILIKE $%d OR user_name ILIKE $%d OR type_identifier_name ILIKE $%d)", i, i+1, i+2)
 		countQuery += fmt.Sprintf(" AND (ref_num ILIKE $%d OR user_name ILIKE $%d OR type_identifier_name ILIKE $%d)", i, i+1, i+2)
@@ -69,59 +217,23 @@ func (r *IdentifierRepository) ListIdentifiers(ctx context.Context, filters map[
 		i += 3
 	}
 
-	countArgs := append([]interface{}{}, args...)
-
-	allowedOrderColumns := []string{"id", "ref_num", "user_name", "type_identifier_name"}
-	orderColumn := utils.GetStringOrDefaultFromArray(filters["order_column"], allowedOrderColumns, "id")
-	orderDirection := utils.GetStringOrDefault(filters["order_direction"], "asc")
-	query += fmt.Sprintf(" ORDER BY %s %s", orderColumn, orderDirection)
-
-	perPage := utils.GetIntOrDefault(filters["per_page"], 10)
-	currentPage := utils.GetIntOrDefault(filters["page"], 1)
-
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", i, i+1)
-	args = append(args, perPage, (currentPage-1)*perPage)
-
-	// Channels for concurrent execution
-	countChan := make(chan error)
-	selectChan := make(chan error)
-
-	// Goroutine for count query
-	go func() {
-		err := r.sqlDB.GetContext(ctx, &total, countQuery, countArgs...)
-		countChan <- err
-	}()
-
-	// Goroutine for select query
-	go func() {
-		err := r.sqlDB.SelectContext(ctx, &identifiers, query, args...)
-		selectChan <- err
-	}()
-
-	// Wait for both goroutines to finish
-	countErr := <-countChan
-	selectErr := <-selectChan
-
-	if countErr != nil {
-		return nil, 0, countErr
-	}
-
-	if selectErr != nil {
-		return nil, 0, selectErr
+	sort, err := utils.ParseSort(filters, sortColumns)
+	if err != nil {
+		return nil, err
 	}
+	query += sort.OrderBy("id")
 
-	return identifiers, total, nil
+	return &ListQuery{query: query, countQuery: countQuery, args: args}, nil
 }
 
 func (r *IdentifierRepository) GetIdentifierByID(ctx context.Context, params *dtos.GetIdentifierParams) (*dtos.IdentifierDetailDTO, error) {
 	var identifier dtos.IdentifierDetailDTO
 	// deletedAt := params.IsDeleted
 
-	query := `SELECT i.id, i.user_id, i.type_identifier_id, i.ref_num, i.status, i.created_at, i.updated_at, i.deleted_at,
-	u.name as user_name,
-	ti.name as type_identifier_name
+	source, historyArgs := detailSource("identifiers", params.AsOf, params.Version)
 
-	FROM identifiers i
+	query := `SELECT ` + utils.SelectColumns(params.Fields, IdentifierDetailFields) + `
+	FROM ` + source + ` i
 	JOIN users u ON i.user_id = u.id
 	JOIN mix_values ti ON i.type_identifier_id = ti.id
 	WHERE 1=1`
@@ -131,59 +243,268 @@ func (r *IdentifierRepository) GetIdentifierByID(ctx context.Context, params *dt
 	i := 1
 	query += " AND i.id = $1"
 	args = append(args, params.ID)
-	i++
+	args = append(args, historyArgs...)
+	i += 1 + len(historyArgs)
 
 	isDeletedQuery := ` AND i.deleted_at IS NULL`
 	if params.IsDeleted != nil && *params.IsDeleted == 1 {
 		isDeletedQuery = " AND i.deleted_at IS NOT NULL"
 	}
 
+	if params.UserID != 0 {
+		query += fmt.Sprintf(" AND i.user_id = $%d", i)
+		args = append(args, params.UserID)
+		i++
+	}
+
 	query += isDeletedQuery
 
-	if err := r.sqlDB.Get(&identifier, query, args...); err != nil {
+	if err := getContext(ctx, r.sqlDB, &identifier, query, args...); err != nil {
 		return nil, err
 	}
 
 	return &identifier, nil
 }
 
-// BeginTransaction starts a new transaction
-func (r *IdentifierRepository) BeginTransaction() *gorm.DB {
-	return r.db.Begin()
+// GetIdentifierHistory lists the versions of a identifier.
+func (r *IdentifierRepository) GetIdentifierHistory(ctx context.Context, id uint, filters map[string]string) ([]dtos.HistoryDTO, int, error) {
+	return listHistory(ctx, r.sqlDB, "identifiers", id, filters)
+}
+
+// GetUsersByIDs loads the users embedded by include=user, keyed by ID.
+func (r *IdentifierRepository) GetUsersByIDs(ctx context.Context, ids []uint) (map[uint]dtos.UserListDTO, error) {
+	return usersByIDs(ctx, r.sqlDB, ids)
+}
+
+// GetIdentifierTypeValidators returns the options_json of the identifier
+// types ids, keyed by ID, for the validators they declare. Types without
+// options are left out.
+func (r *IdentifierRepository) GetIdentifierTypeValidators(ctx context.Context, ids []uint) (map[uint]string, error) {
+	return mixValueOptions(ctx, r.sqlDB, ids)
+}
+
+// GetIdentifierTypeIDs returns the type of the identifiers ids, keyed by ID.
+func (r *IdentifierRepository) GetIdentifierTypeIDs(ctx context.Context, ids []uint) (map[uint]uint, error) {
+	result := make(map[uint]uint)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var identifiers []IdentifierRefNum
+	query := `SELECT id, type_identifier_id FROM identifiers WHERE id IN (?)`
+	if err := selectIn(ctx, r.sqlDB, &identifiers, query, ids); err != nil {
+		return nil, err
+	}
+
+	for _, identifier := range identifiers {
+		result[identifier.ID] = identifier.TypeIdentifierID
+	}
+	return result, nil
+}
+
+// IdentifierRefNum is the ref_num of an identifier and what it is checked by.
+type IdentifierRefNum struct {
+	ID                 uint   `db:"id"`
+	UserID             uint   `db:"user_id"`
+	TypeIdentifierID   uint   `db:"type_identifier_id"`
+	TypeIdentifierName string `db:"type_identifier_name"`
+	RefNum             string `db:"ref_num"`
+}
+
+// ListIdentifierRefNums lists up to limit identifiers not in the trash nor of
+// an erased user, with an ID above afterID, in ID order, for checking their
+// ref_num.
+func (r *IdentifierRepository) ListIdentifierRefNums(ctx context.Context, afterID uint, limit int) ([]IdentifierRefNum, error) {
+	identifiers := []IdentifierRefNum{}
+	query := `
+		SELECT i.id, i.user_id, i.type_identifier_id, ti.name AS type_identifier_name, i.ref_num
+		FROM identifiers i
+		JOIN mix_values ti ON i.type_identifier_id = ti.id
+		WHERE i.id > $1 AND i.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = i.user_id AND u.erased_at IS NOT NULL)
+		ORDER BY i.id
+		LIMIT $2`
+	if err := selectContext(ctx, r.sqlDB, &identifiers, query, afterID, limit); err != nil {
+		return nil, err
+	}
+	return identifiers, nil
 }
 
-func (r *IdentifierRepository) CreateIdentifier(tx *gorm.DB, identifier *models.Identifier) error {
-	if err := tx.Create(identifier).Error; err != nil {
+func (r *IdentifierRepository) CreateIdentifier(ctx context.Context, identifier *models.Identifier) error {
+	if err := gormFrom(ctx, r.db).Create(identifier).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *IdentifierRepository) UpdateIdentifier(tx *gorm.DB, identifier *models.Identifier) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Save(identifier).Error; err != nil {
-			return err
-		}
-		return nil
-	})
+// UpdateIdentifier saves identifier if its Version is still the stored one, and bumps it.
+// Its status only changes through TransitionIdentifier.
+func (r *IdentifierRepository) UpdateIdentifier(ctx context.Context, identifier *models.Identifier) error {
+	expected := identifier.Version
+	identifier.Version = expected + 1
 
+	result := gormFrom(ctx, r.db).Select("*").Omit("status", "status_reason").Where("version = ?", expected).Updates(identifier)
+	if err := checkVersion(result); err != nil {
+		identifier.Version = expected
+		return err
+	}
+	return nil
 }
 
-func (r *IdentifierRepository) DeleteIdentifier(tx *gorm.DB, id uint) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		// if err := tx.Unscoped().Delete(&models.Identifier{}, id).Error; err != nil {
-		if err := tx.Delete(&models.Identifier{}, id).Error; err != nil {
-			return err
-		}
-		return nil
-	})
+func (r *IdentifierRepository) DeleteIdentifier(ctx context.Context, id uint, version uint) error {
+	return checkVersion(gormFrom(ctx, r.db).Exec(`
+		UPDATE identifiers SET deleted_at = NOW(), version = version + 1
+		WHERE id = ? AND version = ? AND deleted_at IS NULL
+	`, id, version))
 }
 
-func (r *IdentifierRepository) RestoreIdentifier(tx *gorm.DB, id uint) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Exec("UPDATE identifiers SET deleted_at = NULL WHERE id = ?", id).Error; err != nil {
-			return err
-		}
-		return nil
-	})
+func (r *IdentifierRepository) RestoreIdentifier(ctx context.Context, id uint, version uint) error {
+	return checkVersion(gormFrom(ctx, r.db).Exec(`
+		UPDATE identifiers SET deleted_at = NULL, version = version + 1
+		WHERE id = ? AND version = ? AND deleted_at IS NOT NULL
+	`, id, version))
+}
+
+// GetIdentifierStatus returns the status of a live identifier, locking it
+// until the end of the unit of work.
+func (r *IdentifierRepository) GetIdentifierStatus(ctx context.Context, id uint) (string, error) {
+	var status string
+	err := getContext(ctx, r.sqlDB, &status, `SELECT status FROM identifiers WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id)
+	return status, err
+}
+
+// TransitionIdentifier moves an identifier, expected at version and in status
+// from, to status to with reason, bumping its version, and records the
+// transition.
+func (r *IdentifierRepository) TransitionIdentifier(ctx context.Context, id uint, version uint, from string, to string, reason *string) error {
+	if err := checkVersion(gormFrom(ctx, r.db).Exec(`
+		UPDATE identifiers SET status = ?, status_reason = ?, version = version + 1
+		WHERE id = ? AND version = ? AND status = ? AND deleted_at IS NULL
+	`, to, reason, id, version, from)); err != nil {
+		return err
+	}
+
+	return gormFrom(ctx, r.db).Exec(`
+		INSERT INTO identifier_status_transitions (identifier_id, from_status, to_status, reason, actor_id)
+		VALUES (?, ?, ?, ?, current_actor_id())
+	`, id, from, to, reason).Error
+}
+
+// IdentifierExpiryReminder is an expiry reminder due for an identifier,
+// expiring in DaysBefore days or less.
+type IdentifierExpiryReminder struct {
+	IdentifierID uint   `db:"identifier_id"`
+	UserID       uint   `db:"user_id"`
+	ExpiresAt    string `db:"expires_at"` // YYYY-MM-DD
+	DaysBefore   int    `db:"days_before"`
+}
+
+// ListDueIdentifierReminders lists up to limit reminders due for the live
+// identifiers not expired yet, with an ID above afterID, in ID order. An
+// identifier is due the reminder of the fewest days among days it expires
+// within, unless it was sent for its expiry date already, so an identifier
+// written close to its expiry date is not sent the earlier ones at once.
+func (r *IdentifierRepository) ListDueIdentifierReminders(ctx context.Context, days []int, afterID uint, limit int) ([]IdentifierExpiryReminder, error) {
+	offsets := make([]int64, len(days))
+	for i, day := range days {
+		offsets[i] = int64(day)
+	}
+
+	reminders := []IdentifierExpiryReminder{}
+	query := `
+		SELECT i.id AS identifier_id, i.user_id, to_char(i.expires_at, 'YYYY-MM-DD') AS expires_at, d.days_before
+		FROM identifiers i
+		CROSS JOIN LATERAL (
+			SELECT MIN(o.days) AS days_before FROM unnest($1::INT[]) AS o(days)
+			WHERE o.days >= i.expires_at - CURRENT_DATE
+		) d
+		WHERE i.id > $2 AND i.deleted_at IS NULL AND i.status <> $4
+		AND i.expires_at >= CURRENT_DATE AND d.days_before IS NOT NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM identifier_expiry_reminders er
+			WHERE er.identifier_id = i.id AND er.expires_at = i.expires_at AND er.days_before = d.days_before
+		)
+		ORDER BY i.id
+		LIMIT $3`
+	if err := selectContext(ctx, r.sqlDB, &reminders, query, pq.Array(offsets), afterID, limit, utils.IdentifierStatusExpired); err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+// RecordIdentifierReminder records that reminder was sent, so it is not due
+// anymore.
+func (r *IdentifierRepository) RecordIdentifierReminder(ctx context.Context, reminder IdentifierExpiryReminder) error {
+	return gormFrom(ctx, r.db).Exec(`
+		INSERT INTO identifier_expiry_reminders (identifier_id, expires_at, days_before)
+		VALUES (?, ?::DATE, ?)
+		ON CONFLICT DO NOTHING
+	`, reminder.IdentifierID, reminder.ExpiresAt, reminder.DaysBefore).Error
+}
+
+// ExpiredIdentifier is a verified identifier whose expiry date has passed.
+type ExpiredIdentifier struct {
+	ID        uint   `db:"id"`
+	Version   uint   `db:"version"`
+	ExpiresAt string `db:"expires_at"` // YYYY-MM-DD
+}
+
+// ListExpiredIdentifiers lists up to limit live verified identifiers whose
+// expiry date has passed, with an ID above afterID, in ID order.
+func (r *IdentifierRepository) ListExpiredIdentifiers(ctx context.Context, afterID uint, limit int) ([]ExpiredIdentifier, error) {
+	identifiers := []ExpiredIdentifier{}
+	query := `
+		SELECT id, version, to_char(expires_at, 'YYYY-MM-DD') AS expires_at
+		FROM identifiers
+		WHERE id > $1 AND deleted_at IS NULL AND status = $2 AND expires_at < CURRENT_DATE
+		ORDER BY id
+		LIMIT $3`
+	if err := selectContext(ctx, r.sqlDB, &identifiers, query, afterID, utils.IdentifierStatusVerified, limit); err != nil {
+		return nil, err
+	}
+	return identifiers, nil
+}
+
+// ListIdentifierTransitions lists the status transitions of an identifier,
+// newest first, limited to the identifiers of userID unless it is 0.
+func (r *IdentifierRepository) ListIdentifierTransitions(ctx context.Context, id uint, userID uint, filters map[string]string) ([]dtos.IdentifierTransitionDTO, int, error) {
+	transitions := []dtos.IdentifierTransitionDTO{}
+	var total int
+
+	from := `FROM identifier_status_transitions t
+	JOIN identifiers i ON i.id = t.identifier_id
+	LEFT JOIN users u ON u.id = t.actor_id
+	WHERE t.identifier_id = $1 AND ($2 = 0 OR i.user_id = $2)`
+
+	query := `SELECT t.id, t.from_status, t.to_status, t.reason, t.actor_id, u.name AS actor_name, t.created_at ` + from +
+		` ORDER BY t.id DESC LIMIT $3 OFFSET $4`
+	countQuery := `SELECT COUNT(*) ` + from
+
+	perPage := utils.GetIntOrDefault(filters["per_page"], 10)
+	currentPage := utils.GetIntOrDefault(filters["page"], 1)
+
+	// Channels for concurrent execution
+	countChan := make(chan error)
+	selectChan := make(chan error)
+
+	go func() {
+		countChan <- getContext(ctx, r.sqlDB, &total, countQuery, id, userID)
+	}()
+
+	go func() {
+		selectChan <- selectContext(ctx, r.sqlDB, &transitions, query, id, userID, perPage, (currentPage-1)*perPage)
+	}()
+
+	countErr := <-countChan
+	selectErr := <-selectChan
+
+	if countErr != nil {
+		return nil, 0, countErr
+	}
+
+	if selectErr != nil {
+		return nil, 0, selectErr
+	}
+
+	return transitions, total, nil
 }
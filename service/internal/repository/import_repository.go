@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+type ImportRepository struct {
+	*Transactor
+	db    *gorm.DB
+	sqlDB *sqlx.DB
+}
+
+func NewImportRepository(db *gorm.DB, sqlDB *sqlx.DB) *ImportRepository {
+	return &ImportRepository{
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
+	}
+}
+
+func (r *ImportRepository) CreateImportJob(ctx context.Context, job *models.ImportJob) error {
+	return gormFrom(ctx, r.db).Create(job).Error
+}
+
+// GetImportJob returns an import job without its file and report.
+func (r *ImportRepository) GetImportJob(ctx context.Context, id uint) (*dtos.ImportJobDTO, error) {
+	var job dtos.ImportJobDTO
+	query := `SELECT id, entity, file_name, status, total_rows, invalid_rows, processed_rows,
+	imported_rows, failed_rows, error, created_by_id, created_at, started_at, finished_at
+	FROM import_jobs WHERE id = $1`
+	if err := getContext(ctx, r.sqlDB, &job, query, id); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetImportFile returns an import job with the file it was uploaded with.
+func (r *ImportRepository) GetImportFile(ctx context.Context, id uint) (*models.ImportJob, error) {
+	var job models.ImportJob
+	if err := gormFrom(ctx, r.db).Omit("report").First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetImportReport returns the report of the last validation or commit of an
+// import job.
+func (r *ImportRepository) GetImportReport(ctx context.Context, id uint) ([]dtos.ImportRowError, error) {
+	var raw []byte
+	if err := getContext(ctx, r.sqlDB, &raw, `SELECT report FROM import_jobs WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+
+	report := []dtos.ImportRowError{}
+	if err := json.Unmarshal(raw, &report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// SetImportStatus moves an import job from one of the statuses from to
+// status, ErrImportStatus when it is in none of them.
+func (r *ImportRepository) SetImportStatus(ctx context.Context, id uint, status string, from ...string) error {
+	result := gormFrom(ctx, r.db).Exec(`
+		UPDATE import_jobs SET status = ?, updated_at = NOW()
+		WHERE id = ? AND status IN ?
+	`, status, id, from)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrImportStatus
+	}
+	return nil
+}
+
+// StartImportJob marks a queued import job running, ErrImportStatus when it
+// is not queued.
+func (r *ImportRepository) StartImportJob(ctx context.Context, id uint) error {
+	result := gormFrom(ctx, r.db).Exec(`
+		UPDATE import_jobs SET status = ?, started_at = NOW(), processed_rows = 0,
+		imported_rows = 0, failed_rows = 0, updated_at = NOW()
+		WHERE id = ? AND status = ?
+	`, utils.ImportStatusRunning, id, utils.ImportStatusQueued)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrImportStatus
+	}
+	return nil
+}
+
+// UpdateImportProgress records how far the commit of an import job got.
+func (r *ImportRepository) UpdateImportProgress(ctx context.Context, id uint, processed int, imported int, failed int) error {
+	return gormFrom(ctx, r.db).Exec(`
+		UPDATE import_jobs SET processed_rows = ?, imported_rows = ?, failed_rows = ?, updated_at = NOW()
+		WHERE id = ?
+	`, processed, imported, failed, id).Error
+}
+
+// FinishImportJob stores the outcome of the commit of an import job. A nil
+// report keeps the one of the dry run.
+func (r *ImportRepository) FinishImportJob(ctx context.Context, id uint, status string, totalRows int, report []dtos.ImportRowError, failure *string) error {
+	var rawReport *string
+	if report != nil {
+		raw, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		value := string(raw)
+		rawReport = &value
+	}
+
+	return gormFrom(ctx, r.db).Exec(`
+		UPDATE import_jobs SET status = ?, total_rows = ?, report = COALESCE(?::jsonb, report),
+		error = ?, finished_at = ?, updated_at = NOW()
+		WHERE id = ?
+	`, status, totalRows, rawReport, failure, time.Now(), id).Error
+}
+
+// MixValueIDs returns the IDs of the live mix_values of a group keyed by
+// their lower case name, how the type columns of an import are resolved.
+func (r *ImportRepository) MixValueIDs(ctx context.Context, group string) (map[string]uint, error) {
+	var rows []struct {
+		ID   uint   `db:"id"`
+		Name string `db:"name"`
+	}
+	query := `SELECT mv.id, mv.name FROM mix_values mv
+	JOIN groups g ON g.id = mv.group_id
+	WHERE g.name = $1 AND mv.deleted_at IS NULL`
+	if err := selectContext(ctx, r.sqlDB, &rows, query, group); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]uint, len(rows))
+	for _, row := range rows {
+		ids[strings.ToLower(row.Name)] = row.ID
+	}
+	return ids, nil
+}
+
+// UserIDsByEmails returns the IDs of the live users with the given emails,
+// keyed by lower case email.
+func (r *ImportRepository) UserIDsByEmails(ctx context.Context, emails []string) (map[string]uint, error) {
+	ids := make(map[string]uint, len(emails))
+	if len(emails) == 0 {
+		return ids, nil
+	}
+
+	query, args, err := sqlx.In(`SELECT id, LOWER(email) AS email FROM users WHERE LOWER(email) IN (?) AND deleted_at IS NULL`, emails)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		ID    uint   `db:"id"`
+		Email string `db:"email"`
+	}
+	if err := selectContext(ctx, r.sqlDB, &rows, r.sqlDB.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		ids[row.Email] = row.ID
+	}
+	return ids, nil
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+)
+
+// Relations are loaded with one query per relation for all requested parents
+// (never one query per parent) and grouped in Go.
+
+// UserIncludeRelations are the relations index-user and show-user can embed.
+var UserIncludeRelations = []string{"contacts", "addresses", "identifiers", "roles"}
+
+// ChildIncludeRelations are the relations the contact, address and
+// identifier endpoints can embed.
+var ChildIncludeRelations = []string{"user"}
+
+func selectIn(ctx context.Context, sqlDB *sqlx.DB, dest interface{}, query string, args ...interface{}) error {
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return err
+	}
+	return selectContext(ctx, sqlDB, dest, sqlDB.Rebind(query), args...)
+}
+
+// mixValueOptions returns the options_json of the mix_values ids, keyed by ID.
+// Values without options are left out.
+func mixValueOptions(ctx context.Context, sqlDB *sqlx.DB, ids []uint) (map[uint]string, error) {
+	result := make(map[uint]string)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var values []struct {
+		ID          uint   `db:"id"`
+		OptionsJSON string `db:"options_json"`
+	}
+	query := `SELECT id, options_json::TEXT AS options_json FROM mix_values WHERE options_json IS NOT NULL AND id IN (?)`
+	if err := selectIn(ctx, sqlDB, &values, query, ids); err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		result[value.ID] = value.OptionsJSON
+	}
+	return result, nil
+}
+
+func usersByIDs(ctx context.Context, sqlDB *sqlx.DB, ids []uint) (map[uint]dtos.UserListDTO, error) {
+	result := make(map[uint]dtos.UserListDTO)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	users := []dtos.UserListDTO{}
+	query := `SELECT id, username, name, email, version FROM users WHERE deleted_at IS NULL AND id IN (?)`
+	if err := selectIn(ctx, sqlDB, &users, query, ids); err != nil {
+		return nil, err
+	}
+	if err := withAvatarURLs(ctx, sqlDB, users); err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		result[uint(user.ID)] = user
+	}
+	return result, nil
+}
+
+func contactsByUserIDs(ctx context.Context, sqlDB *sqlx.DB, userIDs []uint) (map[uint][]dtos.ContactListDTO, error) {
+	contacts := []dtos.ContactListDTO{}
+	query := `SELECT c.id, c.user_id, c.type_contact_id, c.ref_num, c.normalized_ref_num, c.is_primary, c.status, c.created_at, c.updated_at, c.version,
+	u.name as user_name,
+	ti.name as type_contact_name
+
+	FROM contacts c
+	JOIN users u ON c.user_id = u.id
+	JOIN mix_values ti ON c.type_contact_id = ti.id
+	WHERE c.deleted_at IS NULL AND c.user_id IN (?)
+	ORDER BY c.id`
+	if err := selectIn(ctx, sqlDB, &contacts, query, userIDs); err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint][]dtos.ContactListDTO)
+	for _, contact := range contacts {
+		result[contact.UserID] = append(result[contact.UserID], contact)
+	}
+	return result, nil
+}
+
+func addressesByUserIDs(ctx context.Context, sqlDB *sqlx.DB, userIDs []uint) (map[uint][]dtos.AddressListDTO, error) {
+	addresses := []dtos.AddressListDTO{}
+	query := `SELECT c.id, c.user_id, c.type_address_id, c.ref_num,
+	c.address_line1, c.address_line2, c.city, c.region, c.postal_code, c.country_code, c.latitude, c.longitude,
+	c.is_primary, c.status, c.created_at, c.updated_at, c.version,
+	u.name as user_name,
+	ti.name as type_address_name
+
+	FROM addresses c
+	JOIN users u ON c.user_id = u.id
+	JOIN mix_values ti ON c.type_address_id = ti.id
+	WHERE c.deleted_at IS NULL AND c.user_id IN (?)
+	ORDER BY c.id`
+	if err := selectIn(ctx, sqlDB, &addresses, query, userIDs); err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint][]dtos.AddressListDTO)
+	for _, address := range addresses {
+		result[address.UserID] = append(result[address.UserID], address)
+	}
+	return result, nil
+}
+
+func identifiersByUserIDs(ctx context.Context, sqlDB *sqlx.DB, userIDs []uint) (map[uint][]dtos.IdentifierListDTO, error) {
+	identifiers := []dtos.IdentifierListDTO{}
+	query := `SELECT i.id, i.user_id, i.type_identifier_id, i.ref_num, i.status, i.status_reason,
+	to_char(i.issued_at, 'YYYY-MM-DD') AS issued_at, to_char(i.expires_at, 'YYYY-MM-DD') AS expires_at,
+	i.created_at, i.updated_at, i.version,
+	u.name as user_name,
+	ti.name as type_identifier_name
+
+	FROM identifiers i
+	JOIN users u ON i.user_id = u.id
+	JOIN mix_values ti ON i.type_identifier_id = ti.id
+	WHERE i.deleted_at IS NULL AND i.user_id IN (?)
+	ORDER BY i.id`
+	if err := selectIn(ctx, sqlDB, &identifiers, query, userIDs); err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint][]dtos.IdentifierListDTO)
+	for _, identifier := range identifiers {
+		result[identifier.UserID] = append(result[identifier.UserID], identifier)
+	}
+	return result, nil
+}
+
+func rolesByUserIDs(ctx context.Context, sqlDB *sqlx.DB, userIDs []uint) (map[uint][]string, error) {
+	var rows []struct {
+		UserID uint   `db:"user_id"`
+		Name   string `db:"name"`
+	}
+	query := `
+            SELECT p.mv1_id AS user_id, mv.name
+            FROM pools p
+            JOIN mix_values mv ON p.mv2_id = mv.id
+            JOIN groups g1 ON p.group1_id = g1.id
+            JOIN groups g2 ON p.group2_id = g2.id
+            WHERE p.deleted_at IS NULL AND
+						g1.name = 'users' AND g2.name = 'roles' AND p.mv1_id IN (?)
+            ORDER BY p.id
+        `
+	if err := selectIn(ctx, sqlDB, &rows, query, userIDs); err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint][]string)
+	for _, row := range rows {
+		result[row.UserID] = append(result[row.UserID], row.Name)
+	}
+	return result, nil
+}
@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/config"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Weights of the signals scoring a pair of duplicate candidates, summing to 1:
+// the trigram similarity of their names, and whether they share a contact
+// (same type and normalized ref_num) or an identifier (same type and ref_num).
+const (
+	duplicateNameWeight       = 0.4
+	duplicateContactWeight    = 0.3
+	duplicateIdentifierWeight = 0.3
+)
+
+// defaultDuplicateMinScore is the lowest score index-duplicate-user lists
+// without min_score: close names alone, or any shared contact or identifier.
+const defaultDuplicateMinScore = 0.3
+
+// duplicateSortColumns maps the sort keys accepted by FindDuplicateUsers to
+// their columns.
+var duplicateSortColumns = map[string]string{
+	"score":              "score",
+	"name_similarity":    "name_similarity",
+	"shared_contacts":    "shared_contacts",
+	"shared_identifiers": "shared_identifiers",
+	"user_id":            "user_id",
+	"duplicate_id":       "duplicate_id",
+}
+
+// sharedRefNums counts the live rows of a child table of user p.user_id with
+// the same type and value (valueColumn) as one of p.duplicate_id.
+func sharedRefNums(table string, typeColumn string, valueColumn string) string {
+	return `(
+		SELECT COUNT(*) FROM ` + table + ` x
+		JOIN ` + table + ` y ON y.` + typeColumn + ` = x.` + typeColumn + ` AND y.` + valueColumn + ` = x.` + valueColumn + `
+		WHERE x.user_id = p.user_id AND y.user_id = p.duplicate_id
+		AND x.deleted_at IS NULL AND y.deleted_at IS NULL AND x.` + valueColumn + ` <> ''
+	)`
+}
+
+// refNumPairs selects the pairs of users sharing a live row of a child table.
+func refNumPairs(table string, typeColumn string, valueColumn string) string {
+	return `
+		SELECT LEAST(x.user_id, y.user_id), GREATEST(x.user_id, y.user_id)
+		FROM ` + table + ` x
+		JOIN ` + table + ` y ON y.` + typeColumn + ` = x.` + typeColumn + ` AND y.` + valueColumn + ` = x.` + valueColumn + ` AND y.user_id <> x.user_id
+		WHERE x.deleted_at IS NULL AND y.deleted_at IS NULL AND x.` + valueColumn + ` <> ''`
+}
+
+type UserMergeRepository struct {
+	*Transactor
+	db    *gorm.DB
+	sqlDB *sqlx.DB
+}
+
+func NewUserMergeRepository(db *gorm.DB, sqlDB *sqlx.DB) *UserMergeRepository {
+	return &UserMergeRepository{
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
+	}
+}
+
+// FindDuplicateUsers lists the pairs of live users that may be the same
+// person, best candidates first unless sorted otherwise. Pairs come from
+// similar names (pg_trgm, with FUZZY_MATCH_THRESHOLD), shared contact
+// ref_nums and identical identifiers, and are scored by the weights above;
+// min_score (0.3 by default) drops the weaker ones and user_id keeps the
+// pairs of one user.
+func (r *UserMergeRepository) FindDuplicateUsers(ctx context.Context, filters map[string]string) ([]dtos.DuplicateUserDTO, int, error) {
+	duplicates := []dtos.DuplicateUserDTO{}
+	var total int
+
+	minScore, ok, err := utils.ParseFloatFilter(filters, "min_score")
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok {
+		minScore = defaultDuplicateMinScore
+	}
+
+	from := `FROM (
+		SELECT s.*,
+		s.name_similarity * $1::FLOAT8
+		+ (s.shared_contacts > 0)::INT * $2::FLOAT8
+		+ (s.shared_identifiers > 0)::INT * $3::FLOAT8 AS score
+		FROM (
+			SELECT p.user_id, ua.name AS user_name, ua.email AS user_email,
+			p.duplicate_id, ub.name AS duplicate_name, ub.email AS duplicate_email,
+			similarity(COALESCE(ua.name, ''), COALESCE(ub.name, '')) AS name_similarity,
+			` + sharedRefNums("contacts", "type_contact_id", "normalized_ref_num") + ` AS shared_contacts,
+			` + sharedRefNums("identifiers", "type_identifier_id", "ref_num") + ` AS shared_identifiers
+			FROM (
+				SELECT a.id AS user_id, b.id AS duplicate_id
+				FROM users a
+				JOIN users b ON b.id > a.id AND b.name % a.name
+				UNION
+				` + refNumPairs("contacts", "type_contact_id", "normalized_ref_num") + `
+				UNION
+				` + refNumPairs("identifiers", "type_identifier_id", "ref_num") + `
+			) AS p
+			JOIN users ua ON ua.id = p.user_id AND ua.deleted_at IS NULL AND ua.erased_at IS NULL
+			JOIN users ub ON ub.id = p.duplicate_id AND ub.deleted_at IS NULL AND ub.erased_at IS NULL
+		) AS s
+	) AS alias WHERE score >= $4`
+	args := []interface{}{duplicateNameWeight, duplicateContactWeight, duplicateIdentifierWeight, minScore}
+	i := 5
+
+	userID, ok, err := utils.ParseIDFilter(filters, "user_id")
+	if err != nil {
+		return nil, 0, err
+	}
+	if ok {
+		from += fmt.Sprintf(" AND (user_id = $%d OR duplicate_id = $%d)", i, i)
+		args = append(args, userID)
+		i++
+	}
+
+	query := `SELECT * ` + from
+	countQuery := `SELECT COUNT(*) ` + from
+	countArgs := append([]interface{}{}, args...)
+
+	if filters["sort"] == "" && filters["order_column"] == "" {
+		filters["sort"] = "-score"
+	}
+	sort, err := utils.ParseSort(filters, duplicateSortColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+	query += sort.OrderBy("user_id") + ", duplicate_id ASC"
+
+	perPage := utils.GetIntOrDefault(filters["per_page"], 10)
+	currentPage := utils.GetIntOrDefault(filters["page"], 1)
+
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", i, i+1)
+	args = append(args, perPage, (currentPage-1)*perPage)
+
+	// % matches names with the configured similarity
+	err = withSimilarityThreshold(ctx, r.sqlDB, config.GetFuzzyMatchThreshold(), func(ctx context.Context) error {
+		if err := getContext(ctx, r.sqlDB, &total, countQuery, countArgs...); err != nil {
+			return err
+		}
+		return selectContext(ctx, r.sqlDB, &duplicates, query, args...)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return duplicates, total, nil
+}
+
+// MergeUsers moves everything of user sourceID to user targetID: its
+// contacts, addresses and identifiers, deleted or not, and the roles the
+// target does not have yet. The source is then soft deleted, its remaining
+// roles with it, and redirected to the target, as are the users merged into
+// the source before. Both users have to be live and not erased; the versions,
+// when given, are the ones they are expected at.
+//
+// It has to run in a unit of work.
+func (r *UserMergeRepository) MergeUsers(ctx context.Context, sourceID uint, targetID uint, sourceVersion *uint, targetVersion *uint) (*dtos.UserMergeDTO, error) {
+	if unitOfWorkFrom(ctx) == nil {
+		return nil, fmt.Errorf("merging user %d into %d: no unit of work", sourceID, targetID)
+	}
+
+	// locked in ID order, two merges of the same users do not deadlock
+	var users []struct {
+		ID      uint `db:"id"`
+		Version uint `db:"version"`
+	}
+	if err := selectContext(ctx, r.sqlDB, &users, `
+		SELECT id, version FROM users
+		WHERE id IN ($1, $2) AND deleted_at IS NULL AND erased_at IS NULL
+		ORDER BY id
+		FOR UPDATE
+	`, sourceID, targetID); err != nil {
+		return nil, err
+	}
+	if len(users) != 2 {
+		return nil, utils.ErrMergeUsers
+	}
+	for _, user := range users {
+		expected := sourceVersion
+		if user.ID == targetID {
+			expected = targetVersion
+		}
+		if expected != nil && *expected != user.Version {
+			return nil, utils.ErrVersionConflict
+		}
+	}
+
+	merge := dtos.UserMergeDTO{SourceID: sourceID, TargetID: targetID}
+	db := gormFrom(ctx, r.db)
+	for _, table := range userChildTables {
+		// the primaries of the target stay theirs
+		set, args := "", []interface{}{targetID, sourceID}
+		if _, ok := primaryTypeColumns[table]; ok {
+			set, args = ", is_primary = "+keptPrimary(table), []interface{}{targetID, targetID, sourceID}
+		}
+		result := db.Exec(`
+			UPDATE `+table+` AS c SET user_id = ?, version = c.version + 1`+set+` WHERE c.user_id = ?
+		`, args...)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+
+		switch table {
+		case "contacts":
+			merge.Contacts = int(result.RowsAffected)
+		case "addresses":
+			merge.Addresses = int(result.RowsAffected)
+		case "identifiers":
+			merge.Identifiers = int(result.RowsAffected)
+		}
+	}
+
+	result := db.Exec(`
+		UPDATE pools SET mv1_id = ?, version = version + 1
+		WHERE group1_id = ? AND group2_id = ? AND mv1_id = ? AND deleted_at IS NULL
+		AND mv2_id NOT IN (
+			SELECT mv2_id FROM pools
+			WHERE group1_id = ? AND group2_id = ? AND mv1_id = ? AND deleted_at IS NULL
+		)
+	`, targetID, utils.GroupIDUsers, utils.GroupIDRoles, sourceID, utils.GroupIDUsers, utils.GroupIDRoles, targetID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	merge.Roles = int(result.RowsAffected)
+
+	if err := db.Exec(`
+		UPDATE pools SET deleted_at = NOW(), version = version + 1
+		WHERE group1_id = ? AND group2_id = ? AND mv1_id = ? AND deleted_at IS NULL
+	`, utils.GroupIDUsers, utils.GroupIDRoles, sourceID).Error; err != nil {
+		return nil, err
+	}
+
+	// the attachments of the moved rows follow them, the ones of the user are
+	// moved unless the target has the file already
+	if err := db.Exec(`
+		UPDATE attachments SET entity_id = ?
+		WHERE entity = 'users' AND entity_id = ?
+		AND file_id NOT IN (SELECT file_id FROM attachments WHERE entity = 'users' AND entity_id = ?)
+	`, targetID, sourceID, targetID).Error; err != nil {
+		return nil, err
+	}
+
+	// the target keeps its avatar, or takes the one of the source
+	if err := db.Exec(`
+		UPDATE user_avatars SET user_id = ?
+		WHERE user_id = ? AND NOT EXISTS (SELECT 1 FROM user_avatars WHERE user_id = ?)
+	`, targetID, sourceID, targetID).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Exec(`
+		UPDATE users SET deleted_at = NOW(), version = version + 1 WHERE id = ?
+	`, sourceID).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Exec(`
+		UPDATE user_merges SET target_id = ? WHERE target_id = ?
+	`, targetID, sourceID).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Exec(`
+		INSERT INTO user_merges (source_id, target_id, merged_by_id) VALUES (?, ?, current_actor_id())
+		ON CONFLICT (source_id) DO UPDATE SET
+			target_id = EXCLUDED.target_id, merged_by_id = EXCLUDED.merged_by_id, created_at = NOW()
+	`, sourceID, targetID).Error; err != nil {
+		return nil, err
+	}
+
+	return &merge, nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+// primaryTypeColumns maps the child tables having a primary row per user and
+// type, among the ones not in the trash, to their type column.
+var primaryTypeColumns = map[string]string{
+	"contacts":  "type_contact_id",
+	"addresses": "type_address_id",
+}
+
+// primaryGroup is the user and type a row of a child table may be the primary
+// of.
+type primaryGroup struct {
+	UserID uint
+	TypeID uint
+}
+
+// writePrimary returns whether row id of table (0 for a new one) is the
+// primary of the user and type of group once written with them: it stays the
+// primary it is, and becomes the primary of a user and type having none. When
+// it stops being the primary of its former user and type, those are returned
+// to promotePrimary once the row is written.
+func writePrimary(ctx context.Context, db *gorm.DB, table string, id uint, group primaryGroup) (bool, *primaryGroup, error) {
+	typeColumn := primaryTypeColumns[table]
+
+	var former *primaryGroup
+	if id != 0 {
+		var row struct {
+			UserID    uint `gorm:"column:user_id"`
+			TypeID    uint `gorm:"column:type_id"`
+			IsPrimary bool `gorm:"column:is_primary"`
+		}
+		if err := gormFrom(ctx, db).Raw(`
+			SELECT user_id, `+typeColumn+` AS type_id, is_primary FROM `+table+` WHERE id = ?
+		`, id).Scan(&row).Error; err != nil {
+			return false, nil, err
+		}
+		if row.UserID == group.UserID && row.TypeID == group.TypeID {
+			return row.IsPrimary, nil, nil
+		}
+		if row.IsPrimary {
+			former = &primaryGroup{UserID: row.UserID, TypeID: row.TypeID}
+		}
+	}
+
+	var taken bool
+	if err := gormFrom(ctx, db).Raw(`
+		SELECT EXISTS (
+			SELECT 1 FROM `+table+`
+			WHERE user_id = ? AND `+typeColumn+` = ? AND is_primary AND deleted_at IS NULL AND id <> ?
+		)
+	`, group.UserID, group.TypeID, id).Scan(&taken).Error; err != nil {
+		return false, nil, err
+	}
+	return !taken, former, nil
+}
+
+// promotePrimary makes the oldest live row of the user and type of group
+// their primary when they have none, as after their primary was deleted or
+// moved away.
+func promotePrimary(ctx context.Context, db *gorm.DB, table string, group primaryGroup) error {
+	typeColumn := primaryTypeColumns[table]
+	return gormFrom(ctx, db).Exec(`
+		UPDATE `+table+` SET is_primary = TRUE, version = version + 1
+		WHERE id = (
+			SELECT id FROM `+table+`
+			WHERE user_id = ? AND `+typeColumn+` = ? AND deleted_at IS NULL
+			ORDER BY id LIMIT 1
+		) AND NOT EXISTS (
+			SELECT 1 FROM `+table+`
+			WHERE user_id = ? AND `+typeColumn+` = ? AND deleted_at IS NULL AND is_primary
+		)
+	`, group.UserID, group.TypeID, group.UserID, group.TypeID).Error
+}
+
+// deletePrimary soft deletes row id of table, expected at version, and
+// promotes another row to the primary of its user and type when it was theirs.
+func deletePrimary(ctx context.Context, db *gorm.DB, table string, id uint, version uint) error {
+	var deleted []struct {
+		UserID    uint `gorm:"column:user_id"`
+		TypeID    uint `gorm:"column:type_id"`
+		IsPrimary bool `gorm:"column:is_primary"`
+	}
+	// the row as it was: the FROM copy is read before the update
+	if err := gormFrom(ctx, db).Raw(`
+		UPDATE `+table+` AS t SET deleted_at = NOW(), is_primary = FALSE, version = t.version + 1
+		FROM `+table+` AS r
+		WHERE t.id = ? AND t.version = ? AND t.deleted_at IS NULL AND r.id = t.id
+		RETURNING r.user_id, r.`+primaryTypeColumns[table]+` AS type_id, r.is_primary
+	`, id, version).Scan(&deleted).Error; err != nil {
+		return err
+	}
+	if len(deleted) == 0 {
+		return utils.ErrVersionConflict
+	}
+
+	if !deleted[0].IsPrimary {
+		return nil
+	}
+	return promotePrimary(ctx, db, table, primaryGroup{UserID: deleted[0].UserID, TypeID: deleted[0].TypeID})
+}
+
+// restorePrimary restores row id of table, expected at version. It becomes
+// the primary of its user and type when they have none.
+func restorePrimary(ctx context.Context, db *gorm.DB, table string, id uint, version uint) error {
+	typeColumn := primaryTypeColumns[table]
+	return checkVersion(gormFrom(ctx, db).Exec(`
+		UPDATE `+table+` AS t SET deleted_at = NULL, version = t.version + 1,
+		is_primary = NOT EXISTS (
+			SELECT 1 FROM `+table+` AS p
+			WHERE p.user_id = t.user_id AND p.`+typeColumn+` = t.`+typeColumn+` AND p.is_primary AND p.deleted_at IS NULL
+		)
+		WHERE t.id = ? AND t.version = ? AND t.deleted_at IS NOT NULL
+	`, id, version))
+}
+
+// setPrimary makes row id of table, expected at version, the primary of its
+// user and type in place of the current one. It has to run in a transaction.
+func setPrimary(ctx context.Context, db *gorm.DB, table string, id uint, version uint) error {
+	typeColumn := primaryTypeColumns[table]
+	if err := gormFrom(ctx, db).Exec(`
+		UPDATE `+table+` AS t SET is_primary = FALSE, version = t.version + 1
+		FROM `+table+` AS r
+		WHERE r.id = ? AND r.version = ? AND r.deleted_at IS NULL
+		AND t.user_id = r.user_id AND t.`+typeColumn+` = r.`+typeColumn+`
+		AND t.id <> r.id AND t.is_primary AND t.deleted_at IS NULL
+	`, id, version).Error; err != nil {
+		return err
+	}
+
+	return checkVersion(gormFrom(ctx, db).Exec(`
+		UPDATE `+table+` SET is_primary = TRUE, version = version + 1
+		WHERE id = ? AND version = ? AND deleted_at IS NULL
+	`, id, version))
+}
+
+// keptPrimary is the is_primary of row c of table once it belongs to the user
+// given as its parameter, as restored along with it or merged into it: it
+// stays the primary it was unless the user has a live one of its type already.
+func keptPrimary(table string) string {
+	typeColumn := primaryTypeColumns[table]
+	return `c.is_primary AND NOT EXISTS (
+		SELECT 1 FROM ` + table + ` AS p
+		WHERE p.user_id = ? AND p.` + typeColumn + ` = c.` + typeColumn + ` AND p.is_primary AND p.deleted_at IS NULL
+	)`
+}
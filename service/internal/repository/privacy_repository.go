@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+// personalDataColumns are the columns holding personal data, per table of a
+// user and its children. Erasing a user anonymises them in place and redacts
+// them from the history and the audit log.
+var personalDataColumns = map[string][]string{
+	"users":       {"name", "username", "email", "address"},
+	"contacts":    {"ref_num", "normalized_ref_num", "options_json"},
+	"addresses":   {"ref_num", "options_json", "address_line1", "address_line2", "city", "region", "postal_code"},
+	"identifiers": {"ref_num", "options_json", "status_reason"},
+}
+
+// personalDataNumbers are the personal data columns that are not text. They
+// are dropped from the history and the audit log rather than redacted, as
+// "[erased]" would not read back into them.
+var personalDataNumbers = map[string][]string{
+	"addresses": {"latitude", "longitude"},
+}
+
+// erasedUserName is the name an erased user is left with.
+const erasedUserName = "Erased user"
+
+// erasedRefNum is the ref_num the contacts, addresses and identifiers of an
+// erased user are left with, as the history redacts it. It is read as text
+// everywhere, so it cannot be NULL.
+const erasedRefNum = "[erased]"
+
+// ErasedColumns returns what erasing a user sets the personal data columns of
+// its rows of table, one of the children tables, to; nil is NULL.
+func ErasedColumns(table string) map[string]interface{} {
+	columns := make(map[string]interface{})
+	for _, column := range append(personalDataColumns[table], personalDataNumbers[table]...) {
+		columns[column] = nil
+	}
+	if _, ok := columns["ref_num"]; ok {
+		columns["ref_num"] = erasedRefNum
+	}
+	return columns
+}
+
+// subjectAuditLogs selects the audit records about user $1 and its children,
+// purged ones included, or made by the user.
+const subjectAuditLogs = `
+	a.actor_id = $1
+	OR (a.entity = 'users' AND a.entity_id = $1)
+	OR (a.entity = 'contacts' AND a.entity_id IN (SELECT id FROM contacts WHERE user_id = $1))
+	OR (a.entity = 'addresses' AND a.entity_id IN (SELECT id FROM addresses WHERE user_id = $1))
+	OR (a.entity = 'identifiers' AND a.entity_id IN (SELECT id FROM identifiers WHERE user_id = $1))
+	OR (a.entity IN ('contacts', 'addresses', 'identifiers') AND a.action = 'purge' AND (a.before ->> 'user_id')::INT = $1)`
+
+type PrivacyRepository struct {
+	*Transactor
+	db    *gorm.DB
+	sqlDB *sqlx.DB
+}
+
+func NewPrivacyRepository(db *gorm.DB, sqlDB *sqlx.DB) *PrivacyRepository {
+	return &PrivacyRepository{
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
+	}
+}
+
+// GetPersonalData returns everything held about a user, deleted or not: its
+// row, roles, contacts, addresses, identifiers and their status transitions,
+// and audit records. Passwords and search vectors are left out.
+func (r *PrivacyRepository) GetPersonalData(ctx context.Context, userID uint) (*dtos.PersonalDataBundle, error) {
+	var bundle dtos.PersonalDataBundle
+	query := `
+		SELECT
+		(SELECT to_jsonb(u) - ARRAY['search_vector', 'password'] FROM users u WHERE u.id = $1) AS user,
+		(
+			SELECT COALESCE(jsonb_agg(jsonb_build_object('id', mv.id, 'name', mv.name, 'attached_at', p.created_at) ORDER BY mv.id), '[]')
+			FROM pools p
+			JOIN mix_values mv ON mv.id = p.mv2_id
+			WHERE p.group1_id = $2 AND p.group2_id = $3 AND p.mv1_id = $1 AND p.deleted_at IS NULL
+		) AS roles,
+		(SELECT COALESCE(jsonb_agg(to_jsonb(c) - 'search_vector' ORDER BY c.id), '[]') FROM contacts c WHERE c.user_id = $1) AS contacts,
+		(SELECT COALESCE(jsonb_agg(to_jsonb(ad) - 'search_vector' ORDER BY ad.id), '[]') FROM addresses ad WHERE ad.user_id = $1) AS addresses,
+		(SELECT COALESCE(jsonb_agg(to_jsonb(i) - 'search_vector' ORDER BY i.id), '[]') FROM identifiers i WHERE i.user_id = $1) AS identifiers,
+		(
+			SELECT COALESCE(jsonb_agg(to_jsonb(t) ORDER BY t.id), '[]')
+			FROM identifier_status_transitions t
+			WHERE t.identifier_id IN (SELECT id FROM identifiers WHERE user_id = $1)
+		) AS identifier_transitions,
+		(SELECT COALESCE(jsonb_agg(to_jsonb(a) ORDER BY a.id), '[]') FROM audit_logs a WHERE ` + subjectAuditLogs + `) AS audit_logs
+	`
+	if err := getContext(ctx, r.sqlDB, &bundle, query, userID, utils.GroupIDUsers, utils.GroupIDRoles); err != nil {
+		return nil, err
+	}
+	if len(bundle.User) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &bundle, nil
+}
+
+// EraseUser irreversibly anonymises the personal data of a user in place:
+// the user row and its children are kept, so references and counts stay
+// intact, but their personal data columns are overwritten, and redacted from
+// every version in the history tables and every audit record. The IP
+// addresses of the audit records of the user's own requests are dropped, as
+// are the responses kept for its Idempotency-Keys. version, when given, is
+// the version the user is expected at.
+//
+// It has to run in a unit of work: app.erasure lets it redact the otherwise
+// append-only audit log until the transaction ends.
+func (r *PrivacyRepository) EraseUser(ctx context.Context, userID uint, version *uint) (*dtos.UserErasureDTO, error) {
+	if unitOfWorkFrom(ctx) == nil {
+		return nil, fmt.Errorf("erasing user %d: no unit of work", userID)
+	}
+
+	var user struct {
+		Version  uint       `db:"version"`
+		ErasedAt *time.Time `db:"erased_at"`
+	}
+	if err := getContext(ctx, r.sqlDB, &user, `SELECT version, erased_at FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return nil, err
+	}
+	if user.ErasedAt != nil {
+		return nil, utils.ErrUserErased
+	}
+	if version != nil && *version != user.Version {
+		return nil, utils.ErrVersionConflict
+	}
+
+	db := gormFrom(ctx, r.db)
+	if err := db.Exec(`SELECT set_config('app.erasure', 'on', true)`).Error; err != nil {
+		return nil, err
+	}
+
+	erasure := dtos.UserErasureDTO{UserID: userID}
+	if err := getContext(ctx, r.sqlDB, &erasure.ErasedAt, `
+		UPDATE users SET name = $2, username = NULL, email = 'erased-' || id || '@erased.invalid',
+		address = NULL, password = '', erased_at = NOW(), version = version + 1
+		WHERE id = $1
+		RETURNING erased_at
+	`, userID, erasedUserName); err != nil {
+		return nil, err
+	}
+
+	for _, table := range userChildTables {
+		erased := ErasedColumns(table)
+		columns, args := "", []interface{}{}
+		for _, column := range append(personalDataColumns[table], personalDataNumbers[table]...) {
+			columns += column + " = ?, "
+			args = append(args, erased[column])
+		}
+		result := db.Exec(`
+			UPDATE `+table+` SET `+columns+`version = version + 1
+			WHERE user_id = ?
+		`, append(args, userID)...)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+
+		switch table {
+		case "contacts":
+			erasure.Contacts = int(result.RowsAffected)
+		case "addresses":
+			erasure.Addresses = int(result.RowsAffected)
+		case "identifiers":
+			erasure.Identifiers = int(result.RowsAffected)
+		}
+	}
+
+	// The updates above were recorded too, redacting comes after them
+	for _, table := range TrashTables {
+		ids := `SELECT id FROM ` + table + ` WHERE user_id = ?`
+		if table == "users" {
+			ids = `SELECT ?::INT`
+		}
+		columns := pq.Array(personalDataColumns[table])
+		numbers := pq.Array(append([]string{}, personalDataNumbers[table]...))
+
+		if err := db.Exec(`
+			UPDATE `+table+`_history SET data = redact_jsonb(data, ?::TEXT[]) - ?::TEXT[]
+			WHERE id IN (`+ids+`)
+		`, columns, numbers, userID).Error; err != nil {
+			return nil, err
+		}
+
+		// a purged child is only known by the user_id of its last record
+		result := db.Exec(`
+			UPDATE audit_logs SET before = redact_jsonb(before, ?::TEXT[]) - ?::TEXT[], after = redact_jsonb(after, ?::TEXT[]) - ?::TEXT[]
+			WHERE entity = ? AND (entity_id IN (`+ids+`) OR (action = ? AND (before ->> 'user_id')::INT = ?))
+		`, columns, numbers, columns, numbers, table, userID, utils.AuditActionPurge, userID)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		erasure.AuditLogs += int(result.RowsAffected)
+	}
+
+	// the reasons of the reviewers may quote the identifiers
+	if err := db.Exec(`
+		UPDATE identifier_status_transitions SET reason = NULL
+		WHERE identifier_id IN (SELECT id FROM identifiers WHERE user_id = ?)
+	`, userID).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Exec(`
+		UPDATE audit_logs SET ip_address = NULL WHERE actor_id = ? AND ip_address IS NOT NULL
+	`, userID).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Exec(`DELETE FROM idempotency_keys WHERE user_id = ?`, userID).Error; err != nil {
+		return nil, err
+	}
+
+	// the scans of their documents are personal data
+	attachments, err := deleteUserAttachments(db, []uint{userID})
+	if err != nil {
+		return nil, err
+	}
+	erasure.Attachments = attachments
+
+	// as is their picture
+	if err := db.Exec(`DELETE FROM user_avatars WHERE user_id = ?`, userID).Error; err != nil {
+		return nil, err
+	}
+
+	return &erasure, nil
+}
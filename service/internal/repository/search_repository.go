@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"gorm.io/gorm"
+)
+
+// searchHits matches every searchable entity against the tsquery in $1. Soft
+// deleted records, and children of soft deleted users, are never returned.
+// Identifiers of other users are only matched when $2 (read_identifiers) is
+// true, $3 being the authenticated user.
+const searchHits = `WITH q AS (SELECT to_tsquery('simple', $1) AS query),
+hits AS (
+	SELECT 'users' AS type, u.id, u.id AS user_id, u.name AS title,
+	concat_ws(' ', u.name, u.username, u.email) AS document,
+	ts_rank(u.search_vector, q.query) AS rank
+	FROM users u, q
+	WHERE u.deleted_at IS NULL AND u.search_vector @@ q.query
+
+	UNION ALL
+
+	SELECT 'contacts', c.id, c.user_id, u.name,
+	concat_ws(' ', c.ref_num, NULLIF(c.normalized_ref_num, c.ref_num), t.name),
+	ts_rank(c.search_vector, q.query)
+	FROM contacts c
+	JOIN users u ON c.user_id = u.id AND u.deleted_at IS NULL
+	JOIN mix_values t ON c.type_contact_id = t.id, q
+	WHERE c.deleted_at IS NULL AND c.search_vector @@ q.query
+
+	UNION ALL
+
+	SELECT 'addresses', a.id, a.user_id, u.name,
+	concat_ws(' ', a.ref_num, t.name),
+	ts_rank(a.search_vector, q.query)
+	FROM addresses a
+	JOIN users u ON a.user_id = u.id AND u.deleted_at IS NULL
+	JOIN mix_values t ON a.type_address_id = t.id, q
+	WHERE a.deleted_at IS NULL AND a.search_vector @@ q.query
+
+	UNION ALL
+
+	SELECT 'identifiers', i.id, i.user_id, u.name,
+	concat_ws(' ', i.ref_num, t.name),
+	ts_rank(i.search_vector, q.query)
+	FROM identifiers i
+	JOIN users u ON i.user_id = u.id AND u.deleted_at IS NULL
+	JOIN mix_values t ON i.type_identifier_id = t.id, q
+	WHERE i.deleted_at IS NULL AND i.search_vector @@ q.query
+	AND ($2::boolean OR i.user_id = $3)
+)`
+
+type SearchRepository struct {
+	db    *gorm.DB
+	sqlDB *sqlx.DB
+}
+
+func NewSearchRepository(db *gorm.DB, sqlDB *sqlx.DB) *SearchRepository {
+	return &SearchRepository{
+		db:    db,
+		sqlDB: sqlDB,
+	}
+}
+
+// Search returns a page of ranked hits with highlighted snippets, along with
+// the number of hits per type. Facets ignore params.Types so clients can show
+// the counts of the types they filtered out.
+func (r *SearchRepository) Search(ctx context.Context, params *dtos.SearchParams) ([]dtos.SearchHitDTO, map[string]int, error) {
+	hits := []dtos.SearchHitDTO{}
+	facets := make(map[string]int)
+
+	args := []interface{}{params.Query, params.ReadIdentifiers, params.UserID}
+	facetArgs := append([]interface{}{}, args...)
+
+	facetQuery := searchHits + ` SELECT type, COUNT(*) AS total FROM hits GROUP BY type`
+
+	query := searchHits + ` SELECT hits.type, hits.id, hits.user_id, hits.title,
+	ts_headline('simple', hits.document, q.query, 'StartSel=<mark>, StopSel=</mark>, MaxWords=20, MinWords=5') AS snippet,
+	hits.rank
+	FROM hits, q WHERE 1=1`
+
+	i := len(args) + 1
+	if len(params.Types) > 0 {
+		placeholders := make([]string, len(params.Types))
+		for n, t := range params.Types {
+			placeholders[n] = fmt.Sprintf("$%d", i)
+			args = append(args, t)
+			i++
+		}
+		query += " AND hits.type IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	query += " ORDER BY hits.rank DESC, hits.type, hits.id"
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", i, i+1)
+	args = append(args, params.PerPage, (params.Page-1)*params.PerPage)
+
+	// Channels for concurrent execution
+	facetChan := make(chan error)
+	selectChan := make(chan error)
+
+	// Goroutine for facet query
+	go func() {
+		var rows []struct {
+			Type  string `db:"type"`
+			Total int    `db:"total"`
+		}
+		err := selectContext(ctx, r.sqlDB, &rows, facetQuery, facetArgs...)
+		for _, row := range rows {
+			facets[row.Type] = row.Total
+		}
+		facetChan <- err
+	}()
+
+	// Goroutine for select query
+	go func() {
+		err := selectContext(ctx, r.sqlDB, &hits, query, args...)
+		selectChan <- err
+	}()
+
+	// Wait for both goroutines to finish
+	facetErr := <-facetChan
+	selectErr := <-selectChan
+
+	if facetErr != nil {
+		return nil, nil, facetErr
+	}
+
+	if selectErr != nil {
+		return nil, nil, selectErr
+	}
+
+	return hits, facets, nil
+}
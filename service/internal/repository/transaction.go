@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+// maxTransactionAttempts bounds how often WithTransaction runs fn when the
+// transaction keeps failing with a serialization error.
+const maxTransactionAttempts = 3
+
+type unitOfWorkKey struct{}
+
+// unitOfWork is the database transaction carried in a context. GORM and sqlx
+// share the same *sql.Tx, so every repository call made with the context sees
+// (and rolls back with) the same writes. A transaction is a single connection:
+// the sqlx helpers below take mu so the concurrent list queries of a
+// repository do not interleave on it.
+type unitOfWork struct {
+	gormTx     *gorm.DB
+	sqlxTx     *sqlx.Tx
+	mu         sync.Mutex
+	savepoints int
+}
+
+func unitOfWorkFrom(ctx context.Context) *unitOfWork {
+	uow, _ := ctx.Value(unitOfWorkKey{}).(*unitOfWork)
+	return uow
+}
+
+// Transactor runs functions in a unit of work. Repositories pick the unit of
+// work up from the context, outside of one they use their own connections.
+type Transactor struct {
+	db    *gorm.DB
+	sqlDB *sqlx.DB
+}
+
+func NewTransactor(db *gorm.DB, sqlDB *sqlx.DB) *Transactor {
+	return &Transactor{
+		db:    db,
+		sqlDB: sqlDB,
+	}
+}
+
+// WithTransaction runs fn in a transaction, committed when fn returns nil and
+// rolled back otherwise. Called inside another unit of work it runs fn in a
+// savepoint of the outer transaction instead. The outermost call retries fn
+// when the transaction fails with a serialization error or a deadlock, so fn
+// must not have side effects outside the database.
+func (t *Transactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if uow := unitOfWorkFrom(ctx); uow != nil {
+		return uow.withSavepoint(ctx, fn)
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxTransactionAttempts; attempt++ {
+		if err = t.run(ctx, fn); err == nil || !isSerializationFailure(err) {
+			return err
+		}
+
+		select {
+		case <-time.After(time.Duration(attempt*attempt) * 10 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return err
+}
+
+func (t *Transactor) run(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	tx := t.db.WithContext(ctx).Begin()
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	sqlTx, ok := tx.Statement.ConnPool.(*sql.Tx)
+	if !ok {
+		tx.Rollback()
+		return errors.New("transaction does not expose a *sql.Tx")
+	}
+
+	uow := &unitOfWork{
+		gormTx: tx,
+		sqlxTx: &sqlx.Tx{Tx: sqlTx, Mapper: t.sqlDB.Mapper},
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := setActor(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := fn(context.WithValue(ctx, unitOfWorkKey{}, uow)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// setActor exposes the actor of ctx to the attribution and audit triggers as
+// transaction local settings.
+func setActor(ctx context.Context, tx *gorm.DB) error {
+	actor, ok := utils.ActorFrom(ctx)
+	if !ok {
+		return nil
+	}
+
+	actorID := ""
+	if actor.UserID != nil {
+		actorID = fmt.Sprint(*actor.UserID)
+	}
+
+	return tx.Exec(`
+		SELECT set_config('app.actor_id', ?, true),
+		set_config('app.ip_address', ?, true),
+		set_config('app.request_id', ?, true)
+	`, actorID, actor.IPAddress, actor.RequestID).Error
+}
+
+func (uow *unitOfWork) withSavepoint(ctx context.Context, fn func(ctx context.Context) error) error {
+	uow.mu.Lock()
+	uow.savepoints++
+	name := fmt.Sprintf("uow_savepoint_%d", uow.savepoints)
+	err := uow.gormTx.SavePoint(name).Error
+	uow.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(ctx); err != nil {
+		uow.mu.Lock()
+		defer uow.mu.Unlock()
+		if rollbackErr := uow.gormTx.RollbackTo(name).Error; rollbackErr != nil {
+			return rollbackErr
+		}
+		return err
+	}
+
+	uow.mu.Lock()
+	defer uow.mu.Unlock()
+	return uow.gormTx.Exec("RELEASE SAVEPOINT " + name).Error
+}
+
+// isSerializationFailure reports whether err is a serialization failure or a
+// deadlock, the errors after which the whole transaction can be retried.
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+
+	return false
+}
+
+// gormFrom returns the transaction of the unit of work in ctx, or db.
+func gormFrom(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if uow := unitOfWorkFrom(ctx); uow != nil && uow.gormTx != nil {
+		return uow.gormTx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// getContext is sqlDB.GetContext, run in the unit of work of ctx if any.
+func getContext(ctx context.Context, sqlDB *sqlx.DB, dest interface{}, query string, args ...interface{}) error {
+	if uow := unitOfWorkFrom(ctx); uow != nil {
+		uow.mu.Lock()
+		defer uow.mu.Unlock()
+		return uow.sqlxTx.GetContext(ctx, dest, query, args...)
+	}
+	return sqlDB.GetContext(ctx, dest, query, args...)
+}
+
+// selectContext is sqlDB.SelectContext, run in the unit of work of ctx if any.
+func selectContext(ctx context.Context, sqlDB *sqlx.DB, dest interface{}, query string, args ...interface{}) error {
+	if uow := unitOfWorkFrom(ctx); uow != nil {
+		uow.mu.Lock()
+		defer uow.mu.Unlock()
+		return uow.sqlxTx.SelectContext(ctx, dest, query, args...)
+	}
+	return sqlDB.SelectContext(ctx, dest, query, args...)
+}
+
+// execContext is sqlDB.ExecContext, run in the unit of work of ctx if any.
+func execContext(ctx context.Context, sqlDB *sqlx.DB, query string, args ...interface{}) error {
+	if uow := unitOfWorkFrom(ctx); uow != nil {
+		uow.mu.Lock()
+		defer uow.mu.Unlock()
+		_, err := uow.sqlxTx.ExecContext(ctx, query, args...)
+		return err
+	}
+	_, err := sqlDB.ExecContext(ctx, query, args...)
+	return err
+}
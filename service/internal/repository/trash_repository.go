@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+// userChildTables are the tables whose rows belong to a user and follow it
+// to the trash and back.
+var userChildTables = []string{"contacts", "addresses", "identifiers"}
+
+// TrashTables are the tables purged by PurgeBatch, children before the users
+// they reference.
+var TrashTables = append(append([]string{}, userChildTables...), "users")
+
+// trashFields extends the list fields of a resource with deleted_at for its
+// index-trash-* endpoint.
+func trashFields(listFields map[string]utils.Field) map[string]utils.Field {
+	fields := map[string]utils.Field{"deleted_at": {Column: "deleted_at"}}
+	for key, field := range listFields {
+		fields[key] = field
+	}
+	return fields
+}
+
+// trashSortColumns extends the sort columns of a resource with deleted_at.
+func trashSortColumns(sortColumns map[string]string) map[string]string {
+	columns := map[string]string{"deleted_at": "deleted_at"}
+	for key, column := range sortColumns {
+		columns[key] = column
+	}
+	return columns
+}
+
+type TrashRepository struct {
+	*Transactor
+	db    *gorm.DB
+	sqlDB *sqlx.DB
+}
+
+func NewTrashRepository(db *gorm.DB, sqlDB *sqlx.DB) *TrashRepository {
+	return &TrashRepository{
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
+	}
+}
+
+// PurgeBatch hard deletes up to limit rows of table soft deleted before
+// cutoff and returns their IDs. table must be one of TrashTables. Purging
+// users takes their remaining children and role pools with them and clears
+// created_by_id / updated_by_id wherever they point to them.
+func (r *TrashRepository) PurgeBatch(ctx context.Context, table string, cutoff time.Time, limit int) ([]uint, error) {
+	ids := []uint{}
+	if err := selectContext(ctx, r.sqlDB, &ids, `
+		SELECT id FROM `+table+`
+		WHERE deleted_at < $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, cutoff, limit); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	db := gormFrom(ctx, r.db)
+	if table == "users" {
+		if _, err := deleteUserAttachments(db, ids); err != nil {
+			return nil, err
+		}
+
+		for _, child := range userChildTables {
+			if err := db.Exec(`DELETE FROM `+child+` WHERE user_id IN ?`, ids).Error; err != nil {
+				return nil, err
+			}
+		}
+
+		if err := db.Exec(`
+			DELETE FROM pools WHERE group1_id = ? AND mv1_id IN ?
+		`, utils.GroupIDUsers, ids).Error; err != nil {
+			return nil, err
+		}
+
+		for _, owned := range []string{"groups", "pools", "users", "contacts", "addresses", "identifiers"} {
+			for _, column := range []string{"created_by_id", "updated_by_id"} {
+				if err := db.Exec(`UPDATE `+owned+` SET `+column+` = NULL WHERE `+column+` IN ?`, ids).Error; err != nil {
+					return nil, err
+				}
+			}
+		}
+	} else if err := deleteAttachments(db, table, ids); err != nil {
+		return nil, err
+	}
+
+	if err := db.Exec(`DELETE FROM `+table+` WHERE id IN ?`, ids).Error; err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
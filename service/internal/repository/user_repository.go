@@ -2,9 +2,11 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/config"
 	"github.com/nibroos/nb-go-api/service/internal/dtos"
 	"github.com/nibroos/nb-go-api/service/internal/models"
 	"github.com/nibroos/nb-go-api/service/internal/utils"
@@ -13,84 +15,156 @@ import (
 
 type UserRepository interface {
 	GetUsers(ctx context.Context, filters map[string]string) ([]dtos.UserListDTO, int, error)
+	GetTrashedUsers(ctx context.Context, filters map[string]string) ([]dtos.UserListDTO, int, error)
 	GetUserByID(ctx context.Context, params *dtos.GetUserByIDParams) (*dtos.UserDetailDTO, error)
 	GetUserByEmail(ctx context.Context, email string) (*dtos.UserDetailDTO, error)
-	BeginTransaction() *gorm.DB
-	AttachRoles(tx *gorm.DB, user *models.User, roleIDs []uint32) error
-	CreateUser(tx *gorm.DB, user *models.User) error
-	UpdateUser(tx *gorm.DB, user *models.User) error
-	DeleteUser(tx *gorm.DB, id uint) error
-	DeleteRolesByUserID(tx *gorm.DB, userID uint) error
-	RestoreUser(tx *gorm.DB, id uint) error
-	Commit(tx *gorm.DB) error
+	GetUserIncludes(ctx context.Context, userIDs []uint, includes []string) (*dtos.UserIncludes, error)
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	AttachRoles(ctx context.Context, user *models.User, roleIDs []uint32) error
+	CreateUser(ctx context.Context, user *models.User) error
+	UpdateUser(ctx context.Context, user *models.User) error
+	DeleteUser(ctx context.Context, id uint, version uint) error
+	DeleteRolesByUserID(ctx context.Context, userID uint) error
+	RestoreUser(ctx context.Context, id uint, version uint) error
+	DeleteChildrenByUserID(ctx context.Context, userID uint) error
+	RestoreChildrenByUserID(ctx context.Context, userID uint) error
+	GetUserHistory(ctx context.Context, id uint, filters map[string]string) ([]dtos.HistoryDTO, int, error)
+	GetRoleIDsByUserID(ctx context.Context, userID uint) ([]uint32, error)
+	ExportUsers(filters map[string]string) (*ListQuery, error)
+	GetMergedUserID(ctx context.Context, id uint) (uint, bool, error)
 }
 
+// userSortColumns maps the sort keys accepted by GetUsers to their columns.
+var userSortColumns = map[string]string{
+	"id":         "id",
+	"username":   "username",
+	"name":       "name",
+	"email":      "email",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// UserListFields are the fields index-user can select with `fields`.
+var UserListFields = map[string]utils.Field{
+	"id":         {Column: "id"},
+	"username":   {Column: "username"},
+	"name":       {Column: "name"},
+	"email":      {Column: "email"},
+	"version":    {Column: "version"},
+	"score":      {}, // similarity, selected with match=fuzzy only
+	"avatar_url": {}, // loaded by its own query
+}
+
+// UserTrashFields are the fields index-trash-user can select with `fields`.
+var UserTrashFields = trashFields(UserListFields)
+
+// UserDetailFields are the fields show-user can select with `fields`. Roles,
+// permissions, primaries and the avatar are loaded by their own queries.
+var UserDetailFields = map[string]utils.Field{
+	"id":              {Column: "id"},
+	"username":        {Column: "username"},
+	"name":            {Column: "name"},
+	"email":           {Column: "email"},
+	"address":         {Column: "address", Permission: utils.PermissionReadUsers},
+	"created_at":      {Column: "created_at"},
+	"version":         {Column: "version"},
+	"created_by_id":   {Column: "created_by_id"},
+	"updated_by_id":   {Column: "updated_by_id"},
+	"roles":           {},
+	"permissions":     {Permission: utils.PermissionReadUsers},
+	"primary_email":   {},
+	"primary_phone":   {},
+	"primary_address": {Permission: utils.PermissionReadUsers},
+	"avatar_url":      {},
+}
+
+// primariesQuery selects the primary email, phone and address of user $1:
+// the live primary contacts of the types formatted as emails and phones, and
+// its live primary address, written out from its fields. Of several types,
+// the first created one wins.
+const primariesQuery = `
+	SELECT
+	(
+		SELECT COALESCE(c.normalized_ref_num, c.ref_num) FROM contacts c
+		JOIN mix_values t ON t.id = c.type_contact_id
+		WHERE c.user_id = $1 AND c.is_primary AND c.deleted_at IS NULL AND t.options_json ->> 'format' = 'email'
+		ORDER BY c.type_contact_id LIMIT 1
+	) AS primary_email,
+	(
+		SELECT COALESCE(c.normalized_ref_num, c.ref_num) FROM contacts c
+		JOIN mix_values t ON t.id = c.type_contact_id
+		WHERE c.user_id = $1 AND c.is_primary AND c.deleted_at IS NULL AND t.options_json ->> 'format' = 'phone'
+		ORDER BY c.type_contact_id LIMIT 1
+	) AS primary_phone,
+	(
+		SELECT COALESCE(NULLIF(concat_ws(', ', a.address_line1, a.address_line2, a.city, a.region, a.postal_code, a.country_code), ''), a.ref_num)
+		FROM addresses a
+		WHERE a.user_id = $1 AND a.is_primary AND a.deleted_at IS NULL
+		ORDER BY a.type_address_id LIMIT 1
+	) AS primary_address`
+
 type userRepository struct {
+	*Transactor
 	db    *gorm.DB
 	sqlDB *sqlx.DB
 }
 
 func NewUserRepository(db *gorm.DB, sqlDB *sqlx.DB) *userRepository {
 	return &userRepository{
-		db:    db,
-		sqlDB: sqlDB,
+		Transactor: NewTransactor(db, sqlDB),
+		db:         db,
+		sqlDB:      sqlDB,
 	}
 }
 
 func (r *userRepository) GetUsers(ctx context.Context, filters map[string]string) ([]dtos.UserListDTO, int, error) {
-	users := []dtos.UserListDTO{}
-	var total int
+	return r.listUsers(ctx, filters, false)
+}
 
-	query := `SELECT id, username, name, email FROM users WHERE 1=1`
-	countQuery := `SELECT COUNT(*) FROM users WHERE 1=1`
-	var args []interface{}
+// GetTrashedUsers lists the soft deleted users, most recently deleted first.
+func (r *userRepository) GetTrashedUsers(ctx context.Context, filters map[string]string) ([]dtos.UserListDTO, int, error) {
+	return r.listUsers(ctx, filters, true)
+}
 
-	i := 1
-	for key, value := range filters {
-		switch key {
-		case "username", "name", "email":
-			if value != "" {
-				query += fmt.Sprintf(" AND %s ILIKE $%d", key, i)
-				countQuery += fmt.Sprintf(" AND %s ILIKE $%d", key, i)
-				args = append(args, "%"+value+"%")
-				i++
-			}
-		}
-	}
+func (r *userRepository) listUsers(ctx context.Context, filters map[string]string, trashed bool) ([]dtos.UserListDTO, int, error) {
+	users := []dtos.UserListDTO{}
+	var total int
 
-	if value, ok := filters["global"]; ok && value != "" {
-		query += fmt.Sprintf(" AND (username ILIKE $%d OR name ILIKE $%d OR email ILIKE $%d)", i, i+1, i+2)
-		countQuery += fmt.Sprintf(" AND (username ILIKE $%d OR name ILIKE $%d OR email ILIKE $%d)", i, i+1, i+2)
-		args = append(args, "%"+value+"%", "%"+value+"%", "%"+value+"%")
-		i += 3
+	list, err := userListQuery(filters, trashed)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	orderColumn := utils.GetStringOrDefault(filters["order_column"], "id")
-	orderDirection := utils.GetStringOrDefault(filters["order_direction"], "asc")
-	query += fmt.Sprintf(" ORDER BY %s %s", orderColumn, orderDirection)
-
 	perPage := utils.GetIntOrDefault(filters["per_page"], 10)
 	currentPage := utils.GetIntOrDefault(filters["page"], 1)
 
-	countArgs := append([]interface{}{}, args...)
+	query := list.query + fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(list.args)+1, len(list.args)+2)
+	args := append(append([]interface{}{}, list.args...), perPage, (currentPage-1)*perPage)
 
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", i, i+1)
-
-	args = append(args, perPage, (currentPage-1)*perPage)
+	run := func(fn func(ctx context.Context) error) error {
+		return fn(ctx)
+	}
+	if list.threshold > 0 {
+		run = func(fn func(ctx context.Context) error) error {
+			return withSimilarityThreshold(ctx, r.sqlDB, list.threshold, fn)
+		}
+	}
 
 	countChan := make(chan error)
 	selectChan := make(chan error)
 
 	// Goroutine for count query
 	go func() {
-		err := r.sqlDB.GetContext(ctx, &total, countQuery, countArgs...)
-		countChan <- err
+		countChan <- run(func(ctx context.Context) error {
+			return getContext(ctx, r.sqlDB, &total, list.countQuery, list.args...)
+		})
 	}()
 
 	// Goroutine for select query
 	go func() {
-		err := r.sqlDB.SelectContext(ctx, &users, query, args...)
-		selectChan <- err
+		selectChan <- run(func(ctx context.Context) error {
+			return selectContext(ctx, r.sqlDB, &users, query, args...)
+		})
 	}()
 
 	// Wait for both goroutines to finish
@@ -105,9 +179,105 @@ func (r *userRepository) GetUsers(ctx context.Context, filters map[string]string
 		return nil, 0, selectErr
 	}
 
+	allowedFields := UserListFields
+	if trashed {
+		allowedFields = UserTrashFields
+	}
+	if fields, _ := utils.ParseFields(filters["fields"], allowedFields); utils.HasField(fields, "avatar_url") {
+		if err := withAvatarURLs(ctx, r.sqlDB, users); err != nil {
+			return nil, 0, err
+		}
+	}
+
 	return users, total, nil
 }
 
+// ExportUsers returns the query of index-user for filters, unpaged, for
+// StreamExport.
+func (r *userRepository) ExportUsers(filters map[string]string) (*ListQuery, error) {
+	return userListQuery(filters, false)
+}
+
+// userListQuery builds the query of index-user (index-trash-user when
+// trashed) for filters, without paging.
+func userListQuery(filters map[string]string, trashed bool) (*ListQuery, error) {
+	allowedFields, sortColumns, from := UserListFields, userSortColumns, ` FROM users WHERE deleted_at IS NULL`
+	if trashed {
+		allowedFields, sortColumns, from = UserTrashFields, trashSortColumns(userSortColumns), ` FROM users WHERE deleted_at IS NOT NULL`
+		if filters["sort"] == "" && filters["order_column"] == "" {
+			filters["sort"] = "-deleted_at"
+		}
+	}
+
+	fields, err := utils.ParseFields(filters["fields"], allowedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := utils.ParseMatch(filters, config.GetFuzzyMatchThreshold())
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + utils.SelectColumns(fields, allowedFields)
+	countQuery := `SELECT COUNT(*)` + from
+	var args []interface{}
+
+	i := 1
+	global := filters["global"]
+	if global != "" && match.Fuzzy {
+		// % uses the trigram indexes, its threshold is set by withSimilarityThreshold
+		query += fmt.Sprintf(`, GREATEST(similarity(COALESCE(username, ''), $%d), similarity(name, $%d), similarity(email, $%d)) AS score`, i, i, i)
+		query += from
+		query += fmt.Sprintf(" AND (username %% $%d OR name %% $%d OR email %% $%d)", i, i, i)
+		countQuery += fmt.Sprintf(" AND (username %% $%d OR name %% $%d OR email %% $%d)", i, i, i)
+		args = append(args, global)
+		i++
+
+		fuzzySortColumns := map[string]string{"score": "score"}
+		for key, column := range sortColumns {
+			fuzzySortColumns[key] = column
+		}
+		sortColumns = fuzzySortColumns
+		if filters["sort"] == "" && filters["order_column"] == "" {
+			filters["sort"] = "-score"
+		}
+	} else {
+		query += from
+	}
+
+	for key, value := range filters {
+		switch key {
+		case "username", "name", "email":
+			if value != "" {
+				query += fmt.Sprintf(" AND %s ILIKE $%d", key, i)
+				countQuery += fmt.Sprintf(" AND %s ILIKE $%d", key, i)
+				args = append(args, "%"+value+"%")
+				i++
+			}
+		}
+	}
+
+	if global != "" && !match.Fuzzy {
+		query += fmt.Sprintf(" AND (username ILIKE $%d OR name ILIKE $%d OR email ILIKE $%d)", i, i+1, i+2)
+		countQuery += fmt.Sprintf(" AND (username ILIKE $%d OR name ILIKE $%d OR email ILIKE $%d)", i, i+1, i+2)
+		args = append(args, "%"+global+"%", "%"+global+"%", "%"+global+"%")
+		i += 3
+	}
+
+	sort, err := utils.ParseSort(filters, sortColumns)
+	if err != nil {
+		return nil, err
+	}
+	query += sort.OrderBy("id")
+
+	list := &ListQuery{query: query, countQuery: countQuery, args: args}
+	if global != "" && match.Fuzzy {
+		list.threshold = match.Threshold
+	}
+	return list, nil
+}
+
 // func (r *userRepository) GetUsers(ctx context.Context, filters map[string]string) ([]dtos.UserListDTO, string, error) {
 // 	users := []dtos.UserListDTO{}
 
@@ -148,7 +318,7 @@ func (r *userRepository) GetUsers(ctx context.Context, filters map[string]string
 // 	query += fmt.Sprintf(" LIMIT $%d", i)
 // 	args = append(args, perPage)
 
-// 	err := r.sqlDB.SelectContext(ctx, &users, query, args...)
+// 	err := selectContext(ctx, r.sqlDB, &users, query, args...)
 // 	if err != nil {
 // 		return nil, "", err
 // 	}
@@ -164,10 +334,18 @@ func (r *userRepository) GetUsers(ctx context.Context, filters map[string]string
 func (r *userRepository) GetUserByID(ctx context.Context, params *dtos.GetUserByIDParams) (*dtos.UserDetailDTO, error) {
 	var user dtos.UserDetailDTO
 
-	query := `SELECT id, username, name, email, address, password FROM users WHERE id = $1`
+	// roles, permissions and primaries are always the current ones, they have
+	// no history
+	source, historyArgs := detailSource("users", params.AsOf, params.Version)
+
+	query := `SELECT id, username, name, email, address, password, version FROM ` + source + ` AS users WHERE id = $1`
+	if params.Fields != nil {
+		query = `SELECT ` + utils.SelectColumns(params.Fields, UserDetailFields) + ` FROM ` + source + ` AS users WHERE id = $1`
+	}
 
 	var args []interface{}
 	args = append(args, params.ID)
+	args = append(args, historyArgs...)
 
 	isDeletedQuery := ` AND deleted_at IS NULL`
 	if params.IsDeleted != nil && *params.IsDeleted == 1 {
@@ -180,15 +358,21 @@ func (r *userRepository) GetUserByID(ctx context.Context, params *dtos.GetUserBy
 	userChan := make(chan error)
 	roleChan := make(chan error)
 	permissionChan := make(chan error)
+	primaryChan := make(chan error)
 
 	// Goroutine for user query
 	go func() {
-		err := r.sqlDB.GetContext(ctx, &user, query, args...)
+		err := getContext(ctx, r.sqlDB, &user, query, args...)
 		userChan <- err
 	}()
 
 	// Goroutine for role query
 	go func() {
+		if !utils.HasField(params.Fields, "roles") {
+			roleChan <- nil
+			return
+		}
+
 		var roleNames []string
 		roleQuery := `
             SELECT mv.name 
@@ -201,7 +385,7 @@ func (r *userRepository) GetUserByID(ctx context.Context, params *dtos.GetUserBy
             AND p.deleted_at IS NULL
             AND p.mv1_id = $1
         `
-		err := r.sqlDB.SelectContext(ctx, &roleNames, roleQuery, params.ID)
+		err := selectContext(ctx, r.sqlDB, &roleNames, roleQuery, params.ID)
 		if err == nil {
 			user.Roles = roleNames
 		}
@@ -210,6 +394,11 @@ func (r *userRepository) GetUserByID(ctx context.Context, params *dtos.GetUserBy
 
 	// Goroutine for permission query
 	go func() {
+		if !utils.HasField(params.Fields, "permissions") {
+			permissionChan <- nil
+			return
+		}
+
 		var permissionNames []string
 		permissionQuery := `
             SELECT mv.name 
@@ -227,17 +416,38 @@ func (r *userRepository) GetUserByID(ctx context.Context, params *dtos.GetUserBy
                 WHERE g1.name = 'users' AND g2.name = 'roles' AND p.mv1_id = $1
             )
         `
-		err := r.sqlDB.SelectContext(ctx, &permissionNames, permissionQuery, params.ID)
+		err := selectContext(ctx, r.sqlDB, &permissionNames, permissionQuery, params.ID)
 		if err == nil {
 			user.Permissions = permissionNames
 		}
 		permissionChan <- err
 	}()
 
+	// Goroutine for primaries query
+	go func() {
+		if !utils.HasField(params.Fields, "primary_email") && !utils.HasField(params.Fields, "primary_phone") &&
+			!utils.HasField(params.Fields, "primary_address") {
+			primaryChan <- nil
+			return
+		}
+
+		var primaries struct {
+			Email   *string `db:"primary_email"`
+			Phone   *string `db:"primary_phone"`
+			Address *string `db:"primary_address"`
+		}
+		err := getContext(ctx, r.sqlDB, &primaries, primariesQuery, params.ID)
+		if err == nil {
+			user.PrimaryEmail, user.PrimaryPhone, user.PrimaryAddress = primaries.Email, primaries.Phone, primaries.Address
+		}
+		primaryChan <- err
+	}()
+
 	// Wait for all goroutines to finish
 	userErr := <-userChan
 	roleErr := <-roleChan
 	permissionErr := <-permissionChan
+	primaryErr := <-primaryChan
 
 	if userErr != nil {
 		return nil, userErr
@@ -251,13 +461,60 @@ func (r *userRepository) GetUserByID(ctx context.Context, params *dtos.GetUserBy
 		return nil, permissionErr
 	}
 
+	if primaryErr != nil {
+		return nil, primaryErr
+	}
+
+	if utils.HasField(params.Fields, "avatar_url") {
+		urls, err := avatarURLs(ctx, r.sqlDB, []uint{user.ID})
+		if err != nil {
+			return nil, err
+		}
+		if url, ok := urls[user.ID]; ok {
+			user.AvatarURL = &url
+		}
+	}
+
 	return &user, nil
 }
+
+// GetUserHistory lists the versions of a user.
+func (r *userRepository) GetUserHistory(ctx context.Context, id uint, filters map[string]string) ([]dtos.HistoryDTO, int, error) {
+	return listHistory(ctx, r.sqlDB, "users", id, filters)
+}
+
+// GetRoleIDsByUserID returns the IDs of the roles attached to a user.
+func (r *userRepository) GetRoleIDsByUserID(ctx context.Context, userID uint) ([]uint32, error) {
+	roleIDs := []uint32{}
+	err := selectContext(ctx, r.sqlDB, &roleIDs, `
+		SELECT mv2_id FROM pools
+		WHERE group1_id = $1 AND group2_id = $2 AND mv1_id = $3 AND deleted_at IS NULL
+		ORDER BY mv2_id
+	`, utils.GroupIDUsers, utils.GroupIDRoles, userID)
+	if err != nil {
+		return nil, err
+	}
+	return roleIDs, nil
+}
+
+// GetMergedUserID returns the user a user was merged into, ok is false when
+// it was not merged.
+func (r *userRepository) GetMergedUserID(ctx context.Context, id uint) (uint, bool, error) {
+	targetIDs := []uint{}
+	if err := selectContext(ctx, r.sqlDB, &targetIDs, `SELECT target_id FROM user_merges WHERE source_id = $1`, id); err != nil {
+		return 0, false, err
+	}
+	if len(targetIDs) == 0 {
+		return 0, false, nil
+	}
+	return targetIDs[0], true, nil
+}
+
 func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*dtos.UserDetailDTO, error) {
 	var user dtos.UserDetailDTO
 
 	query := `SELECT id, username, name, email, password, address FROM users WHERE deleted_at IS NULL AND (email = $1 OR username = $1)`
-	if err := r.sqlDB.GetContext(ctx, &user, query, email); err != nil {
+	if err := getContext(ctx, r.sqlDB, &user, query, email); err != nil {
 		return nil, err
 	}
 
@@ -279,7 +536,7 @@ func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*dto
             WHERE p.deleted_at IS NULL AND
 						g1.name = 'users' AND g2.name = 'roles' AND p.mv1_id = $1
         `
-		err := r.sqlDB.SelectContext(ctx, &roleNames, roleQuery, id)
+		err := selectContext(ctx, r.sqlDB, &roleNames, roleQuery, id)
 		if err == nil {
 			user.Roles = roleNames
 		}
@@ -305,7 +562,7 @@ func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*dto
                 WHERE g1.name = 'users' AND g2.name = 'roles' AND p.mv1_id = $1
             )
         `
-		err := r.sqlDB.SelectContext(ctx, &permissionNames, permissionQuery, id)
+		err := selectContext(ctx, r.sqlDB, &permissionNames, permissionQuery, id)
 		if err == nil {
 			user.Permissions = permissionNames
 		}
@@ -327,12 +584,48 @@ func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*dto
 	return &user, nil
 }
 
-// BeginTransaction starts a new transaction
-func (r *userRepository) BeginTransaction() *gorm.DB {
-	return r.db.Begin()
+// GetUserIncludes loads the requested relations of the given users, one
+// query per relation.
+func (r *userRepository) GetUserIncludes(ctx context.Context, userIDs []uint, includes []string) (*dtos.UserIncludes, error) {
+	result := &dtos.UserIncludes{}
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	errChan := make(chan error, len(includes))
+	for _, include := range includes {
+		go func(include string) {
+			var err error
+			switch include {
+			case "contacts":
+				result.Contacts, err = contactsByUserIDs(ctx, r.sqlDB, userIDs)
+			case "addresses":
+				result.Addresses, err = addressesByUserIDs(ctx, r.sqlDB, userIDs)
+			case "identifiers":
+				result.Identifiers, err = identifiersByUserIDs(ctx, r.sqlDB, userIDs)
+			case "roles":
+				result.Roles, err = rolesByUserIDs(ctx, r.sqlDB, userIDs)
+			}
+			errChan <- err
+		}(include)
+	}
+
+	// Wait for all goroutines to finish
+	var firstErr error
+	for range includes {
+		if err := <-errChan; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return result, nil
 }
 
-func (r *userRepository) AttachRoles(tx *gorm.DB, user *models.User, roleIDs []uint32) error {
+func (r *userRepository) AttachRoles(ctx context.Context, user *models.User, roleIDs []uint32) error {
 	// Prepare batch insert for new role_user relationships
 	var pools []models.Pool
 	for _, roleID := range roleIDs {
@@ -346,13 +639,13 @@ func (r *userRepository) AttachRoles(tx *gorm.DB, user *models.User, roleIDs []u
 	}
 
 	// delete existing roles
-	if err := r.DeleteRolesByUserID(tx, user.ID); err != nil {
+	if err := r.DeleteRolesByUserID(ctx, user.ID); err != nil {
 		return err
 	}
 
 	// Insert all role_user relationships in a single query
 	if len(pools) > 0 {
-		if err := tx.Create(&pools).Error; err != nil {
+		if err := gormFrom(ctx, r.db).Create(&pools).Error; err != nil {
 			return err
 		}
 	}
@@ -360,57 +653,120 @@ func (r *userRepository) AttachRoles(tx *gorm.DB, user *models.User, roleIDs []u
 	return nil
 }
 
-func (r *userRepository) CreateUser(tx *gorm.DB, user *models.User) error {
-	if err := tx.Create(user).Error; err != nil {
+func (r *userRepository) CreateUser(ctx context.Context, user *models.User) error {
+	if err := gormFrom(ctx, r.db).Create(user).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *userRepository) UpdateUser(tx *gorm.DB, user *models.User) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Save(user).Error; err != nil {
-			return err
-		}
-		return nil
-	})
+// UpdateUser saves user if its Version is still the stored one, and bumps it.
+func (r *userRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	expected := user.Version
+	user.Version = expected + 1
 
+	result := gormFrom(ctx, r.db).Select("*").Where("version = ?", expected).Updates(user)
+	if err := checkVersion(result); err != nil {
+		user.Version = expected
+		return err
+	}
+	return nil
 }
 
-func (r *userRepository) DeleteUser(tx *gorm.DB, id uint) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		// if err := tx.Unscoped().Delete(&models.User{}, id).Error; err != nil {
-		if err := tx.Delete(&models.User{}, id).Error; err != nil {
-			return err
-		}
-		return nil
-	})
+func (r *userRepository) DeleteUser(ctx context.Context, id uint, version uint) error {
+	return checkVersion(gormFrom(ctx, r.db).Exec(`
+		UPDATE users SET deleted_at = NOW(), version = version + 1
+		WHERE id = ? AND version = ? AND deleted_at IS NULL
+	`, id, version))
 }
 
 // DeleteRolesByUserID
-func (r *userRepository) DeleteRolesByUserID(tx *gorm.DB, userID uint) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Exec(`
-			UPDATE pools SET deleted_at = NOW() 
-			WHERE group1_id = ? AND mv1_id = ?
-			AND group2_id = ?
-		`, utils.GroupIDUsers, userID, utils.GroupIDRoles).Error; err != nil {
+func (r *userRepository) DeleteRolesByUserID(ctx context.Context, userID uint) error {
+	if err := gormFrom(ctx, r.db).Exec(`
+		UPDATE pools SET deleted_at = NOW(), version = version + 1
+		WHERE group1_id = ? AND mv1_id = ?
+		AND group2_id = ?
+	`, utils.GroupIDUsers, userID, utils.GroupIDRoles).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *userRepository) RestoreUser(ctx context.Context, id uint, version uint) error {
+	return checkVersion(gormFrom(ctx, r.db).Exec(`
+		UPDATE users SET deleted_at = NULL, version = version + 1
+		WHERE id = ? AND version = ? AND deleted_at IS NOT NULL
+	`, id, version))
+}
+
+// DeleteChildrenByUserID soft deletes the live contacts, addresses and
+// identifiers of a user. Run in the transaction deleting the user they share
+// its deleted_at (NOW() is the start of the transaction), which is how
+// RestoreChildrenByUserID tells them apart from children deleted on their own.
+func (r *userRepository) DeleteChildrenByUserID(ctx context.Context, userID uint) error {
+	for _, table := range userChildTables {
+		if err := gormFrom(ctx, r.db).Exec(`
+			UPDATE `+table+` SET deleted_at = NOW(), version = version + 1
+			WHERE user_id = ? AND deleted_at IS NULL
+		`, userID).Error; err != nil {
 			return err
 		}
-		return nil
-	})
+	}
+	return nil
 }
 
-func (s *userRepository) RestoreUser(tx *gorm.DB, id uint) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Exec("UPDATE users SET deleted_at = NULL WHERE id = ?", id).Error; err != nil {
+// RestoreChildrenByUserID restores the children and roles deleted together
+// with the user. It has to run before the user itself is restored.
+func (r *userRepository) RestoreChildrenByUserID(ctx context.Context, userID uint) error {
+	for _, table := range userChildTables {
+		set, args := "", []interface{}{userID}
+		if _, ok := primaryTypeColumns[table]; ok {
+			set, args = ", is_primary = "+keptPrimary(table), []interface{}{userID, userID}
+		}
+		if err := gormFrom(ctx, r.db).Exec(`
+			UPDATE `+table+` AS c SET deleted_at = NULL, version = c.version + 1`+set+`
+			FROM users u
+			WHERE u.id = c.user_id AND u.id = ? AND c.deleted_at = u.deleted_at
+		`, args...).Error; err != nil {
 			return err
 		}
-		return nil
-	})
+	}
+
+	return gormFrom(ctx, r.db).Exec(`
+		UPDATE pools AS p SET deleted_at = NULL, version = p.version + 1
+		FROM users u
+		WHERE u.id = p.mv1_id AND u.id = ? AND p.deleted_at = u.deleted_at
+		AND p.group1_id = ? AND p.group2_id = ?
+	`, userID, utils.GroupIDUsers, utils.GroupIDRoles).Error
 }
 
-// commit or rollback
-func (r *userRepository) Commit(tx *gorm.DB) error {
-	return tx.Commit().Error
+// withSimilarityThreshold runs fn with pg_trgm.similarity_threshold set to
+// threshold, so the % operator (and with it the trigram indexes) matches with
+// the configured similarity. Outside of a unit of work fn gets a read only
+// one of its own.
+func withSimilarityThreshold(ctx context.Context, sqlDB *sqlx.DB, threshold float64, fn func(ctx context.Context) error) error {
+	setThreshold := `SELECT set_config('pg_trgm.similarity_threshold', $1, true)`
+
+	if unitOfWorkFrom(ctx) != nil {
+		if err := execContext(ctx, sqlDB, setThreshold, fmt.Sprint(threshold)); err != nil {
+			return err
+		}
+		return fn(ctx)
+	}
+
+	tx, err := sqlDB.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, setThreshold, fmt.Sprint(threshold)); err != nil {
+		return err
+	}
+
+	if err := fn(context.WithValue(ctx, unitOfWorkKey{}, &unitOfWork{sqlxTx: tx})); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
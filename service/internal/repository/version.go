@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+// checkVersion turns a versioned write that matched no row into
+// utils.ErrVersionConflict: another write bumped the version since the caller
+// read the record.
+func checkVersion(result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrVersionConflict
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
 	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
 	"github.com/nibroos/nb-go-api/service/internal/repository"
 	"github.com/nibroos/nb-go-api/service/internal/service"
 	"gorm.io/gorm"
@@ -13,19 +14,29 @@ func SetupAddressRoutes(addresses fiber.Router, gormDB *gorm.DB, sqlDB *sqlx.DB)
 	addressRepo := repository.NewAddressRepository(gormDB, sqlDB)
 	addressService := service.NewAddressService(addressRepo)
 	addressController := rest.NewAddressController(addressService)
+	idempotent := middleware.Idempotency(repository.NewIdempotencyRepository(gormDB, sqlDB))
 
 	// prefix /addresses
 
 	addresses.Post("/index-address", addressController.ListAddresses)
 	addresses.Post("/show-address", addressController.GetAddressByID)
-	addresses.Post("/create-address", addressController.CreateAddress)
-	addresses.Post("/update-address", addressController.UpdateAddress)
+	addresses.Post("/create-address", idempotent, addressController.CreateAddress)
+	addresses.Post("/update-address", idempotent, addressController.UpdateAddress)
 	addresses.Post("/delete-address", addressController.DeleteAddress)
 	addresses.Post("/restore-address", addressController.RestoreAddress)
+	addresses.Post("/set-primary-address", addressController.SetPrimaryAddress)
+	addresses.Post("/index-trash-address", addressController.ListTrashedAddresses)
+	addresses.Post("/index-history-address", addressController.ListAddressHistory)
+	addresses.Post("/revert-address", addressController.RevertAddress)
+	addresses.Post("/bulk-create-address", idempotent, addressController.BulkCreateAddresses)
+	addresses.Post("/bulk-update-address", idempotent, addressController.BulkUpdateAddresses)
+	addresses.Post("/bulk-delete-address", idempotent, addressController.BulkDeleteAddresses)
+	addresses.Post("/bulk-restore-address", idempotent, addressController.BulkRestoreAddresses)
 	addresses.Post("/auth-index-address", addressController.ListAddressesByAuthUser)
 	addresses.Post("/auth-show-address", addressController.GetAddressByIDByAuthUser)
-	addresses.Post("/auth-create-address", addressController.CreateAddressByAuthUser)
-	addresses.Post("/auth-update-address", addressController.UpdateAddressByAuthUser)
+	addresses.Post("/auth-create-address", idempotent, addressController.CreateAddressByAuthUser)
+	addresses.Post("/auth-update-address", idempotent, addressController.UpdateAddressByAuthUser)
 	addresses.Post("/auth-delete-address", addressController.DeleteAddressByAuthUser)
 	addresses.Post("/auth-restore-address", addressController.RestoreAddressByAuthUser)
+	addresses.Post("/auth-set-primary-address", addressController.SetPrimaryAddressByAuthUser)
 }
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/storage"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+func SetupAttachmentRoutes(attachments fiber.Router, gormDB *gorm.DB, sqlDB *sqlx.DB, store storage.Storage) {
+	attachmentRepo := repository.NewAttachmentRepository(gormDB, sqlDB)
+	fileService := service.NewFileService(repository.NewFileRepository(gormDB, sqlDB), store)
+	attachmentService := service.NewAttachmentService(attachmentRepo, fileService, store)
+	attachmentController := rest.NewAttachmentController(attachmentService)
+	idempotent := middleware.Idempotency(repository.NewIdempotencyRepository(gormDB, sqlDB))
+	manage := middleware.PermissionMiddleware(utils.PermissionManageAttachments)
+
+	// prefix /attachments, the public download-attachment is set up with the
+	// public routes
+
+	attachments.Post("/upload-attachment", manage, idempotent, attachmentController.UploadAttachment)
+	attachments.Post("/index-attachment", manage, attachmentController.ListAttachments)
+	attachments.Post("/url-attachment", manage, attachmentController.GetAttachmentURL)
+	attachments.Post("/delete-attachment", manage, attachmentController.DeleteAttachment)
+	attachments.Post("/auth-upload-attachment", idempotent, attachmentController.UploadAttachmentByAuthUser)
+	attachments.Post("/auth-index-attachment", attachmentController.ListAttachmentsByAuthUser)
+	attachments.Post("/auth-url-attachment", attachmentController.GetAttachmentURLByAuthUser)
+	attachments.Post("/auth-delete-attachment", attachmentController.DeleteAttachmentByAuthUser)
+}
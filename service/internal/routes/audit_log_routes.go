@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+func SetupAuditLogRoutes(auditLogs fiber.Router, gormDB *gorm.DB, sqlDB *sqlx.DB) {
+	auditLogRepo := repository.NewAuditLogRepository(gormDB, sqlDB)
+	auditLogService := service.NewAuditLogService(auditLogRepo)
+	auditLogController := rest.NewAuditLogController(auditLogService)
+
+	// prefix /audit-logs
+
+	auditLogs.Post("/index-audit-log", middleware.PermissionMiddleware(utils.PermissionReadAuditLogs), auditLogController.ListAuditLogs)
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/storage"
+	"github.com/nibroos/nb-go-api/service/internal/tasks"
+	"gorm.io/gorm"
+)
+
+func SetupAvatarRoutes(users fiber.Router, me fiber.Router, gormDB *gorm.DB, sqlDB *sqlx.DB, store storage.Storage, queue tasks.Enqueuer) {
+	fileService := service.NewFileService(repository.NewFileRepository(gormDB, sqlDB), store)
+	avatarService := service.NewAvatarService(repository.NewAvatarRepository(gormDB, sqlDB), fileService, store, queue)
+	avatarController := rest.NewAvatarController(avatarService)
+	idempotent := middleware.Idempotency(repository.NewIdempotencyRepository(gormDB, sqlDB))
+
+	// prefix /users, the public download-avatar-user is set up with the public
+	// routes
+
+	users.Post("/upload-avatar-user", idempotent, avatarController.UploadAvatar)
+	users.Post("/delete-avatar-user", avatarController.DeleteAvatar)
+
+	// prefix /me
+
+	me.Post("/upload-avatar", idempotent, avatarController.UploadAvatarByAuthUser)
+	me.Post("/delete-avatar", avatarController.DeleteAvatarByAuthUser)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
 	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
 	"github.com/nibroos/nb-go-api/service/internal/repository"
 	"github.com/nibroos/nb-go-api/service/internal/service"
 	"gorm.io/gorm"
@@ -13,19 +14,29 @@ func SetupContactRoutes(contacts fiber.Router, gormDB *gorm.DB, sqlDB *sqlx.DB)
 	contactRepo := repository.NewContactRepository(gormDB, sqlDB)
 	contactService := service.NewContactService(contactRepo)
 	contactController := rest.NewContactController(contactService)
+	idempotent := middleware.Idempotency(repository.NewIdempotencyRepository(gormDB, sqlDB))
 
 	// prefix /contacts
 
 	contacts.Post("/index-contact", contactController.ListContacts)
 	contacts.Post("/show-contact", contactController.GetContactByID)
-	contacts.Post("/create-contact", contactController.CreateContact)
-	contacts.Post("/update-contact", contactController.UpdateContact)
+	contacts.Post("/create-contact", idempotent, contactController.CreateContact)
+	contacts.Post("/update-contact", idempotent, contactController.UpdateContact)
 	contacts.Post("/delete-contact", contactController.DeleteContact)
 	contacts.Post("/restore-contact", contactController.RestoreContact)
+	contacts.Post("/set-primary-contact", contactController.SetPrimaryContact)
+	contacts.Post("/index-trash-contact", contactController.ListTrashedContacts)
+	contacts.Post("/index-history-contact", contactController.ListContactHistory)
+	contacts.Post("/revert-contact", contactController.RevertContact)
+	contacts.Post("/bulk-create-contact", idempotent, contactController.BulkCreateContacts)
+	contacts.Post("/bulk-update-contact", idempotent, contactController.BulkUpdateContacts)
+	contacts.Post("/bulk-delete-contact", idempotent, contactController.BulkDeleteContacts)
+	contacts.Post("/bulk-restore-contact", idempotent, contactController.BulkRestoreContacts)
 	contacts.Post("/auth-index-contact", contactController.ListContactsByAuthUser)
 	contacts.Post("/auth-show-contact", contactController.GetContactByIDByAuthUser)
-	contacts.Post("/auth-create-contact", contactController.CreateContactByAuthUser)
-	contacts.Post("/auth-update-contact", contactController.UpdateContactByAuthUser)
+	contacts.Post("/auth-create-contact", idempotent, contactController.CreateContactByAuthUser)
+	contacts.Post("/auth-update-contact", idempotent, contactController.UpdateContactByAuthUser)
 	contacts.Post("/auth-delete-contact", contactController.DeleteContactByAuthUser)
 	contacts.Post("/auth-restore-contact", contactController.RestoreContactByAuthUser)
+	contacts.Post("/auth-set-primary-contact", contactController.SetPrimaryContactByAuthUser)
 }
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/tasks"
+	"gorm.io/gorm"
+)
+
+func SetupExportRoutes(version fiber.Router, gormDB *gorm.DB, sqlDB *sqlx.DB, queue tasks.Enqueuer) {
+	exportService := service.NewExportService(
+		repository.NewExportRepository(gormDB, sqlDB),
+		repository.NewUserRepository(gormDB, sqlDB),
+		repository.NewContactRepository(gormDB, sqlDB),
+		repository.NewAddressRepository(gormDB, sqlDB),
+		repository.NewIdentifierRepository(gormDB, sqlDB),
+		queue,
+	)
+	exportController := rest.NewExportController(exportService)
+
+	// prefix /api/v1, export-* take the filters of their index-*
+
+	version.Post("/users/export-user", exportController.ExportUsers)
+	version.Post("/contacts/export-contact", exportController.ExportContacts)
+	version.Post("/addresses/export-address", exportController.ExportAddresses)
+	version.Post("/identifiers/export-identifier", exportController.ExportIdentifiers)
+	version.Post("/exports/show-export", exportController.GetExportJob)
+	version.Post("/exports/download-export", exportController.DownloadExport)
+}
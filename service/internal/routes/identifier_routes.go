@@ -4,8 +4,10 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
 	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
 	"github.com/nibroos/nb-go-api/service/internal/repository"
 	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
 	"gorm.io/gorm"
 )
 
@@ -13,19 +15,35 @@ func SetupIdentifierRoutes(identifiers fiber.Router, gormDB *gorm.DB, sqlDB *sql
 	identifierRepo := repository.NewIdentifierRepository(gormDB, sqlDB)
 	identifierService := service.NewIdentifierService(identifierRepo)
 	identifierController := rest.NewIdentifierController(identifierService)
+	idempotent := middleware.Idempotency(repository.NewIdempotencyRepository(gormDB, sqlDB))
 
 	// prefix /identifiers
 
 	identifiers.Post("/index-identifier", identifierController.ListIdentifiers)
 	identifiers.Post("/show-identifier", identifierController.GetIdentifierByID)
-	identifiers.Post("/create-identifier", identifierController.CreateIdentifier)
-	identifiers.Post("/update-identifier", identifierController.UpdateIdentifier)
+	identifiers.Post("/create-identifier", idempotent, identifierController.CreateIdentifier)
+	identifiers.Post("/update-identifier", idempotent, identifierController.UpdateIdentifier)
 	identifiers.Post("/delete-identifier", identifierController.DeleteIdentifier)
 	identifiers.Post("/restore-identifier", identifierController.RestoreIdentifier)
+	identifiers.Post("/submit-identifier", identifierController.SubmitIdentifier)
+	identifiers.Post("/reverify-identifier", idempotent, identifierController.ReverifyIdentifier)
+	identifiers.Post("/approve-identifier", middleware.PermissionMiddleware(utils.PermissionVerifyIdentifiers), identifierController.ApproveIdentifier)
+	identifiers.Post("/reject-identifier", middleware.PermissionMiddleware(utils.PermissionVerifyIdentifiers), identifierController.RejectIdentifier)
+	identifiers.Post("/index-transition-identifier", identifierController.ListIdentifierTransitions)
+	identifiers.Post("/index-trash-identifier", identifierController.ListTrashedIdentifiers)
+	identifiers.Post("/index-history-identifier", identifierController.ListIdentifierHistory)
+	identifiers.Post("/revert-identifier", identifierController.RevertIdentifier)
+	identifiers.Post("/bulk-create-identifier", idempotent, identifierController.BulkCreateIdentifiers)
+	identifiers.Post("/bulk-update-identifier", idempotent, identifierController.BulkUpdateIdentifiers)
+	identifiers.Post("/bulk-delete-identifier", idempotent, identifierController.BulkDeleteIdentifiers)
+	identifiers.Post("/bulk-restore-identifier", idempotent, identifierController.BulkRestoreIdentifiers)
 	identifiers.Post("/auth-index-identifier", identifierController.ListIdentifiersByAuthUser)
 	identifiers.Post("/auth-show-identifier", identifierController.GetIdentifierByAuthUser)
-	identifiers.Post("/auth-create-identifier", identifierController.CreateIdentifierByAuthUser)
-	identifiers.Post("/auth-update-identifier", identifierController.UpdateIdentifierByAuthUser)
+	identifiers.Post("/auth-create-identifier", idempotent, identifierController.CreateIdentifierByAuthUser)
+	identifiers.Post("/auth-update-identifier", idempotent, identifierController.UpdateIdentifierByAuthUser)
 	identifiers.Post("/auth-delete-identifier", identifierController.DeleteIdentifierByAuthUser)
 	identifiers.Post("/auth-restore-identifier", identifierController.RestoreIdentifierByAuthUser)
+	identifiers.Post("/auth-submit-identifier", identifierController.SubmitIdentifierByAuthUser)
+	identifiers.Post("/auth-reverify-identifier", idempotent, identifierController.ReverifyIdentifierByAuthUser)
+	identifiers.Post("/auth-index-transition-identifier", identifierController.ListIdentifierTransitionsByAuthUser)
 }
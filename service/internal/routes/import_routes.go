@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/tasks"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+func SetupImportRoutes(imports fiber.Router, gormDB *gorm.DB, sqlDB *sqlx.DB, queue tasks.Enqueuer) {
+	importRepo := repository.NewImportRepository(gormDB, sqlDB)
+	userService := service.NewUserService(repository.NewUserRepository(gormDB, sqlDB))
+	contactService := service.NewContactService(repository.NewContactRepository(gormDB, sqlDB))
+	importService := service.NewImportService(importRepo, userService, contactService, queue)
+	importController := rest.NewImportController(importService)
+
+	// prefix /imports
+
+	imports.Post("/upload-import", middleware.PermissionMiddleware(utils.PermissionImportData), importController.UploadImport)
+	imports.Post("/show-import", middleware.PermissionMiddleware(utils.PermissionImportData), importController.GetImportJob)
+	imports.Post("/report-import", middleware.PermissionMiddleware(utils.PermissionImportData), importController.DownloadImportReport)
+	imports.Post("/commit-import", middleware.PermissionMiddleware(utils.PermissionImportData), importController.CommitImport)
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+func SetupPrivacyRoutes(users fiber.Router, gormDB *gorm.DB, sqlDB *sqlx.DB) {
+	privacyRepo := repository.NewPrivacyRepository(gormDB, sqlDB)
+	auditLogRepo := repository.NewAuditLogRepository(gormDB, sqlDB)
+	privacyService := service.NewPrivacyService(privacyRepo, auditLogRepo)
+	privacyController := rest.NewPrivacyController(privacyService)
+
+	// prefix /users
+
+	users.Post("/export-personal-data-user", privacyController.ExportPersonalData)
+	users.Post("/erase-user", middleware.PermissionMiddleware(utils.PermissionManagePersonalData), privacyController.EraseUser)
+}
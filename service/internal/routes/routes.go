@@ -1,12 +1,17 @@
 package routes
 
 import (
+	"log"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/hibiken/asynq"
 	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/config"
 	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
 	"github.com/nibroos/nb-go-api/service/internal/middleware"
 	"github.com/nibroos/nb-go-api/service/internal/repository"
 	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/storage"
 	"gorm.io/gorm"
 )
 
@@ -34,13 +39,34 @@ func SetupRoutes(app *fiber.App, gormDB *gorm.DB, sqlDB *sqlx.DB) {
 	auth.Post("/login", rest.NewUserController(service.NewUserService(repository.NewUserRepository(gormDB, sqlDB))).Login)
 	auth.Post("/register", rest.NewUserController(service.NewUserService(repository.NewUserRepository(gormDB, sqlDB))).Register)
 
+	// Attachments and avatars are downloaded through signed URLs, without a JWT
+	store, err := storage.New()
+	if err != nil {
+		log.Fatalf("Failed to set up the storage: %v", err)
+	}
+	files := service.NewFileService(repository.NewFileRepository(gormDB, sqlDB), store)
+	attachments := version.Group("/attachments")
+	attachments.Get("/download-attachment", rest.NewAttachmentController(service.NewAttachmentService(repository.NewAttachmentRepository(gormDB, sqlDB), files, store)).DownloadAttachment)
+	users := version.Group("/users")
+	users.Get("/download-avatar-user", rest.NewAvatarController(service.NewAvatarService(repository.NewAvatarRepository(gormDB, sqlDB), files, store, nil)).DownloadAvatar)
+
 	// Protected routes
 	app.Use(middleware.JWTMiddleware())
 	app.Use(middleware.ConvertToClientTimezone())
 
+	// Imports are committed, large exports written and avatar thumbnails
+	// generated by the worker
+	queue := asynq.NewClient(config.GetAsynqRedisOpt())
+
 	// Grouped routes
-	users := version.Group("/users")
 	SetupUserRoutes(users, gormDB, sqlDB)
+	SetupPrivacyRoutes(users, gormDB, sqlDB)
+	SetupUserMergeRoutes(users, gormDB, sqlDB)
+	SetupUserLocationRoutes(users, gormDB, sqlDB)
+
+	me := version.Group("/me")
+	me.Post("/show-me", rest.NewUserController(service.NewUserService(repository.NewUserRepository(gormDB, sqlDB))).GetMe)
+	SetupAvatarRoutes(users, me, gormDB, sqlDB, store, queue)
 
 	identifiers := version.Group("/identifiers")
 	SetupIdentifierRoutes(identifiers, gormDB, sqlDB)
@@ -51,6 +77,18 @@ func SetupRoutes(app *fiber.App, gormDB *gorm.DB, sqlDB *sqlx.DB) {
 	addresses := version.Group("/addresses")
 	SetupAddressRoutes(addresses, gormDB, sqlDB)
 
+	SetupSearchRoutes(version, gormDB, sqlDB)
+
+	auditLogs := version.Group("/audit-logs")
+	SetupAuditLogRoutes(auditLogs, gormDB, sqlDB)
+
+	SetupAttachmentRoutes(attachments, gormDB, sqlDB, store)
+
+	imports := version.Group("/imports")
+	SetupImportRoutes(imports, gormDB, sqlDB, queue)
+
+	SetupExportRoutes(version, gormDB, sqlDB, queue)
+
 	// Scheduler route
 	// cron := cron.New()
 	// schedulerController := rest.NewSchedulerController(cron, gormDB, sqlDB)
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"gorm.io/gorm"
+)
+
+func SetupSearchRoutes(version fiber.Router, gormDB *gorm.DB, sqlDB *sqlx.DB) {
+	searchRepo := repository.NewSearchRepository(gormDB, sqlDB)
+	searchService := service.NewSearchService(searchRepo)
+	searchController := rest.NewSearchController(searchService)
+
+	// prefix /api/v1
+
+	version.Post("/search", searchController.Search)
+}
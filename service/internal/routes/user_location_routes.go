@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+func SetupUserLocationRoutes(users fiber.Router, gormDB *gorm.DB, sqlDB *sqlx.DB) {
+	addressRepo := repository.NewAddressRepository(gormDB, sqlDB)
+	addressService := service.NewAddressService(addressRepo)
+	addressController := rest.NewAddressController(addressService)
+
+	// prefix /users
+
+	users.Post("/index-location-user", middleware.PermissionMiddleware(utils.PermissionReadUsers), addressController.ListUsersByLocation)
+}
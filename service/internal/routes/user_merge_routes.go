@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"gorm.io/gorm"
+)
+
+func SetupUserMergeRoutes(users fiber.Router, gormDB *gorm.DB, sqlDB *sqlx.DB) {
+	mergeRepo := repository.NewUserMergeRepository(gormDB, sqlDB)
+	auditLogRepo := repository.NewAuditLogRepository(gormDB, sqlDB)
+	mergeService := service.NewUserMergeService(mergeRepo, auditLogRepo)
+	mergeController := rest.NewUserMergeController(mergeService)
+	idempotent := middleware.Idempotency(repository.NewIdempotencyRepository(gormDB, sqlDB))
+
+	// prefix /users
+
+	users.Post("/index-duplicate-user", middleware.PermissionMiddleware(utils.PermissionMergeUsers), mergeController.FindDuplicateUsers)
+	users.Post("/merge-user", middleware.PermissionMiddleware(utils.PermissionMergeUsers), idempotent, mergeController.MergeUsers)
+}
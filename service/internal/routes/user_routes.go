@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
 	"github.com/nibroos/nb-go-api/service/internal/controller/rest"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
 	"github.com/nibroos/nb-go-api/service/internal/repository"
 	"github.com/nibroos/nb-go-api/service/internal/service"
 	"gorm.io/gorm"
@@ -13,13 +14,21 @@ func SetupUserRoutes(users fiber.Router, gormDB *gorm.DB, sqlDB *sqlx.DB) {
 	userRepo := repository.NewUserRepository(gormDB, sqlDB)
 	userService := service.NewUserService(userRepo)
 	userController := rest.NewUserController(userService)
+	idempotent := middleware.Idempotency(repository.NewIdempotencyRepository(gormDB, sqlDB))
 
 	// prefix /users
 
 	users.Post("/index-user", userController.GetUsers)
 	users.Post("/show-user", userController.GetUserByID)
-	users.Post("/create-user", userController.CreateUser)
-	users.Post("/update-user", userController.UpdateUser)
+	users.Post("/create-user", idempotent, userController.CreateUser)
+	users.Post("/update-user", idempotent, userController.UpdateUser)
 	users.Post("/delete-user", userController.DeleteUser)
 	users.Post("/restore-user", userController.RestoreUser)
+	users.Post("/index-trash-user", userController.GetTrashedUsers)
+	users.Post("/index-history-user", userController.GetUserHistory)
+	users.Post("/revert-user", userController.RevertUser)
+	users.Post("/bulk-create-user", idempotent, userController.BulkCreateUsers)
+	users.Post("/bulk-update-user", idempotent, userController.BulkUpdateUsers)
+	users.Post("/bulk-delete-user", idempotent, userController.BulkDeleteUsers)
+	users.Post("/bulk-restore-user", idempotent, userController.BulkRestoreUsers)
 }
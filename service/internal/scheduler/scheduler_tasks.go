@@ -1,9 +1,15 @@
 package scheduler
 
 import (
+	"context"
 	"log"
 	"math/rand"
 	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/config"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/tasks"
 )
 
 func GenerateRandomString() {
@@ -22,3 +28,113 @@ func GenerateRandomNumber() {
 	number := seededRand.Intn(100)
 	log.Printf("Generated Random Number: %d", number)
 }
+
+// PurgeTrash hard deletes the rows that have been in the trash for longer than
+// the configured retention.
+func PurgeTrash(trashService *service.TrashService) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	purged, err := trashService.PurgeTrash(ctx, config.GetTrashRetentionDays(), config.GetTrashPurgeBatchSize())
+	if err != nil {
+		log.Printf("Failed to purge trash after %v: %v", purged, err)
+		return
+	}
+	log.Printf("Purged trash: %v", purged)
+}
+
+// PurgeIdempotencyKeys deletes the Idempotency-Keys whose responses are no
+// longer replayed.
+func PurgeIdempotencyKeys(idempotencyRepo *repository.IdempotencyRepository) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	batchSize := config.GetIdempotencyPurgeBatchSize()
+	var purged int64
+	for {
+		n, err := idempotencyRepo.PurgeExpiredIdempotencyKeys(ctx, batchSize)
+		purged += n
+		if err != nil {
+			log.Printf("Failed to purge Idempotency-Keys after %d: %v", purged, err)
+			return
+		}
+		if n < int64(batchSize) {
+			break
+		}
+	}
+	log.Printf("Purged Idempotency-Keys: %d", purged)
+}
+
+// NormalizeContacts normalizes the stored ref_num of every contact by the
+// format of its type, after the formats of the types have changed.
+func NormalizeContacts(contactService *service.ContactService) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	updated, invalid, err := contactService.RenormalizeContacts(ctx, config.GetSchedulerBatchSize())
+	if err != nil {
+		log.Printf("Failed to normalize contacts after %d: %v", updated, err)
+		return
+	}
+	log.Printf("Normalized contacts: %d updated, %d not in the format of their type", updated, invalid)
+}
+
+// ReportInvalidIdentifiers logs the identifiers whose ref_num fails the
+// validators of their type, which may have been added or changed since the
+// identifiers were written.
+func ReportInvalidIdentifiers(identifierService *service.IdentifierService) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	invalid, checked, err := identifierService.FindInvalidIdentifiers(ctx, config.GetSchedulerBatchSize())
+	if err != nil {
+		log.Printf("Failed to check identifiers after %d: %v", checked, err)
+		return
+	}
+	for _, identifier := range invalid {
+		log.Printf("Invalid identifier %d of user %d (%s): %s", identifier.ID, identifier.UserID, identifier.TypeIdentifierName, identifier.Error)
+	}
+	log.Printf("Checked identifiers: %d, %d invalid", checked, len(invalid))
+}
+
+// RemindExpiringIdentifiers enqueues the reminders of the identifiers
+// expiring within the configured days.
+func RemindExpiringIdentifiers(identifierService *service.IdentifierService, queue tasks.Enqueuer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	reminded, err := identifierService.RemindExpiringIdentifiers(ctx, queue, config.GetIdentifierReminderDays(), config.GetSchedulerBatchSize())
+	if err != nil {
+		log.Printf("Failed to remind expiring identifiers after %d: %v", reminded, err)
+		return
+	}
+	log.Printf("Reminded expiring identifiers: %d", reminded)
+}
+
+// ExpireIdentifiers moves the verified identifiers whose expiry date has
+// passed to expired.
+func ExpireIdentifiers(identifierService *service.IdentifierService) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	expired, err := identifierService.ExpireIdentifiers(ctx, config.GetSchedulerBatchSize())
+	if err != nil {
+		log.Printf("Failed to expire identifiers after %d: %v", expired, err)
+		return
+	}
+	log.Printf("Expired identifiers: %d", expired)
+}
+
+// PurgeOrphanFiles deletes the stored files no attachment or avatar
+// references anymore.
+func PurgeOrphanFiles(fileService *service.FileService) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	purged, err := fileService.PurgeOrphanFiles(ctx, config.GetSchedulerBatchSize())
+	if err != nil {
+		log.Printf("Failed to purge orphan files after %d: %v", purged, err)
+		return
+	}
+	log.Printf("Purged orphan files: %d", purged)
+}
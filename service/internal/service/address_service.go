@@ -33,20 +33,72 @@ func (s *AddressService) ListAddresses(ctx context.Context, filters map[string]s
 	}
 }
 
-func (s *AddressService) CreateAddress(ctx context.Context, address *models.Address) (*models.Address, error) {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
-		return nil, err
+// ListTrashedAddresses lists the soft deleted addresses.
+func (s *AddressService) ListTrashedAddresses(ctx context.Context, filters map[string]string) ([]dtos.AddressListDTO, int, error) {
+
+	resultChan := make(chan dtos.ListAddressesResult, 1)
+
+	go func() {
+		addresses, total, err := s.repo.ListTrashedAddresses(ctx, filters)
+		resultChan <- dtos.ListAddressesResult{Addresses: addresses, Total: total, Err: err}
+	}()
+
+	select {
+	case res := <-resultChan:
+		return res.Addresses, res.Total, res.Err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
 	}
+}
 
-	// Create address
-	if err := s.repo.CreateAddress(tx, address); err != nil {
-		tx.Rollback()
-		return nil, err
+// ListUsersByLocation lists the users with an address in the place the
+// filters give.
+func (s *AddressService) ListUsersByLocation(ctx context.Context, filters map[string]string) ([]dtos.UserLocationDTO, int, error) {
+
+	resultChan := make(chan dtos.ListUserLocationsResult, 1)
+
+	go func() {
+		users, total, err := s.repo.ListUsersByLocation(ctx, filters)
+		resultChan <- dtos.ListUserLocationsResult{Users: users, Total: total, Err: err}
+	}()
+
+	select {
+	case res := <-resultChan:
+		return res.Users, res.Total, res.Err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
 	}
+}
+
+// GetAddressHistory lists the versions of a address.
+func (s *AddressService) GetAddressHistory(ctx context.Context, id uint, filters map[string]string) ([]dtos.HistoryDTO, int, error) {
+
+	resultChan := make(chan dtos.ListHistoryResult, 1)
+
+	go func() {
+		history, total, err := s.repo.GetAddressHistory(ctx, id, filters)
+		resultChan <- dtos.ListHistoryResult{History: history, Total: total, Err: err}
+	}()
 
-	if err := tx.Commit().Error; err != nil {
+	select {
+	case res := <-resultChan:
+		return res.History, res.Total, res.Err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
+
+// GetUsersByIDs loads the users embedded by include=user, keyed by ID.
+func (s *AddressService) GetUsersByIDs(ctx context.Context, ids []uint) (map[uint]dtos.UserListDTO, error) {
+	return s.repo.GetUsersByIDs(ctx, ids)
+}
+
+func (s *AddressService) CreateAddress(ctx context.Context, address *models.Address) (*models.Address, error) {
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Create address
+		return s.repo.CreateAddress(ctx, address)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -77,61 +129,50 @@ func (s *AddressService) GetAddressByID(ctx context.Context, params *dtos.GetAdd
 }
 
 func (s *AddressService) UpdateAddress(ctx context.Context, address *models.Address) (*models.Address, error) {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
-		return nil, err
-	}
-
-	// Update address
-	if err := s.repo.UpdateAddress(tx, address); err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-
-	if err := tx.Commit().Error; err != nil {
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Update address
+		return s.repo.UpdateAddress(ctx, address)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return address, nil
 }
 
-func (s *AddressService) DeleteAddress(ctx context.Context, id uint) error {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
-		return err
-	}
-
-	// Delete address
-	if err := s.repo.DeleteAddress(tx, id); err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	if err := tx.Commit().Error; err != nil {
+func (s *AddressService) DeleteAddress(ctx context.Context, id uint, version uint) error {
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Delete address
+		return s.repo.DeleteAddress(ctx, id, version)
+	})
+	if err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (s *AddressService) RestoreAddress(ctx context.Context, id uint) error {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
+func (s *AddressService) RestoreAddress(ctx context.Context, id uint, version uint) error {
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Restore address
+		return s.repo.RestoreAddress(ctx, id, version)
+	})
+	if err != nil {
 		return err
 	}
 
-	// Restore address
-	if err := s.repo.RestoreAddress(tx, id); err != nil {
-		tx.Rollback()
-		return err
-	}
+	return nil
+}
 
-	if err := tx.Commit().Error; err != nil {
-		return err
-	}
+// SetPrimaryAddress makes a an address the primary of its user and type.
+func (s *AddressService) SetPrimaryAddress(ctx context.Context, id uint, version uint) error {
+	return s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.repo.SetPrimaryAddress(ctx, id, version)
+	})
+}
 
-	return nil
+// RunBulk runs op for the pending items of a bulk request in one transaction,
+// see runBulk.
+func (s *AddressService) RunBulk(ctx context.Context, results []dtos.BulkItemResult, atomic bool, status string, op BulkOp) error {
+	return runBulk(ctx, s.repo, results, atomic, status, op)
 }
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/config"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/storage"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+// attachmentDownloadPath is the endpoint a signed download URL of an
+// attachment points at when the storage does not sign its own.
+const attachmentDownloadPath = "/api/v1/attachments/download-attachment"
+
+type AttachmentService struct {
+	repo    *repository.AttachmentRepository
+	files   *FileService
+	storage storage.Storage
+}
+
+func NewAttachmentService(repo *repository.AttachmentRepository, files *FileService, storage storage.Storage) *AttachmentService {
+	return &AttachmentService{repo: repo, files: files, storage: storage}
+}
+
+// UploadAttachment attaches the file data, named fileName, to row entityID of
+// entity, limited to the rows of userID unless it is 0. The type of the file
+// is sniffed from its content; a file of the same content is stored once and
+// attached to a row once. It returns the attachment and whether it is new.
+func (s *AttachmentService) UploadAttachment(ctx context.Context, entity string, entityID uint, userID uint, fileName string, data []byte) (*dtos.AttachmentDTO, bool, error) {
+	if !utils.IsAttachmentEntity(entity) {
+		return nil, false, utils.ErrAttachmentEntity
+	}
+	if int64(len(data)) > config.GetAttachmentMaxBytes() {
+		return nil, false, utils.ErrAttachmentTooLarge
+	}
+	contentType := utils.SniffContentType(data)
+	if !slices.Contains(config.GetAttachmentTypes(), contentType) {
+		return nil, false, utils.ErrAttachmentType
+	}
+
+	file, err := s.files.SaveFile(ctx, data, contentType)
+	if err != nil {
+		return nil, false, err
+	}
+
+	attachment := models.Attachment{
+		Entity:   entity,
+		EntityID: entityID,
+		FileID:   file.ID,
+		FileName: fileName,
+	}
+	var created bool
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		owner, err := s.repo.GetEntityOwner(ctx, entity, entityID)
+		if err != nil {
+			return err
+		}
+		if userID != 0 && owner != userID {
+			return utils.ErrAttachmentEntity
+		}
+
+		if err := s.files.LockFile(ctx, file.ID); err != nil {
+			return err
+		}
+		created, err = s.repo.CreateAttachment(ctx, &attachment)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	uploaded, err := s.repo.GetAttachmentByID(ctx, attachment.ID)
+	return uploaded, created, err
+}
+
+// ListAttachments lists the attachments of row entityID of entity, limited to
+// the rows of userID unless it is 0.
+func (s *AttachmentService) ListAttachments(ctx context.Context, entity string, entityID uint, userID uint, filters map[string]string) ([]dtos.AttachmentDTO, int, error) {
+	owner, err := s.repo.GetEntityOwner(ctx, entity, entityID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if userID != 0 && owner != userID {
+		return nil, 0, utils.ErrAttachmentEntity
+	}
+	return s.repo.ListAttachments(ctx, entity, entityID, filters)
+}
+
+// GetAttachment returns an attachment, limited to the rows of userID unless
+// it is 0.
+func (s *AttachmentService) GetAttachment(ctx context.Context, id uint, userID uint) (*dtos.AttachmentDTO, error) {
+	attachment, err := s.repo.GetAttachmentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if userID != 0 && attachment.OwnerID != userID {
+		return nil, sql.ErrNoRows
+	}
+	return attachment, nil
+}
+
+// GetAttachmentURL returns a URL downloading an attachment for
+// ATTACHMENT_URL_TTL_MINUTES, limited to the rows of userID unless it is 0.
+// The storage signs it when it can serve the file itself, the service does
+// otherwise, see OpenAttachment.
+func (s *AttachmentService) GetAttachmentURL(ctx context.Context, id uint, userID uint) (*dtos.AttachmentURLDTO, error) {
+	attachment, err := s.GetAttachment(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := config.GetAttachmentURLTTL()
+	expiresAt := time.Now().Add(ttl)
+
+	if presigner, ok := s.storage.(storage.Presigner); ok {
+		presigned, err := presigner.PresignGet(ctx, attachment.StorageKey, attachment.FileName, ttl)
+		if err != nil {
+			return nil, err
+		}
+		return &dtos.AttachmentURLDTO{URL: presigned, ExpiresAt: expiresAt}, nil
+	}
+
+	expires := expiresAt.Unix()
+	query := url.Values{}
+	query.Set("id", fmt.Sprint(attachment.ID))
+	query.Set("expires", fmt.Sprint(expires))
+	query.Set("signature", utils.SignAttachmentURL(config.GetAttachmentURLSecret(), attachment.ID, expires))
+
+	return &dtos.AttachmentURLDTO{
+		URL:       attachmentDownloadPath + "?" + query.Encode(),
+		ExpiresAt: time.Unix(expires, 0),
+	}, nil
+}
+
+// OpenAttachment reads the file of an attachment through a download URL
+// GetAttachmentURL signed.
+func (s *AttachmentService) OpenAttachment(ctx context.Context, id uint, expires int64, signature string) (*dtos.AttachmentDTO, io.ReadCloser, error) {
+	if err := utils.VerifyAttachmentURL(config.GetAttachmentURLSecret(), id, expires, signature, time.Now()); err != nil {
+		return nil, nil, err
+	}
+
+	attachment, err := s.repo.GetAttachmentByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := s.storage.Open(ctx, attachment.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, file, nil
+}
+
+// DeleteAttachment detaches an attachment from its row, limited to the rows
+// of userID unless it is 0.
+func (s *AttachmentService) DeleteAttachment(ctx context.Context, id uint, userID uint) error {
+	if _, err := s.GetAttachment(ctx, id, userID); err != nil {
+		return err
+	}
+	return s.repo.DeleteAttachment(ctx, id)
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+)
+
+type AuditLogService struct {
+	repo *repository.AuditLogRepository
+}
+
+func NewAuditLogService(repo *repository.AuditLogRepository) *AuditLogService {
+	return &AuditLogService{repo: repo}
+}
+
+func (s *AuditLogService) ListAuditLogs(ctx context.Context, filters map[string]string) ([]dtos.AuditLogListDTO, int, error) {
+
+	resultChan := make(chan dtos.ListAuditLogsResult, 1)
+
+	go func() {
+		auditLogs, total, err := s.repo.ListAuditLogs(ctx, filters)
+		resultChan <- dtos.ListAuditLogsResult{AuditLogs: auditLogs, Total: total, Err: err}
+	}()
+
+	select {
+	case res := <-resultChan:
+		return res.AuditLogs, res.Total, res.Err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
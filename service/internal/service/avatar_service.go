@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/hibiken/asynq"
+	"github.com/nibroos/nb-go-api/service/internal/config"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/storage"
+	"github.com/nibroos/nb-go-api/service/internal/tasks"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+// avatarMaxPixels is the most pixels an uploaded avatar may have, so a small
+// file cannot decode to an image filling the memory.
+const avatarMaxPixels = 40_000_000
+
+type AvatarService struct {
+	repo    *repository.AvatarRepository
+	files   *FileService
+	storage storage.Storage
+	queue   tasks.Enqueuer
+}
+
+func NewAvatarService(repo *repository.AvatarRepository, files *FileService, storage storage.Storage, queue tasks.Enqueuer) *AvatarService {
+	return &AvatarService{repo: repo, files: files, storage: storage, queue: queue}
+}
+
+// UploadAvatar makes the JPEG, PNG or WebP image data the avatar of user
+// userID. It is cropped to a square of at most AVATAR_SIZE pixels and stored
+// as a JPEG without its metadata; the worker generates the thumbnails of
+// AVATAR_THUMBNAIL_SIZES afterwards.
+func (s *AvatarService) UploadAvatar(ctx context.Context, userID uint, data []byte) (*dtos.AvatarDTO, error) {
+	if int64(len(data)) > config.GetAvatarMaxBytes() {
+		return nil, utils.ErrAvatarTooLarge
+	}
+
+	img, orientation, err := utils.DecodeAvatar(data, avatarMaxPixels)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := utils.EncodeAvatar(utils.SquareAvatar(img, orientation, config.GetAvatarSize()))
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := s.files.SaveFile(ctx, encoded, "image/jpeg")
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.LockAvatarUser(ctx, userID); err != nil {
+			return err
+		}
+		if err := s.files.LockFile(ctx, file.ID); err != nil {
+			return err
+		}
+		return s.repo.ReplaceAvatar(ctx, userID, file.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the avatar is served whole until its thumbnails exist
+	if _, err := s.queue.EnqueueContext(ctx, tasks.NewAvatarThumbnailsTask(userID, file.ID), asynq.Queue("low")); err != nil {
+		log.Printf("Failed to queue the thumbnails of the avatar of user %d: %v", userID, err)
+	}
+
+	return &dtos.AvatarDTO{
+		UserID:    userID,
+		AvatarURL: utils.AvatarURL(repository.AvatarDownloadPath, config.GetAttachmentURLSecret(), userID, file.ID),
+	}, nil
+}
+
+// DeleteAvatar removes the avatar of user userID, sql.ErrNoRows when it has
+// none.
+func (s *AvatarService) DeleteAvatar(ctx context.Context, userID uint) error {
+	return s.repo.DeleteAvatar(ctx, userID)
+}
+
+// OpenAvatar reads an image of the avatar of user userID through a URL
+// utils.AvatarURL signed for file fileID: the smallest thumbnail of at least
+// size pixels, the uploaded image when there is none or size is 0. A URL of a
+// replaced avatar finds nothing.
+func (s *AvatarService) OpenAvatar(ctx context.Context, userID uint, fileID uint, signature string, size int) (*repository.AvatarImage, io.ReadCloser, error) {
+	if err := utils.VerifyAvatarURL(config.GetAttachmentURLSecret(), userID, fileID, signature); err != nil {
+		return nil, nil, err
+	}
+
+	images, err := s.repo.GetAvatarImages(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(images) == 0 || images[0].Size != 0 || images[0].FileID != fileID {
+		return nil, nil, sql.ErrNoRows
+	}
+
+	image := images[0]
+	if size > 0 {
+		for _, thumbnail := range images[1:] {
+			if thumbnail.Size >= size {
+				image = thumbnail
+				break
+			}
+		}
+	}
+
+	file, err := s.storage.Open(ctx, image.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &image, file, nil
+}
+
+// HandleAvatarThumbnailsTask generates the thumbnails of an avatar, the sizes
+// of AVATAR_THUMBNAIL_SIZES smaller than it. It stops once the avatar is
+// replaced or removed.
+func (s *AvatarService) HandleAvatarThumbnailsTask(ctx context.Context, t *asynq.Task) error {
+	var payload struct {
+		UserID uint `json:"user_id"`
+		FileID uint `json:"file_id"`
+	}
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return err
+	}
+
+	_, data, err := s.files.ReadFile(ctx, payload.FileID)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, storage.ErrNotFound) {
+		// purged, the avatar was replaced or removed
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("avatar of user %d: %w", payload.UserID, err)
+	}
+
+	img, _, err := utils.DecodeAvatar(data, avatarMaxPixels)
+	if err != nil {
+		return fmt.Errorf("avatar of user %d: %w", payload.UserID, err)
+	}
+
+	for _, size := range config.GetAvatarThumbnailSizes() {
+		if size >= img.Bounds().Dx() {
+			// the avatar itself is served
+			continue
+		}
+
+		// the avatar was turned upright when uploaded
+		encoded, err := utils.EncodeAvatar(utils.SquareAvatar(img, 1, size))
+		if err != nil {
+			return err
+		}
+		file, err := s.files.SaveFile(ctx, encoded, "image/jpeg")
+		if err != nil {
+			return err
+		}
+
+		var added bool
+		err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+			if err := s.files.LockFile(ctx, file.ID); err != nil {
+				return err
+			}
+			added, err = s.repo.AddAvatarThumbnail(ctx, payload.UserID, payload.FileID, size, file.ID)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("avatar of user %d: %w", payload.UserID, err)
+		}
+		if !added {
+			return nil
+		}
+	}
+	return nil
+}
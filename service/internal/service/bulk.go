@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+// transactor runs functions in a unit of work, as every repository does.
+type transactor interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// BulkOp writes the item at index of a bulk request and returns the ID and
+// version of the record it left.
+type BulkOp func(ctx context.Context, index int) (id uint, version uint, err error)
+
+// runBulk runs op for the items of results still without a status, all in one
+// transaction and each in a savepoint of it, and records status or the
+// errors of every item. In atomic mode the first failure rolls the whole
+// request back, the other items are reported as skipped and ErrBulkFailed
+// is returned.
+func runBulk(ctx context.Context, tx transactor, results []dtos.BulkItemResult, atomic bool, status string, op BulkOp) error {
+	var pending []int
+	for i := range results {
+		if results[i].Status == "" {
+			pending = append(pending, i)
+		}
+	}
+
+	attempt := make([]dtos.BulkItemResult, len(results))
+	err := tx.WithTransaction(ctx, func(ctx context.Context) error {
+		// the transaction may be retried, results start over with it
+		copy(attempt, results)
+
+		for _, i := range pending {
+			var id, version uint
+			err := tx.WithTransaction(ctx, func(ctx context.Context) (err error) {
+				id, version, err = op(ctx, i)
+				return err
+			})
+			if err != nil {
+				attempt[i].Status = utils.BulkStatusFailed
+				attempt[i].Errors = bulkErrors(err)
+				if atomic {
+					return utils.ErrBulkFailed
+				}
+				continue
+			}
+
+			attempt[i].Status = status
+			attempt[i].ID = &id
+			attempt[i].Version = &version
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, utils.ErrBulkFailed) {
+		return err
+	}
+
+	copy(results, attempt)
+	if err != nil {
+		for i := range results {
+			if results[i].Status != utils.BulkStatusFailed {
+				results[i] = dtos.BulkItemResult{Index: results[i].Index, Status: utils.BulkStatusSkipped}
+			}
+		}
+	}
+	return err
+}
+
+// bulkErrors describes why an item of a bulk request could not be written.
+func bulkErrors(err error) map[string]string {
+	switch {
+	case errors.Is(err, utils.ErrVersionConflict):
+		return map[string]string{"version": "the record has been modified, reload it and retry"}
+	case errors.Is(err, sql.ErrNoRows):
+		return map[string]string{"id": "the record does not exist"}
+	case errors.Is(err, utils.ErrIdentifierLocked), errors.Is(err, utils.ErrIdentifierTransition):
+		return map[string]string{"status": err.Error()}
+	default:
+		return map[string]string{"error": err.Error()}
+	}
+}
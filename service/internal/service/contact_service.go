@@ -2,10 +2,13 @@ package service
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/nibroos/nb-go-api/service/internal/config"
 	"github.com/nibroos/nb-go-api/service/internal/dtos"
 	"github.com/nibroos/nb-go-api/service/internal/models"
 	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
 )
 
 type ContactService struct {
@@ -33,20 +36,53 @@ func (s *ContactService) ListContacts(ctx context.Context, filters map[string]st
 	}
 }
 
-func (s *ContactService) CreateContact(ctx context.Context, contact *models.Contact) (*models.Contact, error) {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
-		return nil, err
+// ListTrashedContacts lists the soft deleted contacts.
+func (s *ContactService) ListTrashedContacts(ctx context.Context, filters map[string]string) ([]dtos.ContactListDTO, int, error) {
+
+	resultChan := make(chan dtos.ListContactsResult, 1)
+
+	go func() {
+		contacts, total, err := s.repo.ListTrashedContacts(ctx, filters)
+		resultChan <- dtos.ListContactsResult{Contacts: contacts, Total: total, Err: err}
+	}()
+
+	select {
+	case res := <-resultChan:
+		return res.Contacts, res.Total, res.Err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
 	}
+}
 
-	// Create contact
-	if err := s.repo.CreateContact(tx, contact); err != nil {
-		tx.Rollback()
-		return nil, err
+// GetContactHistory lists the versions of a contact.
+func (s *ContactService) GetContactHistory(ctx context.Context, id uint, filters map[string]string) ([]dtos.HistoryDTO, int, error) {
+
+	resultChan := make(chan dtos.ListHistoryResult, 1)
+
+	go func() {
+		history, total, err := s.repo.GetContactHistory(ctx, id, filters)
+		resultChan <- dtos.ListHistoryResult{History: history, Total: total, Err: err}
+	}()
+
+	select {
+	case res := <-resultChan:
+		return res.History, res.Total, res.Err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
 	}
+}
+
+// GetUsersByIDs loads the users embedded by include=user, keyed by ID.
+func (s *ContactService) GetUsersByIDs(ctx context.Context, ids []uint) (map[uint]dtos.UserListDTO, error) {
+	return s.repo.GetUsersByIDs(ctx, ids)
+}
 
-	if err := tx.Commit().Error; err != nil {
+func (s *ContactService) CreateContact(ctx context.Context, contact *models.Contact) (*models.Contact, error) {
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Create contact
+		return s.repo.CreateContact(ctx, contact)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -77,61 +113,176 @@ func (s *ContactService) GetContactByID(ctx context.Context, params *dtos.GetCon
 }
 
 func (s *ContactService) UpdateContact(ctx context.Context, contact *models.Contact) (*models.Contact, error) {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
-		return nil, err
-	}
-
-	// Update contact
-	if err := s.repo.UpdateContact(tx, contact); err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-
-	if err := tx.Commit().Error; err != nil {
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Update contact
+		return s.repo.UpdateContact(ctx, contact)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return contact, nil
 }
 
-func (s *ContactService) DeleteContact(ctx context.Context, id uint) error {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
+func (s *ContactService) DeleteContact(ctx context.Context, id uint, version uint) error {
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Delete contact
+		return s.repo.DeleteContact(ctx, id, version)
+	})
+	if err != nil {
 		return err
 	}
 
-	// Delete contact
-	if err := s.repo.DeleteContact(tx, id); err != nil {
-		tx.Rollback()
-		return err
-	}
+	return nil
+}
 
-	if err := tx.Commit().Error; err != nil {
+func (s *ContactService) RestoreContact(ctx context.Context, id uint, version uint) error {
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Restore contact
+		return s.repo.RestoreContact(ctx, id, version)
+	})
+	if err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (s *ContactService) RestoreContact(ctx context.Context, id uint) error {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
-		return err
+// SetPrimaryContact makes a a contact the primary of its user and type.
+func (s *ContactService) SetPrimaryContact(ctx context.Context, id uint, version uint) error {
+	return s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.repo.SetPrimaryContact(ctx, id, version)
+	})
+}
+
+// RunBulk runs op for the pending items of a bulk request in one transaction,
+// see runBulk.
+func (s *ContactService) RunBulk(ctx context.Context, results []dtos.BulkItemResult, atomic bool, status string, op BulkOp) error {
+	return runBulk(ctx, s.repo, results, atomic, status, op)
+}
+
+// CheckRefNums checks the ref_num written to contacts against the format of
+// their type and returns them normalized. A normalized ref_num is taken when
+// another contact of the user, not in the trash, or an earlier one of checks,
+// is stored as it. invalid holds, by index, why a ref_num is refused.
+func (s *ContactService) CheckRefNums(ctx context.Context, checks []dtos.ContactRefNumCheck) (normalized []string, invalid map[int]string, err error) {
+	var unknown []uint
+	for _, check := range checks {
+		if check.TypeContactID == 0 && check.ID != 0 {
+			unknown = append(unknown, check.ID)
+		}
+	}
+	types, err := s.repo.GetContactTypeIDs(ctx, unknown)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Restore contact
-	if err := s.repo.RestoreContact(tx, id); err != nil {
-		tx.Rollback()
-		return err
+	typeIDs := make([]uint, len(checks))
+	for i, check := range checks {
+		typeIDs[i] = check.TypeContactID
+		if typeIDs[i] == 0 {
+			typeIDs[i] = types[check.ID]
+		}
+	}
+	formats, err := s.contactFormats(ctx, typeIDs)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return err
+	normalized = make([]string, len(checks))
+	invalid = make(map[int]string)
+	var indexes []int
+	var userIDs, ids []uint
+	var values []string
+	seen := make(map[string]bool)
+	for i, check := range checks {
+		value, err := utils.NormalizeContact(formats[typeIDs[i]], check.RefNum)
+		if err != nil {
+			invalid[i] = err.Error()
+			continue
+		}
+		normalized[i] = value
+
+		key := fmt.Sprintf("%d:%s", check.UserID, value)
+		if seen[key] {
+			invalid[i] = "the ref_num has already been taken"
+			continue
+		}
+		seen[key] = true
+		indexes = append(indexes, i)
+		userIDs = append(userIDs, check.UserID)
+		ids = append(ids, check.ID)
+		values = append(values, value)
 	}
 
-	return nil
+	taken, err := s.repo.TakenContactRefNums(ctx, userIDs, values, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, j := range taken {
+		invalid[indexes[j]] = "the ref_num has already been taken"
+	}
+	return normalized, invalid, nil
+}
+
+// RenormalizeContacts normalizes the stored ref_num of every contact, in
+// batches of batchSize, after the format of a type has changed. Contacts no
+// longer in the format of their type keep their ref_num with its whitespace
+// collapsed, and are counted in invalid.
+func (s *ContactService) RenormalizeContacts(ctx context.Context, batchSize int) (updated int, invalid int, err error) {
+	var afterID uint
+	for {
+		contacts, err := s.repo.ListContactRefNums(ctx, afterID, batchSize)
+		if err != nil {
+			return updated, invalid, err
+		}
+		if len(contacts) == 0 {
+			return updated, invalid, nil
+		}
+
+		typeIDs := make([]uint, len(contacts))
+		for i, contact := range contacts {
+			typeIDs[i] = contact.TypeContactID
+		}
+		formats, err := s.contactFormats(ctx, typeIDs)
+		if err != nil {
+			return updated, invalid, err
+		}
+
+		changed := make(map[uint]string)
+		for _, contact := range contacts {
+			value, err := utils.NormalizeContact(formats[contact.TypeContactID], contact.RefNum)
+			if err != nil {
+				invalid++
+				value, _ = utils.NormalizeContact(utils.ContactFormat{}, contact.RefNum)
+			}
+			if contact.NormalizedRefNum == nil || *contact.NormalizedRefNum != value {
+				changed[contact.ID] = value
+			}
+		}
+		if err := s.repo.SetNormalizedRefNums(ctx, changed); err != nil {
+			return updated, invalid, err
+		}
+		updated += len(changed)
+		afterID = contacts[len(contacts)-1].ID
+	}
+}
+
+// contactFormats returns the formats the contact types typeIDs declare, phone
+// numbers defaulting to the configured region. Types without one are left out.
+func (s *ContactService) contactFormats(ctx context.Context, typeIDs []uint) (map[uint]utils.ContactFormat, error) {
+	options, err := s.repo.GetContactTypeFormats(ctx, typeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	formats := make(map[uint]utils.ContactFormat, len(options))
+	for id, optionsJSON := range options {
+		format := utils.ParseContactFormat(&optionsJSON)
+		if format.Format == utils.ContactFormatPhone && format.DefaultRegion == "" {
+			format.DefaultRegion = config.GetDefaultPhoneRegion()
+		}
+		formats[id] = format
+	}
+	return formats, nil
 }
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hibiken/asynq"
+	"github.com/nibroos/nb-go-api/service/internal/config"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/tasks"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+// exportProgressRows is how often a running export job records its progress.
+const exportProgressRows = 10000
+
+// exportQuery builds the query of an index-* endpoint for an export.
+type exportQuery func(filters map[string]string) (*repository.ListQuery, error)
+
+type ExportService struct {
+	repo    *repository.ExportRepository
+	queries map[string]exportQuery
+	queue   tasks.Enqueuer
+}
+
+func NewExportService(repo *repository.ExportRepository, users repository.UserRepository, contacts *repository.ContactRepository, addresses *repository.AddressRepository, identifiers *repository.IdentifierRepository, queue tasks.Enqueuer) *ExportService {
+	return &ExportService{
+		repo: repo,
+		queries: map[string]exportQuery{
+			utils.ExportEntityUsers:       users.ExportUsers,
+			utils.ExportEntityContacts:    contacts.ExportContacts,
+			utils.ExportEntityAddresses:   addresses.ExportAddresses,
+			utils.ExportEntityIdentifiers: identifiers.ExportIdentifiers,
+		},
+		queue: queue,
+	}
+}
+
+// PrepareExport builds the export of entity for the filters of its index-*
+// endpoint and counts its rows, failing on invalid filters before anything
+// is written.
+func (s *ExportService) PrepareExport(ctx context.Context, entity string, filters map[string]string) (*repository.ListQuery, int, error) {
+	list, err := s.queries[entity](filters)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.repo.CountExport(ctx, list)
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// StreamExport writes the rows of a prepared export to w.
+func (s *ExportService) StreamExport(ctx context.Context, list *repository.ListQuery, w utils.ExportWriter) (int, error) {
+	return s.repo.StreamExport(ctx, list, w)
+}
+
+// QueueExport keeps an export of userID as a job for the worker to write to a
+// file. filters are the ones the export was prepared with.
+func (s *ExportService) QueueExport(ctx context.Context, userID uint, entity string, format string, filters map[string]string, total int) (*dtos.ExportJobDTO, error) {
+	rawFilters, err := json.Marshal(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	job := models.ExportJob{
+		Entity:      entity,
+		Format:      format,
+		Filters:     string(rawFilters),
+		Status:      utils.ExportStatusQueued,
+		TotalRows:   total,
+		CreatedByID: &userID,
+	}
+
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.repo.CreateExportJob(ctx, &job)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.queue.EnqueueContext(ctx, tasks.NewExportRunTask(job.ID), asynq.Queue("low")); err != nil {
+		failure := fmt.Sprintf("could not be queued: %v", err)
+		if failErr := s.repo.FailExportJob(ctx, job.ID, failure); failErr != nil {
+			return nil, errors.Join(err, failErr)
+		}
+		return nil, err
+	}
+
+	return s.GetExportJob(ctx, job.ID, userID)
+}
+
+// GetExportJob returns an export job of userID and how far it got.
+func (s *ExportService) GetExportJob(ctx context.Context, id uint, userID uint) (*dtos.ExportJobDTO, error) {
+	job, err := s.repo.GetExportJob(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.TotalRows > 0 {
+		job.Progress = float64(job.ProcessedRows) * 100 / float64(job.TotalRows)
+	} else if job.Status == utils.ExportStatusCompleted {
+		job.Progress = 100
+	}
+	return job, nil
+}
+
+// GetExportFile returns the path of the file of a completed export job of
+// userID.
+func (s *ExportService) GetExportFile(ctx context.Context, id uint, userID uint) (string, error) {
+	fileName, err := s.repo.GetExportFile(ctx, id, userID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config.GetExportDir(), fileName), nil
+}
+
+// HandleExportRunTask writes a queued export job to a file of EXPORT_DIR. The
+// rows are the ones matching its filters when it runs.
+func (s *ExportService) HandleExportRunTask(ctx context.Context, t *asynq.Task) error {
+	var payload struct {
+		ExportJobID uint `json:"export_job_id"`
+	}
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return err
+	}
+
+	job, err := s.repo.StartExportJob(ctx, payload.ExportJobID)
+	if err != nil {
+		return fmt.Errorf("export %d: %w", payload.ExportJobID, err)
+	}
+
+	if err := s.run(ctx, job); err != nil {
+		if failErr := s.repo.FailExportJob(ctx, job.ID, err.Error()); failErr != nil {
+			return errors.Join(err, failErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *ExportService) run(ctx context.Context, job *models.ExportJob) error {
+	var filters map[string]string
+	if err := json.Unmarshal([]byte(job.Filters), &filters); err != nil {
+		return err
+	}
+
+	query, ok := s.queries[job.Entity]
+	if !ok {
+		return fmt.Errorf("unknown export entity %q", job.Entity)
+	}
+	list, err := query(filters)
+	if err != nil {
+		return err
+	}
+
+	dir := config.GetExportDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("export-%d.%s", job.ID, job.Format)
+	path := filepath.Join(dir, fileName)
+
+	// written aside and renamed, a download never sees a partial file
+	file, err := os.CreateTemp(dir, fileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	w := &progressExportWriter{ExportWriter: utils.NewExportWriter(job.Format, file), progress: func(rows int) error {
+		return s.repo.UpdateExportProgress(ctx, job.ID, rows)
+	}}
+	rows, err := s.repo.StreamExport(ctx, list, w)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+
+	return s.repo.CompleteExportJob(ctx, job.ID, rows, fileName, info.Size())
+}
+
+// progressExportWriter reports every exportProgressRows rows written.
+type progressExportWriter struct {
+	utils.ExportWriter
+	rows     int
+	progress func(rows int) error
+}
+
+func (w *progressExportWriter) WriteRow(values []interface{}) error {
+	if err := w.ExportWriter.WriteRow(values); err != nil {
+		return err
+	}
+	w.rows++
+	if w.rows%exportProgressRows == 0 {
+		return w.progress(w.rows)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+
+	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/storage"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+// FileService keeps the distinct file contents the attachments and avatars
+// reference, each stored once.
+type FileService struct {
+	repo    *repository.FileRepository
+	storage storage.Storage
+}
+
+func NewFileService(repo *repository.FileRepository, storage storage.Storage) *FileService {
+	return &FileService{repo: repo, storage: storage}
+}
+
+// SaveFile returns the file of content data, storing and recording it as of
+// contentType when there is none. It is stored before it is recorded, so a
+// copy left by a failure is never referenced, and must not be called in a
+// unit of work, which may be retried.
+func (s *FileService) SaveFile(ctx context.Context, data []byte, contentType string) (*models.File, error) {
+	sum := sha256.Sum256(data)
+	file := models.File{
+		SHA256:      hex.EncodeToString(sum[:]),
+		Size:        int64(len(data)),
+		ContentType: contentType,
+	}
+
+	existing, err := s.repo.GetFileBySHA256(ctx, file.SHA256)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	file.StorageKey = utils.FileStorageKey(file.SHA256, hex.EncodeToString(suffix))
+
+	if err := s.storage.Put(ctx, file.StorageKey, bytes.NewReader(data), file.Size, file.ContentType); err != nil {
+		return nil, err
+	}
+
+	created, err := s.repo.CreateFile(ctx, &file)
+	if err == nil && created {
+		return &file, nil
+	}
+
+	// an upload of the same content recorded its copy first
+	if err := s.storage.Delete(ctx, file.StorageKey); err != nil {
+		log.Printf("Failed to delete unrecorded file %s: %v", file.StorageKey, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetFileBySHA256(ctx, file.SHA256)
+}
+
+// LockFile keeps file id from being purged until the end of the unit of work
+// of ctx, in which it gets referenced.
+func (s *FileService) LockFile(ctx context.Context, id uint) error {
+	return s.repo.LockFile(ctx, id)
+}
+
+// ReadFile returns the content of file id.
+func (s *FileService) ReadFile(ctx context.Context, id uint) (*models.File, []byte, error) {
+	file, err := s.repo.GetFileByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := s.storage.Open(ctx, file.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	var data bytes.Buffer
+	if _, err := data.ReadFrom(r); err != nil {
+		return nil, nil, err
+	}
+	return file, data.Bytes(), nil
+}
+
+// PurgeOrphanFiles deletes the files nothing references anymore, in batches
+// of batchSize, and returns how many it deleted. A file is removed from the
+// storage once its record is gone, so nothing can reference a removed file.
+func (s *FileService) PurgeOrphanFiles(ctx context.Context, batchSize int) (purged int, err error) {
+	var afterID uint
+	for {
+		files, err := s.repo.ListOrphanFiles(ctx, afterID, batchSize)
+		if err != nil {
+			return purged, err
+		}
+		if len(files) == 0 {
+			return purged, nil
+		}
+
+		for _, file := range files {
+			deleted, err := s.repo.DeleteOrphanFile(ctx, file.ID)
+			if err != nil {
+				return purged, err
+			}
+			if !deleted {
+				continue
+			}
+			if err := s.storage.Delete(ctx, file.StorageKey); err != nil {
+				log.Printf("Failed to delete stored file %s: %v", file.StorageKey, err)
+			}
+			purged++
+		}
+		afterID = files[len(files)-1].ID
+	}
+}
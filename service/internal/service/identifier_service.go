@@ -2,10 +2,17 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/hibiken/asynq"
 
 	"github.com/nibroos/nb-go-api/service/internal/dtos"
 	"github.com/nibroos/nb-go-api/service/internal/models"
 	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/tasks"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
 )
 
 type IdentifierService struct {
@@ -33,20 +40,57 @@ func (s *IdentifierService) ListIdentifiers(ctx context.Context, filters map[str
 	}
 }
 
-func (s *IdentifierService) CreateIdentifier(ctx context.Context, identifier *models.Identifier) (*models.Identifier, error) {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
-		return nil, err
+// ListTrashedIdentifiers lists the soft deleted identifiers.
+func (s *IdentifierService) ListTrashedIdentifiers(ctx context.Context, filters map[string]string) ([]dtos.IdentifierListDTO, int, error) {
+
+	resultChan := make(chan dtos.ListIdentifiersResult, 1)
+
+	go func() {
+		identifiers, total, err := s.repo.ListTrashedIdentifiers(ctx, filters)
+		resultChan <- dtos.ListIdentifiersResult{Identifiers: identifiers, Total: total, Err: err}
+	}()
+
+	select {
+	case res := <-resultChan:
+		return res.Identifiers, res.Total, res.Err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
 	}
+}
 
-	// Create identifier
-	if err := s.repo.CreateIdentifier(tx, identifier); err != nil {
-		tx.Rollback()
-		return nil, err
+// GetIdentifierHistory lists the versions of a identifier.
+func (s *IdentifierService) GetIdentifierHistory(ctx context.Context, id uint, filters map[string]string) ([]dtos.HistoryDTO, int, error) {
+
+	resultChan := make(chan dtos.ListHistoryResult, 1)
+
+	go func() {
+		history, total, err := s.repo.GetIdentifierHistory(ctx, id, filters)
+		resultChan <- dtos.ListHistoryResult{History: history, Total: total, Err: err}
+	}()
+
+	select {
+	case res := <-resultChan:
+		return res.History, res.Total, res.Err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
 	}
+}
+
+// GetUsersByIDs loads the users embedded by include=user, keyed by ID.
+func (s *IdentifierService) GetUsersByIDs(ctx context.Context, ids []uint) (map[uint]dtos.UserListDTO, error) {
+	return s.repo.GetUsersByIDs(ctx, ids)
+}
+
+// CreateIdentifier creates identifier as a draft.
+func (s *IdentifierService) CreateIdentifier(ctx context.Context, identifier *models.Identifier) (*models.Identifier, error) {
+	identifier.Status = utils.IdentifierStatusDraft
+	identifier.StatusReason = nil
 
-	if err := tx.Commit().Error; err != nil {
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Create identifier
+		return s.repo.CreateIdentifier(ctx, identifier)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -76,64 +120,98 @@ func (s *IdentifierService) GetIdentifierByID(ctx context.Context, params *dtos.
 	}
 }
 
+// UpdateIdentifier updates identifier in place, keeping its status. Submitted
+// and verified identifiers are locked, see ReverifyIdentifier.
 func (s *IdentifierService) UpdateIdentifier(ctx context.Context, identifier *models.Identifier) (*models.Identifier, error) {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
-		return nil, err
-	}
-
-	// Update identifier
-	if err := s.repo.UpdateIdentifier(tx, identifier); err != nil {
-		tx.Rollback()
-		return nil, err
-	}
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		status, err := s.repo.GetIdentifierStatus(ctx, identifier.ID)
+		if err != nil {
+			return err
+		}
+		if !utils.IdentifierEditable(status) {
+			return utils.ErrIdentifierLocked
+		}
+		identifier.Status = status
 
-	if err := tx.Commit().Error; err != nil {
+		// Update identifier
+		return s.repo.UpdateIdentifier(ctx, identifier)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return identifier, nil
 }
 
-func (s *IdentifierService) DeleteIdentifier(ctx context.Context, id uint) error {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
+func (s *IdentifierService) DeleteIdentifier(ctx context.Context, id uint, version uint) error {
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Delete identifier
+		return s.repo.DeleteIdentifier(ctx, id, version)
+	})
+	if err != nil {
 		return err
 	}
 
-	// Delete identifier
-	if err := s.repo.DeleteIdentifier(tx, id); err != nil {
-		tx.Rollback()
-		return err
-	}
+	return nil
+}
 
-	if err := tx.Commit().Error; err != nil {
+func (s *IdentifierService) RestoreIdentifier(ctx context.Context, id uint, version uint) error {
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Restore identifier
+		return s.repo.RestoreIdentifier(ctx, id, version)
+	})
+	if err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (s *IdentifierService) RestoreIdentifier(ctx context.Context, id uint) error {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
-		return err
-	}
+// TransitionIdentifier moves an identifier, expected at version, to status
+// to with reason, if its current status allows it, see
+// utils.CanTransitionIdentifier.
+func (s *IdentifierService) TransitionIdentifier(ctx context.Context, id uint, version uint, to string, reason *string) error {
+	return s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.transitionIdentifier(ctx, id, version, to, reason)
+	})
+}
 
-	// Restore identifier
-	if err := s.repo.RestoreIdentifier(tx, id); err != nil {
-		tx.Rollback()
-		return err
+// ReverifyIdentifier writes the changes of identifier and submits it for
+// review again, the way to change a verified identifier. The transition comes
+// first, so no version holds the changes as verified.
+func (s *IdentifierService) ReverifyIdentifier(ctx context.Context, identifier *models.Identifier) (*models.Identifier, error) {
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.transitionIdentifier(ctx, identifier.ID, identifier.Version, utils.IdentifierStatusSubmitted, nil); err != nil {
+			return err
+		}
+		identifier.Version++
+		identifier.Status = utils.IdentifierStatusSubmitted
+		identifier.StatusReason = nil
+
+		return s.repo.UpdateIdentifier(ctx, identifier)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := tx.Commit().Error; err != nil {
+	return identifier, nil
+}
+
+// ListIdentifierTransitions lists the status transitions of an identifier,
+// limited to the identifiers of userID unless it is 0.
+func (s *IdentifierService) ListIdentifierTransitions(ctx context.Context, id uint, userID uint, filters map[string]string) ([]dtos.IdentifierTransitionDTO, int, error) {
+	return s.repo.ListIdentifierTransitions(ctx, id, userID, filters)
+}
+
+func (s *IdentifierService) transitionIdentifier(ctx context.Context, id uint, version uint, to string, reason *string) error {
+	status, err := s.repo.GetIdentifierStatus(ctx, id)
+	if err != nil {
 		return err
 	}
-
-	return nil
+	if !utils.CanTransitionIdentifier(status, to) {
+		return utils.ErrIdentifierTransition
+	}
+	return s.repo.TransitionIdentifier(ctx, id, version, status, to, reason)
 }
 
 func (s *IdentifierService) ListIdentifiersByAuthUser(ctx context.Context, filters map[string]string) ([]dtos.IdentifierListDTO, int, error) {
@@ -151,3 +229,162 @@ func (s *IdentifierService) ListIdentifiersByAuthUser(ctx context.Context, filte
 		return nil, 0, ctx.Err()
 	}
 }
+
+// RunBulk runs op for the pending items of a bulk request in one transaction,
+// see runBulk.
+func (s *IdentifierService) RunBulk(ctx context.Context, results []dtos.BulkItemResult, atomic bool, status string, op BulkOp) error {
+	return runBulk(ctx, s.repo, results, atomic, status, op)
+}
+
+// CheckRefNums checks the ref_num written to identifiers against the
+// validators of their type. invalid holds, by index, why a ref_num is refused.
+func (s *IdentifierService) CheckRefNums(ctx context.Context, checks []dtos.IdentifierRefNumCheck) (invalid map[int]string, err error) {
+	var unknown []uint
+	for _, check := range checks {
+		if check.TypeIdentifierID == 0 && check.ID != 0 {
+			unknown = append(unknown, check.ID)
+		}
+	}
+	types, err := s.repo.GetIdentifierTypeIDs(ctx, unknown)
+	if err != nil {
+		return nil, err
+	}
+
+	typeIDs := make([]uint, len(checks))
+	for i, check := range checks {
+		typeIDs[i] = check.TypeIdentifierID
+		if typeIDs[i] == 0 {
+			typeIDs[i] = types[check.ID]
+		}
+	}
+	validators, err := s.identifierValidators(ctx, typeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	invalid = make(map[int]string)
+	for i, check := range checks {
+		if err := utils.ValidateIdentifier(validators[typeIDs[i]], check.RefNum); err != nil {
+			invalid[i] = err.Error()
+		}
+	}
+	return invalid, nil
+}
+
+// FindInvalidIdentifiers checks the ref_num of every identifier not in the
+// trash against the validators of its type, in batches of batchSize, and
+// returns the ones failing them with the number checked.
+func (s *IdentifierService) FindInvalidIdentifiers(ctx context.Context, batchSize int) (invalid []dtos.InvalidIdentifierDTO, checked int, err error) {
+	invalid = []dtos.InvalidIdentifierDTO{}
+
+	var afterID uint
+	for {
+		identifiers, err := s.repo.ListIdentifierRefNums(ctx, afterID, batchSize)
+		if err != nil {
+			return invalid, checked, err
+		}
+		if len(identifiers) == 0 {
+			return invalid, checked, nil
+		}
+
+		typeIDs := make([]uint, len(identifiers))
+		for i, identifier := range identifiers {
+			typeIDs[i] = identifier.TypeIdentifierID
+		}
+		validators, err := s.identifierValidators(ctx, typeIDs)
+		if err != nil {
+			return invalid, checked, err
+		}
+
+		for _, identifier := range identifiers {
+			if err := utils.ValidateIdentifier(validators[identifier.TypeIdentifierID], identifier.RefNum); err != nil {
+				invalid = append(invalid, dtos.InvalidIdentifierDTO{
+					ID:                 identifier.ID,
+					UserID:             identifier.UserID,
+					TypeIdentifierID:   identifier.TypeIdentifierID,
+					TypeIdentifierName: identifier.TypeIdentifierName,
+					Error:              err.Error(),
+				})
+			}
+		}
+		checked += len(identifiers)
+		afterID = identifiers[len(identifiers)-1].ID
+	}
+}
+
+// RemindExpiringIdentifiers enqueues the expiry reminders due for the
+// identifiers expiring within one of days, see
+// IdentifierRepository.ListDueIdentifierReminders, in batches of batchSize. It
+// returns how many it enqueued; one failing to enqueue is due again next run.
+func (s *IdentifierService) RemindExpiringIdentifiers(ctx context.Context, queue tasks.Enqueuer, days []int, batchSize int) (reminded int, err error) {
+	var afterID uint
+	for {
+		reminders, err := s.repo.ListDueIdentifierReminders(ctx, days, afterID, batchSize)
+		if err != nil {
+			return reminded, err
+		}
+		if len(reminders) == 0 {
+			return reminded, nil
+		}
+
+		for _, reminder := range reminders {
+			task := tasks.NewIdentifierExpiryReminderTask(int(reminder.UserID), int(reminder.IdentifierID), reminder.ExpiresAt, reminder.DaysBefore)
+			if _, err := queue.EnqueueContext(ctx, task, asynq.Queue("default")); err != nil {
+				return reminded, err
+			}
+			if err := s.repo.RecordIdentifierReminder(ctx, reminder); err != nil {
+				return reminded, err
+			}
+			reminded++
+		}
+		afterID = reminders[len(reminders)-1].IdentifierID
+	}
+}
+
+// ExpireIdentifiers moves the verified identifiers whose expiry date has
+// passed to expired, in batches of batchSize, and returns how many it moved.
+func (s *IdentifierService) ExpireIdentifiers(ctx context.Context, batchSize int) (expired int, err error) {
+	var afterID uint
+	for {
+		identifiers, err := s.repo.ListExpiredIdentifiers(ctx, afterID, batchSize)
+		if err != nil {
+			return expired, err
+		}
+		if len(identifiers) == 0 {
+			return expired, nil
+		}
+
+		for _, identifier := range identifiers {
+			reason := fmt.Sprintf("expired on %s", identifier.ExpiresAt)
+			err := s.TransitionIdentifier(ctx, identifier.ID, identifier.Version, utils.IdentifierStatusExpired, &reason)
+			switch {
+			case err == nil:
+				expired++
+			case errors.Is(err, utils.ErrVersionConflict), errors.Is(err, utils.ErrIdentifierTransition), errors.Is(err, sql.ErrNoRows):
+				// changed or deleted since it was listed
+			default:
+				return expired, err
+			}
+		}
+		afterID = identifiers[len(identifiers)-1].ID
+	}
+}
+
+// identifierValidators returns the validators the identifier types typeIDs
+// declare, keyed by type. Types without any are left out; a type whose
+// validators cannot be built is an error.
+func (s *IdentifierService) identifierValidators(ctx context.Context, typeIDs []uint) (map[uint][]utils.IdentifierValidator, error) {
+	options, err := s.repo.GetIdentifierTypeValidators(ctx, typeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	validators := make(map[uint][]utils.IdentifierValidator, len(options))
+	for id, optionsJSON := range options {
+		validators[id], err = utils.ParseIdentifierValidators(&optionsJSON)
+		if err != nil {
+			return nil, fmt.Errorf("identifier type %d: %w", id, err)
+		}
+	}
+	return validators, nil
+}
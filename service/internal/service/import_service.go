@@ -0,0 +1,517 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/nibroos/nb-go-api/service/internal/config"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/tasks"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/nibroos/nb-go-api/service/internal/validators/form_requests"
+)
+
+var (
+	// ErrInvalidImport is returned for an upload that cannot be read as an
+	// import of its entity.
+	ErrInvalidImport = errors.New("invalid import")
+
+	// ErrImportTooLarge is returned for an upload with more than
+	// IMPORT_MAX_ROWS data rows.
+	ErrImportTooLarge = errors.New("too many rows")
+)
+
+// importFields are the fields the columns of an import map to, by entity,
+// with the aliases a header may use for them. role_ids takes role names or
+// IDs separated by commas, type_contact_id a contact type name or ID, and
+// user_email stands for the user_id of the user with that email.
+var importFields = map[string]map[string][]string{
+	utils.ImportEntityUsers: {
+		"name":     nil,
+		"username": nil,
+		"email":    nil,
+		"address":  nil,
+		"password": nil,
+		"role_ids": {"roles", "role"},
+	},
+	utils.ImportEntityContacts: {
+		"user_id":         nil,
+		"user_email":      {"email"},
+		"type_contact_id": {"type", "contact_type", "type_contact"},
+		"ref_num":         {"contact", "value"},
+		"status":          nil,
+	},
+}
+
+// importRow is a data row of an import and the request it maps to.
+type importRow struct {
+	line    int
+	user    dtos.CreateUserRequest
+	contact dtos.CreateContactRequest
+	refNum  string            // how the ref_num of contact is stored
+	errors  map[string]string // cells that could not be read
+}
+
+type ImportService struct {
+	repo     *repository.ImportRepository
+	users    *UserService
+	contacts *ContactService
+	queue    tasks.Enqueuer
+}
+
+func NewImportService(repo *repository.ImportRepository, users *UserService, contacts *ContactService, queue tasks.Enqueuer) *ImportService {
+	return &ImportService{repo: repo, users: users, contacts: contacts, queue: queue}
+}
+
+// UploadImport validates a spreadsheet as a dry run and keeps it as an import
+// job, with the report of the rows that would fail.
+func (s *ImportService) UploadImport(ctx context.Context, entity string, fileName string, data []byte, mapping map[string]string) (*dtos.ImportJobDTO, error) {
+	if _, ok := importFields[entity]; !ok {
+		return nil, fmt.Errorf("%w: entity must be %s or %s", ErrInvalidImport, utils.ImportEntityUsers, utils.ImportEntityContacts)
+	}
+
+	rows, report, err := s.dryRun(ctx, entity, fileName, data, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	rawMapping, err := json.Marshal(mapping)
+	if err != nil {
+		return nil, err
+	}
+	rawReport, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+
+	job := models.ImportJob{
+		Entity:      entity,
+		FileName:    fileName,
+		File:        data,
+		Mapping:     string(rawMapping),
+		Status:      utils.ImportStatusValidated,
+		TotalRows:   len(rows),
+		InvalidRows: countInvalid(rows),
+		Report:      string(rawReport),
+	}
+
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.repo.CreateImportJob(ctx, &job)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetImportJob(ctx, job.ID)
+}
+
+// GetImportJob returns an import job and the progress of its commit.
+func (s *ImportService) GetImportJob(ctx context.Context, id uint) (*dtos.ImportJobDTO, error) {
+	job, err := s.repo.GetImportJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.TotalRows > 0 {
+		job.Progress = float64(job.ProcessedRows) * 100 / float64(job.TotalRows)
+	}
+	return job, nil
+}
+
+// GetImportReport returns the per row errors of the dry run or, once
+// committed, of the commit of an import job.
+func (s *ImportService) GetImportReport(ctx context.Context, id uint) ([]dtos.ImportRowError, error) {
+	return s.repo.GetImportReport(ctx, id)
+}
+
+// CommitImport queues a validated import job for the worker.
+func (s *ImportService) CommitImport(ctx context.Context, id uint) (*dtos.ImportJobDTO, error) {
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.repo.SetImportStatus(ctx, id, utils.ImportStatusQueued, utils.ImportStatusValidated)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.queue.EnqueueContext(ctx, tasks.NewImportCommitTask(id), asynq.Queue("default")); err != nil {
+		// leave the job committable
+		if statusErr := s.repo.SetImportStatus(ctx, id, utils.ImportStatusValidated, utils.ImportStatusQueued); statusErr != nil {
+			return nil, errors.Join(err, statusErr)
+		}
+		return nil, err
+	}
+
+	return s.GetImportJob(ctx, id)
+}
+
+// HandleImportCommitTask writes the rows of a queued import job, BULK_MAX_ITEMS
+// rows per transaction, recording the progress after each. The rows are
+// validated again first: the data may have changed since the dry run. Rows
+// failing are reported, the others are written regardless.
+func (s *ImportService) HandleImportCommitTask(ctx context.Context, t *asynq.Task) error {
+	var payload struct {
+		ImportJobID uint `json:"import_job_id"`
+	}
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return err
+	}
+
+	job, err := s.repo.GetImportFile(ctx, payload.ImportJobID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.StartImportJob(ctx, job.ID); err != nil {
+		return fmt.Errorf("import %d: %w", job.ID, err)
+	}
+
+	if err := s.commit(ctx, job); err != nil {
+		failure := err.Error()
+		if finishErr := s.repo.FinishImportJob(ctx, job.ID, utils.ImportStatusFailed, job.TotalRows, nil, &failure); finishErr != nil {
+			return errors.Join(err, finishErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *ImportService) commit(ctx context.Context, job *models.ImportJob) error {
+	// the writes are attributed to whoever uploaded the file
+	ctx = utils.WithActor(ctx, utils.Actor{UserID: job.CreatedByID, RequestID: fmt.Sprintf("import-%d", job.ID)})
+
+	var mapping map[string]string
+	if err := json.Unmarshal([]byte(job.Mapping), &mapping); err != nil {
+		return err
+	}
+
+	rows, report, err := s.dryRun(ctx, job.Entity, job.FileName, job.File, mapping)
+	if err != nil {
+		return err
+	}
+
+	run := s.users.RunBulk
+	if job.Entity == utils.ImportEntityContacts {
+		run = s.contacts.RunBulk
+	}
+
+	processed, imported, failed := 0, 0, 0
+	chunkSize := config.GetBulkMaxItems()
+	for start := 0; start < len(rows); start += chunkSize {
+		chunk := rows[start:min(start+chunkSize, len(rows))]
+
+		results := make([]dtos.BulkItemResult, len(chunk))
+		for i, row := range chunk {
+			results[i].Index = i
+			if len(row.errors) > 0 {
+				results[i].Status = utils.BulkStatusFailed
+			}
+		}
+
+		if err := run(ctx, results, false, utils.BulkStatusCreated, func(ctx context.Context, i int) (uint, uint, error) {
+			return s.importRow(ctx, job.Entity, &chunk[i])
+		}); err != nil {
+			return err
+		}
+
+		for i, result := range results {
+			processed++
+			if result.Status == utils.BulkStatusCreated {
+				imported++
+				continue
+			}
+			failed++
+			if len(chunk[i].errors) == 0 {
+				report = append(report, rowErrors(chunk[i].line, result.Errors)...)
+			}
+		}
+
+		if err := s.repo.UpdateImportProgress(ctx, job.ID, processed, imported, failed); err != nil {
+			return err
+		}
+	}
+
+	sort.SliceStable(report, func(i, j int) bool { return report[i].Row < report[j].Row })
+
+	return s.repo.FinishImportJob(ctx, job.ID, utils.ImportStatusCompleted, len(rows), report, nil)
+}
+
+// importRow writes a validated row.
+func (s *ImportService) importRow(ctx context.Context, entity string, row *importRow) (uint, uint, error) {
+	if entity == utils.ImportEntityContacts {
+		createdAt := time.Now()
+		contact := models.Contact{
+			TypeContactID: row.contact.TypeContactID,
+			UserID:        row.contact.UserID,
+			RefNum:        row.contact.RefNum,
+			Status:        row.contact.Status,
+			CreatedAt:     &createdAt,
+		}
+		contact.NormalizedRefNum = &row.refNum
+
+		createdContact, err := s.contacts.CreateContact(ctx, &contact)
+		if err != nil {
+			return 0, 0, err
+		}
+		return createdContact.ID, createdContact.Version, nil
+	}
+
+	// CreateUser hashes the password
+	user := models.User{
+		Name:     row.user.Name,
+		Username: row.user.Username,
+		Email:    row.user.Email,
+		Password: row.user.Password,
+		Address:  row.user.Address,
+	}
+
+	createdUser, err := s.users.CreateUser(ctx, &user, row.user.RoleIDs)
+	if err != nil {
+		return 0, 0, err
+	}
+	return createdUser.ID, createdUser.Version, nil
+}
+
+// dryRun reads the data rows of an import file, maps them to requests and
+// validates them as their bulk endpoint would. The rows failing keep their
+// errors, which the report lists.
+func (s *ImportService) dryRun(ctx context.Context, entity string, fileName string, data []byte, mapping map[string]string) ([]importRow, []dtos.ImportRowError, error) {
+	sheet, err := utils.ReadSpreadsheet(fileName, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if len(sheet) == 0 {
+		return nil, nil, fmt.Errorf("%w: the file has no header row", ErrInvalidImport)
+	}
+
+	columns, err := utils.MapColumns(sheet[0], mapping, importFields[entity])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	maxRows := config.GetImportMaxRows()
+	var rows []importRow
+	for i, cells := range sheet[1:] {
+		if strings.Join(cells, "") == "" {
+			continue
+		}
+		rows = append(rows, importRow{line: i + 2, errors: map[string]string{}})
+		if len(rows) > maxRows {
+			return nil, nil, fmt.Errorf("%w: at most %d rows are accepted", ErrImportTooLarge, maxRows)
+		}
+	}
+
+	cell := func(row importRow, field string) string {
+		column, ok := columns[field]
+		if !ok || column >= len(sheet[row.line-1]) {
+			return ""
+		}
+		return sheet[row.line-1][column]
+	}
+
+	var invalid map[int]map[string]string
+	if entity == utils.ImportEntityContacts {
+		invalid, err = s.mapContacts(ctx, rows, cell)
+	} else {
+		invalid, err = s.mapUsers(ctx, rows, cell)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var report []dtos.ImportRowError
+	for i := range rows {
+		// a cell that could not be read explains the validation error of its field
+		for field, message := range invalid[i] {
+			if _, ok := rows[i].errors[field]; !ok {
+				rows[i].errors[field] = message
+			}
+		}
+		report = append(report, rowErrors(rows[i].line, rows[i].errors)...)
+	}
+	if report == nil {
+		report = []dtos.ImportRowError{}
+	}
+
+	return rows, report, nil
+}
+
+func (s *ImportService) mapUsers(ctx context.Context, rows []importRow, cell func(importRow, string) string) (map[int]map[string]string, error) {
+	roles, err := s.repo.MixValueIDs(ctx, "roles")
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]dtos.CreateUserRequest, len(rows))
+	for i := range rows {
+		reqs[i] = dtos.CreateUserRequest{
+			Name:     cell(rows[i], "name"),
+			Username: optionalCell(cell(rows[i], "username")),
+			Email:    cell(rows[i], "email"),
+			Address:  optionalCell(cell(rows[i], "address")),
+			Password: cell(rows[i], "password"),
+		}
+
+		for _, role := range splitCell(cell(rows[i], "role_ids")) {
+			id, ok := resolveID(role, roles)
+			if !ok {
+				rows[i].errors["role_ids"] = fmt.Sprintf("unknown role %q", role)
+				continue
+			}
+			reqs[i].RoleIDs = append(reqs[i].RoleIDs, uint32(id))
+		}
+
+		rows[i].user = reqs[i]
+	}
+
+	return form_requests.NewUserStoreRequest().ValidateBatch(reqs, ctx), nil
+}
+
+func (s *ImportService) mapContacts(ctx context.Context, rows []importRow, cell func(importRow, string) string) (map[int]map[string]string, error) {
+	types, err := s.repo.MixValueIDs(ctx, "contacts")
+	if err != nil {
+		return nil, err
+	}
+
+	var emails []string
+	for _, row := range rows {
+		if email := cell(row, "user_email"); email != "" {
+			emails = append(emails, strings.ToLower(email))
+		}
+	}
+	users, err := s.repo.UserIDsByEmails(ctx, emails)
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]dtos.CreateContactRequest, len(rows))
+	for i := range rows {
+		reqs[i] = dtos.CreateContactRequest{
+			RefNum: cell(rows[i], "ref_num"),
+			Status: 1,
+		}
+
+		if userID := cell(rows[i], "user_id"); userID != "" {
+			id, err := strconv.ParseUint(userID, 10, 32)
+			if err != nil {
+				rows[i].errors["user_id"] = "the user_id must be a number"
+			}
+			reqs[i].UserID = uint(id)
+		} else if email := cell(rows[i], "user_email"); email != "" {
+			id, ok := users[strings.ToLower(email)]
+			if !ok {
+				rows[i].errors["user_id"] = fmt.Sprintf("no user with the email %q", email)
+			}
+			reqs[i].UserID = id
+		}
+
+		if typeName := cell(rows[i], "type_contact_id"); typeName != "" {
+			id, ok := resolveID(typeName, types)
+			if !ok {
+				rows[i].errors["type_contact_id"] = fmt.Sprintf("unknown contact type %q", typeName)
+			}
+			reqs[i].TypeContactID = id
+		}
+
+		// status defaults to active
+		if status := cell(rows[i], "status"); status != "" {
+			value, err := strconv.ParseUint(status, 10, 32)
+			if err != nil {
+				rows[i].errors["status"] = "the status must be a number"
+			}
+			reqs[i].Status = uint(value)
+		}
+
+		rows[i].contact = reqs[i]
+	}
+
+	invalid := form_requests.NewContactStoreRequest().ValidateBatch(reqs, ctx)
+
+	// the rows that passed are checked against the format of their type and
+	// the contacts of their user, the earlier rows of the file included
+	var checks []dtos.ContactRefNumCheck
+	var indexes []int
+	for i, req := range reqs {
+		if _, ok := invalid[i]; !ok && len(rows[i].errors) == 0 {
+			checks = append(checks, dtos.ContactRefNumCheck{UserID: req.UserID, TypeContactID: req.TypeContactID, RefNum: req.RefNum})
+			indexes = append(indexes, i)
+		}
+	}
+	normalized, refNumErrors, err := s.contacts.CheckRefNums(ctx, checks)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range indexes {
+		rows[i].refNum = normalized[j]
+		if message, ok := refNumErrors[j]; ok {
+			rows[i].errors["ref_num"] = message
+		}
+	}
+
+	return invalid, nil
+}
+
+// resolveID reads a cell holding a mix_values ID or name.
+func resolveID(value string, ids map[string]uint) (uint, bool) {
+	if id, err := strconv.ParseUint(value, 10, 32); err == nil {
+		for _, known := range ids {
+			if known == uint(id) {
+				return known, true
+			}
+		}
+		return 0, false
+	}
+
+	id, ok := ids[strings.ToLower(value)]
+	return id, ok
+}
+
+// splitCell reads a cell holding a list separated by commas or semicolons.
+func splitCell(value string) []string {
+	var values []string
+	for _, v := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func optionalCell(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func countInvalid(rows []importRow) int {
+	count := 0
+	for _, row := range rows {
+		if len(row.errors) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// rowErrors lists the errors of a row by field.
+func rowErrors(line int, errs map[string]string) []dtos.ImportRowError {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	report := make([]dtos.ImportRowError, 0, len(fields))
+	for _, field := range fields {
+		report = append(report, dtos.ImportRowError{Row: line, Field: field, Error: errs[field]})
+	}
+	return report
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+type PrivacyService struct {
+	repo      *repository.PrivacyRepository
+	auditRepo *repository.AuditLogRepository
+}
+
+func NewPrivacyService(repo *repository.PrivacyRepository, auditRepo *repository.AuditLogRepository) *PrivacyService {
+	return &PrivacyService{repo: repo, auditRepo: auditRepo}
+}
+
+// GetPersonalData returns everything held about a user.
+func (s *PrivacyService) GetPersonalData(ctx context.Context, userID uint) (*dtos.PersonalDataBundle, error) {
+	bundle, err := s.repo.GetPersonalData(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	bundle.GeneratedAt = time.Now()
+	return bundle, nil
+}
+
+// PersonalDataFiles splits a bundle into the files of its ZIP archive.
+func (s *PrivacyService) PersonalDataFiles(bundle *dtos.PersonalDataBundle) []utils.BundleFile {
+	return []utils.BundleFile{
+		{Name: "user.json", Data: bundle.User},
+		{Name: "roles.json", Data: bundle.Roles},
+		{Name: "contacts.json", Data: bundle.Contacts},
+		{Name: "addresses.json", Data: bundle.Addresses},
+		{Name: "identifiers.json", Data: bundle.Identifiers},
+		{Name: "audit_logs.json", Data: bundle.AuditLogs},
+	}
+}
+
+// EraseUser anonymises the personal data of a user and records the erasure
+// in the audit log, in one transaction. It cannot be undone.
+func (s *PrivacyService) EraseUser(ctx context.Context, userID uint, version *uint, reason string) (*dtos.UserErasureDTO, error) {
+	var erasure *dtos.UserErasureDTO
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		erasure, err = s.repo.EraseUser(ctx, userID, version)
+		if err != nil {
+			return err
+		}
+
+		metadata, err := json.Marshal(map[string]interface{}{
+			"reason":      reason,
+			"contacts":    erasure.Contacts,
+			"addresses":   erasure.Addresses,
+			"identifiers": erasure.Identifiers,
+			"audit_logs":  erasure.AuditLogs,
+		})
+		if err != nil {
+			return err
+		}
+		raw := string(metadata)
+
+		log := models.AuditLog{
+			Entity:   "users",
+			EntityID: &userID,
+			Action:   utils.AuditActionErase,
+			Metadata: &raw,
+		}
+		if actor, ok := utils.ActorFrom(ctx); ok {
+			log.ActorID = actor.UserID
+			if actor.IPAddress != "" {
+				log.IPAddress = &actor.IPAddress
+			}
+			if actor.RequestID != "" {
+				log.RequestID = &actor.RequestID
+			}
+		}
+		return s.auditRepo.CreateAuditLog(ctx, &log)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return erasure, nil
+}
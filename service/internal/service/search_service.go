@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+type SearchService struct {
+	repo *repository.SearchRepository
+}
+
+func NewSearchService(repo *repository.SearchRepository) *SearchService {
+	return &SearchService{repo: repo}
+}
+
+// Search returns the hits of the requested page, the hits per type and the
+// total of hits across the requested types.
+func (s *SearchService) Search(ctx context.Context, params *dtos.SearchParams) ([]dtos.SearchHitDTO, map[string]int, int, error) {
+
+	resultChan := make(chan dtos.SearchResult, 1)
+
+	go func() {
+		hits, facets, err := s.repo.Search(ctx, params)
+		resultChan <- dtos.SearchResult{Hits: hits, Facets: facets, Err: err}
+	}()
+
+	select {
+	case res := <-resultChan:
+		if res.Err != nil {
+			return nil, nil, 0, res.Err
+		}
+
+		// report every type, including the ones without hits
+		for _, t := range utils.SearchTypes {
+			if _, ok := res.Facets[t]; !ok {
+				res.Facets[t] = 0
+			}
+		}
+
+		types := params.Types
+		if len(types) == 0 {
+			types = utils.SearchTypes
+		}
+
+		total := 0
+		for _, t := range types {
+			total += res.Facets[t]
+		}
+
+		return res.Hits, res.Facets, total, nil
+	case <-ctx.Done():
+		return nil, nil, 0, ctx.Err()
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+type TrashService struct {
+	repo      *repository.TrashRepository
+	auditRepo *repository.AuditLogRepository
+}
+
+func NewTrashService(repo *repository.TrashRepository, auditRepo *repository.AuditLogRepository) *TrashService {
+	return &TrashService{repo: repo, auditRepo: auditRepo}
+}
+
+// PurgeTrash hard deletes the rows soft deleted more than retentionDays ago,
+// batchSize rows per transaction, and records every batch in the audit log.
+// It returns the number of purged rows per table, including the tables purged
+// before an error.
+func (s *TrashService) PurgeTrash(ctx context.Context, retentionDays int, batchSize int) (map[string]int, error) {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	purged := make(map[string]int, len(repository.TrashTables))
+
+	for _, table := range repository.TrashTables {
+		for {
+			var ids []uint
+			err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+				var err error
+				ids, err = s.repo.PurgeBatch(ctx, table, cutoff, batchSize)
+				if err != nil || len(ids) == 0 {
+					return err
+				}
+
+				metadata, err := json.Marshal(map[string]interface{}{
+					"ids":            ids,
+					"retention_days": retentionDays,
+					"cutoff":         cutoff,
+				})
+				if err != nil {
+					return err
+				}
+				raw := string(metadata)
+
+				return s.auditRepo.CreateAuditLog(ctx, &models.AuditLog{
+					Entity:   table,
+					Action:   utils.AuditActionPurge,
+					Metadata: &raw,
+				})
+			})
+			if err != nil {
+				return purged, err
+			}
+
+			purged[table] += len(ids)
+			if len(ids) < batchSize {
+				break
+			}
+		}
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/models"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+)
+
+type UserMergeService struct {
+	repo      *repository.UserMergeRepository
+	auditRepo *repository.AuditLogRepository
+}
+
+func NewUserMergeService(repo *repository.UserMergeRepository, auditRepo *repository.AuditLogRepository) *UserMergeService {
+	return &UserMergeService{repo: repo, auditRepo: auditRepo}
+}
+
+func (s *UserMergeService) FindDuplicateUsers(ctx context.Context, filters map[string]string) ([]dtos.DuplicateUserDTO, int, error) {
+
+	resultChan := make(chan dtos.ListDuplicateUsersResult, 1)
+
+	go func() {
+		duplicates, total, err := s.repo.FindDuplicateUsers(ctx, filters)
+		resultChan <- dtos.ListDuplicateUsersResult{Duplicates: duplicates, Total: total, Err: err}
+	}()
+
+	select {
+	case res := <-resultChan:
+		return res.Duplicates, res.Total, res.Err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
+
+// MergeUsers merges user sourceID into user targetID and records the merge
+// in the audit log of the target, in one transaction.
+func (s *UserMergeService) MergeUsers(ctx context.Context, req *dtos.MergeUsersRequest) (*dtos.UserMergeDTO, error) {
+	var merge *dtos.UserMergeDTO
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		merge, err = s.repo.MergeUsers(ctx, req.SourceID, req.TargetID, req.SourceVersion, req.TargetVersion)
+		if err != nil {
+			return err
+		}
+
+		metadata, err := json.Marshal(merge)
+		if err != nil {
+			return err
+		}
+		raw := string(metadata)
+
+		log := models.AuditLog{
+			Entity:   "users",
+			EntityID: &req.TargetID,
+			Action:   utils.AuditActionMerge,
+			Metadata: &raw,
+		}
+		if actor, ok := utils.ActorFrom(ctx); ok {
+			log.ActorID = actor.UserID
+			if actor.IPAddress != "" {
+				log.IPAddress = &actor.IPAddress
+			}
+			if actor.RequestID != "" {
+				log.RequestID = &actor.RequestID
+			}
+		}
+		return s.auditRepo.CreateAuditLog(ctx, &log)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merge, nil
+}
@@ -3,7 +3,6 @@ package service
 import (
 	"context"
 	"errors"
-	"sync"
 
 	"github.com/nibroos/nb-go-api/service/internal/dtos"
 	"github.com/nibroos/nb-go-api/service/internal/models"
@@ -37,6 +36,57 @@ func (s *UserService) GetUsers(ctx context.Context, filters map[string]string) (
 	}
 }
 
+// GetTrashedUsers lists the soft deleted users.
+func (s *UserService) GetTrashedUsers(ctx context.Context, filters map[string]string) ([]dtos.UserListDTO, int, error) {
+
+	resultChan := make(chan dtos.GetUsersResult, 1)
+
+	go func() {
+		users, total, err := s.repo.GetTrashedUsers(ctx, filters)
+		resultChan <- dtos.GetUsersResult{Users: users, Total: total, Err: err}
+	}()
+
+	select {
+	case res := <-resultChan:
+		return res.Users, res.Total, res.Err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
+
+// GetUserHistory lists the versions of a user.
+func (s *UserService) GetUserHistory(ctx context.Context, id uint, filters map[string]string) ([]dtos.HistoryDTO, int, error) {
+
+	resultChan := make(chan dtos.ListHistoryResult, 1)
+
+	go func() {
+		history, total, err := s.repo.GetUserHistory(ctx, id, filters)
+		resultChan <- dtos.ListHistoryResult{History: history, Total: total, Err: err}
+	}()
+
+	select {
+	case res := <-resultChan:
+		return res.History, res.Total, res.Err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
+
+// GetRoleIDsByUserID returns the IDs of the roles attached to a user.
+func (s *UserService) GetRoleIDsByUserID(ctx context.Context, userID uint) ([]uint32, error) {
+	return s.repo.GetRoleIDsByUserID(ctx, userID)
+}
+
+// GetMergedUserID returns the user a user was merged into, if it was.
+func (s *UserService) GetMergedUserID(ctx context.Context, id uint) (uint, bool, error) {
+	return s.repo.GetMergedUserID(ctx, id)
+}
+
+// GetUserIncludes loads the relations requested with `include` for the given users.
+func (s *UserService) GetUserIncludes(ctx context.Context, userIDs []uint, includes []string) (*dtos.UserIncludes, error) {
+	return s.repo.GetUserIncludes(ctx, userIDs, includes)
+}
+
 func (s *UserService) CreateUser(ctx context.Context, user *models.User, roleIDs []uint32) (*models.User, error) {
 	// Hash password before saving
 	if user.Password == "" {
@@ -53,25 +103,16 @@ func (s *UserService) CreateUser(ctx context.Context, user *models.User, roleIDs
 	}
 	user.Password = hashedPassword
 
-	// Begin transaction
-	tx := s.repo.BeginTransaction()
-	if tx == nil {
-		return nil, errors.New("failed to begin transaction")
-	}
-
-	// Create user
-	if err := s.repo.CreateUser(tx, user); err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-
-	// Attach roles
-	if err := s.repo.AttachRoles(tx, user, roleIDs); err != nil {
-		tx.Rollback()
-		return nil, err
-	}
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Create user
+		if err := s.repo.CreateUser(ctx, user); err != nil {
+			return err
+		}
 
-	if err := s.repo.Commit(tx); err != nil {
+		// Attach roles
+		return s.repo.AttachRoles(ctx, user, roleIDs)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -107,25 +148,16 @@ func (s *UserService) UpdateUser(ctx context.Context, user *models.User, roleIDs
 		return nil, errors.New("roleIDs cannot be empty")
 	}
 
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
-		return nil, err
-	}
-
-	// Update user
-	if err := s.repo.UpdateUser(tx, user); err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-
-	// Attach roles
-	if err := s.repo.AttachRoles(tx, user, roleIDs); err != nil {
-		tx.Rollback()
-		return nil, err
-	}
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Update user
+		if err := s.repo.UpdateUser(ctx, user); err != nil {
+			return err
+		}
 
-	if err := s.repo.Commit(tx); err != nil {
+		// Attach roles
+		return s.repo.AttachRoles(ctx, user, roleIDs)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -145,75 +177,36 @@ func (s *UserService) Authenticate(ctx context.Context, email, password string)
 	return user, nil
 }
 
-func (s *UserService) DeleteUser(ctx context.Context, id uint) error {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
-		return err
-	}
-
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	// Channels for concurrent execution
-	deleteRolesChan := make(chan error, 1)
-	deleteUserChan := make(chan error, 1)
-
-	// Goroutine for deleting roles
-	go func() {
-		defer wg.Done()
-		err := s.repo.DeleteRolesByUserID(tx, id)
-		deleteRolesChan <- err
-	}()
-
-	// Goroutine for deleting user
-	go func() {
-		defer wg.Done()
-		err := s.repo.DeleteUser(tx, id)
-		deleteUserChan <- err
-	}()
-
-	// Wait for both goroutines to finish
-	wg.Wait()
-	close(deleteRolesChan)
-	close(deleteUserChan)
-
-	deleteRolesErr := <-deleteRolesChan
-	deleteUserErr := <-deleteUserChan
-
-	if deleteRolesErr != nil {
-		tx.Rollback()
-		return deleteRolesErr
-	}
-
-	if deleteUserErr != nil {
-		tx.Rollback()
-		return deleteUserErr
-	}
+func (s *UserService) DeleteUser(ctx context.Context, id uint, version uint) error {
+	// The roles, the children and the user are deleted one after the other:
+	// the unit of work is a single connection.
+	return s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeleteRolesByUserID(ctx, id); err != nil {
+			return err
+		}
 
-	if err := s.repo.Commit(tx); err != nil {
-		return err
-	}
+		if err := s.repo.DeleteChildrenByUserID(ctx, id); err != nil {
+			return err
+		}
 
-	return nil
+		return s.repo.DeleteUser(ctx, id, version)
+	})
 }
 
-func (s *UserService) RestoreUser(ctx context.Context, id uint) error {
-	// Transaction handling
-	tx := s.repo.BeginTransaction()
-	if err := tx.Error; err != nil {
-		return err
-	}
-
-	// Restore user
-	if err := s.repo.RestoreUser(tx, id); err != nil {
-		tx.Rollback()
-		return err
-	}
+func (s *UserService) RestoreUser(ctx context.Context, id uint, version uint) error {
+	return s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Children deleted together with the user are found by the user's
+		// deleted_at, so they are restored first
+		if err := s.repo.RestoreChildrenByUserID(ctx, id); err != nil {
+			return err
+		}
 
-	if err := tx.Commit().Error; err != nil {
-		return err
-	}
+		return s.repo.RestoreUser(ctx, id, version)
+	})
+}
 
-	return nil
+// RunBulk runs op for the pending items of a bulk request in one transaction,
+// see runBulk.
+func (s *UserService) RunBulk(ctx context.Context, results []dtos.BulkItemResult, atomic bool, status string, op BulkOp) error {
+	return runBulk(ctx, s.repo, results, atomic, status, op)
 }
@@ -0,0 +1,50 @@
+// Package storage keeps the files of the service, such as attachments, on
+// the local filesystem or in an S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/config"
+)
+
+// ErrNotFound is returned when reading a key nothing is stored under.
+var ErrNotFound = errors.New("no file is stored under the key")
+
+// Storage keeps files under keys, slash separated paths.
+type Storage interface {
+	// Put stores the size bytes of r under key as a file of contentType,
+	// replacing any file stored under it.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+
+	// Open reads the file stored under key, ErrNotFound when there is none.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the file stored under key. There being none is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner is a Storage clients can download from directly, through URLs it
+// signs.
+type Presigner interface {
+	// PresignGet returns a URL downloading the file stored under key as
+	// fileName, valid for expiry.
+	PresignGet(ctx context.Context, key string, fileName string, expiry time.Duration) (string, error)
+}
+
+// New returns the Storage STORAGE_DRIVER configures.
+func New() (Storage, error) {
+	switch driver := config.GetStorageDriver(); driver {
+	case "local":
+		return NewLocal(config.GetStorageDir()), nil
+	case "s3":
+		return NewS3(config.GetS3Options())
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q, expected local or s3", driver)
+	}
+}
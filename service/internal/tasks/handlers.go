@@ -47,3 +47,24 @@ func HandleReminderEmailTask(c context.Context, t *asynq.Task) error {
 
 	return nil
 }
+
+// HandleIdentifierExpiryReminderTask for identifier expiry reminder task.
+func HandleIdentifierExpiryReminderTask(c context.Context, t *asynq.Task) error {
+	// Get the user and the identifier expiring from the given task.
+	var payload struct {
+		UserID       int    `json:"user_id"`
+		IdentifierID int    `json:"identifier_id"`
+		ExpiresAt    string `json:"expires_at"`
+		DaysBefore   int    `json:"days_before"`
+	}
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return err
+	}
+
+	// Dummy message to the worker's output.
+	fmt.Printf("Send Identifier Expiry Reminder Email to User ID %d\n", payload.UserID)
+	fmt.Printf("Reason: identifier %d expires on %s, within %d days\n", payload.IdentifierID, payload.ExpiresAt, payload.DaysBefore)
+	log.Printf("Processed identifier expiry reminder task for User ID %d", payload.UserID)
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package tasks
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
@@ -15,8 +16,29 @@ const (
 	// TypeReminderEmail is a name of the task type
 	// for sending a reminder email.
 	TypeReminderEmail = "email:reminder"
+
+	// TypeImportCommit is a name of the task type
+	// for writing the rows of a validated import.
+	TypeImportCommit = "import:commit"
+
+	// TypeExportRun is a name of the task type
+	// for writing an export too large to stream to a file.
+	TypeExportRun = "export:run"
+
+	// TypeIdentifierExpiryReminder is a name of the task type
+	// for reminding a user their identifier expires soon.
+	TypeIdentifierExpiryReminder = "email:identifier_expiry_reminder"
+
+	// TypeAvatarThumbnails is a name of the task type
+	// for generating the thumbnails of an uploaded avatar.
+	TypeAvatarThumbnails = "image:avatar_thumbnails"
 )
 
+// Enqueuer enqueues tasks for the worker, as *asynq.Client does.
+type Enqueuer interface {
+	EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+}
+
 // NewWelcomeEmailTask task payload for a new welcome email.
 func NewWelcomeEmailTask(id int) *asynq.Task {
 	// Specify task payload.
@@ -53,3 +75,81 @@ func NewReminderEmailTask(id int, ts time.Time) *asynq.Task {
 	// Return a new task with given type and payload.
 	return asynq.NewTask(TypeReminderEmail, payloadBytes, asynq.MaxRetry(5), asynq.Timeout(1*time.Minute))
 }
+
+// NewIdentifierExpiryReminderTask task payload for an identifier expiry
+// reminder, daysBefore days or less before expiresAt.
+func NewIdentifierExpiryReminderTask(userID int, identifierID int, expiresAt string, daysBefore int) *asynq.Task {
+	// Specify task payload.
+	payload := map[string]interface{}{
+		"user_id":       userID,       // set user ID
+		"identifier_id": identifierID, // set identifier ID
+		"expires_at":    expiresAt,    // set expiry date
+		"days_before":   daysBefore,   // set days before the expiry date
+	}
+
+	// Marshal the payload to JSON.
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		// Handle error.
+		return nil
+	}
+
+	// Return a new task with given type and payload.
+	return asynq.NewTask(TypeIdentifierExpiryReminder, payloadBytes, asynq.MaxRetry(5), asynq.Timeout(1*time.Minute))
+}
+
+// NewImportCommitTask task payload for committing an import job.
+func NewImportCommitTask(jobID uint) *asynq.Task {
+	// Specify task payload.
+	payload := map[string]interface{}{
+		"import_job_id": jobID, // set import job ID
+	}
+
+	// Marshal the payload to JSON.
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		// Handle error.
+		return nil
+	}
+
+	// Not retried: rows written before a failure would be written twice.
+	return asynq.NewTask(TypeImportCommit, payloadBytes, asynq.MaxRetry(0), asynq.Timeout(30*time.Minute))
+}
+
+// NewExportRunTask task payload for running an export job.
+func NewExportRunTask(jobID uint) *asynq.Task {
+	// Specify task payload.
+	payload := map[string]interface{}{
+		"export_job_id": jobID, // set export job ID
+	}
+
+	// Marshal the payload to JSON.
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		// Handle error.
+		return nil
+	}
+
+	// Not retried: a failed export is reported on the job, asked for again.
+	return asynq.NewTask(TypeExportRun, payloadBytes, asynq.MaxRetry(0), asynq.Timeout(time.Hour))
+}
+
+// NewAvatarThumbnailsTask task payload for generating the thumbnails of the
+// avatar of a user, stored as file fileID.
+func NewAvatarThumbnailsTask(userID uint, fileID uint) *asynq.Task {
+	// Specify task payload.
+	payload := map[string]interface{}{
+		"user_id": userID, // set user ID
+		"file_id": fileID, // set avatar file ID
+	}
+
+	// Marshal the payload to JSON.
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		// Handle error.
+		return nil
+	}
+
+	// Retried: a thumbnail generated twice is stored once.
+	return asynq.NewTask(TypeAvatarThumbnails, payloadBytes, asynq.MaxRetry(5), asynq.Timeout(5*time.Minute))
+}
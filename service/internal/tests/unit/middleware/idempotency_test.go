@@ -0,0 +1,166 @@
+package unit_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/middleware"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryIdempotencyStore is an IdempotencyStore in memory, without expiry.
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[dtos.IdempotencyKey]*dtos.IdempotencyRecord
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[dtos.IdempotencyKey]*dtos.IdempotencyRecord)}
+}
+
+func (s *memoryIdempotencyStore) ClaimIdempotencyKey(ctx context.Context, key dtos.IdempotencyKey, requestHash string, ttl time.Duration, lockTimeout time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[key]; ok {
+		return false, nil
+	}
+	s.records[key] = &dtos.IdempotencyRecord{RequestHash: requestHash, Status: utils.IdempotencyStatusProcessing}
+	return true, nil
+}
+
+func (s *memoryIdempotencyStore) GetIdempotencyKey(ctx context.Context, key dtos.IdempotencyKey) (*dtos.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[key]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *record
+	return &copied, nil
+}
+
+func (s *memoryIdempotencyStore) CompleteIdempotencyKey(ctx context.Context, key dtos.IdempotencyKey, status int, headers map[string]string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[key]
+	record.Status = utils.IdempotencyStatusCompleted
+	record.ResponseStatus = status
+	record.ResponseHeaders = headers
+	record.ResponseBody = append([]byte{}, body...)
+	return nil
+}
+
+func (s *memoryIdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, key dtos.IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func idempotentRequest(t *testing.T, app *fiber.App, key string, body string) (*http.Response, string) {
+	req := httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(utils.HeaderIdempotencyKey, key)
+	}
+
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp, string(raw)
+}
+
+func TestIdempotencyReplaysTheFirstResponse(t *testing.T) {
+	var calls int32
+	app := fiber.New()
+	app.Post("/create", middleware.Idempotency(newMemoryIdempotencyStore()), func(ctx *fiber.Ctx) error {
+		n := atomic.AddInt32(&calls, 1)
+		ctx.Set(fiber.HeaderETag, `"1"`)
+		return ctx.Status(http.StatusCreated).JSON(fiber.Map{"call": n})
+	})
+
+	first, firstBody := idempotentRequest(t, app, "key-1", `{"name":"Alice"}`)
+	assert.Equal(t, http.StatusCreated, first.StatusCode)
+
+	retry, retryBody := idempotentRequest(t, app, "key-1", `{"name":"Alice"}`)
+	assert.Equal(t, http.StatusCreated, retry.StatusCode)
+	assert.Equal(t, firstBody, retryBody)
+	assert.Equal(t, `"1"`, retry.Header.Get(fiber.HeaderETag))
+	assert.Equal(t, "true", retry.Header.Get(utils.HeaderIdempotentReplayed))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	reused, _ := idempotentRequest(t, app, "key-1", `{"name":"Bob"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.StatusCode)
+
+	// without a key, or with another one, the request runs
+	idempotentRequest(t, app, "", `{"name":"Alice"}`)
+	idempotentRequest(t, app, "key-2", `{"name":"Alice"}`)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestIdempotencyDoesNotKeepServerErrors(t *testing.T) {
+	var calls int32
+	app := fiber.New()
+	app.Post("/create", middleware.Idempotency(newMemoryIdempotencyStore()), func(ctx *fiber.Ctx) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "failed"})
+		}
+		return ctx.Status(http.StatusCreated).JSON(fiber.Map{"message": "created"})
+	})
+
+	first, _ := idempotentRequest(t, app, "key-1", `{}`)
+	assert.Equal(t, http.StatusInternalServerError, first.StatusCode)
+
+	retry, _ := idempotentRequest(t, app, "key-1", `{}`)
+	assert.Equal(t, http.StatusCreated, retry.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestIdempotencyDuplicatesWaitForTheFirstRequest(t *testing.T) {
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	app := fiber.New()
+	app.Post("/create", middleware.Idempotency(newMemoryIdempotencyStore()), func(ctx *fiber.Ctx) error {
+		atomic.AddInt32(&calls, 1)
+		close(started)
+		<-release
+		return ctx.Status(http.StatusCreated).JSON(fiber.Map{"message": "created"})
+	})
+
+	var wg sync.WaitGroup
+	statuses := make([]int, 2)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		resp, _ := idempotentRequest(t, app, "key-1", `{}`)
+		statuses[0] = resp.StatusCode
+	}()
+
+	<-started
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		resp, _ := idempotentRequest(t, app, "key-1", `{}`)
+		statuses[1] = resp.StatusCode
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, []int{http.StatusCreated, http.StatusCreated}, statuses)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
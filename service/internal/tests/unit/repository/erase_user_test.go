@@ -0,0 +1,134 @@
+package unit_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// rowConnector is a database answering every query with one row.
+type rowConnector struct {
+	columns []string
+	values  []driver.Value
+}
+
+func (c *rowConnector) Connect(context.Context) (driver.Conn, error) { return &rowConn{c}, nil }
+func (c *rowConnector) Driver() driver.Driver                        { return nil }
+
+type rowConn struct{ c *rowConnector }
+
+func (c *rowConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *rowConn) Close() error                        { return nil }
+func (c *rowConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *rowConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &oneRow{c: c.c}, nil
+}
+
+type oneRow struct {
+	c    *rowConnector
+	done bool
+}
+
+func (r *oneRow) Columns() []string { return r.c.columns }
+func (r *oneRow) Close() error      { return nil }
+
+func (r *oneRow) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.c.values)
+	return nil
+}
+
+// sampleValue is a value of the column read into a field of type t.
+func sampleValue(t reflect.Type) driver.Value {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "sample"
+	case reflect.Bool:
+		return true
+	case reflect.Float32, reflect.Float64:
+		return 1.5
+	}
+	return int64(1)
+}
+
+// erasedRow is the row a listing of dest reads for a row of table left by an
+// erasure, its other columns filled.
+func erasedRow(table string, dest interface{}) *rowConnector {
+	erased := repository.ErasedColumns(table)
+	row := &rowConnector{}
+	typ := reflect.TypeOf(dest).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		column := typ.Field(i).Tag.Get("db")
+		if column == "" || column == "-" {
+			continue
+		}
+		value := sampleValue(typ.Field(i).Type)
+		if erasedValue, ok := erased[column]; ok {
+			value = erasedValue
+		}
+		row.columns = append(row.columns, column)
+		row.values = append(row.values, value)
+	}
+	return row
+}
+
+func TestErasedChildrenStillList(t *testing.T) {
+	tests := []struct {
+		table string
+		dest  interface{}
+	}{
+		{"contacts", &[]dtos.ContactListDTO{}},
+		{"contacts", &[]dtos.ContactDetailDTO{}},
+		{"contacts", &[]repository.ContactRefNum{}},
+		{"addresses", &[]dtos.AddressListDTO{}},
+		{"addresses", &[]dtos.AddressDetailDTO{}},
+		{"identifiers", &[]dtos.IdentifierListDTO{}},
+		{"identifiers", &[]dtos.IdentifierDetailDTO{}},
+		{"identifiers", &[]repository.IdentifierRefNum{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(reflect.TypeOf(tt.dest).Elem().Elem().Name(), func(t *testing.T) {
+			row := erasedRow(tt.table, reflect.New(reflect.TypeOf(tt.dest).Elem().Elem()).Interface())
+			db := sqlx.NewDb(sql.OpenDB(row), "postgres")
+			defer db.Close()
+
+			assert.NoError(t, db.Select(tt.dest, "SELECT"))
+			assert.Equal(t, 1, reflect.ValueOf(tt.dest).Elem().Len())
+		})
+	}
+}
+
+func TestErasedColumns(t *testing.T) {
+	// ref_num is read as text everywhere, the rest of the personal data is
+	// nullable
+	for _, table := range []string{"contacts", "addresses", "identifiers"} {
+		erased := repository.ErasedColumns(table)
+		assert.Equal(t, "[erased]", erased["ref_num"], table)
+		for column, value := range erased {
+			if column != "ref_num" {
+				assert.Nil(t, value, table+"."+column)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewLocal(t.TempDir())
+
+	key := "attachments/ab/cd/abcd-1"
+	assert.NoError(t, store.Put(ctx, key, strings.NewReader("scan"), 4, "application/pdf"))
+
+	file, err := store.Open(ctx, key)
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, "scan", string(data))
+
+	assert.NoError(t, store.Delete(ctx, key))
+	_, err = store.Open(ctx, key)
+	assert.True(t, errors.Is(err, storage.ErrNotFound))
+
+	// deleting twice is fine, leaving the directory is not
+	assert.NoError(t, store.Delete(ctx, key))
+	assert.Error(t, store.Put(ctx, "../outside", strings.NewReader("x"), 1, "text/plain"))
+	_, err = store.Open(ctx, "/etc/passwd")
+	assert.Error(t, err)
+}
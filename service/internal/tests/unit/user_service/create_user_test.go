@@ -3,13 +3,9 @@ package unit_test
 import (
 	"context"
 	"fmt"
-	"log"
-	"path/filepath"
 	"testing"
 	"time"
 
-	"github.com/joho/godotenv"
-	"github.com/nibroos/nb-go-api/service/internal/config"
 	"github.com/nibroos/nb-go-api/service/internal/mocks"
 	"github.com/nibroos/nb-go-api/service/internal/models"
 	"github.com/nibroos/nb-go-api/service/internal/service"
@@ -17,24 +13,15 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 )
 
 // Failure in HashPassword
-// Failure in Transaction Begin
 // Failure in Create User
 // Failure in Attach Roles
 // Failure in Commit
 
 func TestCreateUser(t *testing.T) {
 
-	// Load environment variables from .env file
-	err := godotenv.Load(filepath.Join("..", "..", "..", "..", ".env"))
-	if err != nil {
-		log.Fatalf("Error loading .env file")
-	}
-
 	mockRepo := new(mocks.MockUserRepository)
 	userService := service.NewUserService(mockRepo)
 
@@ -63,20 +50,11 @@ func TestCreateUser(t *testing.T) {
 		Address:  utils.Ptr("TAddress"),
 	}
 
-	// Retrieve database credentials from environment variables using GetTestDatabaseURL
-	configDBTest := config.GetTestDatabaseURL()
-
-	// mockDB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-	mockDB, err := gorm.Open(postgres.Open(configDBTest), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Failed to connect to the Gorm database: %v", err)
-	}
-
 	tests := []struct {
 		name          string
 		user          models.User
 		roleIDs       []uint32
-		mockBeginTx   *gorm.DB
+		mockTx        bool
 		mockCreateErr error
 		mockAttachErr error
 		mockCommitErr error
@@ -87,7 +65,7 @@ func TestCreateUser(t *testing.T) {
 			name:          "success create user",
 			user:          user,
 			roleIDs:       roleIDs,
-			mockBeginTx:   mockDB.Begin(),
+			mockTx:        true,
 			mockCreateErr: nil,
 			mockAttachErr: nil,
 			mockCommitErr: nil,
@@ -98,7 +76,7 @@ func TestCreateUser(t *testing.T) {
 			name:          "error hash password",
 			user:          models.User{Password: ""},
 			roleIDs:       roleIDs,
-			mockBeginTx:   nil,
+			mockTx:        false,
 			mockCreateErr: nil,
 			mockAttachErr: nil,
 			mockCommitErr: nil,
@@ -109,7 +87,7 @@ func TestCreateUser(t *testing.T) {
 			name:          "error roleIDs empty error",
 			user:          user,
 			roleIDs:       []uint32{},
-			mockBeginTx:   nil,
+			mockTx:        false,
 			mockCreateErr: nil,
 			mockAttachErr: nil,
 			mockCommitErr: nil,
@@ -120,11 +98,10 @@ func TestCreateUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.mockBeginTx != nil {
-				mockRepo.On("BeginTransaction").Return(tt.mockBeginTx)
-				mockRepo.On("CreateUser", tt.mockBeginTx, mock.AnythingOfType("*models.User")).Return(tt.mockCreateErr)
-				mockRepo.On("AttachRoles", tt.mockBeginTx, mock.AnythingOfType("*models.User"), tt.roleIDs).Return(tt.mockAttachErr)
-				mockRepo.On("Commit", tt.mockBeginTx).Return(tt.mockCommitErr)
+			if tt.mockTx {
+				mockRepo.On("WithTransaction", mock.Anything).Return(tt.mockCommitErr)
+				mockRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*models.User")).Return(tt.mockCreateErr)
+				mockRepo.On("AttachRoles", mock.Anything, mock.AnythingOfType("*models.User"), tt.roleIDs).Return(tt.mockAttachErr)
 			}
 
 			user, err := userService.CreateUser(ctx, &tt.user, tt.roleIDs)
@@ -2,47 +2,31 @@ package unit_test
 
 import (
 	"context"
-	"log"
-	"path/filepath"
 	"testing"
 	"time"
 
-	"github.com/joho/godotenv"
-	"github.com/nibroos/nb-go-api/service/internal/config"
 	"github.com/nibroos/nb-go-api/service/internal/mocks"
 	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
 	"github.com/stretchr/testify/assert"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestDeleteUser(t *testing.T) {
 
-	// Load environment variables from .env file
-	err := godotenv.Load(filepath.Join("..", "..", "..", "..", ".env"))
-	if err != nil {
-		log.Fatalf("Error loading .env file")
-	}
-
 	mockRepo := new(mocks.MockUserRepository)
 	userService := service.NewUserService(mockRepo)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	// Retrieve database credentials from environment variables using GetTestDatabaseURL
-	configDBTest := config.GetTestDatabaseURL()
-
-	mockDB, err := gorm.Open(postgres.Open(configDBTest), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Failed to connect to the Gorm database: %v", err)
-	}
-
 	tests := []struct {
 		name               string
 		userID             uint
-		mockBeginTx        *gorm.DB
+		version            uint
+		mockTx             bool
 		mockDeleteRolesErr error
+		mockDeleteChildErr error
 		mockDeleteUserErr  error
 		mockCommitErr      error
 		expectedErr        error
@@ -50,26 +34,37 @@ func TestDeleteUser(t *testing.T) {
 		{
 			name:               "success",
 			userID:             1,
-			mockBeginTx:        mockDB.Begin(),
+			version:            1,
+			mockTx:             true,
 			mockDeleteRolesErr: nil,
+			mockDeleteChildErr: nil,
 			mockDeleteUserErr:  nil,
 			mockCommitErr:      nil,
 			expectedErr:        nil,
 		},
+		{
+			name:               "error version conflict",
+			userID:             2,
+			version:            3,
+			mockTx:             true,
+			mockDeleteRolesErr: nil,
+			mockDeleteChildErr: nil,
+			mockDeleteUserErr:  utils.ErrVersionConflict,
+			mockCommitErr:      nil,
+			expectedErr:        utils.ErrVersionConflict,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.mockBeginTx != nil {
-				mockRepo.On("BeginTransaction").Return(tt.mockBeginTx)
-				mockRepo.On("DeleteRolesByUserID", tt.mockBeginTx, tt.userID).Return(tt.mockDeleteRolesErr)
-				mockRepo.On("DeleteUser", tt.mockBeginTx, tt.userID).Return(tt.mockDeleteUserErr)
-				mockRepo.On("Commit", tt.mockBeginTx).Return(tt.mockCommitErr)
-			} else {
-				mockRepo.On("BeginTransaction").Return(nil)
+			if tt.mockTx {
+				mockRepo.On("WithTransaction", mock.Anything).Return(tt.mockCommitErr)
+				mockRepo.On("DeleteRolesByUserID", mock.Anything, tt.userID).Return(tt.mockDeleteRolesErr)
+				mockRepo.On("DeleteChildrenByUserID", mock.Anything, tt.userID).Return(tt.mockDeleteChildErr)
+				mockRepo.On("DeleteUser", mock.Anything, tt.userID, tt.version).Return(tt.mockDeleteUserErr)
 			}
 
-			err := userService.DeleteUser(ctx, tt.userID)
+			err := userService.DeleteUser(ctx, tt.userID, tt.version)
 
 			assert.Equal(t, tt.expectedErr, err)
 			mockRepo.AssertExpectations(t)
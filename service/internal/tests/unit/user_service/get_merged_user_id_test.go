@@ -0,0 +1,34 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/mocks"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMergedUserID(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	userService := service.NewUserService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("GetMergedUserID", ctx, uint(2)).Return(uint(1), true, nil).Once()
+	targetID, merged, err := userService.GetMergedUserID(ctx, 2)
+	assert.NoError(t, err)
+	assert.True(t, merged)
+	assert.Equal(t, uint(1), targetID)
+
+	mockRepo.On("GetMergedUserID", ctx, uint(3)).Return(uint(0), false, nil).Once()
+	_, merged, err = userService.GetMergedUserID(ctx, 3)
+	assert.NoError(t, err)
+	assert.False(t, merged)
+
+	mockRepo.On("GetMergedUserID", ctx, uint(4)).Return(uint(0), false, errors.New("repository error")).Once()
+	_, _, err = userService.GetMergedUserID(ctx, 4)
+	assert.EqualError(t, err, "repository error")
+
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,69 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/mocks"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetUserHistory(t *testing.T) {
+
+	mockRepo := new(mocks.MockUserRepository)
+	userService := service.NewUserService(mockRepo)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	validTo := "2026-10-19T10:00:00Z"
+
+	tests := []struct {
+		name          string
+		userID        uint
+		mockHistory   []dtos.HistoryDTO
+		mockTotal     int
+		mockErr       error
+		expectedTotal int
+		expectedErr   error
+	}{
+		{
+			name:   "success",
+			userID: 1,
+			mockHistory: []dtos.HistoryDTO{
+				{Version: 2, Operation: "update", Data: []byte(`{"id":1,"name":"New"}`), ValidFrom: validTo},
+				{Version: 1, Operation: "create", Data: []byte(`{"id":1,"name":"Old"}`), ValidFrom: "2026-10-18T10:00:00Z", ValidTo: &validTo},
+			},
+			mockTotal:     2,
+			expectedTotal: 2,
+		},
+		{
+			name:        "error fetching history",
+			userID:      2,
+			mockHistory: []dtos.HistoryDTO{},
+			mockErr:     errors.New("query failed"),
+			expectedErr: errors.New("query failed"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := map[string]string{}
+			mockRepo.On("GetUserHistory", mock.Anything, tt.userID, filters).Return(tt.mockHistory, tt.mockTotal, tt.mockErr)
+
+			history, total, err := userService.GetUserHistory(ctx, tt.userID, filters)
+
+			assert.Equal(t, tt.expectedErr, err)
+			if tt.expectedErr == nil {
+				assert.Equal(t, tt.mockHistory, history)
+				assert.Equal(t, tt.expectedTotal, total)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
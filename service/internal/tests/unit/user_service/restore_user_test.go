@@ -0,0 +1,74 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/mocks"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRestoreUser(t *testing.T) {
+
+	mockRepo := new(mocks.MockUserRepository)
+	userService := service.NewUserService(mockRepo)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name                string
+		userID              uint
+		version             uint
+		mockRestoreChildErr error
+		mockRestoreUserErr  error
+		expectRestoreUser   bool
+		expectedErr         error
+	}{
+		{
+			name:              "success",
+			userID:            1,
+			version:           2,
+			expectRestoreUser: true,
+		},
+		{
+			name:               "error version conflict",
+			userID:             2,
+			version:            3,
+			mockRestoreUserErr: utils.ErrVersionConflict,
+			expectRestoreUser:  true,
+			expectedErr:        utils.ErrVersionConflict,
+		},
+		{
+			name:                "error restoring children",
+			userID:              3,
+			version:             2,
+			mockRestoreChildErr: errors.New("restore children failed"),
+			expectRestoreUser:   false,
+			expectedErr:         errors.New("restore children failed"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo.On("WithTransaction", mock.Anything).Return(nil)
+			mockRepo.On("RestoreChildrenByUserID", mock.Anything, tt.userID).Return(tt.mockRestoreChildErr)
+			if tt.expectRestoreUser {
+				mockRepo.On("RestoreUser", mock.Anything, tt.userID, tt.version).Return(tt.mockRestoreUserErr)
+			}
+
+			err := userService.RestoreUser(ctx, tt.userID, tt.version)
+
+			assert.Equal(t, tt.expectedErr, err)
+			if !tt.expectRestoreUser {
+				mockRepo.AssertNotCalled(t, "RestoreUser", mock.Anything, tt.userID, tt.version)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/mocks"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRunBulk(t *testing.T) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// the item at index 1 fails with a version conflict, the item at index 2
+	// already failed validation
+	op := func(ctx context.Context, i int) (uint, uint, error) {
+		if i == 1 {
+			return 0, 0, utils.ErrVersionConflict
+		}
+		return uint(i + 10), 1, nil
+	}
+
+	newResults := func() []dtos.BulkItemResult {
+		return []dtos.BulkItemResult{
+			{Index: 0},
+			{Index: 1},
+			{Index: 2, Status: utils.BulkStatusFailed, Errors: map[string]string{"email": "the email has already been taken"}},
+			{Index: 3},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		atomic         bool
+		expectedErr    error
+		expectedStatus []string
+	}{
+		{
+			name:           "partial writes the valid items",
+			atomic:         false,
+			expectedStatus: []string{utils.BulkStatusCreated, utils.BulkStatusFailed, utils.BulkStatusFailed, utils.BulkStatusCreated},
+		},
+		{
+			name:           "atomic skips every item after a failure",
+			atomic:         true,
+			expectedErr:    utils.ErrBulkFailed,
+			expectedStatus: []string{utils.BulkStatusSkipped, utils.BulkStatusFailed, utils.BulkStatusFailed, utils.BulkStatusSkipped},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockUserRepository)
+			userService := service.NewUserService(mockRepo)
+			mockRepo.On("WithTransaction", mock.Anything).Return(nil)
+
+			results := newResults()
+			err := userService.RunBulk(ctx, results, tt.atomic, utils.BulkStatusCreated, op)
+
+			assert.Equal(t, tt.expectedErr, err)
+			for i, result := range results {
+				assert.Equal(t, i, result.Index)
+				assert.Equal(t, tt.expectedStatus[i], result.Status)
+			}
+			assert.Contains(t, results[1].Errors, "version")
+			assert.Equal(t, "the email has already been taken", results[2].Errors["email"])
+			if !tt.atomic {
+				assert.Equal(t, uint(13), *results[3].ID)
+			} else {
+				assert.Nil(t, results[0].ID)
+			}
+		})
+	}
+}
+
+func TestRunBulkCommitError(t *testing.T) {
+
+	mockRepo := new(mocks.MockUserRepository)
+	userService := service.NewUserService(mockRepo)
+	mockRepo.On("WithTransaction", mock.Anything).Return(errors.New("commit failed"))
+
+	results := []dtos.BulkItemResult{{Index: 0}}
+	err := userService.RunBulk(context.Background(), results, false, utils.BulkStatusDeleted, func(ctx context.Context, i int) (uint, uint, error) {
+		return 1, 2, nil
+	})
+
+	assert.EqualError(t, err, "commit failed")
+	assert.Equal(t, "", results[0].Status)
+}
@@ -3,36 +3,23 @@ package unit_test
 import (
 	"context"
 	"fmt"
-	"log"
-	"path/filepath"
 	"testing"
 	"time"
 
-	"github.com/joho/godotenv"
-	"github.com/nibroos/nb-go-api/service/internal/config"
 	"github.com/nibroos/nb-go-api/service/internal/mocks"
 	"github.com/nibroos/nb-go-api/service/internal/models"
 	"github.com/nibroos/nb-go-api/service/internal/service"
 	"github.com/nibroos/nb-go-api/service/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 )
 
-// Failure in Transaction Begin
 // Failure in Update User
 // Failure in Attach Roles
 // Failure in Commit
 
 func TestUpdateUser(t *testing.T) {
 
-	// Load environment variables from .env file
-	err := godotenv.Load(filepath.Join("..", "..", "..", "..", ".env"))
-	if err != nil {
-		log.Fatalf("Error loading .env file")
-	}
-
 	mockRepo := new(mocks.MockUserRepository)
 	userService := service.NewUserService(mockRepo)
 
@@ -59,19 +46,11 @@ func TestUpdateUser(t *testing.T) {
 		Address:  utils.Ptr("TAddress"),
 	}
 
-	// Retrieve database credentials from environment variables using GetTestDatabaseURL
-	configDBTest := config.GetTestDatabaseURL()
-
-	mockDB, err := gorm.Open(postgres.Open(configDBTest), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Failed to connect to the Gorm database: %v", err)
-	}
-
 	tests := []struct {
 		name          string
 		user          models.User
 		roleIDs       []uint32
-		mockBeginTx   *gorm.DB
+		mockTx        bool
 		mockUpdateErr error
 		mockAttachErr error
 		mockCommitErr error
@@ -82,7 +61,7 @@ func TestUpdateUser(t *testing.T) {
 			name:          "success update user",
 			user:          user,
 			roleIDs:       roleIDs,
-			mockBeginTx:   mockDB.Begin(),
+			mockTx:        true,
 			mockUpdateErr: nil,
 			mockAttachErr: nil,
 			mockCommitErr: nil,
@@ -93,7 +72,7 @@ func TestUpdateUser(t *testing.T) {
 			name:          "error roleIDs empty",
 			user:          user,
 			roleIDs:       []uint32{},
-			mockBeginTx:   nil,
+			mockTx:        false,
 			mockUpdateErr: nil,
 			mockAttachErr: nil,
 			mockCommitErr: nil,
@@ -104,13 +83,10 @@ func TestUpdateUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.mockBeginTx != nil {
-				mockRepo.On("BeginTransaction").Return(tt.mockBeginTx)
-				mockRepo.On("UpdateUser", tt.mockBeginTx, mock.AnythingOfType("*models.User")).Return(tt.mockUpdateErr)
-				mockRepo.On("AttachRoles", tt.mockBeginTx, mock.AnythingOfType("*models.User"), tt.roleIDs).Return(tt.mockAttachErr)
-				mockRepo.On("Commit", tt.mockBeginTx).Return(tt.mockCommitErr)
-			} else {
-				mockRepo.On("BeginTransaction").Return(nil)
+			if tt.mockTx {
+				mockRepo.On("WithTransaction", mock.Anything).Return(tt.mockCommitErr)
+				mockRepo.On("UpdateUser", mock.Anything, mock.AnythingOfType("*models.User")).Return(tt.mockUpdateErr)
+				mockRepo.On("AttachRoles", mock.Anything, mock.AnythingOfType("*models.User"), tt.roleIDs).Return(tt.mockAttachErr)
 			}
 
 			user, err := userService.UpdateUser(ctx, &tt.user, tt.roleIDs)
@@ -0,0 +1,72 @@
+package unit_test
+
+import (
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	address := utils.NormalizeAddress(utils.Address{
+		Line1:       "  10   Downing  Street ",
+		City:        "LONDON",
+		Region:      " greater   london",
+		PostalCode:  "sw1a1aa",
+		CountryCode: "gb ",
+	})
+
+	assert.Equal(t, utils.Address{
+		Line1:       "10 Downing Street",
+		City:        "London",
+		Region:      "Greater London",
+		PostalCode:  "SW1A 1AA",
+		CountryCode: "GB",
+	}, address)
+
+	// a casing someone chose is kept, abbreviated regions are upper-cased
+	address = utils.NormalizeAddress(utils.Address{City: "McAllen", Region: "tx", PostalCode: "78501", CountryCode: "us"})
+	assert.Equal(t, "McAllen", address.City)
+	assert.Equal(t, "TX", address.Region)
+
+	assert.Equal(t, "1234 AB", utils.NormalizePostalCode("NL", "1234ab"))
+	assert.Equal(t, "100-0001", utils.NormalizePostalCode("JP", "1000001"))
+	assert.Equal(t, "12345", utils.NormalizePostalCode("", " 12345 "))
+}
+
+func TestValidPostalCode(t *testing.T) {
+	assert.True(t, utils.ValidPostalCode("US", "62704"))
+	assert.True(t, utils.ValidPostalCode("US", "62704-1234"))
+	assert.False(t, utils.ValidPostalCode("US", "6270"))
+	assert.True(t, utils.ValidPostalCode("GB", "SW1A 1AA"))
+	assert.False(t, utils.ValidPostalCode("GB", "12345"))
+	assert.True(t, utils.ValidPostalCode("ID", "10220"))
+
+	// countries without a pattern, or none, take anything like a postal code
+	assert.True(t, utils.ValidPostalCode("KE", "00100"))
+	assert.True(t, utils.ValidPostalCode("", "AB-123"))
+	assert.False(t, utils.ValidPostalCode("", "not a postal code"))
+}
+
+func TestValidCountryCode(t *testing.T) {
+	assert.True(t, utils.ValidCountryCode("ID"))
+	assert.False(t, utils.ValidCountryCode("id"))
+	assert.False(t, utils.ValidCountryCode("XX"))
+}
+
+func TestFormatAddress(t *testing.T) {
+	assert.Equal(t, "1 Main St, Apt 2, Springfield, IL 62704, US", utils.FormatAddress(utils.Address{
+		Line1:       "1 Main St",
+		Line2:       "Apt 2",
+		City:        "Springfield",
+		Region:      "IL",
+		PostalCode:  "62704",
+		CountryCode: "US",
+	}))
+	assert.Equal(t, "Jl. Sudirman 1, Jakarta, 10220, ID", utils.FormatAddress(utils.Address{
+		Line1:       "Jl. Sudirman 1",
+		City:        "Jakarta",
+		PostalCode:  "10220",
+		CountryCode: "ID",
+	}))
+}
@@ -0,0 +1,52 @@
+package unit_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsAttachmentEntity(t *testing.T) {
+	assert.True(t, utils.IsAttachmentEntity("identifiers"))
+	assert.True(t, utils.IsAttachmentEntity("users"))
+	assert.False(t, utils.IsAttachmentEntity("groups"))
+	assert.False(t, utils.IsAttachmentEntity(""))
+}
+
+func TestSniffContentType(t *testing.T) {
+	assert.Equal(t, "application/pdf", utils.SniffContentType([]byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")))
+	assert.Equal(t, "image/png", utils.SniffContentType([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")))
+	assert.Equal(t, "image/jpeg", utils.SniffContentType([]byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00")))
+	assert.Equal(t, "image/webp", utils.SniffContentType([]byte("RIFF\x24\x00\x00\x00WEBPVP8 ")))
+
+	// the parameters are dropped, and a name or claimed type has no say
+	assert.Equal(t, "text/plain", utils.SniffContentType([]byte("not a scan.pdf")))
+}
+
+func TestFileStorageKey(t *testing.T) {
+	sum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	assert.Equal(t, "files/9f/86/"+sum+"-abc", utils.FileStorageKey(sum, "abc"))
+}
+
+func TestVerifyAttachmentURL(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	expires := now.Add(15 * time.Minute).Unix()
+	signature := utils.SignAttachmentURL("secret", 42, expires)
+
+	assert.NoError(t, utils.VerifyAttachmentURL("secret", 42, expires, signature, now))
+	assert.NoError(t, utils.VerifyAttachmentURL("secret", 42, expires, signature, now.Add(15*time.Minute)))
+
+	failures := map[string]error{
+		"expired":       utils.VerifyAttachmentURL("secret", 42, expires, signature, now.Add(16*time.Minute)),
+		"other id":      utils.VerifyAttachmentURL("secret", 43, expires, signature, now),
+		"later expiry":  utils.VerifyAttachmentURL("secret", 42, expires+3600, signature, now),
+		"other secret":  utils.VerifyAttachmentURL("other", 42, expires, signature, now),
+		"bad signature": utils.VerifyAttachmentURL("secret", 42, expires, "deadbeef", now),
+	}
+	for name, err := range failures {
+		assert.True(t, errors.Is(err, utils.ErrAttachmentURL), name)
+	}
+}
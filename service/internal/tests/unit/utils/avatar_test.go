@@ -0,0 +1,134 @@
+package unit_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// halves is a w by h image, red above blue.
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if y >= h/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// withOrientation inserts an EXIF segment with an orientation tag after the
+// SOI marker of a JPEG.
+func withOrientation(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	tiff = append(append(tiff, entry...), 0, 0, 0, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+func TestDecodeAvatar(t *testing.T) {
+	img, orientation, err := utils.DecodeAvatar(encodePNG(t, halves(30, 20)), 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, orientation)
+	assert.Equal(t, image.Rect(0, 0, 30, 20), img.Bounds())
+
+	// the size is checked before the pixels are decoded
+	_, _, err = utils.DecodeAvatar(encodePNG(t, halves(30, 20)), 599)
+	assert.ErrorIs(t, err, utils.ErrAvatarImage)
+
+	_, _, err = utils.DecodeAvatar([]byte("GIF89a not an avatar"), 1000)
+	assert.ErrorIs(t, err, utils.ErrAvatarImage)
+
+	_, orientation, err = utils.DecodeAvatar(withOrientation(encodeJPEG(t, halves(20, 20)), 6), 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, orientation)
+}
+
+func TestSquareAvatar(t *testing.T) {
+	img := halves(30, 20)
+
+	assert.Equal(t, image.Rect(0, 0, 10, 10), utils.SquareAvatar(img, 1, 10).Bounds())
+
+	// never scaled up
+	assert.Equal(t, image.Rect(0, 0, 20, 20), utils.SquareAvatar(img, 1, 512).Bounds())
+}
+
+func TestSquareAvatarOrientation(t *testing.T) {
+	// 6 is turned a quarter clockwise: the red top ends on the right
+	square := utils.SquareAvatar(halves(20, 20), 6, 20)
+
+	r, _, b, _ := square.At(18, 10).RGBA()
+	assert.Greater(t, r, b)
+	r, _, b, _ = square.At(1, 10).RGBA()
+	assert.Greater(t, b, r)
+}
+
+func TestEncodeAvatarStripsMetadata(t *testing.T) {
+	data := withOrientation(encodeJPEG(t, halves(20, 20)), 6)
+	assert.True(t, bytes.Contains(data, []byte("Exif")))
+
+	img, orientation, err := utils.DecodeAvatar(data, 1000)
+	assert.NoError(t, err)
+
+	encoded, err := utils.EncodeAvatar(utils.SquareAvatar(img, orientation, 20))
+	assert.NoError(t, err)
+	assert.False(t, bytes.Contains(encoded, []byte("Exif")))
+
+	// upright once encoded, nothing turns it again
+	_, orientation, err = utils.DecodeAvatar(encoded, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, orientation)
+}
+
+func TestVerifyAvatarURL(t *testing.T) {
+	secret := "secret"
+	signature := utils.SignAvatarURL(secret, 7, 42)
+
+	assert.NoError(t, utils.VerifyAvatarURL(secret, 7, 42, signature))
+
+	// a URL names the user and the content
+	assert.ErrorIs(t, utils.VerifyAvatarURL(secret, 8, 42, signature), utils.ErrAvatarURL)
+	assert.ErrorIs(t, utils.VerifyAvatarURL(secret, 7, 43, signature), utils.ErrAvatarURL)
+	assert.ErrorIs(t, utils.VerifyAvatarURL("other", 7, 42, signature), utils.ErrAvatarURL)
+
+	parsed, err := url.Parse(utils.AvatarURL("/api/v1/users/download-avatar-user", secret, 7, 42))
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/v1/users/download-avatar-user", parsed.Path)
+	assert.Equal(t, "7", parsed.Query().Get("user_id"))
+	assert.Equal(t, "42", parsed.Query().Get("v"))
+	assert.Equal(t, signature, parsed.Query().Get("signature"))
+}
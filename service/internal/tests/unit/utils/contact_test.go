@@ -0,0 +1,59 @@
+package unit_test
+
+import (
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseContactFormat(t *testing.T) {
+	options := `{"format": "phone", "default_region": "id"}`
+	assert.Equal(t, utils.ContactFormat{Format: utils.ContactFormatPhone, DefaultRegion: "ID"}, utils.ParseContactFormat(&options))
+
+	for _, options := range []string{`{"format": "fax"}`, `[]`, `not json`} {
+		assert.Equal(t, utils.ContactFormat{}, utils.ParseContactFormat(&options), options)
+	}
+	assert.Equal(t, utils.ContactFormat{}, utils.ParseContactFormat(nil))
+}
+
+func TestNormalizeContact(t *testing.T) {
+	valid := []struct {
+		format utils.ContactFormat
+		value  string
+		want   string
+	}{
+		{utils.ContactFormat{Format: utils.ContactFormatEmail}, " John.Doe@Example.COM ", "john.doe@example.com"},
+		{utils.ContactFormat{Format: utils.ContactFormatPhone, DefaultRegion: "ID"}, "0812-3456-7890", "+6281234567890"},
+		{utils.ContactFormat{Format: utils.ContactFormatPhone, DefaultRegion: "ID"}, "+1 (202) 555-0143", "+12025550143"},
+		{utils.ContactFormat{Format: utils.ContactFormatPhone}, "+62 21 5795 1234", "+622157951234"},
+		{utils.ContactFormat{Format: utils.ContactFormatURL}, "Example.com/About#team", "https://example.com/About"},
+		{utils.ContactFormat{Format: utils.ContactFormatURL}, "HTTP://WWW.Example.com/", "http://www.example.com"},
+		{utils.ContactFormat{Format: utils.ContactFormatHandle}, "@John_Doe.1", "@john_doe.1"},
+		{utils.ContactFormat{}, "  anything   at all ", "anything at all"},
+	}
+	for _, c := range valid {
+		got, err := utils.NormalizeContact(c.format, c.value)
+		assert.NoError(t, err, c.value)
+		assert.Equal(t, c.want, got, c.value)
+	}
+
+	invalid := []struct {
+		format utils.ContactFormat
+		value  string
+	}{
+		{utils.ContactFormat{Format: utils.ContactFormatEmail}, "john.doe"},
+		{utils.ContactFormat{Format: utils.ContactFormatEmail}, "John <john@example.com>"},
+		{utils.ContactFormat{Format: utils.ContactFormatEmail}, "john@localhost"},
+		{utils.ContactFormat{Format: utils.ContactFormatPhone, DefaultRegion: "ID"}, "12"},
+		{utils.ContactFormat{Format: utils.ContactFormatPhone}, "0812-3456-7890"},
+		{utils.ContactFormat{Format: utils.ContactFormatURL}, "ftp://example.com"},
+		{utils.ContactFormat{Format: utils.ContactFormatURL}, "not a url"},
+		{utils.ContactFormat{Format: utils.ContactFormatHandle}, "@john doe"},
+		{utils.ContactFormat{Format: utils.ContactFormatHandle}, "@.john"},
+	}
+	for _, c := range invalid {
+		_, err := utils.NormalizeContact(c.format, c.value)
+		assert.Error(t, err, c.value)
+	}
+}
@@ -0,0 +1,48 @@
+package unit_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExportFormat(t *testing.T) {
+	format, err := utils.ParseExportFormat(map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, utils.ExportFormatCSV, format)
+
+	format, err = utils.ParseExportFormat(map[string]string{"format": "ndjson"})
+	assert.NoError(t, err)
+	assert.Equal(t, utils.ExportFormatNDJSON, format)
+
+	_, err = utils.ParseExportFormat(map[string]string{"format": "xlsx"})
+	assert.True(t, errors.Is(err, utils.ErrInvalidFilter))
+}
+
+func writeExport(t *testing.T, format string) string {
+	var buf bytes.Buffer
+	w := utils.NewExportWriter(format, &buf)
+
+	createdAt := time.Date(2026, 10, 19, 14, 30, 0, 0, time.UTC)
+	assert.NoError(t, w.WriteHeader([]string{"id", "name", "username", "created_at"}))
+	assert.NoError(t, w.WriteRow([]interface{}{int64(1), []byte(`Alice "Al"`), nil, createdAt}))
+	assert.NoError(t, w.WriteRow([]interface{}{int64(2), []byte("Bob, Jr."), []byte("bob"), createdAt}))
+	assert.NoError(t, w.Flush())
+
+	return buf.String()
+}
+
+func TestCSVExportWriter(t *testing.T) {
+	assert.Equal(t, "id,name,username,created_at\n"+
+		"1,\"Alice \"\"Al\"\"\",,2026-10-19T14:30:00Z\n"+
+		"2,\"Bob, Jr.\",bob,2026-10-19T14:30:00Z\n", writeExport(t, utils.ExportFormatCSV))
+}
+
+func TestNDJSONExportWriter(t *testing.T) {
+	assert.Equal(t, `{"id":1,"name":"Alice \"Al\"","username":null,"created_at":"2026-10-19T14:30:00Z"}`+"\n"+
+		`{"id":2,"name":"Bob, Jr.","username":"bob","created_at":"2026-10-19T14:30:00Z"}`+"\n", writeExport(t, utils.ExportFormatNDJSON))
+}
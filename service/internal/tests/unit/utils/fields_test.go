@@ -0,0 +1,49 @@
+package unit_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFields(t *testing.T) {
+	allowed := map[string]utils.Field{
+		"id":      {Column: "id"},
+		"name":    {Column: "name"},
+		"address": {Column: "address", Permission: utils.PermissionReadUsers},
+		"roles":   {},
+	}
+
+	fields, err := utils.ParseFields("", allowed)
+	assert.NoError(t, err)
+	assert.Nil(t, fields)
+
+	fields, err = utils.ParseFields("name, roles,name", allowed)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "roles"}, fields)
+	assert.Equal(t, "id, name", utils.SelectColumns(fields, allowed))
+
+	_, err = utils.ParseFields("name,password", allowed)
+	assert.True(t, errors.Is(err, utils.ErrInvalidFields))
+}
+
+func TestPickFields(t *testing.T) {
+	users := []dtos.UserListDTO{
+		{ID: 1, Name: "User One", Email: "user1@example.com"},
+		{ID: 2, Name: "User Two", Email: "user2@example.com"},
+	}
+
+	picked, err := utils.PickFields(users, []string{"id", "name"})
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"id": float64(1), "name": "User One"},
+		{"id": float64(2), "name": "User Two"},
+	}, picked)
+
+	picked, err = utils.PickFields(&users[0], []string{"id", "email"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": float64(1), "email": "user1@example.com"}, picked)
+}
@@ -0,0 +1,83 @@
+package unit_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIDFilter(t *testing.T) {
+	id, ok, err := utils.ParseIDFilter(map[string]string{}, "actor_id")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Zero(t, id)
+
+	id, ok, err = utils.ParseIDFilter(map[string]string{"actor_id": "42"}, "actor_id")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint(42), id)
+
+	for _, raw := range []string{"abc", "-1", "1.5"} {
+		_, _, err = utils.ParseIDFilter(map[string]string{"actor_id": raw}, "actor_id")
+		assert.True(t, errors.Is(err, utils.ErrInvalidFilter), raw)
+	}
+}
+
+func TestParseTimeFilter(t *testing.T) {
+	_, ok, err := utils.ParseTimeFilter(map[string]string{}, "created_from")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	at, ok, err := utils.ParseTimeFilter(map[string]string{"created_from": "2026-10-19"}, "created_from")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), at)
+
+	at, ok, err = utils.ParseTimeFilter(map[string]string{"created_from": "2026-10-19T08:30:00+07:00"}, "created_from")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, at.Equal(time.Date(2026, 10, 19, 1, 30, 0, 0, time.UTC)))
+
+	_, _, err = utils.ParseTimeFilter(map[string]string{"created_from": "19/10/2026"}, "created_from")
+	assert.True(t, errors.Is(err, utils.ErrInvalidFilter))
+}
+
+func TestParseFloatFilter(t *testing.T) {
+	_, ok, err := utils.ParseFloatFilter(map[string]string{}, "min_score")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	value, ok, err := utils.ParseFloatFilter(map[string]string{"min_score": "0.75"}, "min_score")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 0.75, value)
+
+	for _, raw := range []string{"abc", "NaN", "Inf"} {
+		_, _, err = utils.ParseFloatFilter(map[string]string{"min_score": raw}, "min_score")
+		assert.True(t, errors.Is(err, utils.ErrInvalidFilter), raw)
+	}
+}
+
+func TestParseDaysFilter(t *testing.T) {
+	_, ok, err := utils.ParseDaysFilter(map[string]string{}, "expiring_within")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	days, ok, err := utils.ParseDaysFilter(map[string]string{"expiring_within": "30"}, "expiring_within")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 30, days)
+
+	days, ok, err = utils.ParseDaysFilter(map[string]string{"expiring_within": "0"}, "expiring_within")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Zero(t, days)
+
+	for _, raw := range []string{"abc", "-1", "1.5"} {
+		_, _, err = utils.ParseDaysFilter(map[string]string{"expiring_within": raw}, "expiring_within")
+		assert.True(t, errors.Is(err, utils.ErrInvalidFilter), raw)
+	}
+}
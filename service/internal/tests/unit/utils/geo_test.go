@@ -0,0 +1,64 @@
+package unit_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNearFilter(t *testing.T) {
+	_, ok, err := utils.ParseNearFilter(map[string]string{})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	near, ok, err := utils.ParseNearFilter(map[string]string{"near": "-6.2, 106.8", "radius": "5"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, utils.GeoPoint{Latitude: -6.2, Longitude: 106.8}, near.Point)
+	if assert.NotNil(t, near.RadiusKm) {
+		assert.Equal(t, 5.0, *near.RadiusKm)
+	}
+
+	// without radius every address is listed by distance
+	near, ok, err = utils.ParseNearFilter(map[string]string{"near": "51.5,-0.12"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, near.RadiusKm)
+
+	for _, filters := range []map[string]string{
+		{"near": "51.5"},
+		{"near": "91,0"},
+		{"near": "0,181"},
+		{"near": "a,b"},
+		{"near": "0,0", "radius": "0"},
+		{"near": "0,0", "radius": "far"},
+		{"radius": "5"},
+	} {
+		_, _, err = utils.ParseNearFilter(filters)
+		assert.True(t, errors.Is(err, utils.ErrInvalidFilter), filters)
+	}
+}
+
+func TestParseBoundingBoxFilter(t *testing.T) {
+	_, ok, err := utils.ParseBoundingBoxFilter(map[string]string{})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	box, ok, err := utils.ParseBoundingBoxFilter(map[string]string{"bbox": "-6.4,106.6,-6.1,107"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, utils.BoundingBox{MinLatitude: -6.4, MinLongitude: 106.6, MaxLatitude: -6.1, MaxLongitude: 107}, box)
+
+	// across the antimeridian
+	box, ok, err = utils.ParseBoundingBoxFilter(map[string]string{"bbox": "-20,170,-10,-170"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Greater(t, box.MinLongitude, box.MaxLongitude)
+
+	for _, raw := range []string{"1,2,3", "10,0,5,1", "-91,0,0,0", "0,0,0,x"} {
+		_, _, err = utils.ParseBoundingBoxFilter(map[string]string{"bbox": raw})
+		assert.True(t, errors.Is(err, utils.ErrInvalidFilter), raw)
+	}
+}
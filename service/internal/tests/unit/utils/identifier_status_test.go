@@ -0,0 +1,43 @@
+package unit_test
+
+import (
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransitionIdentifier(t *testing.T) {
+	allowed := [][2]string{
+		{utils.IdentifierStatusDraft, utils.IdentifierStatusSubmitted},
+		{utils.IdentifierStatusSubmitted, utils.IdentifierStatusVerified},
+		{utils.IdentifierStatusSubmitted, utils.IdentifierStatusRejected},
+		{utils.IdentifierStatusRejected, utils.IdentifierStatusSubmitted},
+		{utils.IdentifierStatusVerified, utils.IdentifierStatusSubmitted},
+		{utils.IdentifierStatusVerified, utils.IdentifierStatusExpired},
+		{utils.IdentifierStatusExpired, utils.IdentifierStatusSubmitted},
+	}
+	for _, transition := range allowed {
+		assert.True(t, utils.CanTransitionIdentifier(transition[0], transition[1]), transition)
+	}
+
+	refused := [][2]string{
+		{utils.IdentifierStatusDraft, utils.IdentifierStatusVerified},
+		{utils.IdentifierStatusRejected, utils.IdentifierStatusVerified},
+		{utils.IdentifierStatusExpired, utils.IdentifierStatusVerified},
+		{utils.IdentifierStatusVerified, utils.IdentifierStatusRejected},
+		{utils.IdentifierStatusSubmitted, utils.IdentifierStatusSubmitted},
+		{"unknown", utils.IdentifierStatusSubmitted},
+	}
+	for _, transition := range refused {
+		assert.False(t, utils.CanTransitionIdentifier(transition[0], transition[1]), transition)
+	}
+}
+
+func TestIdentifierEditable(t *testing.T) {
+	assert.True(t, utils.IdentifierEditable(utils.IdentifierStatusDraft))
+	assert.True(t, utils.IdentifierEditable(utils.IdentifierStatusRejected))
+	assert.True(t, utils.IdentifierEditable(utils.IdentifierStatusExpired))
+	assert.False(t, utils.IdentifierEditable(utils.IdentifierStatusSubmitted))
+	assert.False(t, utils.IdentifierEditable(utils.IdentifierStatusVerified))
+}
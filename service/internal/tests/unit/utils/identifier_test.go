@@ -0,0 +1,83 @@
+package unit_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIdentifierValidators(t *testing.T) {
+	options := `{"validators": [{"type": "regex", "pattern": "^[A-Z][0-9]{7}$"}, {"type": "length", "min": 8, "max": 8}]}`
+	validators, err := utils.ParseIdentifierValidators(&options)
+	assert.NoError(t, err)
+	assert.Len(t, validators, 2)
+
+	assert.NoError(t, utils.ValidateIdentifier(validators, "b 1234567"))
+	assert.Error(t, utils.ValidateIdentifier(validators, "12345678"))
+
+	for _, options := range []string{
+		`{"validators": [{"type": "regex", "pattern": "("}]}`,
+		`{"validators": [{"type": "length", "min": 9, "max": 8}]}`,
+		`{"validators": [{"type": "custom", "name": "unknown"}]}`,
+		`{"validators": [{"type": "checksum"}]}`,
+	} {
+		_, err := utils.ParseIdentifierValidators(&options)
+		assert.Error(t, err, options)
+	}
+
+	// types without validators take any ref_num
+	validators, err = utils.ParseIdentifierValidators(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, utils.ValidateIdentifier(validators, "anything"))
+}
+
+func TestIdentifierValidatorMessage(t *testing.T) {
+	options := `{"validators": [{"type": "luhn", "message": "the ref_num is not a card number"}]}`
+	validators, err := utils.ParseIdentifierValidators(&options)
+	assert.NoError(t, err)
+	assert.EqualError(t, utils.ValidateIdentifier(validators, "79927398710"), "the ref_num is not a card number")
+}
+
+func TestCustomIdentifierValidators(t *testing.T) {
+	utils.RegisterIdentifierValidator("even", func(value string) error {
+		if len(value)%2 != 0 {
+			return errors.New("the ref_num must have an even length")
+		}
+		return nil
+	})
+
+	options := `{"validators": [{"type": "custom", "name": "even"}]}`
+	validators, err := utils.ParseIdentifierValidators(&options)
+	assert.NoError(t, err)
+	assert.NoError(t, utils.ValidateIdentifier(validators, "ab"))
+	assert.EqualError(t, utils.ValidateIdentifier(validators, "abc"), "the ref_num must have an even length")
+
+	ktp := `{"validators": [{"type": "custom", "name": "ktp"}]}`
+	validators, err = utils.ParseIdentifierValidators(&ktp)
+	assert.NoError(t, err)
+	assert.NoError(t, utils.ValidateIdentifier(validators, "3171014506900001")) // a woman born on 5 June 1990
+	assert.Error(t, utils.ValidateIdentifier(validators, "0171010506900001"))
+	assert.Error(t, utils.ValidateIdentifier(validators, "3171014513900001")) // month 13
+
+	npwp := `{"validators": [{"type": "custom", "name": "npwp"}]}`
+	validators, err = utils.ParseIdentifierValidators(&npwp)
+	assert.NoError(t, err)
+	assert.NoError(t, utils.ValidateIdentifier(validators, "01.234.567.4-901.000"))
+	assert.Error(t, utils.ValidateIdentifier(validators, "01.234.567.5-901.000"))
+	assert.NoError(t, utils.ValidateIdentifier(validators, "3171014506900001"))
+}
+
+func TestValidLuhn(t *testing.T) {
+	assert.True(t, utils.ValidLuhn("79927398713"))
+	assert.False(t, utils.ValidLuhn("79927398710"))
+	assert.False(t, utils.ValidLuhn("7992739871A"))
+}
+
+func TestValidMod11(t *testing.T) {
+	isbn := []int{2, 3, 4, 5, 6, 7, 8, 9, 10}
+	assert.True(t, utils.ValidMod11("0306406152", isbn))
+	assert.True(t, utils.ValidMod11("080442957X", isbn))
+	assert.False(t, utils.ValidMod11("0306406153", isbn))
+}
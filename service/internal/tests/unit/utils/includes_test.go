@@ -0,0 +1,28 @@
+package unit_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIncludes(t *testing.T) {
+	allowed := []string{"contacts", "addresses", "identifiers", "roles"}
+
+	includes, err := utils.ParseIncludes("contacts, roles,contacts", allowed)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"contacts", "roles"}, includes)
+
+	_, err = utils.ParseIncludes("contacts,password", allowed)
+	assert.True(t, errors.Is(err, utils.ErrInvalidInclude))
+}
+
+func TestNestInto(t *testing.T) {
+	items := []map[string]interface{}{{"id": 1}, {"id": 2}}
+	utils.NestInto(items, "roles", []interface{}{[]string{"superadmin"}, []string{}})
+
+	assert.Equal(t, []string{"superadmin"}, items[0]["roles"])
+	assert.Equal(t, []string{}, items[1]["roles"])
+}
@@ -0,0 +1,25 @@
+package unit_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMatch(t *testing.T) {
+	match, err := utils.ParseMatch(map[string]string{}, 0.3)
+	assert.NoError(t, err)
+	assert.Equal(t, utils.Match{Threshold: 0.3}, match)
+
+	match, err = utils.ParseMatch(map[string]string{"match": "fuzzy", "threshold": "0.45"}, 0.3)
+	assert.NoError(t, err)
+	assert.Equal(t, utils.Match{Fuzzy: true, Threshold: 0.45}, match)
+
+	_, err = utils.ParseMatch(map[string]string{"match": "regex"}, 0.3)
+	assert.True(t, errors.Is(err, utils.ErrInvalidMatch))
+
+	_, err = utils.ParseMatch(map[string]string{"match": "fuzzy", "threshold": "2"}, 0.3)
+	assert.True(t, errors.Is(err, utils.ErrInvalidMatch))
+}
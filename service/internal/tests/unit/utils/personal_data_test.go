@@ -0,0 +1,61 @@
+package unit_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePersonalDataFormat(t *testing.T) {
+	format, err := utils.ParsePersonalDataFormat("")
+	assert.NoError(t, err)
+	assert.Equal(t, utils.PersonalDataFormatJSON, format)
+
+	format, err = utils.ParsePersonalDataFormat("zip")
+	assert.NoError(t, err)
+	assert.Equal(t, utils.PersonalDataFormatZIP, format)
+
+	_, err = utils.ParsePersonalDataFormat("csv")
+	assert.True(t, errors.Is(err, utils.ErrInvalidFilter))
+}
+
+func TestWriteJSONZip(t *testing.T) {
+	modified := time.Date(2026, 10, 19, 15, 30, 0, 0, time.UTC)
+	files := []utils.BundleFile{
+		{Name: "user.json", Data: json.RawMessage(`{"id":1,"name":"Alice"}`)},
+		{Name: "contacts.json", Data: []map[string]interface{}{{"id": 2, "ref_num": "555"}}},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, utils.WriteJSONZip(&buf, files, modified))
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	assert.Len(t, archive.File, 2)
+
+	read := func(i int) interface{} {
+		entry, err := archive.File[i].Open()
+		assert.NoError(t, err)
+		defer entry.Close()
+		raw, err := io.ReadAll(entry)
+		assert.NoError(t, err)
+
+		var data interface{}
+		assert.NoError(t, json.Unmarshal(raw, &data))
+		return data
+	}
+
+	assert.Equal(t, "user.json", archive.File[0].Name)
+	assert.True(t, modified.Equal(archive.File[0].Modified.UTC()))
+	assert.Equal(t, map[string]interface{}{"id": float64(1), "name": "Alice"}, read(0))
+
+	assert.Equal(t, "contacts.json", archive.File[1].Name)
+	assert.Equal(t, []interface{}{map[string]interface{}{"id": float64(2), "ref_num": "555"}}, read(1))
+}
@@ -0,0 +1,29 @@
+package unit_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchQuery(t *testing.T) {
+	assert.Equal(t, "jo:* & doe:*", utils.SearchQuery("  Jo DOE "))
+	assert.Equal(t, "user1@example.com:*", utils.SearchQuery("user1@example.com"))
+	assert.Equal(t, "a:* & b:*", utils.SearchQuery("a&! (b):*"))
+	assert.Equal(t, "", utils.SearchQuery("!& |"))
+}
+
+func TestParseSearchTypes(t *testing.T) {
+	types, err := utils.ParseSearchTypes("")
+	assert.NoError(t, err)
+	assert.Nil(t, types)
+
+	types, err = utils.ParseSearchTypes("users, identifiers")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"users", "identifiers"}, types)
+
+	_, err = utils.ParseSearchTypes("users,roles")
+	assert.True(t, errors.Is(err, utils.ErrInvalidSearchType))
+}
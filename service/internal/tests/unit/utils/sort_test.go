@@ -0,0 +1,77 @@
+package unit_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSort(t *testing.T) {
+	allowed := map[string]string{
+		"id":         "id",
+		"name":       "name",
+		"created_at": "created_at",
+	}
+
+	tests := []struct {
+		name          string
+		filters       map[string]string
+		expectedOrder string
+		expectedErr   error
+	}{
+		{
+			name:          "no sort falls back to id",
+			filters:       map[string]string{},
+			expectedOrder: " ORDER BY id ASC",
+		},
+		{
+			name:          "multiple keys with direction",
+			filters:       map[string]string{"sort": "-created_at,name"},
+			expectedOrder: " ORDER BY created_at DESC, name ASC, id ASC",
+		},
+		{
+			name:          "nulls modifier and default",
+			filters:       map[string]string{"sort": "-created_at:nulls_last,name", "nulls": "first"},
+			expectedOrder: " ORDER BY created_at DESC NULLS LAST, name ASC NULLS FIRST, id ASC",
+		},
+		{
+			name:          "id is not added twice",
+			filters:       map[string]string{"sort": "-id"},
+			expectedOrder: " ORDER BY id DESC",
+		},
+		{
+			name:          "legacy order_column and order_direction",
+			filters:       map[string]string{"order_column": "name", "order_direction": "desc"},
+			expectedOrder: " ORDER BY name DESC, id ASC",
+		},
+		{
+			name:        "unknown key",
+			filters:     map[string]string{"sort": "password"},
+			expectedErr: utils.ErrInvalidSort,
+		},
+		{
+			name:        "injected direction",
+			filters:     map[string]string{"order_column": "name", "order_direction": "desc; DROP TABLE users"},
+			expectedErr: utils.ErrInvalidSort,
+		},
+		{
+			name:        "unknown modifier",
+			filters:     map[string]string{"sort": "name:random"},
+			expectedErr: utils.ErrInvalidSort,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := utils.ParseSort(tt.filters, allowed)
+			if tt.expectedErr != nil {
+				assert.True(t, errors.Is(err, tt.expectedErr))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOrder, spec.OrderBy("id"))
+		})
+	}
+}
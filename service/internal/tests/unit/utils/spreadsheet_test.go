@@ -0,0 +1,69 @@
+package unit_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestReadSpreadsheetCSV(t *testing.T) {
+	data := []byte("\xef\xbb\xbfName,Email\n Alice , alice@example.com\nBob\n")
+
+	rows, err := utils.ReadSpreadsheet("users.CSV", data)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Name", "Email"},
+		{"Alice", "alice@example.com"},
+		{"Bob"},
+	}, rows)
+}
+
+func TestReadSpreadsheetXLSX(t *testing.T) {
+	file := excelize.NewFile()
+	defer file.Close()
+	assert.NoError(t, file.SetSheetRow("Sheet1", "A1", &[]interface{}{"Name", "Email"}))
+	assert.NoError(t, file.SetSheetRow("Sheet1", "A2", &[]interface{}{"Alice ", "alice@example.com"}))
+	buf, err := file.WriteToBuffer()
+	assert.NoError(t, err)
+
+	rows, err := utils.ReadSpreadsheet("users.xlsx", buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Name", "Email"},
+		{"Alice", "alice@example.com"},
+	}, rows)
+}
+
+func TestReadSpreadsheetUnsupported(t *testing.T) {
+	_, err := utils.ReadSpreadsheet("users.xls", []byte("x"))
+	assert.True(t, errors.Is(err, utils.ErrUnsupportedSpreadsheet))
+}
+
+func TestMapColumns(t *testing.T) {
+	fields := map[string][]string{
+		"name":            nil,
+		"type_contact_id": {"type", "contact_type"},
+		"ref_num":         {"contact"},
+	}
+
+	columns, err := utils.MapColumns([]string{"Name", "Contact Type", "Notes", "Contact"}, nil, fields)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"name": 0, "type_contact_id": 1, "ref_num": 3}, columns)
+
+	// an explicit mapping wins over the header
+	columns, err = utils.MapColumns([]string{"Name", "Phone"}, map[string]string{"Phone": "ref_num"}, fields)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"name": 0, "ref_num": 1}, columns)
+
+	_, err = utils.MapColumns([]string{"Name"}, map[string]string{"Name": "password"}, fields)
+	assert.True(t, errors.Is(err, utils.ErrInvalidMapping))
+
+	_, err = utils.MapColumns([]string{"Type", "contact-type"}, nil, fields)
+	assert.True(t, errors.Is(err, utils.ErrInvalidMapping))
+
+	_, err = utils.MapColumns([]string{"Name"}, map[string]string{"Phone": "ref_num"}, fields)
+	assert.True(t, errors.Is(err, utils.ErrInvalidMapping))
+}
@@ -0,0 +1,32 @@
+package unit_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nibroos/nb-go-api/service/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	version, err := utils.ParseIfMatch("")
+	assert.NoError(t, err)
+	assert.Nil(t, version)
+
+	version, err = utils.ParseIfMatch("*")
+	assert.NoError(t, err)
+	assert.Nil(t, version)
+
+	version, err = utils.ParseIfMatch(utils.ETag(7))
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), *version)
+
+	version, err = utils.ParseIfMatch(`W/"3"`)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), *version)
+
+	for _, header := range []string{"3", `"abc"`, `"1", "2"`} {
+		_, err = utils.ParseIfMatch(header)
+		assert.True(t, errors.Is(err, utils.ErrInvalidIfMatch), header)
+	}
+}
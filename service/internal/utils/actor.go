@@ -0,0 +1,24 @@
+package utils
+
+import "context"
+
+// Actor is who makes a write. The unit of work hands it to the database,
+// where triggers fill created_by_id / updated_by_id and the audit log.
+type Actor struct {
+	UserID    *uint // nil for anonymous writes such as register
+	IPAddress string
+	RequestID string
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor carried by ctx, if any.
+func ActorFrom(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok
+}
@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Address is the structured form of an address.
+type Address struct {
+	Line1       string
+	Line2       string
+	City        string
+	Region      string
+	PostalCode  string
+	CountryCode string
+}
+
+// countryCodes are the ISO 3166-1 alpha-2 country codes.
+var countryCodes = func() map[string]bool {
+	codes := make(map[string]bool)
+	for _, code := range strings.Fields(`
+		AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL
+		BM BN BO BQ BR BS BT BV BW BY BZ CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV
+		CW CX CY CZ DE DJ DK DM DO DZ EC EE EG EH ER ES ET FI FJ FK FM FO FR GA GB GD
+		GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM HN HR HT HU ID IE IL IM
+		IN IO IQ IR IS IT JE JM JO JP KE KG KH KI KM KN KP KR KW KY KZ LA LB LC LI LK
+		LR LS LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW
+		MX MY MZ NA NC NE NF NG NI NL NO NP NR NU NZ OM PA PE PF PG PH PK PL PM PN PR
+		PS PT PW PY QA RE RO RS RU RW SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS
+		ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ UA UG UM US UY
+		UZ VA VC VE VG VI VN VU WF WS YE YT ZA ZM ZW`) {
+		codes[code] = true
+	}
+	return codes
+}()
+
+// postalCodePatterns are the formats of the postal codes of the countries
+// that have one we check, as NormalizePostalCode writes them. Postal codes of
+// the other countries only have to look like one.
+var postalCodePatterns = map[string]*regexp.Regexp{
+	"AT": regexp.MustCompile(`^\d{4}$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"BE": regexp.MustCompile(`^\d{4}$`),
+	"BR": regexp.MustCompile(`^\d{5}-\d{3}$`),
+	"CA": regexp.MustCompile(`^[ABCEGHJ-NPRSTVXY]\d[ABCEGHJ-NPRSTV-Z] \d[ABCEGHJ-NPRSTV-Z]\d$`),
+	"CH": regexp.MustCompile(`^\d{4}$`),
+	"CN": regexp.MustCompile(`^\d{6}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"DK": regexp.MustCompile(`^\d{4}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"FI": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}$`),
+	"ID": regexp.MustCompile(`^\d{5}$`),
+	"IN": regexp.MustCompile(`^\d{6}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"JP": regexp.MustCompile(`^\d{3}-\d{4}$`),
+	"KR": regexp.MustCompile(`^\d{5}$`),
+	"MX": regexp.MustCompile(`^\d{5}$`),
+	"MY": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^\d{4} [A-Z]{2}$`),
+	"NO": regexp.MustCompile(`^\d{4}$`),
+	"NZ": regexp.MustCompile(`^\d{4}$`),
+	"PH": regexp.MustCompile(`^\d{4}$`),
+	"PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+	"PT": regexp.MustCompile(`^\d{4}-\d{3}$`),
+	"RU": regexp.MustCompile(`^\d{6}$`),
+	"SE": regexp.MustCompile(`^\d{3} \d{2}$`),
+	"SG": regexp.MustCompile(`^\d{6}$`),
+	"TH": regexp.MustCompile(`^\d{5}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"VN": regexp.MustCompile(`^\d{6}$`),
+}
+
+// anyPostalCode is what a postal code of a country without a pattern has to
+// look like.
+var anyPostalCode = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{1,8}[A-Z0-9]$`)
+
+// postalCodeSpaces are the positions, from the end, where the countries
+// writing their postal codes in two parts put the space.
+var postalCodeSpaces = map[string]int{
+	"CA": 3,
+	"GB": 3,
+	"NL": 2,
+	"SE": 2,
+}
+
+// postalCodeDashes are the positions, from the end, where the countries
+// writing their postal codes in two parts put the dash.
+var postalCodeDashes = map[string]int{
+	"BR": 3,
+	"JP": 4,
+	"PL": 3,
+	"PT": 3,
+}
+
+// ValidCountryCode reports whether code is an ISO 3166-1 alpha-2 country code.
+func ValidCountryCode(code string) bool {
+	return countryCodes[code]
+}
+
+// ValidPostalCode reports whether code is a postal code of country, as
+// NormalizePostalCode writes it. Without a country, or for a country whose
+// format is not checked, it only has to look like a postal code.
+func ValidPostalCode(country string, code string) bool {
+	if pattern, ok := postalCodePatterns[country]; ok {
+		return pattern.MatchString(code)
+	}
+	return anyPostalCode.MatchString(code)
+}
+
+// NormalizeAddress canonicalises the casing and whitespace of an address.
+func NormalizeAddress(a Address) Address {
+	country := NormalizeCountryCode(a.CountryCode)
+	return Address{
+		Line1:       collapseSpaces(a.Line1),
+		Line2:       collapseSpaces(a.Line2),
+		City:        titleIfUniform(collapseSpaces(a.City)),
+		Region:      normalizeRegion(a.Region),
+		PostalCode:  NormalizePostalCode(country, a.PostalCode),
+		CountryCode: country,
+	}
+}
+
+// NormalizeCountryCode upper-cases a country code.
+func NormalizeCountryCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// NormalizePostalCode upper-cases a postal code and, for the countries
+// writing it in two parts, puts the separator where it belongs.
+func NormalizePostalCode(country string, code string) string {
+	code = strings.ToUpper(collapseSpaces(code))
+
+	if at, ok := postalCodeSpaces[country]; ok {
+		compact := strings.ReplaceAll(code, " ", "")
+		if len(compact) > at {
+			return compact[:len(compact)-at] + " " + compact[len(compact)-at:]
+		}
+		return compact
+	}
+	if at, ok := postalCodeDashes[country]; ok {
+		compact := strings.NewReplacer(" ", "", "-", "").Replace(code)
+		if len(compact) > at {
+			return compact[:len(compact)-at] + "-" + compact[len(compact)-at:]
+		}
+		return compact
+	}
+	return code
+}
+
+// FormatAddress writes an address on one line, the way ref_num holds it:
+// "line 1, line 2, city, region postal code, country code".
+func FormatAddress(a Address) string {
+	var parts []string
+	for _, part := range []string{a.Line1, a.Line2, a.City, strings.TrimSpace(a.Region + " " + a.PostalCode), a.CountryCode} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// normalizeRegion upper-cases the abbreviated regions ("ca", "nsw") and
+// title-cases the others like a city.
+func normalizeRegion(region string) string {
+	region = collapseSpaces(region)
+	if len(region) <= 3 && !strings.ContainsRune(region, ' ') {
+		return strings.ToUpper(region)
+	}
+	return titleIfUniform(region)
+}
+
+// collapseSpaces trims s and turns every run of whitespace into one space.
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// titleIfUniform title-cases s when it is all upper or all lower case, and
+// leaves a casing someone chose ("McAllen", "Saint-Étienne") alone.
+func titleIfUniform(s string) string {
+	if s != strings.ToUpper(s) && s != strings.ToLower(s) {
+		return s
+	}
+
+	runes := []rune(strings.ToLower(s))
+	start := true
+	for i, r := range runes {
+		if start && unicode.IsLetter(r) {
+			runes[i] = unicode.ToUpper(r)
+		}
+		start = unicode.IsSpace(r) || r == '-' || r == '.'
+	}
+	return string(runes)
+}
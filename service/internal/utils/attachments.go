@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// AttachmentEntities are the entities files can be attached to.
+var AttachmentEntities = []string{"users", "contacts", "addresses", "identifiers"}
+
+var (
+	// ErrAttachmentEntity is returned when attaching a file to an entity that
+	// takes none, or to a row of it that does not exist.
+	ErrAttachmentEntity = errors.New("the entity does not exist or takes no attachments")
+
+	// ErrAttachmentTooLarge is returned when a file is larger than
+	// ATTACHMENT_MAX_MB.
+	ErrAttachmentTooLarge = errors.New("the file is larger than allowed")
+
+	// ErrAttachmentType is returned when the content of a file is not of one
+	// of the ATTACHMENT_TYPES.
+	ErrAttachmentType = errors.New("the file is not of a type allowed")
+
+	// ErrAttachmentURL is returned when a download URL was not signed by the
+	// service or has expired.
+	ErrAttachmentURL = errors.New("the download URL is invalid or has expired")
+)
+
+// IsAttachmentEntity reports whether files can be attached to entity.
+func IsAttachmentEntity(entity string) bool {
+	for _, e := range AttachmentEntities {
+		if e == entity {
+			return true
+		}
+	}
+	return false
+}
+
+// SniffContentType returns the media type of data, without parameters, as
+// read from its first bytes rather than from its name or what its client
+// claims.
+func SniffContentType(data []byte) string {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// FileStorageKey returns the key the file of SHA-256 sum is stored under,
+// spread over directories by its first bytes. suffix keeps apart the copies
+// two concurrent uploads of the same file store.
+func FileStorageKey(sum string, suffix string) string {
+	return fmt.Sprintf("files/%s/%s/%s-%s", sum[:2], sum[2:4], sum, suffix)
+}
+
+// SignAttachmentURL returns the signature of the download URL of attachment
+// id valid until expires, a Unix time.
+func SignAttachmentURL(secret string, id uint, expires int64) string {
+	return sign(secret, fmt.Sprintf("%d:%d", id, expires))
+}
+
+// VerifyAttachmentURL checks that signature is the one of the download URL of
+// attachment id valid until expires, and that now is not after it.
+func VerifyAttachmentURL(secret string, id uint, expires int64, signature string, now time.Time) error {
+	if now.Unix() > expires {
+		return ErrAttachmentURL
+	}
+	if !hmac.Equal([]byte(SignAttachmentURL(secret, id, expires)), []byte(signature)) {
+		return ErrAttachmentURL
+	}
+	return nil
+}
+
+// sign returns the hex HMAC-SHA256 of message with secret.
+func sign(secret string, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"net/url"
+	"slices"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// avatarQuality is the JPEG quality avatars and their thumbnails are encoded
+// with.
+const avatarQuality = 85
+
+// avatarFormats are the image formats an avatar is decoded from.
+var avatarFormats = []string{"jpeg", "png", "webp"}
+
+var (
+	// ErrAvatarTooLarge is returned when an avatar is larger than
+	// AVATAR_MAX_MB.
+	ErrAvatarTooLarge = errors.New("the avatar is larger than allowed")
+
+	// ErrAvatarImage is returned when an avatar is not a JPEG, PNG or WebP
+	// image, or too large once decoded.
+	ErrAvatarImage = errors.New("the file is not a JPEG, PNG or WebP image of an allowed size")
+
+	// ErrAvatarURL is returned when an avatar URL was not signed by the
+	// service.
+	ErrAvatarURL = errors.New("the avatar URL is invalid")
+)
+
+// DecodeAvatar decodes a JPEG, PNG or WebP image of at most maxPixels pixels,
+// the size being checked before the pixels are decoded. It returns the EXIF
+// orientation of a JPEG, 1 when there is none.
+func DecodeAvatar(data []byte, maxPixels int) (image.Image, int, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || !slices.Contains(avatarFormats, format) {
+		return nil, 0, ErrAvatarImage
+	}
+	if config.Width < 1 || config.Height < 1 || config.Width*config.Height > maxPixels {
+		return nil, 0, ErrAvatarImage
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, ErrAvatarImage
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+	return img, orientation, nil
+}
+
+// SquareAvatar crops the centered square of img, scales it down to at most
+// size pixels a side and turns it upright as orientation, an EXIF orientation,
+// tells. Transparent pixels are made white.
+func SquareAvatar(img image.Image, orientation int, size int) image.Image {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	square := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	side = min(side, size)
+	scaled := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(scaled, scaled.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, square, draw.Over, nil)
+
+	return orient(scaled, orientation)
+}
+
+// EncodeAvatar encodes img as a JPEG. Nothing of the metadata of the image it
+// was decoded from, EXIF included, is kept.
+func EncodeAvatar(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: avatarQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// orient turns a square img upright as an EXIF orientation tells.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	n := img.Bounds().Dx()
+	oriented := image.NewRGBA(img.Bounds())
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			// the pixel of img shown at x, y
+			sx, sy := x, y
+			switch orientation {
+			case 2:
+				sx = n - 1 - x
+			case 3:
+				sx, sy = n-1-x, n-1-y
+			case 4:
+				sy = n - 1 - y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, n-1-x
+			case 7:
+				sx, sy = n-1-y, n-1-x
+			case 8:
+				sx, sy = n-1-y, x
+			}
+			oriented.SetRGBA(x, y, img.RGBAAt(sx, sy))
+		}
+	}
+	return oriented
+}
+
+// jpegOrientation reads the EXIF orientation of a JPEG, 1 when it has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xD9 || marker == 0xDA {
+			// the image data starts, the metadata is before it
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag of the first IFD of the TIFF
+// structure of an EXIF segment, 1 when it has none.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// AvatarURL returns the URL of the avatar of user userID stored as file
+// fileID. A client asks for a thumbnail by adding a size parameter. The URL
+// names the content, it does not expire and is replaced with the avatar.
+func AvatarURL(path string, secret string, userID uint, fileID uint) string {
+	query := url.Values{}
+	query.Set("user_id", fmt.Sprint(userID))
+	query.Set("v", fmt.Sprint(fileID))
+	query.Set("signature", SignAvatarURL(secret, userID, fileID))
+	return path + "?" + query.Encode()
+}
+
+// SignAvatarURL returns the signature of the URL of the avatar of user userID
+// stored as file fileID.
+func SignAvatarURL(secret string, userID uint, fileID uint) string {
+	return sign(secret, fmt.Sprintf("avatar:%d:%d", userID, fileID))
+}
+
+// VerifyAvatarURL checks that signature is the one of the URL of the avatar
+// of user userID stored as file fileID.
+func VerifyAvatarURL(secret string, userID uint, fileID uint, signature string) error {
+	if !hmac.Equal([]byte(SignAvatarURL(secret, userID, fileID)), []byte(signature)) {
+		return ErrAvatarURL
+	}
+	return nil
+}
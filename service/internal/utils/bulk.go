@@ -0,0 +1,23 @@
+package utils
+
+import "errors"
+
+// Modes of the bulk-* endpoints.
+const (
+	BulkModeAtomic  = "atomic"  // nothing is written unless every item succeeds
+	BulkModePartial = "partial" // every item succeeds or fails on its own
+)
+
+// Statuses of an item in the response of a bulk-* endpoint.
+const (
+	BulkStatusCreated  = "created"
+	BulkStatusUpdated  = "updated"
+	BulkStatusDeleted  = "deleted"
+	BulkStatusRestored = "restored"
+	BulkStatusFailed   = "failed"
+	BulkStatusSkipped  = "skipped" // not written, another item of the atomic request failed
+)
+
+// ErrBulkFailed is returned when an item of an atomic bulk request fails and
+// the whole request is rolled back.
+var ErrBulkFailed = errors.New("bulk request failed")
@@ -9,4 +9,17 @@ const (
 	GroupIDUsers = 3
 
 	RoleStudent = 2
+
+	PermissionReadUsers          = "read_users"
+	PermissionReadIdentifiers    = "read_identifiers"
+	PermissionReadAuditLogs      = "read_audit_logs"
+	PermissionImportData         = "import_data"
+	PermissionManagePersonalData = "manage_personal_data"
+	PermissionMergeUsers         = "merge_users"
+	PermissionVerifyIdentifiers  = "verify_identifiers"
+	PermissionManageAttachments  = "manage_attachments"
+
+	AuditActionPurge = "purge"
+	AuditActionErase = "erase"
+	AuditActionMerge = "merge"
 )
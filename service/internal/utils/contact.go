@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// The formats a contact type may declare in its options_json, as
+// {"format": "phone", "default_region": "ID"}.
+const (
+	ContactFormatEmail  = "email"
+	ContactFormatPhone  = "phone"
+	ContactFormatURL    = "url"
+	ContactFormatHandle = "handle"
+)
+
+// ContactFormat is the format the contacts of a type are validated and
+// normalized with. A type without one takes any ref_num.
+type ContactFormat struct {
+	Format        string `json:"format"`
+	DefaultRegion string `json:"default_region"` // phone numbers written without their country code
+}
+
+var handlePattern = regexp.MustCompile(`^[a-z0-9_][a-z0-9_.]{0,29}$`)
+
+// ParseContactFormat reads the format a contact type declares in its
+// options_json. Options that are not an object, or declare an unknown format,
+// declare none.
+func ParseContactFormat(optionsJSON *string) ContactFormat {
+	var format ContactFormat
+	if optionsJSON == nil || json.Unmarshal([]byte(*optionsJSON), &format) != nil {
+		return ContactFormat{}
+	}
+
+	switch format.Format {
+	case ContactFormatEmail, ContactFormatPhone, ContactFormatURL, ContactFormatHandle:
+		format.DefaultRegion = strings.ToUpper(format.DefaultRegion)
+		return format
+	}
+	return ContactFormat{}
+}
+
+// NormalizeContact checks that value is a contact in format and returns it
+// normalized: email addresses lower-cased, phone numbers in E.164, URLs with
+// their scheme and host lower-cased and handles as "@name". Without a format
+// only its whitespace is collapsed.
+func NormalizeContact(format ContactFormat, value string) (string, error) {
+	value = collapseSpaces(value)
+
+	switch format.Format {
+	case ContactFormatEmail:
+		address, err := mail.ParseAddress(value)
+		if err != nil || address.Name != "" || address.Address != value || !strings.Contains(value[strings.LastIndex(value, "@"):], ".") {
+			return "", errors.New("the ref_num must be an email address")
+		}
+		return strings.ToLower(value), nil
+
+	case ContactFormatPhone:
+		number, err := phonenumbers.Parse(value, format.DefaultRegion)
+		if err != nil || !phonenumbers.IsValidNumber(number) {
+			if format.DefaultRegion != "" {
+				return "", errors.New("the ref_num must be a phone number, with its country code outside " + format.DefaultRegion)
+			}
+			return "", errors.New("the ref_num must be a phone number with its country code")
+		}
+		return phonenumbers.Format(number, phonenumbers.E164), nil
+
+	case ContactFormatURL:
+		if !strings.Contains(value, "://") {
+			value = "https://" + value
+		}
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.Contains(u.Hostname(), ".") || strings.ContainsRune(value, ' ') {
+			return "", errors.New("the ref_num must be an http or https URL")
+		}
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+		u.Fragment = ""
+		if u.Path == "/" {
+			u.Path = ""
+		}
+		return u.String(), nil
+
+	case ContactFormatHandle:
+		handle := strings.ToLower(strings.TrimPrefix(value, "@"))
+		if !handlePattern.MatchString(handle) {
+			return "", errors.New("the ref_num must be a handle of letters, digits, _ and .")
+		}
+		return "@" + handle, nil
+	}
+
+	return value, nil
+}
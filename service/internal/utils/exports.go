@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Entities an export can dump, one per index-* endpoint.
+const (
+	ExportEntityUsers       = "users"
+	ExportEntityContacts    = "contacts"
+	ExportEntityAddresses   = "addresses"
+	ExportEntityIdentifiers = "identifiers"
+)
+
+// Formats of an export.
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// Statuses of an export job, run by the worker once queued.
+const (
+	ExportStatusQueued    = "queued"
+	ExportStatusRunning   = "running"
+	ExportStatusCompleted = "completed"
+	ExportStatusFailed    = "failed"
+)
+
+// ErrExportStatus is returned when an export job is not in the status an
+// operation needs, such as downloading an export still running.
+var ErrExportStatus = errors.New("the export is not in a status allowing this")
+
+// ParseExportFormat reads the `format` filter of an export-* request, csv
+// when unset.
+func ParseExportFormat(filters map[string]string) (string, error) {
+	switch format := filters["format"]; format {
+	case "":
+		return ExportFormatCSV, nil
+	case ExportFormatCSV, ExportFormatNDJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: format must be %s or %s", ErrInvalidFilter, ExportFormatCSV, ExportFormatNDJSON)
+	}
+}
+
+// ExportContentType returns the media type of an export format.
+func ExportContentType(format string) string {
+	if format == ExportFormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+// ExportWriter writes the rows of an export as they are read: the header
+// once, then the values of each row in the order of the header.
+type ExportWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []interface{}) error
+	Flush() error
+}
+
+// NewExportWriter returns the ExportWriter of format writing to w.
+func NewExportWriter(format string, w io.Writer) ExportWriter {
+	if format == ExportFormatNDJSON {
+		return &ndjsonExportWriter{w: bufio.NewWriter(w)}
+	}
+	return &csvExportWriter{w: csv.NewWriter(w)}
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (e *csvExportWriter) WriteHeader(columns []string) error {
+	return e.w.Write(columns)
+}
+
+func (e *csvExportWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := exportValue(value).(type) {
+		case nil:
+		case time.Time:
+			record[i] = v.Format(time.RFC3339)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvExportWriter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// ndjsonExportWriter writes a JSON object per line, its keys in the order of
+// the columns.
+type ndjsonExportWriter struct {
+	w       *bufio.Writer
+	columns [][]byte
+}
+
+func (e *ndjsonExportWriter) WriteHeader(columns []string) error {
+	e.columns = make([][]byte, len(columns))
+	for i, column := range columns {
+		key, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		e.columns[i] = key
+	}
+	return nil
+}
+
+func (e *ndjsonExportWriter) WriteRow(values []interface{}) error {
+	e.w.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		raw, err := json.Marshal(exportValue(value))
+		if err != nil {
+			return err
+		}
+		e.w.Write(e.columns[i])
+		e.w.WriteByte(':')
+		e.w.Write(raw)
+	}
+	e.w.WriteByte('}')
+	_, err := e.w.WriteString("\n")
+	return err
+}
+
+func (e *ndjsonExportWriter) Flush() error {
+	return e.w.Flush()
+}
+
+// exportValue turns the text the driver returns as bytes into a string.
+func exportValue(value interface{}) interface{} {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value
+}
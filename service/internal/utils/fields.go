@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	// ErrInvalidFields is returned when `fields` names a field that does not
+	// exist on the resource.
+	ErrInvalidFields = errors.New("invalid fields")
+
+	// ErrFieldForbidden is returned when `fields` selects a sensitive field the
+	// caller has no permission to read.
+	ErrFieldForbidden = errors.New("field not permitted")
+)
+
+// Field describes a selectable field of a resource.
+type Field struct {
+	Column     string // SQL expression, empty for fields loaded by a separate query
+	Permission string // permission required to select the field, empty if public
+}
+
+// ParseFields validates a comma separated `fields` value against the allowed
+// fields of a resource. The id field is always part of the result. An empty
+// value returns nil, meaning every field.
+func ParseFields(raw string, allowed map[string]Field) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	keys := []string{}
+	seen := make(map[string]bool)
+	if _, ok := allowed["id"]; ok {
+		keys = append(keys, "id")
+		seen["id"] = true
+	}
+
+	for _, key := range strings.Split(raw, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		if _, ok := allowed[key]; !ok {
+			return nil, fmt.Errorf("%w: %s is not a selectable field", ErrInvalidFields, key)
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// SelectFields resolves the fields the current caller receives. Explicitly
+// requested sensitive fields without the matching permission are rejected,
+// while the default (no `fields`) silently leaves them out. Owners reading
+// their own records (the auth-* endpoints) may select every field.
+func SelectFields(ctx *fiber.Ctx, raw string, allowed map[string]Field, owner bool) ([]string, error) {
+	keys, err := ParseFields(raw, allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	if keys == nil {
+		for key, field := range allowed {
+			if owner || field.Permission == "" || HasPermission(ctx, field.Permission) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		return keys, nil
+	}
+
+	if owner {
+		return keys, nil
+	}
+
+	for _, key := range keys {
+		if permission := allowed[key].Permission; permission != "" && !HasPermission(ctx, permission) {
+			return nil, fmt.Errorf("%w: %s requires the %s permission", ErrFieldForbidden, key, permission)
+		}
+	}
+
+	return keys, nil
+}
+
+// SelectColumns renders the SQL projection for the given keys. Nil keys
+// select every field of the resource.
+func SelectColumns(keys []string, allowed map[string]Field) string {
+	if keys == nil {
+		for key := range allowed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	columns := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if field, ok := allowed[key]; ok && field.Column != "" {
+			columns = append(columns, field.Column)
+		}
+	}
+
+	return strings.Join(columns, ", ")
+}
+
+// HasField reports whether key is part of the selection. Nil keys select
+// every field.
+func HasField(keys []string, key string) bool {
+	if keys == nil {
+		return true
+	}
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// PickFields narrows the JSON representation of a DTO, a slice of DTOs or a
+// slice of interfaces to the given keys.
+func PickFields(data interface{}, keys []string) (interface{}, error) {
+	if keys == nil {
+		return data, nil
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pick := func(item map[string]interface{}) map[string]interface{} {
+		picked := make(map[string]interface{}, len(keys))
+		for _, key := range keys {
+			if value, ok := item[key]; ok {
+				picked[key] = value
+			}
+		}
+		return picked
+	}
+
+	if len(raw) > 0 && raw[0] == '[' {
+		var items []map[string]interface{}
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		picked := make([]map[string]interface{}, 0, len(items))
+		for _, item := range items {
+			picked = append(picked, pick(item))
+		}
+		return picked, nil
+	}
+
+	var item map[string]interface{}
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, nil
+	}
+
+	return pick(item), nil
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidFilter is returned when a filter holds a value of the wrong type.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// ParseIDFilter reads the ID held by filters[key]. ok is false when the
+// filter is not set.
+func ParseIDFilter(filters map[string]string, key string) (id uint, ok bool, err error) {
+	raw := filters[key]
+	if raw == "" {
+		return 0, false, nil
+	}
+
+	value, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: %s must be an ID", ErrInvalidFilter, key)
+	}
+	return uint(value), true, nil
+}
+
+// ParseTimeFilter reads the RFC 3339 timestamp or YYYY-MM-DD date (midnight
+// UTC) held by filters[key]. ok is false when the filter is not set.
+func ParseTimeFilter(filters map[string]string, key string) (at time.Time, ok bool, err error) {
+	raw := filters[key]
+	if raw == "" {
+		return time.Time{}, false, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if at, err := time.Parse(layout, raw); err == nil {
+			return at, true, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("%w: %s must be a date or an RFC 3339 timestamp", ErrInvalidFilter, key)
+}
+
+// ParseFloatFilter reads the number held by filters[key]. ok is false when
+// the filter is not set.
+func ParseFloatFilter(filters map[string]string, key string) (value float64, ok bool, err error) {
+	raw := filters[key]
+	if raw == "" {
+		return 0, false, nil
+	}
+
+	value, err = strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false, fmt.Errorf("%w: %s must be a number", ErrInvalidFilter, key)
+	}
+	return value, true, nil
+}
+
+// ParseDaysFilter reads the number of days, 0 or more, held by filters[key].
+// ok is false when the filter is not set.
+func ParseDaysFilter(filters map[string]string, key string) (days int, ok bool, err error) {
+	raw := filters[key]
+	if raw == "" {
+		return 0, false, nil
+	}
+
+	days, err = strconv.Atoi(raw)
+	if err != nil || days < 0 {
+		return 0, false, fmt.Errorf("%w: %s must be a number of days", ErrInvalidFilter, key)
+	}
+	return days, true, nil
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GeoPoint is a position in degrees.
+type GeoPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// BoundingBox is the area between two corners. MinLongitude is greater than
+// MaxLongitude for a box across the antimeridian.
+type BoundingBox struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+// NearFilter is the near and radius filters of a list: the rows within
+// RadiusKm of Point, or all of them by distance when RadiusKm is nil.
+type NearFilter struct {
+	Point    GeoPoint
+	RadiusKm *float64
+}
+
+// ParseNearFilter reads near=lat,lng and radius, in kilometres. ok is false
+// when near is not set; radius is only taken with it.
+func ParseNearFilter(filters map[string]string) (near NearFilter, ok bool, err error) {
+	raw := filters["near"]
+	if raw == "" {
+		if filters["radius"] != "" {
+			return NearFilter{}, false, fmt.Errorf("%w: radius needs near", ErrInvalidFilter)
+		}
+		return NearFilter{}, false, nil
+	}
+
+	values, err := parseCoordinates(raw, 2)
+	if err != nil || !validLatitude(values[0]) || !validLongitude(values[1]) {
+		return NearFilter{}, false, fmt.Errorf("%w: near must be latitude,longitude", ErrInvalidFilter)
+	}
+	near.Point = GeoPoint{Latitude: values[0], Longitude: values[1]}
+
+	radius, ok, err := ParseFloatFilter(filters, "radius")
+	if err != nil {
+		return NearFilter{}, false, err
+	}
+	if ok {
+		if radius <= 0 {
+			return NearFilter{}, false, fmt.Errorf("%w: radius must be positive", ErrInvalidFilter)
+		}
+		near.RadiusKm = &radius
+	}
+	return near, true, nil
+}
+
+// ParseBoundingBoxFilter reads bbox=min_lat,min_lng,max_lat,max_lng. ok is
+// false when the filter is not set.
+func ParseBoundingBoxFilter(filters map[string]string) (box BoundingBox, ok bool, err error) {
+	raw := filters["bbox"]
+	if raw == "" {
+		return BoundingBox{}, false, nil
+	}
+
+	values, err := parseCoordinates(raw, 4)
+	if err != nil || !validLatitude(values[0]) || !validLongitude(values[1]) || !validLatitude(values[2]) || !validLongitude(values[3]) || values[0] > values[2] {
+		return BoundingBox{}, false, fmt.Errorf("%w: bbox must be min_latitude,min_longitude,max_latitude,max_longitude", ErrInvalidFilter)
+	}
+	return BoundingBox{MinLatitude: values[0], MinLongitude: values[1], MaxLatitude: values[2], MaxLongitude: values[3]}, true, nil
+}
+
+// parseCoordinates reads n numbers separated by commas.
+func parseCoordinates(raw string, n int) ([]float64, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("%d numbers expected", n)
+	}
+
+	values := make([]float64, n)
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("%q is not a number", part)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func validLatitude(value float64) bool {
+	return value >= -90 && value <= 90
+}
+
+func validLongitude(value float64) bool {
+	return value >= -180 && value <= 180
+}
@@ -0,0 +1,14 @@
+package utils
+
+// HeaderIdempotencyKey is the header making a create-*, update-* or bulk-*
+// request safe to retry.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// HeaderIdempotentReplayed marks a response replayed for an Idempotency-Key.
+const HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+// Statuses of an Idempotency-Key.
+const (
+	IdempotencyStatusProcessing = "processing"
+	IdempotencyStatusCompleted  = "completed"
+)
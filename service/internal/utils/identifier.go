@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The validators an identifier type may declare in its options_json, as
+// {"validators": [{"type": "length", "min": 16, "max": 16}, {"type": "luhn"}]}.
+const (
+	IdentifierValidatorRegex  = "regex"
+	IdentifierValidatorLength = "length"
+	IdentifierValidatorLuhn   = "luhn"
+	IdentifierValidatorMod11  = "mod11"
+	IdentifierValidatorCustom = "custom"
+)
+
+// IdentifierValidator checks the compact form of a ref_num, see
+// CompactIdentifier. Its error is the field error of the ref_num.
+type IdentifierValidator func(value string) error
+
+// IdentifierValidatorSpec is one validator of an identifier type.
+type IdentifierValidatorSpec struct {
+	Type    string `json:"type"`
+	Pattern string `json:"pattern"` // regex
+	Min     int    `json:"min"`     // length
+	Max     int    `json:"max"`     // length, 0 for no maximum
+	Weights []int  `json:"weights"` // mod11, from the digit left of the check digit leftwards
+	Name    string `json:"name"`    // custom, as registered with RegisterIdentifierValidator
+	Message string `json:"message"` // replaces the error of the validator
+}
+
+var (
+	customIdentifierValidators   = map[string]IdentifierValidator{"ktp": validKTP, "npwp": validNPWP}
+	customIdentifierValidatorsMu sync.RWMutex
+
+	identifierSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "/", "")
+	defaultMod11Weights  = []int{2, 3, 4, 5, 6, 7}
+)
+
+// RegisterIdentifierValidator makes validator available to the identifier
+// types declaring {"type": "custom", "name": name}.
+func RegisterIdentifierValidator(name string, validator IdentifierValidator) {
+	customIdentifierValidatorsMu.Lock()
+	defer customIdentifierValidatorsMu.Unlock()
+	customIdentifierValidators[name] = validator
+}
+
+// CompactIdentifier is the form of a ref_num identifier validators check:
+// without spaces, dashes, dots and slashes, upper-cased. 01.234.567.8-901.000
+// is checked as 012345678901000.
+func CompactIdentifier(value string) string {
+	return strings.ToUpper(identifierSeparators.Replace(value))
+}
+
+// ParseIdentifierValidators builds the validators an identifier type declares
+// in its options_json. Options without validators declare none; a validator
+// that cannot be built is an error of the type, not of its identifiers.
+func ParseIdentifierValidators(optionsJSON *string) ([]IdentifierValidator, error) {
+	if optionsJSON == nil {
+		return nil, nil
+	}
+
+	var options struct {
+		Validators []IdentifierValidatorSpec `json:"validators"`
+	}
+	if err := json.Unmarshal([]byte(*optionsJSON), &options); err != nil {
+		return nil, nil
+	}
+
+	validators := make([]IdentifierValidator, 0, len(options.Validators))
+	for i, spec := range options.Validators {
+		validator, err := spec.build()
+		if err != nil {
+			return nil, fmt.Errorf("validators.%d: %w", i, err)
+		}
+		if spec.Message != "" {
+			validator = withMessage(validator, spec.Message)
+		}
+		validators = append(validators, validator)
+	}
+	return validators, nil
+}
+
+// ValidateIdentifier checks value against validators, returning the error of
+// the first it fails.
+func ValidateIdentifier(validators []IdentifierValidator, value string) error {
+	compact := CompactIdentifier(value)
+	for _, validator := range validators {
+		if err := validator(compact); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (spec IdentifierValidatorSpec) build() (IdentifierValidator, error) {
+	switch spec.Type {
+	case IdentifierValidatorRegex:
+		pattern, err := regexp.Compile(spec.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		return func(value string) error {
+			if !pattern.MatchString(value) {
+				return errors.New("the ref_num is not in the format of its type")
+			}
+			return nil
+		}, nil
+
+	case IdentifierValidatorLength:
+		if spec.Min < 0 || (spec.Max != 0 && spec.Max < spec.Min) {
+			return nil, errors.New("min and max must be a range")
+		}
+		return func(value string) error {
+			n := len([]rune(value))
+			switch {
+			case spec.Max == spec.Min && n != spec.Min:
+				return fmt.Errorf("the ref_num must be %d characters", spec.Min)
+			case n < spec.Min:
+				return fmt.Errorf("the ref_num must be at least %d characters", spec.Min)
+			case spec.Max != 0 && n > spec.Max:
+				return fmt.Errorf("the ref_num may not be more than %d characters", spec.Max)
+			}
+			return nil
+		}, nil
+
+	case IdentifierValidatorLuhn:
+		return func(value string) error {
+			if !ValidLuhn(value) {
+				return errors.New("the ref_num has an invalid check digit")
+			}
+			return nil
+		}, nil
+
+	case IdentifierValidatorMod11:
+		weights := spec.Weights
+		if len(weights) == 0 {
+			weights = defaultMod11Weights
+		}
+		return func(value string) error {
+			if !ValidMod11(value, weights) {
+				return errors.New("the ref_num has an invalid check digit")
+			}
+			return nil
+		}, nil
+
+	case IdentifierValidatorCustom:
+		customIdentifierValidatorsMu.RLock()
+		validator, ok := customIdentifierValidators[spec.Name]
+		customIdentifierValidatorsMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown custom validator %q", spec.Name)
+		}
+		return validator, nil
+	}
+
+	return nil, fmt.Errorf("unknown validator type %q", spec.Type)
+}
+
+func withMessage(validator IdentifierValidator, message string) IdentifierValidator {
+	return func(value string) error {
+		if validator(value) != nil {
+			return errors.New(message)
+		}
+		return nil
+	}
+}
+
+// ValidLuhn reports whether value is digits whose last is their Luhn check
+// digit.
+func ValidLuhn(value string) bool {
+	if len(value) < 2 || !isDigits(value) {
+		return false
+	}
+
+	sum := 0
+	for i := len(value) - 1; i >= 0; i-- {
+		digit := int(value[i] - '0')
+		if (len(value)-1-i)%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
+
+// ValidMod11 reports whether value is digits whose last is their mod-11
+// check digit: 11 minus the sum of the other digits times weights, the first
+// weight applying to the digit left of the check digit and the weights
+// repeating leftwards, modulo 11. A check digit of 10 is written X.
+func ValidMod11(value string, weights []int) bool {
+	if len(value) < 2 || len(weights) == 0 || !isDigits(value[:len(value)-1]) {
+		return false
+	}
+
+	sum := 0
+	for i := len(value) - 2; i >= 0; i-- {
+		sum += int(value[i]-'0') * weights[(len(value)-2-i)%len(weights)]
+	}
+
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return value[len(value)-1] == 'X'
+	}
+	return value[len(value)-1] == byte('0'+check)
+}
+
+// validKTP checks an Indonesian NIK: 16 digits, starting with the code of a
+// province, the date of birth in the 7th to 12th, 40 added to the day for women.
+func validKTP(value string) error {
+	if len(value) != 16 || !isDigits(value) {
+		return errors.New("the ref_num must be 16 digits")
+	}
+
+	province, _ := strconv.Atoi(value[0:2])
+	day, _ := strconv.Atoi(value[6:8])
+	month, _ := strconv.Atoi(value[8:10])
+	if day > 40 {
+		day -= 40
+	}
+	if province < 11 || province > 94 || day < 1 || day > 31 || month < 1 || month > 12 {
+		return errors.New("the ref_num is not a valid NIK")
+	}
+	return nil
+}
+
+// validNPWP checks an Indonesian tax number: 15 digits, the 9th the Luhn check
+// digit of the first 8, or, since 2024, the 16 digits of a NIK.
+func validNPWP(value string) error {
+	if len(value) == 16 {
+		return validKTP(value)
+	}
+	if len(value) != 15 || !isDigits(value) {
+		return errors.New("the ref_num must be 15 or 16 digits")
+	}
+	if !ValidLuhn(value[:9]) {
+		return errors.New("the ref_num has an invalid check digit")
+	}
+	return nil
+}
+
+func isDigits(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
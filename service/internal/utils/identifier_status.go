@@ -0,0 +1,57 @@
+package utils
+
+import "errors"
+
+// Statuses of an identifier. A new identifier is a draft its owner submits
+// for review; a reviewer verifies or rejects it. A rejected one is submitted
+// again once corrected, a verified one expires, and changing a verified or
+// expired one submits it for re-verification.
+const (
+	IdentifierStatusDraft     = "draft"
+	IdentifierStatusSubmitted = "submitted"
+	IdentifierStatusVerified  = "verified"
+	IdentifierStatusRejected  = "rejected"
+	IdentifierStatusExpired   = "expired"
+)
+
+// identifierTransitions maps each status to the ones an identifier in it can
+// move to.
+var identifierTransitions = map[string][]string{
+	IdentifierStatusDraft:     {IdentifierStatusSubmitted},
+	IdentifierStatusSubmitted: {IdentifierStatusVerified, IdentifierStatusRejected},
+	IdentifierStatusRejected:  {IdentifierStatusSubmitted},
+	IdentifierStatusVerified:  {IdentifierStatusSubmitted, IdentifierStatusExpired},
+	IdentifierStatusExpired:   {IdentifierStatusSubmitted},
+}
+
+var (
+	// ErrIdentifierTransition is returned when an identifier cannot move from
+	// its status to the one asked for, such as verifying a draft.
+	ErrIdentifierTransition = errors.New("the identifier is not in a status allowing this")
+
+	// ErrIdentifierLocked is returned when updating an identifier under review
+	// or verified, which only changes through re-verification.
+	ErrIdentifierLocked = errors.New("the identifier is submitted or verified, it only changes through re-verification")
+)
+
+// CanTransitionIdentifier reports whether an identifier can move from status
+// from to status to.
+func CanTransitionIdentifier(from, to string) bool {
+	for _, status := range identifierTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IdentifierEditable reports whether an identifier in status can be updated
+// in place: drafts, rejected and expired ones can, submitted and verified
+// ones cannot.
+func IdentifierEditable(status string) bool {
+	switch status {
+	case IdentifierStatusDraft, IdentifierStatusRejected, IdentifierStatusExpired:
+		return true
+	}
+	return false
+}
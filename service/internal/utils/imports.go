@@ -0,0 +1,23 @@
+package utils
+
+import "errors"
+
+// Entities an import can create.
+const (
+	ImportEntityUsers    = "users"
+	ImportEntityContacts = "contacts"
+)
+
+// Statuses of an import job. An upload is validated right away; committing
+// queues it for the worker.
+const (
+	ImportStatusValidated = "validated"
+	ImportStatusQueued    = "queued"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+// ErrImportStatus is returned when an import job is not in the status an
+// operation needs, such as committing an import twice.
+var ErrImportStatus = errors.New("the import is not in a status allowing this")
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidInclude is returned when `include` names a relation the resource
+// cannot embed.
+var ErrInvalidInclude = errors.New("invalid include")
+
+// ParseIncludes validates a comma separated `include` value against the
+// relations a resource can embed.
+func ParseIncludes(raw string, allowed []string) ([]string, error) {
+	includes := []string{}
+	seen := make(map[string]bool)
+
+	for _, include := range strings.Split(raw, ",") {
+		include = strings.TrimSpace(include)
+		if include == "" || seen[include] {
+			continue
+		}
+
+		valid := false
+		for _, relation := range allowed {
+			if include == relation {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("%w: %s cannot be included", ErrInvalidInclude, include)
+		}
+
+		seen[include] = true
+		includes = append(includes, include)
+	}
+
+	return includes, nil
+}
+
+// HasInclude reports whether relation is part of the includes.
+func HasInclude(includes []string, relation string) bool {
+	for _, include := range includes {
+		if include == relation {
+			return true
+		}
+	}
+	return false
+}
+
+// NestInto adds values[i] under key to the i-th item returned by PickFields.
+// A single item (show endpoints) takes values[0].
+func NestInto(data interface{}, key string, values []interface{}) {
+	switch items := data.(type) {
+	case []map[string]interface{}:
+		for i, item := range items {
+			if i < len(values) {
+				item[key] = values[i]
+			}
+		}
+	case map[string]interface{}:
+		if len(values) > 0 {
+			items[key] = values[0]
+		}
+	}
+}
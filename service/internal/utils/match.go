@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrInvalidMatch is returned when `match` or `threshold` hold an unsupported
+// value.
+var ErrInvalidMatch = errors.New("invalid match")
+
+// Match describes how the global filter compares values.
+type Match struct {
+	Fuzzy     bool    // pg_trgm similarity instead of ILIKE
+	Threshold float64 // minimum similarity of a fuzzy match
+}
+
+// ParseMatch reads `match` (contains, the default, or fuzzy) and the optional
+// `threshold` overriding defaultThreshold for fuzzy matching.
+func ParseMatch(filters map[string]string, defaultThreshold float64) (Match, error) {
+	match := Match{Threshold: defaultThreshold}
+
+	switch filters["match"] {
+	case "", "contains":
+	case "fuzzy":
+		match.Fuzzy = true
+	default:
+		return match, fmt.Errorf("%w: match must be contains or fuzzy", ErrInvalidMatch)
+	}
+
+	if raw := filters["threshold"]; raw != "" {
+		threshold, err := strconv.ParseFloat(raw, 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			return match, fmt.Errorf("%w: threshold must be a number between 0 and 1", ErrInvalidMatch)
+		}
+		match.Threshold = threshold
+	}
+
+	return match, nil
+}
@@ -0,0 +1,7 @@
+package utils
+
+import "errors"
+
+// ErrMergeUsers is returned when merging users that are not both live and
+// not erased.
+var ErrMergeUsers = errors.New("both users have to exist, not be deleted and not be erased")
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Formats of a personal data export.
+const (
+	PersonalDataFormatJSON = "json"
+	PersonalDataFormatZIP  = "zip"
+)
+
+// ErrUserErased is returned when erasing a user whose personal data was
+// already erased.
+var ErrUserErased = errors.New("the personal data of the user was already erased")
+
+// ErasedValue replaces erased personal data in the audit log and history.
+const ErasedValue = "[erased]"
+
+// ParsePersonalDataFormat reads the `format` of an export-personal-data-user
+// request, json when unset.
+func ParsePersonalDataFormat(format string) (string, error) {
+	switch format {
+	case "":
+		return PersonalDataFormatJSON, nil
+	case PersonalDataFormatJSON, PersonalDataFormatZIP:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: format must be %s or %s", ErrInvalidFilter, PersonalDataFormatJSON, PersonalDataFormatZIP)
+	}
+}
+
+// BundleFile is a JSON document of a ZIP bundle.
+type BundleFile struct {
+	Name string
+	Data interface{}
+}
+
+// WriteJSONZip writes files to w as a ZIP archive, each one an indented JSON
+// document stamped with modified.
+func WriteJSONZip(w io.Writer, files []BundleFile, modified time.Time) error {
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		entry, err := archive.CreateHeader(&zip.FileHeader{Name: file.Name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.Data); err != nil {
+			return fmt.Errorf("%s: %w", file.Name, err)
+		}
+	}
+	return archive.Close()
+}
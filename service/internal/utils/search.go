@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidSearchType is returned when `types` names an entity type the
+// global search does not cover.
+var ErrInvalidSearchType = errors.New("invalid search type")
+
+// SearchTypes are the entity types the global search covers.
+var SearchTypes = []string{"users", "contacts", "addresses", "identifiers"}
+
+// ParseSearchTypes validates a comma separated `types` value. An empty value
+// returns nil, meaning every type.
+func ParseSearchTypes(raw string) ([]string, error) {
+	var types []string
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+
+		valid := false
+		for _, searchType := range SearchTypes {
+			if t == searchType {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSearchType, t)
+		}
+
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// SearchQuery turns free text into a prefix matching tsquery, every term
+// having to match (`jo doe` becomes `jo:* & doe:*`). Characters with a
+// meaning in tsquery syntax are dropped; an empty string means nothing is
+// left to search for.
+func SearchQuery(raw string) string {
+	terms := []string{}
+	for _, word := range strings.Fields(raw) {
+		term := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("@.-_", r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, word)
+		if term != "" {
+			terms = append(terms, term+":*")
+		}
+	}
+	return strings.Join(terms, " & ")
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidSort is returned when a sort spec references a key that is not
+// allowed for the resource or uses an unknown direction / nulls modifier.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// SortKey is a single validated entry of a sort spec.
+type SortKey struct {
+	Column string
+	Desc   bool
+	Nulls  string // "FIRST", "LAST" or "" for the database default
+}
+
+// SortSpec is an ordered list of validated sort keys.
+type SortSpec []SortKey
+
+// ParseSort builds a SortSpec from the filters of a list request.
+//
+// The `sort` filter takes a comma separated list of keys, a leading "-" means
+// descending and an optional ":nulls_first" / ":nulls_last" suffix controls
+// where NULL values are placed, e.g. `sort=-created_at:nulls_last,name`.
+// The `nulls` filter ("first" or "last") sets the default for keys without a
+// suffix. When `sort` is empty the legacy `order_column` / `order_direction`
+// pair is used instead.
+//
+// allowedColumns maps the public sort key to the SQL column it orders by.
+func ParseSort(filters map[string]string, allowedColumns map[string]string) (SortSpec, error) {
+	defaultNulls, err := parseNulls(filters["nulls"])
+	if err != nil {
+		return nil, err
+	}
+
+	raw := strings.TrimSpace(filters["sort"])
+	if raw == "" {
+		column := strings.TrimSpace(filters["order_column"])
+		if column == "" {
+			return SortSpec{}, nil
+		}
+
+		switch strings.ToLower(strings.TrimSpace(filters["order_direction"])) {
+		case "", "asc":
+			raw = column
+		case "desc":
+			raw = "-" + column
+		default:
+			return nil, fmt.Errorf("%w: order_direction must be asc or desc", ErrInvalidSort)
+		}
+	}
+
+	spec := SortSpec{}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		key := SortKey{Nulls: defaultNulls}
+		if strings.HasPrefix(part, "-") {
+			key.Desc = true
+			part = part[1:]
+		} else if strings.HasPrefix(part, "+") {
+			part = part[1:]
+		}
+
+		if name, modifier, ok := strings.Cut(part, ":"); ok {
+			part = name
+			switch modifier {
+			case "nulls_first":
+				key.Nulls = "FIRST"
+			case "nulls_last":
+				key.Nulls = "LAST"
+			default:
+				return nil, fmt.Errorf("%w: unknown modifier %q on %s", ErrInvalidSort, modifier, name)
+			}
+		}
+
+		column, ok := allowedColumns[part]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not a sortable field", ErrInvalidSort, part)
+		}
+		if seen[part] {
+			continue
+		}
+		seen[part] = true
+
+		key.Column = column
+		spec = append(spec, key)
+	}
+
+	return spec, nil
+}
+
+// OrderBy renders the spec as an ORDER BY clause. The tiebreaker column is
+// always appended (unless already present) so that pagination is stable.
+func (s SortSpec) OrderBy(tiebreaker string) string {
+	parts := make([]string, 0, len(s)+1)
+	hasTiebreaker := false
+
+	for _, key := range s {
+		part := key.Column
+		if key.Desc {
+			part += " DESC"
+		} else {
+			part += " ASC"
+		}
+		if key.Nulls != "" {
+			part += " NULLS " + key.Nulls
+		}
+		if key.Column == tiebreaker {
+			hasTiebreaker = true
+		}
+		parts = append(parts, part)
+	}
+
+	if !hasTiebreaker {
+		parts = append(parts, tiebreaker+" ASC")
+	}
+
+	return " ORDER BY " + strings.Join(parts, ", ")
+}
+
+func parseNulls(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return "", nil
+	case "first":
+		return "FIRST", nil
+	case "last":
+		return "LAST", nil
+	default:
+		return "", fmt.Errorf("%w: nulls must be first or last", ErrInvalidSort)
+	}
+}
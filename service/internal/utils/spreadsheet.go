@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ErrUnsupportedSpreadsheet is returned by ReadSpreadsheet for files that are
+// neither CSV nor XLSX.
+var ErrUnsupportedSpreadsheet = errors.New("unsupported spreadsheet, expected a .csv or .xlsx file")
+
+// ReadSpreadsheet reads the rows of a CSV file or of the first sheet of an
+// XLSX file, the format being told by the extension of fileName. Cells are
+// trimmed; rows may have fewer cells than the header.
+func ReadSpreadsheet(fileName string, data []byte) ([][]string, error) {
+	var rows [][]string
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+		reader.FieldsPerRecord = -1
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		rows = records
+	case ".xlsx":
+		file, err := excelize.OpenReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		sheets := file.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, nil
+		}
+		if rows, err = file.GetRows(sheets[0]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedSpreadsheet
+	}
+
+	for _, row := range rows {
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+	}
+	return rows, nil
+}
+
+// ErrInvalidMapping is returned by MapColumns for a mapping naming an unknown
+// field or two columns for the same field.
+var ErrInvalidMapping = errors.New("invalid column mapping")
+
+// MapColumns matches the header row of a spreadsheet to fields, given as
+// field name to aliases. mapping names the field of a header cell
+// explicitly; the other cells match the field or alias equal to their
+// normalized text (lower case, spaces and dashes as underscores), or nothing.
+// It returns the column index of each matched field.
+func MapColumns(header []string, mapping map[string]string, fields map[string][]string) (map[string]int, error) {
+	names := make(map[string]string)
+	for field, aliases := range fields {
+		names[field] = field
+		for _, alias := range aliases {
+			names[alias] = field
+		}
+	}
+
+	columns := make(map[string]int)
+	for i, cell := range header {
+		field, mapped := mapping[cell]
+		if mapped {
+			if _, ok := fields[field]; !ok {
+				return nil, fmt.Errorf("%w: unknown field %q for column %q", ErrInvalidMapping, field, cell)
+			}
+		} else {
+			normalized := strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(cell))
+			if field = names[normalized]; field == "" {
+				continue
+			}
+		}
+
+		if other, ok := columns[field]; ok {
+			return nil, fmt.Errorf("%w: columns %q and %q both map to %s", ErrInvalidMapping, header[other], cell, field)
+		}
+		columns[field] = i
+	}
+
+	for cell := range mapping {
+		if !slices.Contains(header, cell) {
+			return nil, fmt.Errorf("%w: no column %q", ErrInvalidMapping, cell)
+		}
+	}
+
+	return columns, nil
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"reflect"
 	"runtime"
@@ -96,6 +97,29 @@ func SendResponse(ctx *fiber.Ctx, response Response, statusCode int) error {
 	return ctx.Status(statusCode).JSON(response)
 }
 
+// SendQueryError responds with the status QueryErrorStatus picks for err.
+func SendQueryError(ctx *fiber.Ctx, err error) error {
+	status := QueryErrorStatus(err)
+	return SendResponse(ctx, WrapResponse(nil, nil, err.Error(), int16(status)), status)
+}
+
+// QueryErrorStatus maps errors caused by invalid query parameters (sort,
+// fields, ...) to a client error status, anything else is a server error.
+func QueryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidSort), errors.Is(err, ErrInvalidFields), errors.Is(err, ErrInvalidInclude),
+		errors.Is(err, ErrInvalidSearchType), errors.Is(err, ErrInvalidMatch),
+		errors.Is(err, ErrInvalidIfMatch), errors.Is(err, ErrInvalidFilter):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrFieldForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrVersionConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func AtoiDefault(str string, def int) int {
 	value, err := strconv.Atoi(str)
 	if err != nil {
@@ -307,9 +331,14 @@ func executeSQLFile(db *sql.DB, filePath string) error {
 
 // BodyParserWithNull converts empty strings to null and parses the request body into the provided struct.
 func BodyParserWithNull(ctx *fiber.Ctx, out interface{}) error {
-	// Parse the request body into a map
+	return UnmarshalWithNull(ctx.Body(), out)
+}
+
+// UnmarshalWithNull converts empty strings to null and parses the JSON object data into the provided struct.
+func UnmarshalWithNull(data []byte, out interface{}) error {
+	// Parse the data into a map
 	var body map[string]interface{}
-	if err := json.Unmarshal(ctx.Body(), &body); err != nil {
+	if err := json.Unmarshal(data, &body); err != nil {
 		return err
 	}
 
@@ -337,7 +366,6 @@ func BodyParserWithNull(ctx *fiber.Ctx, out interface{}) error {
 	return nil
 }
 
-// convertEmptyStringsToNull uses reflection to convert empty strings to null in struct fields.
 func convertEmptyStringsToNull(out interface{}) {
 	v := reflect.ValueOf(out).Elem()
 	for i := 0; i < v.NumField(); i++ {
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrVersionConflict is returned when a write expects another version of
+	// the record than the stored one.
+	ErrVersionConflict = errors.New("version conflict")
+
+	// ErrInvalidIfMatch is returned when If-Match is not a single ETag
+	// produced by ETag.
+	ErrInvalidIfMatch = errors.New("invalid If-Match")
+)
+
+// ETag renders the version of a record as a strong entity tag.
+func ETag(version uint) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ParseIfMatch reads the version from an If-Match header. An empty header or
+// `*` returns nil, meaning any version.
+func ParseIfMatch(header string) (*uint, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	tag := strings.TrimPrefix(header, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIfMatch, header)
+	}
+
+	version, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIfMatch, header)
+	}
+
+	v := uint(version)
+	return &v, nil
+}
@@ -0,0 +1,69 @@
+package form_requests
+
+import (
+	"fmt"
+
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/thedevsaddam/govalidator"
+)
+
+// addressFieldRules adds the rules of the structured fields of an address to
+// rules: once any of them is given, the first line, the city and the country
+// are required, and the postal code has to be one of the country.
+func addressFieldRules(rules govalidator.MapData, f dtos.AddressFields) govalidator.MapData {
+	structured := f.AddressLine1 != "" || f.AddressLine2 != "" || f.City != "" || f.Region != "" || f.PostalCode != "" || f.CountryCode != ""
+
+	for field, fieldRules := range map[string][]string{
+		"address_line1": {"max:255"},
+		"address_line2": {"max:255"},
+		"city":          {"max:100"},
+		"region":        {"max:100"},
+		"postal_code":   {fmt.Sprintf("postal_code:%s", f.CountryCode)},
+		"country_code":  {"country_code"},
+	} {
+		if structured && (field == "address_line1" || field == "city" || field == "country_code") {
+			fieldRules = append([]string{"required"}, fieldRules...)
+		}
+		rules[field] = fieldRules
+	}
+	return rules
+}
+
+// coordinateErrors checks the latitude and longitude of an address, which
+// govalidator does not see: they are pointers, 0 being a coordinate. They go
+// together.
+func coordinateErrors(f dtos.AddressFields) map[string]string {
+	errors := make(map[string]string)
+	switch {
+	case f.Latitude == nil && f.Longitude != nil:
+		errors["latitude"] = "The latitude field is required with the longitude"
+	case f.Latitude != nil && f.Longitude == nil:
+		errors["longitude"] = "The longitude field is required with the latitude"
+	}
+	if f.Latitude != nil && (*f.Latitude < -90 || *f.Latitude > 90) {
+		errors["latitude"] = "The latitude field must be between -90 and 90"
+	}
+	if f.Longitude != nil && (*f.Longitude < -180 || *f.Longitude > 180) {
+		errors["longitude"] = "The longitude field must be between -180 and 180"
+	}
+	return errors
+}
+
+// withCoordinateErrors adds the coordinate errors of each item of a batch to
+// the errors govalidator found.
+func withCoordinateErrors(failed map[int]map[string]string, n int, fields func(i int) dtos.AddressFields) map[int]map[string]string {
+	for i := 0; i < n; i++ {
+		for field, message := range coordinateErrors(fields(i)) {
+			if failed == nil {
+				failed = make(map[int]map[string]string)
+			}
+			if failed[i] == nil {
+				failed[i] = make(map[string]string)
+			}
+			if _, ok := failed[i][field]; !ok {
+				failed[i][field] = message
+			}
+		}
+	}
+	return failed
+}
@@ -20,12 +20,7 @@ func NewAddressStoreRequest() *AddressStoreRequest {
 
 // Validate validates the RegisterRequest.
 func (r *AddressStoreRequest) Validate(req *dtos.CreateAddressRequest, ctx context.Context) map[string]string {
-	rules := govalidator.MapData{
-		"type_address_id": []string{"required", "exists:mix_values,id"},
-		"user_id":         []string{"required", "exists:users,id"},
-		"ref_num":         []string{"required"},
-		"status":          []string{"required"},
-	}
+	rules := r.rules(req)
 
 	opts := govalidator.Options{
 		Data:  req,
@@ -35,13 +30,31 @@ func (r *AddressStoreRequest) Validate(req *dtos.CreateAddressRequest, ctx conte
 	v := govalidator.New(opts)
 	mappedErrors := v.ValidateStruct()
 
-	if len(mappedErrors) == 0 {
-		return nil
-	}
-
-	errors := make(map[string]string)
+	errors := coordinateErrors(req.AddressFields)
 	for field, err := range mappedErrors {
 		errors[field] = err[0]
 	}
+
+	if len(errors) == 0 {
+		return nil
+	}
 	return errors
 }
+
+// ValidateBatch validates the items of a bulk request, the database rules
+// checked for all of them at once. It returns the errors by item index.
+func (r *AddressStoreRequest) ValidateBatch(reqs []dtos.CreateAddressRequest, ctx context.Context) map[int]map[string]string {
+	failed := validateBatch(ctx, len(reqs), func(i int) (interface{}, govalidator.MapData, govalidator.MapData) {
+		return &reqs[i], r.rules(&reqs[i]), nil
+	})
+	return withCoordinateErrors(failed, len(reqs), func(i int) dtos.AddressFields { return reqs[i].AddressFields })
+}
+
+func (r *AddressStoreRequest) rules(req *dtos.CreateAddressRequest) govalidator.MapData {
+	return addressFieldRules(govalidator.MapData{
+		"type_address_id": []string{"required", "exists:mix_values,id"},
+		"user_id":         []string{"required", "exists:users,id"},
+		"ref_num":         []string{"required"},
+		"status":          []string{"required"},
+	}, req.AddressFields)
+}
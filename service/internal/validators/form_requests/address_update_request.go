@@ -21,12 +21,7 @@ func NewAddressUpdateRequest() *AddressUpdateRequest {
 
 // Validate validates the RegisterRequest.
 func (r *AddressUpdateRequest) Validate(req *dtos.UpdateAddressRequest, ctx context.Context) map[string]string {
-	rules := govalidator.MapData{
-		"type_address_id": []string{"exists:mix_values,id"},
-		"user_id":         []string{"required", "exists:users,id"},
-		"ref_num":         []string{"required", fmt.Sprintf("unique_ig:addresses,id,%d", req.ID)},
-		"status":          []string{"required"},
-	}
+	rules := r.rules(req)
 
 	opts := govalidator.Options{
 		Data:  req,
@@ -36,13 +31,31 @@ func (r *AddressUpdateRequest) Validate(req *dtos.UpdateAddressRequest, ctx cont
 	v := govalidator.New(opts)
 	mappedErrors := v.ValidateStruct()
 
-	if len(mappedErrors) == 0 {
-		return nil
-	}
-
-	errors := make(map[string]string)
+	errors := coordinateErrors(req.AddressFields)
 	for field, err := range mappedErrors {
 		errors[field] = err[0]
 	}
+
+	if len(errors) == 0 {
+		return nil
+	}
 	return errors
 }
+
+// ValidateBatch validates the items of a bulk request, the database rules
+// checked for all of them at once. It returns the errors by item index.
+func (r *AddressUpdateRequest) ValidateBatch(reqs []dtos.UpdateAddressRequest, ctx context.Context) map[int]map[string]string {
+	failed := validateBatch(ctx, len(reqs), func(i int) (interface{}, govalidator.MapData, govalidator.MapData) {
+		return &reqs[i], r.rules(&reqs[i]), nil
+	})
+	return withCoordinateErrors(failed, len(reqs), func(i int) dtos.AddressFields { return reqs[i].AddressFields })
+}
+
+func (r *AddressUpdateRequest) rules(req *dtos.UpdateAddressRequest) govalidator.MapData {
+	return addressFieldRules(govalidator.MapData{
+		"type_address_id": []string{"exists:mix_values,id"},
+		"user_id":         []string{"required", "exists:users,id"},
+		"ref_num":         []string{"required", fmt.Sprintf("unique_ig:addresses,id,%d", req.ID)},
+		"status":          []string{"required"},
+	}, req.AddressFields)
+}
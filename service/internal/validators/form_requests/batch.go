@@ -0,0 +1,205 @@
+package form_requests
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/nibroos/nb-go-api/service/internal/validators"
+	"github.com/thedevsaddam/govalidator"
+)
+
+// batchLookup is a unique, unique_ig or exists rule lifted out of the
+// validation of each item so that it runs once for a whole batch.
+type batchLookup struct {
+	exists bool
+	table  string
+	column string
+}
+
+// batchValue is a value of a batch checked by a batchLookup.
+type batchValue struct {
+	index     int
+	field     string
+	value     interface{}
+	ignoredID string // the row the value may belong to, for unique_ig
+}
+
+// parseBatchRule reads a unique, unique_ig or exists rule.
+func parseBatchRule(rule string) (batchLookup, string, bool) {
+	name, params, _ := strings.Cut(rule, ":")
+	args := strings.Split(params, ",")
+
+	switch {
+	case name == "unique" && len(args) == 2:
+		return batchLookup{table: args[0], column: args[1]}, "", true
+	case name == "unique_ig" && len(args) == 3:
+		return batchLookup{table: args[0], column: args[1]}, args[2], true
+	case name == "exists" && len(args) == 2:
+		return batchLookup{exists: true, table: args[0], column: args[1]}, "", true
+	}
+	return batchLookup{}, "", false
+}
+
+// validateBatch validates n items like their single-item form request would,
+// with the rules request returns for each. The database rules are collected
+// and checked with one query per table and column, and a unique value given
+// twice in the batch fails on its second occurrence. It returns the errors by
+// item index, nil when every item is valid.
+func validateBatch(ctx context.Context, n int, request func(i int) (data interface{}, rules govalidator.MapData, messages govalidator.MapData)) map[int]map[string]string {
+	failed := make(map[int]map[string]string)
+	addError := func(index int, field string, message string) {
+		if failed[index] == nil {
+			failed[index] = make(map[string]string)
+		}
+		if _, ok := failed[index][field]; !ok {
+			failed[index][field] = message
+		}
+	}
+
+	lookups := make(map[batchLookup][]batchValue)
+	var order []batchLookup
+
+	for i := 0; i < n; i++ {
+		data, rules, messages := request(i)
+
+		itemRules := govalidator.MapData{}
+		for field, fieldRules := range rules {
+			for _, rule := range fieldRules {
+				lookup, ignoredID, ok := parseBatchRule(rule)
+				if !ok {
+					itemRules[field] = append(itemRules[field], rule)
+					continue
+				}
+
+				value, ok := fieldValue(data, field)
+				if !ok {
+					continue
+				}
+				if _, seen := lookups[lookup]; !seen {
+					order = append(order, lookup)
+				}
+				lookups[lookup] = append(lookups[lookup], batchValue{index: i, field: field, value: value, ignoredID: ignoredID})
+			}
+		}
+
+		v := govalidator.New(govalidator.Options{
+			Data:     data,
+			Rules:    itemRules,
+			Messages: messages,
+		})
+		for field, err := range v.ValidateStruct() {
+			addError(i, field, err[0])
+		}
+	}
+
+	for _, lookup := range order {
+		for _, check := range runLookup(ctx, lookup, lookups[lookup]) {
+			addError(check.index, check.field, check.message)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	return failed
+}
+
+type batchFailure struct {
+	index   int
+	field   string
+	message string
+}
+
+// runLookup checks values against lookup. When the batched query fails, as
+// it does when a value does not fit the column, the values are looked up one
+// by one so that only the offending ones fail.
+func runLookup(ctx context.Context, lookup batchLookup, values []batchValue) []batchFailure {
+	args := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		args = append(args, v.value)
+	}
+
+	existing, err := validators.ExistingValues(ctx, lookup.table, lookup.column, args)
+	if err != nil {
+		var failures []batchFailure
+		for _, v := range values {
+			failures = append(failures, runLookupValue(ctx, lookup, v, values)...)
+		}
+		return failures
+	}
+
+	var failures []batchFailure
+	for _, v := range values {
+		if message, failed := checkValue(lookup, v, existing, values); failed {
+			failures = append(failures, batchFailure{index: v.index, field: v.field, message: message})
+		}
+	}
+	return failures
+}
+
+func runLookupValue(ctx context.Context, lookup batchLookup, v batchValue, values []batchValue) []batchFailure {
+	existing, err := validators.ExistingValues(ctx, lookup.table, lookup.column, []interface{}{v.value})
+	if err != nil {
+		return []batchFailure{{index: v.index, field: v.field, message: fmt.Sprintf("database error: %v", err)}}
+	}
+	if message, failed := checkValue(lookup, v, existing, values); failed {
+		return []batchFailure{{index: v.index, field: v.field, message: message}}
+	}
+	return nil
+}
+
+// checkValue applies lookup to v given the rows holding the values of the
+// batch, with the messages of the single-item rules.
+func checkValue(lookup batchLookup, v batchValue, existing map[string][]string, values []batchValue) (string, bool) {
+	key := fmt.Sprint(v.value)
+	if lookup.exists {
+		if len(existing[key]) == 0 {
+			return fmt.Sprintf("the %s does not exist", v.field), true
+		}
+		return "", false
+	}
+
+	for _, id := range existing[key] {
+		if id != v.ignoredID {
+			return fmt.Sprintf("the %s has already been taken", v.field), true
+		}
+	}
+
+	for _, other := range values {
+		if other.index >= v.index {
+			break
+		}
+		if fmt.Sprint(other.value) == key && (other.ignoredID == "" || other.ignoredID != v.ignoredID) {
+			return fmt.Sprintf("the %s is given more than once in the batch", v.field), true
+		}
+	}
+	return "", false
+}
+
+// fieldValue returns the value of the field of data tagged json:"name", false
+// when it is missing or empty: empty fields are left to the required rule.
+func fieldValue(data interface{}, name string) (interface{}, bool) {
+	v := reflect.Indirect(reflect.ValueOf(data))
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag != name {
+			continue
+		}
+
+		field := v.Field(i)
+		for field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				return nil, false
+			}
+			field = field.Elem()
+		}
+		if field.IsZero() {
+			return nil, false
+		}
+		return field.Interface(), true
+	}
+	return nil, false
+}
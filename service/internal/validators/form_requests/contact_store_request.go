@@ -20,12 +20,7 @@ func NewContactStoreRequest() *ContactStoreRequest {
 
 // Validate validates the RegisterRequest.
 func (r *ContactStoreRequest) Validate(req *dtos.CreateContactRequest, ctx context.Context) map[string]string {
-	rules := govalidator.MapData{
-		"type_contact_id": []string{"required", "exists:mix_values,id"},
-		"user_id":         []string{"required", "exists:users,id"},
-		"ref_num":         []string{"required"},
-		"status":          []string{"required"},
-	}
+	rules := r.rules(req)
 
 	opts := govalidator.Options{
 		Data:  req,
@@ -45,3 +40,20 @@ func (r *ContactStoreRequest) Validate(req *dtos.CreateContactRequest, ctx conte
 	}
 	return errors
 }
+
+// ValidateBatch validates the items of a bulk request, the database rules
+// checked for all of them at once. It returns the errors by item index.
+func (r *ContactStoreRequest) ValidateBatch(reqs []dtos.CreateContactRequest, ctx context.Context) map[int]map[string]string {
+	return validateBatch(ctx, len(reqs), func(i int) (interface{}, govalidator.MapData, govalidator.MapData) {
+		return &reqs[i], r.rules(&reqs[i]), nil
+	})
+}
+
+func (r *ContactStoreRequest) rules(req *dtos.CreateContactRequest) govalidator.MapData {
+	return govalidator.MapData{
+		"type_contact_id": []string{"required", "exists:mix_values,id"},
+		"user_id":         []string{"required", "exists:users,id"},
+		"ref_num":         []string{"required"},
+		"status":          []string{"required"},
+	}
+}
@@ -2,7 +2,6 @@ package form_requests
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/nibroos/nb-go-api/service/internal/dtos"
 	"github.com/thedevsaddam/govalidator"
@@ -21,12 +20,7 @@ func NewContactUpdateRequest() *ContactUpdateRequest {
 
 // Validate validates the RegisterRequest.
 func (r *ContactUpdateRequest) Validate(req *dtos.UpdateContactRequest, ctx context.Context) map[string]string {
-	rules := govalidator.MapData{
-		"type_contact_id": []string{"exists:mix_values,id"},
-		"user_id":         []string{"required", "exists:users,id"},
-		"ref_num":         []string{"required", fmt.Sprintf("unique_ig:contacts,id,%d", req.ID)},
-		"status":          []string{"required"},
-	}
+	rules := r.rules(req)
 
 	opts := govalidator.Options{
 		Data:  req,
@@ -46,3 +40,20 @@ func (r *ContactUpdateRequest) Validate(req *dtos.UpdateContactRequest, ctx cont
 	}
 	return errors
 }
+
+// ValidateBatch validates the items of a bulk request, the database rules
+// checked for all of them at once. It returns the errors by item index.
+func (r *ContactUpdateRequest) ValidateBatch(reqs []dtos.UpdateContactRequest, ctx context.Context) map[int]map[string]string {
+	return validateBatch(ctx, len(reqs), func(i int) (interface{}, govalidator.MapData, govalidator.MapData) {
+		return &reqs[i], r.rules(&reqs[i]), nil
+	})
+}
+
+func (r *ContactUpdateRequest) rules(req *dtos.UpdateContactRequest) govalidator.MapData {
+	return govalidator.MapData{
+		"type_contact_id": []string{"exists:mix_values,id"},
+		"user_id":         []string{"required", "exists:users,id"},
+		"ref_num":         []string{"required"}, // its format and uniqueness are checked by ContactService.CheckRefNums
+		"status":          []string{"required"},
+	}
+}
@@ -0,0 +1,49 @@
+package form_requests
+
+import (
+	"time"
+
+	"github.com/nibroos/nb-go-api/service/internal/dtos"
+)
+
+// validityErrors checks the dates of an identifier, which govalidator does
+// not see as they are pointers: each is a YYYY-MM-DD date, and the expiry date
+// comes after the issue date.
+func validityErrors(v dtos.IdentifierValidity) map[string]string {
+	errors := make(map[string]string)
+	var issuedAt, expiresAt time.Time
+	var err error
+	if v.IssuedAt != nil {
+		if issuedAt, err = time.Parse("2006-01-02", *v.IssuedAt); err != nil {
+			errors["issued_at"] = "The issued_at field must be a date as yyyy-mm-dd"
+		}
+	}
+	if v.ExpiresAt != nil {
+		if expiresAt, err = time.Parse("2006-01-02", *v.ExpiresAt); err != nil {
+			errors["expires_at"] = "The expires_at field must be a date as yyyy-mm-dd"
+		}
+	}
+	if len(errors) == 0 && v.IssuedAt != nil && v.ExpiresAt != nil && !expiresAt.After(issuedAt) {
+		errors["expires_at"] = "The expires_at field must be a date after issued_at"
+	}
+	return errors
+}
+
+// withValidityErrors adds the validity errors of each item of a batch to the
+// errors govalidator found.
+func withValidityErrors(failed map[int]map[string]string, n int, validity func(i int) dtos.IdentifierValidity) map[int]map[string]string {
+	for i := 0; i < n; i++ {
+		for field, message := range validityErrors(validity(i)) {
+			if failed == nil {
+				failed = make(map[int]map[string]string)
+			}
+			if failed[i] == nil {
+				failed[i] = make(map[string]string)
+			}
+			if _, ok := failed[i][field]; !ok {
+				failed[i][field] = message
+			}
+		}
+	}
+	return failed
+}
@@ -21,12 +21,7 @@ func NewIdentifierUpdateRequest() *IdentifierUpdateRequest {
 
 // Validate validates the RegisterRequest.
 func (r *IdentifierUpdateRequest) Validate(req *dtos.UpdateIdentifierRequest, ctx context.Context) map[string]string {
-	rules := govalidator.MapData{
-		"type_identifier_id": []string{"exists:mix_values,id"},
-		"user_id":            []string{"required", "exists:users,id"},
-		"ref_num":            []string{"required", fmt.Sprintf("unique_ig:identifiers,id,%d", req.ID)},
-		"status":             []string{"required"},
-	}
+	rules := r.rules(req)
 
 	opts := govalidator.Options{
 		Data:  req,
@@ -36,13 +31,30 @@ func (r *IdentifierUpdateRequest) Validate(req *dtos.UpdateIdentifierRequest, ct
 	v := govalidator.New(opts)
 	mappedErrors := v.ValidateStruct()
 
-	if len(mappedErrors) == 0 {
-		return nil
-	}
-
-	errors := make(map[string]string)
+	errors := validityErrors(req.IdentifierValidity)
 	for field, err := range mappedErrors {
 		errors[field] = err[0]
 	}
+
+	if len(errors) == 0 {
+		return nil
+	}
 	return errors
 }
+
+// ValidateBatch validates the items of a bulk request, the database rules
+// checked for all of them at once. It returns the errors by item index.
+func (r *IdentifierUpdateRequest) ValidateBatch(reqs []dtos.UpdateIdentifierRequest, ctx context.Context) map[int]map[string]string {
+	failed := validateBatch(ctx, len(reqs), func(i int) (interface{}, govalidator.MapData, govalidator.MapData) {
+		return &reqs[i], r.rules(&reqs[i]), nil
+	})
+	return withValidityErrors(failed, len(reqs), func(i int) dtos.IdentifierValidity { return reqs[i].IdentifierValidity })
+}
+
+func (r *IdentifierUpdateRequest) rules(req *dtos.UpdateIdentifierRequest) govalidator.MapData {
+	return govalidator.MapData{
+		"type_identifier_id": []string{"exists:mix_values,id"},
+		"user_id":            []string{"required", "exists:users,id"},
+		"ref_num":            []string{"required", fmt.Sprintf("unique_ig:identifiers,id,%d", req.ID)},
+	}
+}
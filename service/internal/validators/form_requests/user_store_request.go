@@ -20,18 +20,9 @@ func NewUserStoreRequest() *UserStoreRequest {
 
 // Validate validates the RegisterRequest.
 func (r *UserStoreRequest) Validate(req *dtos.CreateUserRequest, ctx context.Context) map[string]string {
-	// utils.DD(req)
-	rules := govalidator.MapData{
-		"name":     []string{"required", "min:3"},
-		"username": []string{"unique:users,username"},
-		"email":    []string{"required", "email", "unique:users,email"},
-		"password": []string{"required", "min:4"},
-		"role_ids": []string{"required"},
-	}
+	rules := r.rules(req)
 
-	messages := govalidator.MapData{
-		"role_ids": []string{"required:The roles field is required."},
-	}
+	messages := r.messages()
 
 	opts := govalidator.Options{
 		Data:     req,
@@ -52,3 +43,27 @@ func (r *UserStoreRequest) Validate(req *dtos.CreateUserRequest, ctx context.Con
 	}
 	return errors
 }
+
+// ValidateBatch validates the items of a bulk request, the database rules
+// checked for all of them at once. It returns the errors by item index.
+func (r *UserStoreRequest) ValidateBatch(reqs []dtos.CreateUserRequest, ctx context.Context) map[int]map[string]string {
+	return validateBatch(ctx, len(reqs), func(i int) (interface{}, govalidator.MapData, govalidator.MapData) {
+		return &reqs[i], r.rules(&reqs[i]), r.messages()
+	})
+}
+
+func (r *UserStoreRequest) rules(req *dtos.CreateUserRequest) govalidator.MapData {
+	return govalidator.MapData{
+		"name":     []string{"required", "min:3"},
+		"username": []string{"unique:users,username"},
+		"email":    []string{"required", "email", "unique:users,email"},
+		"password": []string{"required", "min:4"},
+		"role_ids": []string{"required"},
+	}
+}
+
+func (r *UserStoreRequest) messages() govalidator.MapData {
+	return govalidator.MapData{
+		"role_ids": []string{"required:The roles field is required."},
+	}
+}
@@ -21,17 +21,9 @@ func NewUserdUpdateRequest() *UserdUpdateRequest {
 
 // Validate validates the RegisterRequest.
 func (r *UserdUpdateRequest) Validate(req *dtos.UpdateUserRequest, ctx context.Context) map[string]string {
-	// utils.DD(req)
-	rules := govalidator.MapData{
-		"name":     []string{"required", "min:3"},
-		"username": []string{fmt.Sprintf("unique_ig:users,username,%d", req.ID)},
-		"email":    []string{"required", "email", fmt.Sprintf("unique_ig:users,email,%d", req.ID)},
-		"role_ids": []string{"required"},
-	}
+	rules := r.rules(req)
 
-	messages := govalidator.MapData{
-		"role_ids": []string{"required:The roles field is required."},
-	}
+	messages := r.messages()
 
 	opts := govalidator.Options{
 		Data:     req,
@@ -52,3 +44,26 @@ func (r *UserdUpdateRequest) Validate(req *dtos.UpdateUserRequest, ctx context.C
 	}
 	return errors
 }
+
+// ValidateBatch validates the items of a bulk request, the database rules
+// checked for all of them at once. It returns the errors by item index.
+func (r *UserdUpdateRequest) ValidateBatch(reqs []dtos.UpdateUserRequest, ctx context.Context) map[int]map[string]string {
+	return validateBatch(ctx, len(reqs), func(i int) (interface{}, govalidator.MapData, govalidator.MapData) {
+		return &reqs[i], r.rules(&reqs[i]), r.messages()
+	})
+}
+
+func (r *UserdUpdateRequest) rules(req *dtos.UpdateUserRequest) govalidator.MapData {
+	return govalidator.MapData{
+		"name":     []string{"required", "min:3"},
+		"username": []string{fmt.Sprintf("unique_ig:users,username,%d", req.ID)},
+		"email":    []string{"required", "email", fmt.Sprintf("unique_ig:users,email,%d", req.ID)},
+		"role_ids": []string{"required"},
+	}
+}
+
+func (r *UserdUpdateRequest) messages() govalidator.MapData {
+	return govalidator.MapData{
+		"role_ids": []string{"required:The roles field is required."},
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/jmoiron/sqlx"
 	"github.com/nibroos/nb-go-api/service/internal/dtos"
+	"github.com/nibroos/nb-go-api/service/internal/utils"
 	"github.com/thedevsaddam/govalidator"
 )
 
@@ -29,6 +30,8 @@ func InitValidator(database *sqlx.DB) {
 	govalidator.AddCustomRule("array", arrayRule)
 	govalidator.AddCustomRule("array_max", arrayMaxRule)
 	govalidator.AddCustomRule("exists", isExistsRule)
+	govalidator.AddCustomRule("country_code", countryCodeRule)
+	govalidator.AddCustomRule("postal_code", postalCodeRule)
 }
 
 // uniqueValidator checks if a field value is unique in the database.
@@ -268,4 +271,61 @@ func isExistsRule(field string, rule string, message string, value interface{})
 	return nil
 }
 
+// countryCodeRule checks that the value is an ISO 3166-1 alpha-2 country code.
+func countryCodeRule(field string, rule string, message string, value interface{}) error {
+	code, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("invalid value type")
+	}
+
+	if !utils.ValidCountryCode(code) {
+		return fmt.Errorf("the %s must be an ISO 3166-1 alpha-2 country code", field)
+	}
+
+	return nil
+}
+
+// postalCodeRule checks that the value is a postal code of the country given
+// as parameter (postal_code:GB), or looks like one without a country.
+func postalCodeRule(field string, rule string, message string, value interface{}) error {
+	code, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("invalid value type")
+	}
+
+	_, country, _ := strings.Cut(rule, ":")
+	if !utils.ValidPostalCode(country, code) {
+		if country != "" {
+			return fmt.Errorf("the %s is not a valid postal code of %s", field, country)
+		}
+		return fmt.Errorf("the %s is not a valid postal code", field)
+	}
+
+	return nil
+}
+
 // TODO make a function to validate mix_values group, 2 params, group and value
+
+// ExistingValues looks the values of column up in table in one query, the
+// unique, unique_ig and exists rules run for a whole batch. It returns the IDs
+// of the rows holding each value found, keyed by the value as text.
+func ExistingValues(ctx context.Context, table string, column string, values []interface{}) (map[string][]string, error) {
+	query, args, err := sqlx.In(fmt.Sprintf("SELECT %s::text AS value, id::text AS id FROM %s WHERE %s IN (?)", column, table, column), values)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Value string `db:"value"`
+		ID    string `db:"id"`
+	}
+	if err := db.SelectContext(ctx, &rows, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string][]string, len(rows))
+	for _, row := range rows {
+		existing[row.Value] = append(existing[row.Value], row.ID)
+	}
+	return existing, nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -79,9 +80,12 @@ func main() {
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
 		ErrorHandler: middleware.ErrorHandler,
+		// room for an attachment or an avatar and the rest of its multipart form
+		BodyLimit: max(fiber.DefaultBodyLimit, int(max(config.GetAttachmentMaxBytes(), config.GetAvatarMaxBytes()))+1<<20),
 	})
 
 	// Attach middleware
+	app.Use(requestid.New())
 	app.Use(middleware.ConvertEmptyStringsToNull())
 	app.Use(middleware.ConvertRequestToFilters())
 
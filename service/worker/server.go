@@ -1,28 +1,75 @@
 package main
 
 import (
-	"fmt"
 	"log"
-	"os"
-	"strconv"
+	"time"
 
 	"github.com/hibiken/asynq"
+	"github.com/jmoiron/sqlx"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+	"github.com/nibroos/nb-go-api/service/internal/config"
+	"github.com/nibroos/nb-go-api/service/internal/repository"
+	"github.com/nibroos/nb-go-api/service/internal/service"
+	"github.com/nibroos/nb-go-api/service/internal/storage"
 	"github.com/nibroos/nb-go-api/service/internal/tasks"
+	"github.com/nibroos/nb-go-api/service/internal/validators"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
 )
 
 func main() {
+	// Load environment variables from .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
 	// Create and configure Redis connection.
-	redisConnection := asynq.RedisClientOpt{
-		Addr:     fmt.Sprintf("%s:%s", os.Getenv("REDIS_HOST"), os.Getenv("REDIS_PORT")),
-		Password: os.Getenv("REDIS_PASSWORD"),
-		DB: func() int {
-			db, err := strconv.Atoi(os.Getenv("REDIS_DB"))
-			if err != nil {
-				log.Fatalf("Invalid REDIS_DB value: %v", err)
-			}
-			return db
-		}(),
+	redisConnection := config.GetAsynqRedisOpt()
+
+	// The import, export and avatar tasks go through the services, like the REST server.
+	dbURL := config.GetDatabaseURL()
+	sqlDB, err := sqlx.Connect("postgres", dbURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to the SQL database: %v", err)
 	}
+	sqlDB.SetMaxOpenConns(20)
+	sqlDB.SetMaxIdleConns(5)
+	sqlDB.SetConnMaxLifetime(time.Hour)
+
+	gormDB, err := gorm.Open(postgres.Open(dbURL), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("Failed to connect to the Gorm database: %v", err)
+	}
+
+	validators.InitValidator(sqlDB)
+
+	importService := service.NewImportService(
+		repository.NewImportRepository(gormDB, sqlDB),
+		service.NewUserService(repository.NewUserRepository(gormDB, sqlDB)),
+		service.NewContactService(repository.NewContactRepository(gormDB, sqlDB)),
+		nil, // the worker does not queue imports
+	)
+
+	exportService := service.NewExportService(
+		repository.NewExportRepository(gormDB, sqlDB),
+		repository.NewUserRepository(gormDB, sqlDB),
+		repository.NewContactRepository(gormDB, sqlDB),
+		repository.NewAddressRepository(gormDB, sqlDB),
+		repository.NewIdentifierRepository(gormDB, sqlDB),
+		nil, // the worker does not queue exports
+	)
+
+	store, err := storage.New()
+	if err != nil {
+		log.Fatalf("Failed to set up the storage: %v", err)
+	}
+	avatarService := service.NewAvatarService(
+		repository.NewAvatarRepository(gormDB, sqlDB),
+		service.NewFileService(repository.NewFileRepository(gormDB, sqlDB), store),
+		store,
+		nil, // the worker does not queue thumbnails
+	)
 
 	// Create and configure Asynq worker server.
 	worker := asynq.NewServer(redisConnection, asynq.Config{
@@ -51,6 +98,30 @@ func main() {
 		tasks.HandleReminderEmailTask, // handler function
 	)
 
+	// Define a task handler for the identifier expiry reminder task.
+	mux.HandleFunc(
+		tasks.TypeIdentifierExpiryReminder,       // task type
+		tasks.HandleIdentifierExpiryReminderTask, // handler function
+	)
+
+	// Define a task handler for the import commit task.
+	mux.HandleFunc(
+		tasks.TypeImportCommit,               // task type
+		importService.HandleImportCommitTask, // handler function
+	)
+
+	// Define a task handler for the export run task.
+	mux.HandleFunc(
+		tasks.TypeExportRun,               // task type
+		exportService.HandleExportRunTask, // handler function
+	)
+
+	// Define a task handler for the avatar thumbnails task.
+	mux.HandleFunc(
+		tasks.TypeAvatarThumbnails,               // task type
+		avatarService.HandleAvatarThumbnailsTask, // handler function
+	)
+
 	// Run worker server.
 	if err := worker.Run(mux); err != nil {
 		log.Fatal(err)